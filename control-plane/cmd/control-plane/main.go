@@ -68,9 +68,23 @@ func main() {
 		}()
 	}
 
-	allowAll := policy.StaticRulesetResolver{RulesetText: "package policy\n default allow = true"}
-	evaluator := &policy.OPAEvaluator{Resolver: allowAll}
-	enforcer := orchestration.PolicyEnforcer{Evaluator: evaluator}
+	policyStore := policy.StorageStore{Store: stores.PolicyStore}
+	evaluator := &policy.OPAEvaluator{Resolver: policy.StoreRulesetResolver{Store: policyStore}}
+	auditStore := audit.StorageAdapter{Store: stores.AuditStore}
+	decisions := policy.StorageDecisionLog{Store: stores.DecisionStore}
+	inputs := policy.StorageInputStore{Store: stores.InputStore, Retention: cfg.PolicyInputRetention}
+	policyService := policy.Service{
+		Store:     policyStore,
+		Evaluator: evaluator,
+		Logger:    audit.StoreLogger{Store: auditStore},
+		Inputs:    inputs,
+		Decisions: decisions,
+	}
+	enforcer := orchestration.PolicyEnforcer{Evaluator: policy.RecordingEvaluator{
+		Evaluator: evaluator,
+		Decisions: decisions,
+		Inputs:    inputs,
+	}}
 	dataPlaneClient := client.DataPlaneClient{BaseURL: cfg.DataPlaneURL}
 
 	jobService := orchestration.JobService{
@@ -85,18 +99,28 @@ func main() {
 		Logger:   audit.StdoutLogger{},
 	}
 	stepper := sessions.StepService{
-		Runner: sessions.DataPlaneStepRunner{Client: dataPlaneClient},
-		Store:  sessions.StorageStepStore{Store: stores.SessionStepStore},
-		Logger: audit.StdoutLogger{},
+		Runner:   sessions.DataPlaneStepRunner{Client: dataPlaneClient},
+		Store:    sessions.StorageStepStore{Store: stores.SessionStepStore},
+		Logger:   audit.StdoutLogger{},
+		Sessions: stores.SessionStore,
 	}
 
+	reconciler := orchestration.JobReconciler{Service: jobService, Client: dataPlaneClient}
+	go runPeriodically(context.Background(), cfg.JobReconcileInterval, "job reconcile", reconciler.Run)
+	ttlWorker := sessions.TTLWorker{
+		Store:   sessions.StorageSessionStore{Store: stores.SessionStore},
+		Cleanup: dataPlaneClient.TerminateSession,
+		Lock:    stores.Lock,
+	}
+	go runPeriodically(context.Background(), cfg.SessionTTLInterval, "session ttl", ttlWorker.Run)
+
 	deps := api.Dependencies{
 		JobService:     &jobService,
 		JobStore:       stores.JobStore,
 		SessionService: &sessionService,
 		Stepper:        &stepper,
-		PolicyStore:    policy.NewInMemoryStore(),
-		AuditStore:     &audit.InMemoryStore{},
+		PolicyService:  &policyService,
+		AuditStore:     auditStore,
 	}
 
 	if cfg.MCPAddr != "" {
@@ -120,6 +144,10 @@ func main() {
 	if telemetry.MetricsHandler != nil {
 		router.Handle("/metrics", telemetry.MetricsHandler)
 	}
+	router.Mount("/internal", api.InternalRouter(api.InternalDependencies{
+		JobService:    &jobService,
+		CallbackToken: cfg.JobCallbackToken,
+	}))
 	router.Mount("/", apiHandler)
 	if err := http.ListenAndServe(addr, router); err != nil {
 		log.Fatalf("server error: %v", err)
@@ -133,6 +161,21 @@ func getenv(key, fallback string) string {
 	return fallback
 }
 
+func runPeriodically(ctx context.Context, interval time.Duration, name string, fn func(context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := fn(ctx); err != nil {
+				log.Printf("%s error: %v", name, err)
+			}
+		}
+	}
+}
+
 type telemetryInit struct {
 	Shutdown       func(context.Context) error
 	MetricsHandler http.Handler
@@ -15,6 +15,7 @@ require (
 	go.opentelemetry.io/otel/sdk v1.28.0
 	go.opentelemetry.io/otel/sdk/metric v1.27.0
 	modernc.org/sqlite v1.28.0
+	shared v0.0.0
 )
 
 require (
@@ -72,3 +73,5 @@ require (
 	modernc.org/token v1.0.1 // indirect
 	sigs.k8s.io/yaml v1.4.0 // indirect
 )
+
+replace shared => ../shared
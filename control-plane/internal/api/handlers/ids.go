@@ -0,0 +1,15 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// newID returns prefix, a timestamp and a random suffix, so IDs stay
+// sortable but never collide or can be guessed.
+func newID(prefix string) string {
+	suffix := make([]byte, 8)
+	_, _ = rand.Read(suffix)
+	return prefix + "-" + time.Now().UTC().Format("20060102150405") + "-" + hex.EncodeToString(suffix)
+}
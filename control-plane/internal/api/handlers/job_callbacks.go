@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"control-plane/internal/orchestration"
+	"control-plane/internal/storage"
+	"control-plane/pkg/client"
+)
+
+type JobCallbackHandler struct {
+	Service orchestration.JobService
+}
+
+type jobCallbackRequest struct {
+	RunID         string               `json:"runId"`
+	Status        string               `json:"status"`
+	ExitStatus    int                  `json:"exitStatus"`
+	OutputRef     string               `json:"outputRef"`
+	ErrorRef      string               `json:"errorRef"`
+	ArtifactRefs  []string             `json:"artifactRefs"`
+	ResourceUsage client.ResourceUsage `json:"resourceUsage"`
+}
+
+func (h JobCallbackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	jobID := chi.URLParam(r, "jobId")
+	if jobID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var req jobCallbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("jobs: callback decode error: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	err := h.Service.CompleteJob(r.Context(), orchestration.JobResult{
+		JobID:        jobID,
+		RunID:        req.RunID,
+		Status:       orchestration.JobStatus(req.Status),
+		ExitStatus:   req.ExitStatus,
+		OutputRef:    req.OutputRef,
+		ErrorRef:     req.ErrorRef,
+		ArtifactRefs: req.ArtifactRefs,
+		Usage:        storage.ResourceUsage(req.ResourceUsage),
+	})
+	if err != nil {
+		log.Printf("jobs: callback error job_id=%s: %v", jobID, err)
+		if errors.Is(err, orchestration.ErrJobStatusNotTerminal) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+	log.Printf("jobs: completed job_id=%s run_id=%s status=%s exit_status=%d ts=%s", jobID, req.RunID, req.Status, req.ExitStatus, time.Now().UTC().Format(time.RFC3339))
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/go-chi/chi/v5"
 
+	"control-plane/internal/api/middleware"
 	"control-plane/internal/orchestration"
 	"control-plane/internal/storage"
 )
@@ -18,17 +19,76 @@ type JobHandler struct {
 }
 
 type jobRequest struct {
-	TenantID string `json:"tenantId"`
-	AgentID  string `json:"agentId"`
-	PolicyID string `json:"policyId"`
-	Language string `json:"language"`
-	Code     string `json:"code"`
+	TenantID       string   `json:"tenantId"`
+	AgentID        string   `json:"agentId"`
+	PolicyID       string   `json:"policyId"`
+	Language       string   `json:"language"`
+	Code           string   `json:"code"`
+	TimeoutSeconds int      `json:"timeoutSeconds"`
+	Deps           []string `json:"deps"`
 }
 
 type jobResponse struct {
-	ID         string `json:"id"`
-	Status     string `json:"status"`
-	ExitStatus int    `json:"exit_status,omitempty"`
+	ID            string                 `json:"id"`
+	Status        string                 `json:"status"`
+	TenantID      string                 `json:"tenant_id,omitempty"`
+	AgentID       string                 `json:"agent_id,omitempty"`
+	PolicyID      string                 `json:"policy_id,omitempty"`
+	Language      string                 `json:"language,omitempty"`
+	RunID         string                 `json:"run_id,omitempty"`
+	ExitStatus    int                    `json:"exit_status,omitempty"`
+	OutputRef     string                 `json:"output_ref,omitempty"`
+	ErrorRef      string                 `json:"error_ref,omitempty"`
+	ArtifactRefs  []string               `json:"artifact_refs,omitempty"`
+	CreatedAt     string                 `json:"created_at,omitempty"`
+	UpdatedAt     string                 `json:"updated_at,omitempty"`
+	FinishedAt    string                 `json:"finished_at,omitempty"`
+	ResourceUsage *resourceUsageResponse `json:"resource_usage,omitempty"`
+}
+
+type resourceUsageResponse struct {
+	CPUTimeMs   int64 `json:"cpu_time_ms"`
+	MaxRSSBytes int64 `json:"max_rss_bytes"`
+	IOBytes     int64 `json:"io_bytes"`
+}
+
+func newResourceUsageResponse(usage storage.ResourceUsage) *resourceUsageResponse {
+	return &resourceUsageResponse{
+		CPUTimeMs:   usage.CPUTimeMs,
+		MaxRSSBytes: usage.MaxRSSBytes,
+		IOBytes:     usage.IOBytes,
+	}
+}
+
+func newJobResponse(job storage.Job) jobResponse {
+	resp := jobResponse{
+		ID:           job.ID,
+		Status:       job.Status,
+		TenantID:     job.TenantID,
+		AgentID:      job.AgentID,
+		PolicyID:     job.PolicyID,
+		Language:     job.Language,
+		RunID:        job.RunID,
+		ExitStatus:   job.ExitStatus,
+		OutputRef:    job.OutputRef,
+		ErrorRef:     job.ErrorRef,
+		ArtifactRefs: job.ArtifactRefs,
+		CreatedAt:    formatTimestamp(job.CreatedAt),
+		UpdatedAt:    formatTimestamp(job.UpdatedAt),
+		FinishedAt:   formatTimestamp(job.FinishedAt),
+	}
+	// Usage is only known once the run has finished.
+	if !job.FinishedAt.IsZero() {
+		resp.ResourceUsage = newResourceUsageResponse(job.Usage)
+	}
+	return resp
+}
+
+func formatTimestamp(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
 }
 
 func (h JobHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -46,19 +106,37 @@ func (h JobHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
+	if req.TimeoutSeconds < 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if tenantID, ok := middleware.TenantID(r.Context()); ok {
+		if req.TenantID == "" {
+			req.TenantID = tenantID
+		}
+		if req.TenantID != tenantID {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+	}
 	job := orchestration.Job{
-		ID:        "job-" + time.Now().UTC().Format("20060102150405"),
-		TenantID:  req.TenantID,
-		AgentID:   req.AgentID,
-		PolicyID:  req.PolicyID,
-		Language:  req.Language,
-		Code:      req.Code,
-		Status:    orchestration.JobQueued,
+		ID:             newID("job"),
+		TenantID:       req.TenantID,
+		AgentID:        req.AgentID,
+		PolicyID:       req.PolicyID,
+		Language:       req.Language,
+		Code:           req.Code,
+		Status:         orchestration.JobQueued,
+		TimeoutSeconds: req.TimeoutSeconds,
+		Deps:           req.Deps,
 	}
 	job.Workspace = job.ID
 	_, err := h.Service.CreateJob(r.Context(), job)
 	if err != nil {
 		log.Printf("jobs: create error: %v", err)
+		if writePolicyDenied(w, err) {
+			return
+		}
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -83,6 +161,11 @@ func (h JobHandler) handleGet(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusNotFound)
 		return
 	}
+	if tenantID, ok := middleware.TenantID(r.Context()); ok && (tenantID == "" || tenantID != job.TenantID) {
+		// Report jobs owned by other tenants as missing so IDs cannot be probed.
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(jobResponse{ID: job.ID, Status: job.Status})
+	_ = json.NewEncoder(w).Encode(newJobResponse(job))
 }
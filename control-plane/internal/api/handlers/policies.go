@@ -2,14 +2,23 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/go-chi/chi/v5"
+
+	"control-plane/internal/api/middleware"
+	"control-plane/internal/orchestration"
 	"control-plane/internal/policy"
+	"shared/pkg/contracts"
 )
 
+// PolicyHandler serves the /policies routes, one method per route.
 type PolicyHandler struct {
-	Store policy.Store
+	Service policy.Service
 }
 
 type policyRequest struct {
@@ -19,22 +28,467 @@ type policyRequest struct {
 	Ruleset  string `json:"ruleset"`
 }
 
-func (h PolicyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+type policyRollbackRequest struct {
+	Version int `json:"version"`
+}
+
+// maxDryRunInputs bounds the inputs of one evaluate request.
+const maxDryRunInputs = 100
+
+type policyEvaluateRequest struct {
+	Ruleset string            `json:"ruleset"`
+	Inputs  []json.RawMessage `json:"inputs"`
+	Recent  int               `json:"recent"`
+}
+
+type policyEvaluateResponse struct {
+	Results []policyEvaluation `json:"results"`
+	Changed int                `json:"changed"`
+}
+
+type policyEvaluation struct {
+	Input    json.RawMessage         `json:"input"`
+	Decision policyDecisionResponse  `json:"decision"`
+	Active   *policyDecisionResponse `json:"active,omitempty"`
+	Changed  bool                    `json:"changed"`
+}
+
+type policyDecisionResponse struct {
+	Allowed              bool                  `json:"allowed"`
+	Reason               string                `json:"reason,omitempty"`
+	Error                string                `json:"error,omitempty"`
+	Limits               *policyLimitsResponse `json:"limits,omitempty"`
+	RuntimeClass         string                `json:"runtime_class,omitempty"`
+	EgressAllowlist      []string              `json:"egress_allowlist,omitempty"`
+	DepsAllowlist        []string              `json:"deps_allowlist,omitempty"`
+	MaxSessionTTLSeconds int64                 `json:"max_session_ttl_seconds,omitempty"`
+	MaxFileBytes         int64                 `json:"max_file_bytes,omitempty"`
+	Secrets              []string              `json:"secrets,omitempty"`
+}
+
+type policyLimitsResponse struct {
+	CPUMillicores int   `json:"cpu_millicores,omitempty"`
+	MemoryBytes   int64 `json:"memory_bytes,omitempty"`
+	DiskBytes     int64 `json:"disk_bytes,omitempty"`
+	PIDs          int   `json:"pids,omitempty"`
+}
+
+type policyCompileErrorResponse struct {
+	Code     string                 `json:"code"`
+	Message  string                 `json:"message"`
+	Problems []policyCompileProblem `json:"problems"`
+}
+
+type policyCompileProblem struct {
+	Row     int    `json:"row,omitempty"`
+	Col     int    `json:"col,omitempty"`
+	Message string `json:"message"`
+}
+
+func newPolicyDecisionResponse(decision policy.Decision, err error) policyDecisionResponse {
+	if err != nil {
+		return policyDecisionResponse{Error: err.Error()}
+	}
+	resp := policyDecisionResponse{
+		Allowed:              decision.Allowed,
+		Reason:               decision.Reason,
+		RuntimeClass:         decision.RuntimeClass,
+		EgressAllowlist:      decision.EgressAllowlist,
+		DepsAllowlist:        decision.DepsAllowlist,
+		MaxSessionTTLSeconds: int64(decision.MaxSessionTTL / time.Second),
+		MaxFileBytes:         decision.MaxFileBytes,
+		Secrets:              decision.Secrets,
+	}
+	if decision.Limits != (policy.Limits{}) {
+		resp.Limits = &policyLimitsResponse{
+			CPUMillicores: decision.Limits.CPUMillicores,
+			MemoryBytes:   decision.Limits.MemoryBytes,
+			DiskBytes:     decision.Limits.DiskBytes,
+			PIDs:          decision.Limits.PIDs,
+		}
+	}
+	return resp
+}
+
+type policyDecisionLogResponse struct {
+	WorkloadID    string  `json:"workload_id,omitempty"`
+	PolicyVersion int     `json:"policy_version"`
+	InputHash     string  `json:"input_hash"`
+	Allowed       bool    `json:"allowed"`
+	Reason        string  `json:"reason,omitempty"`
+	Error         string  `json:"error,omitempty"`
+	LatencyMs     float64 `json:"latency_ms"`
+	Time          string  `json:"time"`
+}
+
+type policyResponse struct {
+	TenantID  string `json:"tenant_id"`
+	Name      string `json:"name"`
+	Version   int    `json:"version"`
+	Ruleset   string `json:"ruleset"`
+	Deleted   bool   `json:"deleted,omitempty"`
+	CreatedAt string `json:"created_at,omitempty"`
+	CreatedBy string `json:"created_by,omitempty"`
+}
+
+func newPolicyResponse(stored policy.Policy) policyResponse {
+	tenantID, name := policy.SplitKey(stored.ID)
+	return policyResponse{
+		TenantID:  tenantID,
+		Name:      name,
+		Version:   stored.Version,
+		Ruleset:   stored.Ruleset,
+		Deleted:   stored.Deleted,
+		CreatedAt: formatTimestamp(stored.CreatedAt),
+		CreatedBy: stored.CreatedBy,
+	}
+}
+
+// ready answers 500 when the handler has no policy store.
+func (h PolicyHandler) ready(w http.ResponseWriter) bool {
+	if h.Service.Store == nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
+// Create stores a policy version from POST /policies.
+func (h PolicyHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if !h.ready(w) {
+		return
+	}
 	var req policyRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		log.Printf("policies: decode error: %v", err)
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	if req.TenantID == "" || req.Name == "" {
+	if tenantID, ok := middleware.TenantID(r.Context()); ok {
+		if req.TenantID == "" {
+			req.TenantID = tenantID
+		}
+		if req.TenantID != tenantID {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+	}
+	if req.TenantID == "" || req.Name == "" || req.Version < 0 {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	policyID := req.TenantID + ":" + req.Name
-	if err := h.Store.Upsert(r.Context(), policy.Policy{ID: policyID, Version: req.Version, Ruleset: req.Ruleset}); err != nil {
+	actor, _ := middleware.AgentID(r.Context())
+	stored, err := h.Service.Put(r.Context(), policy.Policy{
+		ID:        policy.Key(req.TenantID, req.Name),
+		Version:   req.Version,
+		Ruleset:   req.Ruleset,
+		CreatedBy: actor,
+	})
+	if err != nil {
 		log.Printf("policies: upsert error: %v", err)
+		if errors.Is(err, policy.ErrStalePolicyVersion) {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(newPolicyResponse(stored))
+}
+
+// List returns the current versions of the tenant's policies.
+func (h PolicyHandler) List(w http.ResponseWriter, r *http.Request) {
+	if !h.ready(w) {
+		return
+	}
+	tenantID, ok := policyTenant(w, r)
+	if !ok {
+		return
+	}
+	list, err := h.Service.List(r.Context(), tenantID)
+	if err != nil {
+		log.Printf("policies: list error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	resp := make([]policyResponse, 0, len(list))
+	for _, stored := range list {
+		resp = append(resp, newPolicyResponse(stored))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// Get returns the current version, or with ?at= the version that was
+// in force at that time.
+func (h PolicyHandler) Get(w http.ResponseWriter, r *http.Request) {
+	if !h.ready(w) {
+		return
+	}
+	id, ok := policyKey(w, r)
+	if !ok {
+		return
+	}
+	var stored policy.Policy
+	var err error
+	if raw := r.URL.Query().Get("at"); raw != "" {
+		at, parseErr := time.Parse(time.RFC3339, raw)
+		if parseErr != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		stored, err = h.Service.At(r.Context(), id, at)
+	} else {
+		stored, err = h.Service.Get(r.Context(), id)
+	}
+	if err != nil {
+		writePolicyError(w, "get", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(newPolicyResponse(stored))
+}
+
+// Versions returns a policy's history, tombstones included.
+func (h PolicyHandler) Versions(w http.ResponseWriter, r *http.Request) {
+	if !h.ready(w) {
+		return
+	}
+	id, ok := policyKey(w, r)
+	if !ok {
+		return
+	}
+	versions, err := h.Service.Versions(r.Context(), id)
+	if err != nil {
+		writePolicyError(w, "versions", err)
+		return
+	}
+	resp := make([]policyResponse, 0, len(versions))
+	for _, stored := range versions {
+		resp = append(resp, newPolicyResponse(stored))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// Decisions returns the latest entries of a policy's decision log, limited
+// by the since, before and limit query parameters.
+func (h PolicyHandler) Decisions(w http.ResponseWriter, r *http.Request) {
+	if !h.ready(w) {
+		return
+	}
+	id, ok := policyKey(w, r)
+	if !ok {
+		return
+	}
+	var query policy.DecisionQuery
+	for name, bound := range map[string]*time.Time{"since": &query.Since, "before": &query.Before} {
+		raw := r.URL.Query().Get(name)
+		if raw == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339Nano, raw)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		*bound = parsed
+	}
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		query.Limit = limit
+	}
+	records, err := h.Service.ListDecisions(r.Context(), id, query)
+	if err != nil {
+		writePolicyError(w, "decisions", err)
+		return
+	}
+	// Times keep full precision, so the oldest entry's time can be passed as
+	// before to page back through the log.
+	resp := make([]policyDecisionLogResponse, 0, len(records))
+	for _, record := range records {
+		resp = append(resp, policyDecisionLogResponse{
+			WorkloadID:    record.WorkloadID,
+			PolicyVersion: record.PolicyVersion,
+			InputHash:     record.InputHash,
+			Allowed:       record.Allowed,
+			Reason:        record.Reason,
+			Error:         record.Error,
+			LatencyMs:     float64(record.Latency.Microseconds()) / 1000,
+			Time:          record.Time.UTC().Format(time.RFC3339Nano),
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// Rollback stores an earlier version's ruleset as the next version.
+func (h PolicyHandler) Rollback(w http.ResponseWriter, r *http.Request) {
+	if !h.ready(w) {
+		return
+	}
+	id, ok := policyKey(w, r)
+	if !ok {
+		return
+	}
+	var req policyRollbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Version <= 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	actor, _ := middleware.AgentID(r.Context())
+	stored, err := h.Service.Rollback(r.Context(), id, req.Version, actor)
+	if err != nil {
+		writePolicyError(w, "rollback", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(newPolicyResponse(stored))
+}
+
+// Evaluate dry-runs a candidate ruleset against the given inputs and
+// the policy's recently recorded ones, flagging inputs on which it decides
+// differently from the stored version. Nothing is stored.
+func (h PolicyHandler) Evaluate(w http.ResponseWriter, r *http.Request) {
+	if !h.ready(w) {
+		return
+	}
+	id, ok := policyKey(w, r)
+	if !ok {
+		return
+	}
+	var req policyEvaluateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("policies: decode error: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if req.Ruleset == "" || req.Recent < 0 || len(req.Inputs)+req.Recent == 0 || len(req.Inputs)+req.Recent > maxDryRunInputs {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	results, err := h.Service.DryRun(r.Context(), id, req.Ruleset, req.Inputs, req.Recent)
+	var compileErr *policy.CompileError
+	switch {
+	case errors.As(err, &compileErr):
+		resp := policyCompileErrorResponse{Code: "invalid_ruleset", Message: compileErr.Error()}
+		for _, problem := range compileErr.Problems {
+			resp.Problems = append(resp.Problems, policyCompileProblem{Row: problem.Row, Col: problem.Col, Message: problem.Message})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(resp)
+		return
+	case errors.Is(err, policy.ErrNoInputs):
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		return
+	case err != nil:
+		log.Printf("policies: evaluate error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	resp := policyEvaluateResponse{Results: make([]policyEvaluation, 0, len(results))}
+	for _, result := range results {
+		evaluation := policyEvaluation{
+			Input:    result.Input,
+			Decision: newPolicyDecisionResponse(result.Decision, result.Err),
+			Changed:  result.Changed,
+		}
+		if result.Active != nil {
+			active := newPolicyDecisionResponse(*result.Active, result.ActiveErr)
+			evaluation.Active = &active
+		}
+		if result.Changed {
+			resp.Changed++
+		}
+		resp.Results = append(resp.Results, evaluation)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// Delete appends a tombstone version.
+func (h PolicyHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if !h.ready(w) {
+		return
+	}
+	id, ok := policyKey(w, r)
+	if !ok {
+		return
+	}
+	actor, _ := middleware.AgentID(r.Context())
+	if err := h.Service.Delete(r.Context(), id, actor); err != nil {
+		writePolicyError(w, "delete", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// policyTenant is the caller's tenant. Without claims (AUTHZ_BYPASS) the
+// caller names the tenant.
+func policyTenant(w http.ResponseWriter, r *http.Request) (string, bool) {
+	tenantID, ok := middleware.TenantID(r.Context())
+	if !ok {
+		tenantID = r.URL.Query().Get("tenantId")
+	}
+	if tenantID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return "", false
+	}
+	return tenantID, true
+}
+
+// policyKey is the store ID of the addressed policy within the caller's
+// tenant, so other tenants' policies cannot be reached.
+func policyKey(w http.ResponseWriter, r *http.Request) (string, bool) {
+	tenantID, ok := policyTenant(w, r)
+	if !ok {
+		return "", false
+	}
+	return policy.Key(tenantID, chi.URLParam(r, "policyId")), true
+}
+
+// writePolicyDenied answers a workload the policy did not allow with a
+// contracts.Error carrying the reason, and reports whether err was one: 403
+// for a denial or a policy that failed to decide, 404 when the named policy
+// does not exist.
+func writePolicyDenied(w http.ResponseWriter, err error) bool {
+	var denied *policy.DeniedError
+	var failed *policy.EvaluationError
+	status, code := http.StatusForbidden, contracts.ErrForbidden
+	var message string
+	switch {
+	case errors.As(err, &denied):
+		message = denied.Reason
+	case errors.Is(err, orchestration.ErrDependencyNotAllowed):
+		message = err.Error()
+	case errors.As(err, &failed):
+		message = failed.Error()
+	case errors.Is(err, policy.ErrPolicyNotFound):
+		status, code = http.StatusNotFound, contracts.ErrNotFound
+		message = "policy not found; workloads need a stored policy"
+	default:
+		return false
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(contracts.Error{Code: code, Message: message})
+	return true
+}
+
+func writePolicyError(w http.ResponseWriter, op string, err error) {
+	switch {
+	case errors.Is(err, policy.ErrPolicyNotFound), errors.Is(err, policy.ErrPolicyVersionNotFound):
+		w.WriteHeader(http.StatusNotFound)
+	case errors.Is(err, policy.ErrStalePolicyVersion):
+		w.WriteHeader(http.StatusConflict)
+	default:
+		log.Printf("policies: %s error: %v", op, err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
 }
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"control-plane/internal/sessions"
+	"control-plane/pkg/client"
+)
+
+type sessionFileResponse struct {
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	Checksum string `json:"checksum"`
+}
+
+func (h SessionHandler) handleUpload(w http.ResponseWriter, r *http.Request) {
+	session, ok := h.lookup(w, r)
+	if !ok {
+		return
+	}
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	file, err := h.Service.UploadFile(r.Context(), session, path, r.Body)
+	if err != nil {
+		log.Printf("sessions: upload session_id=%s error: %v", session.ID, err)
+		if writePolicyDenied(w, err) {
+			return
+		}
+		w.WriteHeader(fileErrorStatus(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(sessionFileResponse{Path: file.Path, Size: file.Size, Checksum: file.Checksum})
+}
+
+func (h SessionHandler) handleDownload(w http.ResponseWriter, r *http.Request) {
+	session, ok := h.lookup(w, r)
+	if !ok {
+		return
+	}
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	body, file, err := h.Service.DownloadFile(r.Context(), session, path)
+	if err != nil {
+		log.Printf("sessions: download session_id=%s error: %v", session.ID, err)
+		if writePolicyDenied(w, err) {
+			return
+		}
+		w.WriteHeader(fileErrorStatus(err))
+		return
+	}
+	defer body.Close()
+	w.Header().Set("Content-Type", "application/octet-stream")
+	if file.Size >= 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(file.Size, 10))
+	}
+	if file.Checksum != "" {
+		w.Header().Set(client.FileChecksumHeader, file.Checksum)
+	}
+	if _, err := io.Copy(w, body); err != nil {
+		log.Printf("sessions: download session_id=%s copy error: %v", session.ID, err)
+	}
+}
+
+func fileErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, client.ErrInvalidFilePath):
+		return http.StatusBadRequest
+	case errors.Is(err, client.ErrFileNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, client.ErrFileTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, client.ErrWorkspaceUnavailable), errors.Is(err, sessions.ErrSessionNotActive):
+		return http.StatusConflict
+	case errors.Is(err, sessions.ErrFileTransferDenied):
+		return http.StatusForbidden
+	}
+	return http.StatusBadGateway
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"control-plane/internal/sessions"
+	"control-plane/pkg/client"
+)
+
+type snapshotRequest struct {
+	IncludeState bool `json:"includeState"`
+}
+
+type snapshotResponse struct {
+	ID        string `json:"id"`
+	SessionID string `json:"session_id"`
+	Runtime   string `json:"runtime"`
+	HasState  bool   `json:"has_state"`
+	Size      int64  `json:"size"`
+	Checksum  string `json:"checksum"`
+	CreatedAt string `json:"created_at,omitempty"`
+}
+
+func (h SessionHandler) handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	session, ok := h.lookup(w, r)
+	if !ok {
+		return
+	}
+	var req snapshotRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	snapshot, err := h.Service.Snapshot(r.Context(), session, req.IncludeState)
+	if err != nil {
+		log.Printf("sessions: snapshot session_id=%s error: %v", session.ID, err)
+		switch {
+		case errors.Is(err, sessions.ErrSessionNotActive):
+			w.WriteHeader(http.StatusConflict)
+		case errors.Is(err, client.ErrSnapshotStateUnavailable):
+			w.WriteHeader(http.StatusUnprocessableEntity)
+		case errors.Is(err, client.ErrSnapshotsUnsupported):
+			w.WriteHeader(http.StatusNotImplemented)
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(snapshotResponse{
+		ID:        snapshot.ID,
+		SessionID: snapshot.SessionID,
+		Runtime:   snapshot.Runtime,
+		HasState:  snapshot.HasState,
+		Size:      snapshot.Size,
+		Checksum:  snapshot.Checksum,
+		CreatedAt: formatTimestamp(snapshot.CreatedAt),
+	})
+}
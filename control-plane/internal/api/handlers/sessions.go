@@ -2,13 +2,19 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 
+	"control-plane/internal/api/middleware"
+	"control-plane/internal/orchestration"
 	"control-plane/internal/sessions"
+	"control-plane/pkg/client"
+	"shared/sessionagent"
 )
 
 type SessionHandler struct {
@@ -17,11 +23,13 @@ type SessionHandler struct {
 }
 
 type sessionRequest struct {
-	TenantID   string `json:"tenantId"`
-	AgentID    string `json:"agentId"`
-	PolicyID   string `json:"policyId"`
-	TTLSeconds int    `json:"ttlSeconds"`
-	Runtime    string `json:"runtime"`
+	TenantID   string   `json:"tenantId"`
+	AgentID    string   `json:"agentId"`
+	PolicyID   string   `json:"policyId"`
+	TTLSeconds int      `json:"ttlSeconds"`
+	Runtime    string   `json:"runtime"`
+	Deps       []string `json:"deps"`
+	SnapshotID string   `json:"snapshotId"`
 }
 
 type sessionResponse struct {
@@ -29,19 +37,112 @@ type sessionResponse struct {
 	Status string `json:"status"`
 }
 
+type sessionDetailResponse struct {
+	ID           string `json:"id"`
+	Status       string `json:"status"`
+	TenantID     string `json:"tenant_id,omitempty"`
+	AgentID      string `json:"agent_id,omitempty"`
+	PolicyID     string `json:"policy_id,omitempty"`
+	Runtime      string `json:"runtime,omitempty"`
+	RuntimeID    string `json:"runtime_id,omitempty"`
+	CreatedAt    string `json:"created_at,omitempty"`
+	LastActivity string `json:"last_activity,omitempty"`
+	ExpiresAt    string `json:"expires_at,omitempty"`
+}
+
+type sessionStepResponse struct {
+	ID            string                 `json:"id"`
+	Command       string                 `json:"command"`
+	Status        string                 `json:"status"`
+	StartedAt     string                 `json:"started_at,omitempty"`
+	ResourceUsage *resourceUsageResponse `json:"resource_usage"`
+}
+
+func newSessionDetailResponse(session sessions.Session) sessionDetailResponse {
+	return sessionDetailResponse{
+		ID:           session.ID,
+		Status:       string(session.Status),
+		TenantID:     session.TenantID,
+		AgentID:      session.AgentID,
+		PolicyID:     session.PolicyID,
+		Runtime:      session.Runtime,
+		RuntimeID:    session.RuntimeID,
+		CreatedAt:    formatTimestamp(session.CreatedAt),
+		LastActivity: formatTimestamp(session.LastActivity),
+		ExpiresAt:    formatTimestamp(session.ExpiresAt),
+	}
+}
+
 type stepRequest struct {
-	Command string `json:"command"`
+	Command   string `json:"command"`
+	TimeoutMs int64  `json:"timeoutMs"`
+}
+
+func (r stepRequest) valid() bool {
+	return r.Command != "" && r.TimeoutMs >= 0
+}
+
+func (r stepRequest) step() sessions.StepRequest {
+	return sessions.StepRequest{Command: r.Command, Timeout: time.Duration(r.TimeoutMs) * time.Millisecond}
 }
 
 type stepResponse struct {
-	ID     string `json:"id"`
-	Status string `json:"status"`
-	Stdout string `json:"stdout"`
-	Stderr string `json:"stderr"`
+	ID            string                 `json:"id"`
+	Status        string                 `json:"status"`
+	Stdout        string                 `json:"stdout"`
+	Stderr        string                 `json:"stderr"`
+	StateLost     bool                   `json:"state_lost,omitempty"`
+	ResourceUsage *resourceUsageResponse `json:"resource_usage"`
+}
+
+type interruptResponse struct {
+	ID          string `json:"id"`
+	Interrupted bool   `json:"interrupted"`
+}
+
+type stepChunkEvent struct {
+	Data string `json:"data"`
+}
+
+type stepStatusEvent struct {
+	ID            string                 `json:"id"`
+	Status        string                 `json:"status"`
+	Error         string                 `json:"error,omitempty"`
+	StateLost     bool                   `json:"state_lost,omitempty"`
+	ResourceUsage *resourceUsageResponse `json:"resource_usage,omitempty"`
 }
 
 func (h SessionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if r.Method == http.MethodPost && chi.URLParam(r, "sessionId") != "" {
+	sessionID := chi.URLParam(r, "sessionId")
+	switch {
+	case r.Method == http.MethodGet && sessionID == "":
+		h.handleList(w, r)
+		return
+	case r.Method == http.MethodPut && strings.HasSuffix(r.URL.Path, "/files"):
+		h.handleUpload(w, r)
+		return
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/files"):
+		h.handleDownload(w, r)
+		return
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/steps"):
+		h.handleListSteps(w, r)
+		return
+	case r.Method == http.MethodGet:
+		h.handleGet(w, r)
+		return
+	case r.Method == http.MethodDelete && sessionID != "":
+		h.handleDelete(w, r)
+		return
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/snapshot"):
+		h.handleSnapshot(w, r)
+		return
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/steps/stream"):
+		h.handleStepStream(w, r)
+		return
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/interrupt"):
+		h.handleInterrupt(w, r)
+		return
+	case r.Method == http.MethodPost && sessionID != "":
 		h.handleStep(w, r)
 		return
 	}
@@ -55,22 +156,44 @@ func (h SessionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	if req.Runtime == "" {
+	if req.Runtime == "" && req.SnapshotID == "" {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
+	if tenantID, ok := middleware.TenantID(r.Context()); ok {
+		if req.TenantID == "" {
+			req.TenantID = tenantID
+		}
+		if req.TenantID != tenantID {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+	}
 	session := sessions.Session{
-		ID:       "session-" + time.Now().UTC().Format("20060102150405"),
-		TenantID: req.TenantID,
-		AgentID:  req.AgentID,
-		PolicyID: req.PolicyID,
-		Runtime:  req.Runtime,
-		TTL:      time.Duration(req.TTLSeconds) * time.Second,
-		Status:   sessions.StatusActive,
+		ID:         newID("session"),
+		TenantID:   req.TenantID,
+		AgentID:    req.AgentID,
+		PolicyID:   req.PolicyID,
+		Runtime:    req.Runtime,
+		Deps:       req.Deps,
+		SnapshotID: req.SnapshotID,
+		TTL:        time.Duration(req.TTLSeconds) * time.Second,
+		Status:     sessions.StatusActive,
 	}
 	_, err := h.Service.CreateSession(r.Context(), session)
 	if err != nil {
 		log.Printf("sessions: create error: %v", err)
+		if writePolicyDenied(w, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, client.ErrSnapshotNotFound):
+			w.WriteHeader(http.StatusNotFound)
+			return
+		case errors.Is(err, sessions.ErrSnapshotRuntimeMismatch):
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -79,17 +202,128 @@ func (h SessionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(sessionResponse{ID: session.ID, Status: string(session.Status)})
 }
 
+func (h SessionHandler) handleList(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := middleware.TenantID(r.Context())
+	if !ok {
+		// Without claims (AUTHZ_BYPASS) the caller names the tenant.
+		tenantID = r.URL.Query().Get("tenantId")
+	}
+	if tenantID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	list, err := h.Service.List(r.Context(), tenantID)
+	if err != nil {
+		log.Printf("sessions: list error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	resp := make([]sessionDetailResponse, 0, len(list))
+	for _, session := range list {
+		resp = append(resp, newSessionDetailResponse(session))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func (h SessionHandler) handleGet(w http.ResponseWriter, r *http.Request) {
+	session, ok := h.lookup(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(newSessionDetailResponse(session))
+}
+
+func (h SessionHandler) handleListSteps(w http.ResponseWriter, r *http.Request) {
+	session, ok := h.lookup(w, r)
+	if !ok {
+		return
+	}
+	steps, err := h.Stepper.List(r.Context(), session.ID)
+	if err != nil {
+		log.Printf("sessions: list steps error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	resp := make([]sessionStepResponse, 0, len(steps))
+	for _, step := range steps {
+		resp = append(resp, sessionStepResponse{
+			ID:            step.ID,
+			Command:       step.Command,
+			Status:        step.Status,
+			StartedAt:     formatTimestamp(step.StartedAt),
+			ResourceUsage: newResourceUsageResponse(step.Usage),
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func (h SessionHandler) handleDelete(w http.ResponseWriter, r *http.Request) {
+	session, ok := h.lookup(w, r)
+	if !ok {
+		return
+	}
+	session, err := h.Service.Terminate(r.Context(), session.ID)
+	if err != nil {
+		log.Printf("sessions: terminate error: %v", err)
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(sessionResponse{ID: session.ID, Status: string(session.Status)})
+}
+
+// lookup loads the addressed session, writing a 404 when it does not exist
+// or belongs to another tenant so session IDs cannot be probed.
+func (h SessionHandler) lookup(w http.ResponseWriter, r *http.Request) (sessions.Session, bool) {
+	if h.Service.Store == nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return sessions.Session{}, false
+	}
+	session, err := h.Service.Get(r.Context(), chi.URLParam(r, "sessionId"))
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return sessions.Session{}, false
+	}
+	if tenantID, ok := middleware.TenantID(r.Context()); ok && (tenantID == "" || tenantID != session.TenantID) {
+		w.WriteHeader(http.StatusNotFound)
+		return sessions.Session{}, false
+	}
+	return session, true
+}
+
+// requireActive answers 410 for an expired session and 409 for a
+// terminated one, whose runtimes are gone, and reports whether the session
+// can still run steps.
+func requireActive(w http.ResponseWriter, session sessions.Session) bool {
+	switch session.Status {
+	case sessions.StatusActive:
+		return true
+	case sessions.StatusExpired:
+		w.WriteHeader(http.StatusGone)
+	default:
+		w.WriteHeader(http.StatusConflict)
+	}
+	return false
+}
+
 func (h SessionHandler) handleStep(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
 	}
+	session, ok := h.lookup(w, r)
+	if !ok || !requireActive(w, session) {
+		return
+	}
 	var req stepRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	if req.Command == "" {
+	if !req.valid() {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
@@ -97,17 +331,85 @@ func (h SessionHandler) handleStep(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusNotImplemented)
 		return
 	}
-	result, err := h.Stepper.Run(r.Context(), chi.URLParam(r, "sessionId"), req.Command)
+	result, err := h.Stepper.Run(r.Context(), session.ID, req.step())
 	if err != nil {
+		log.Printf("sessions: step error session_id=%s: %v", session.ID, err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+	orchestration.RecordResourceUsage(r.Context(), session.TenantID, "step", result.Usage)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusAccepted)
 	_ = json.NewEncoder(w).Encode(stepResponse{
-		ID:     result.ID,
-		Status: "accepted",
-		Stdout: result.Stdout,
-		Stderr: result.Stderr,
+		ID:            result.ID,
+		Status:        result.Status,
+		Stdout:        result.Stdout,
+		Stderr:        result.Stderr,
+		StateLost:     result.StateLost,
+		ResourceUsage: newResourceUsageResponse(result.Usage),
 	})
 }
+
+// handleStepStream relays a step's output as server-sent events: stdout and
+// stderr chunks while the step runs, then a single status event.
+func (h SessionHandler) handleStepStream(w http.ResponseWriter, r *http.Request) {
+	session, ok := h.lookup(w, r)
+	if !ok || !requireActive(w, session) {
+		return
+	}
+	var req stepRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !req.valid() {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if _, ok := h.Stepper.Runner.(sessions.StepStreamer); !ok {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+	events := sessionagent.NewEventStream(w)
+	result, err := h.Stepper.Stream(r.Context(), session.ID, req.step(), func(stream string, data string) {
+		_ = events.Send(stream, stepChunkEvent{Data: data})
+	})
+	if err != nil {
+		log.Printf("sessions: step stream error: %v", err)
+		if !events.Started() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_ = events.Send("status", stepStatusEvent{Status: "failed", Error: err.Error()})
+		return
+	}
+	orchestration.RecordResourceUsage(r.Context(), session.TenantID, "step", result.Usage)
+	_ = events.Send("status", stepStatusEvent{
+		ID:            result.ID,
+		Status:        result.Status,
+		StateLost:     result.StateLost,
+		ResourceUsage: newResourceUsageResponse(result.Usage),
+	})
+}
+
+// handleInterrupt stops the step running in a session. The step itself
+// then reports interrupted.
+func (h SessionHandler) handleInterrupt(w http.ResponseWriter, r *http.Request) {
+	session, ok := h.lookup(w, r)
+	if !ok || !requireActive(w, session) {
+		return
+	}
+	interrupted, err := h.Stepper.Interrupt(r.Context(), session.ID)
+	if err != nil {
+		log.Printf("sessions: interrupt error session_id=%s: %v", session.ID, err)
+		if errors.Is(err, sessions.ErrInterruptUnsupported) {
+			w.WriteHeader(http.StatusNotImplemented)
+			return
+		}
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(interruptResponse{ID: session.ID, Interrupted: interrupted})
+}
@@ -0,0 +1,31 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"control-plane/internal/api/handlers"
+	"control-plane/internal/api/middleware"
+	"control-plane/internal/orchestration"
+)
+
+type InternalDependencies struct {
+	JobService    *orchestration.JobService
+	CallbackToken string
+}
+
+// InternalRouter serves callbacks from the data plane. It is mounted outside
+// the tenant API because callers authenticate with a shared token, not a JWT.
+func InternalRouter(deps InternalDependencies) http.Handler {
+	r := chi.NewRouter()
+	r.Use(middleware.CallbackAuth(deps.CallbackToken))
+
+	jobService := orchestration.JobService{}
+	if deps.JobService != nil {
+		jobService = *deps.JobService
+	}
+	r.Post("/jobs/{jobId}/complete", handlers.JobCallbackHandler{Service: jobService}.ServeHTTP)
+
+	return r
+}
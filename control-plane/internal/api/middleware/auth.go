@@ -57,6 +57,18 @@ func Auth(next http.Handler) http.Handler {
 	})
 }
 
+// TenantID returns the tenant from the caller's JWT claims. ok is false when
+// the request was not authenticated with a token, e.g. under AUTHZ_BYPASS.
+func TenantID(ctx context.Context) (string, bool) {
+	tenantID, ok := ctx.Value(ctxTenantIDKey).(string)
+	return tenantID, ok
+}
+
+func AgentID(ctx context.Context) (string, bool) {
+	agentID, ok := ctx.Value(ctxAgentIDKey).(string)
+	return agentID, ok
+}
+
 func parseBearer(header string) (string, error) {
 	if header == "" {
 		return "", errors.New("missing authorization header")
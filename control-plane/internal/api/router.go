@@ -21,7 +21,7 @@ type Dependencies struct {
 	JobStore        storage.JobStore
 	SessionService  *sessions.Service
 	Stepper         *sessions.StepService
-	PolicyStore     policy.Store
+	PolicyService   *policy.Service
 	AuditStore      audit.Store
 	WorkflowService *orchestration.WorkflowService
 	ServiceStarter  func(service services.Service) (string, error)
@@ -47,8 +47,9 @@ func RouterWithDependencies(deps Dependencies) http.Handler {
 	if jobStore == nil && deps.JobService != nil {
 		jobStore = deps.JobService.Store
 	}
-	r.Post("/jobs", handlers.JobHandler{Service: jobService, Store: jobStore}.ServeHTTP)
-	r.Get("/jobs/{jobId}", notImplemented)
+	jobHandler := handlers.JobHandler{Service: jobService, Store: jobStore}
+	r.Post("/jobs", jobHandler.ServeHTTP)
+	r.Get("/jobs/{jobId}", jobHandler.ServeHTTP)
 
 	sessionService := sessions.Service{}
 	if deps.SessionService != nil {
@@ -60,20 +61,43 @@ func RouterWithDependencies(deps Dependencies) http.Handler {
 	}
 	sessionHandler := handlers.SessionHandler{Service: sessionService, Stepper: stepper}
 	r.Post("/sessions", sessionHandler.ServeHTTP)
+	r.Get("/sessions", sessionHandler.ServeHTTP)
+	r.Get("/sessions/{sessionId}", sessionHandler.ServeHTTP)
+	r.Delete("/sessions/{sessionId}", sessionHandler.ServeHTTP)
+	r.Get("/sessions/{sessionId}/steps", sessionHandler.ServeHTTP)
+	r.Put("/sessions/{sessionId}/files", sessionHandler.ServeHTTP)
+	r.Get("/sessions/{sessionId}/files", sessionHandler.ServeHTTP)
+	r.Post("/sessions/{sessionId}/snapshot", sessionHandler.ServeHTTP)
 	r.Post("/sessions/{sessionId}/steps", sessionHandler.ServeHTTP)
+	r.Post("/sessions/{sessionId}/steps/stream", sessionHandler.ServeHTTP)
+	r.Post("/sessions/{sessionId}/interrupt", sessionHandler.ServeHTTP)
 
 	r.Post("/artifacts/upload", notImplemented)
 	r.Get("/artifacts/{artifactId}/download", notImplemented)
 
-	policyStore := deps.PolicyStore
-	if policyStore == nil {
-		policyStore = policy.NewInMemoryStore()
-	}
 	auditStore := deps.AuditStore
 	if auditStore == nil {
 		auditStore = &audit.InMemoryStore{}
 	}
-	r.Post("/policies", handlers.PolicyHandler{Store: policyStore}.ServeHTTP)
+	policyService := policy.Service{
+		Store:     policy.NewInMemoryStore(),
+		Evaluator: &policy.OPAEvaluator{},
+		Logger:    audit.StoreLogger{Store: auditStore},
+		Inputs:    &policy.InMemoryInputStore{},
+		Decisions: &policy.InMemoryDecisionLog{},
+	}
+	if deps.PolicyService != nil {
+		policyService = *deps.PolicyService
+	}
+	policyHandler := handlers.PolicyHandler{Service: policyService}
+	r.Post("/policies", policyHandler.Create)
+	r.Get("/policies", policyHandler.List)
+	r.Get("/policies/{policyId}", policyHandler.Get)
+	r.Delete("/policies/{policyId}", policyHandler.Delete)
+	r.Get("/policies/{policyId}/versions", policyHandler.Versions)
+	r.Get("/policies/{policyId}/decisions", policyHandler.Decisions)
+	r.Post("/policies/{policyId}/rollback", policyHandler.Rollback)
+	r.Post("/policies/{policyId}/evaluate", policyHandler.Evaluate)
 	r.Get("/audit/events", handlers.AuditHandler{Store: auditStore}.ServeHTTP)
 
 	workflowService := orchestration.WorkflowService{}
@@ -2,6 +2,10 @@ package audit
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
 
 	"control-plane/internal/storage"
 )
@@ -12,20 +16,27 @@ type Store interface {
 }
 
 type InMemoryStore struct {
+	mu     sync.Mutex
 	events []Event
 }
 
 func (s *InMemoryStore) Append(ctx context.Context, event Event) error {
 	_ = ctx
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.events = append(s.events, event)
 	return nil
 }
 
 func (s *InMemoryStore) List(ctx context.Context) ([]Event, error) {
 	_ = ctx
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	return append([]Event(nil), s.events...), nil
 }
 
+// StorageAdapter keeps audit events in the control plane's database, so
+// they survive restarts and are shared by replicas.
 type StorageAdapter struct {
 	Store storage.AuditStore
 }
@@ -35,9 +46,13 @@ func (s StorageAdapter) Append(ctx context.Context, event Event) error {
 		return ErrStoreUnavailable
 	}
 	return s.Store.Append(ctx, storage.AuditEvent{
-		ID:      event.Detail,
-		Action:  event.Action,
-		Outcome: event.Outcome,
+		ID:        eventID(),
+		TenantID:  event.TenantID,
+		ActorID:   event.ActorID,
+		Action:    event.Action,
+		Outcome:   event.Outcome,
+		Detail:    event.Detail,
+		CreatedAt: event.Time,
 	})
 }
 
@@ -52,14 +67,23 @@ func (s StorageAdapter) List(ctx context.Context) ([]Event, error) {
 	events := make([]Event, 0, len(items))
 	for _, item := range items {
 		events = append(events, Event{
-			Action:  item.Action,
-			Outcome: item.Outcome,
-			Detail:  item.ID,
+			TenantID: item.TenantID,
+			ActorID:  item.ActorID,
+			Action:   item.Action,
+			Outcome:  item.Outcome,
+			Detail:   item.Detail,
+			Time:     item.CreatedAt,
 		})
 	}
 	return events, nil
 }
 
+func eventID() string {
+	suffix := make([]byte, 8)
+	_, _ = rand.Read(suffix)
+	return "audit-" + time.Now().UTC().Format("20060102150405") + "-" + hex.EncodeToString(suffix)
+}
+
 var ErrStoreUnavailable = storageError("audit store unavailable")
 
 type storageError string
@@ -3,35 +3,44 @@ package config
 import (
 	"errors"
 	"os"
+	"time"
 )
 
 type Config struct {
-	Env            string
-	DataPlaneURL   string
-	DatabaseDriver string
-	DatabaseURL    string
-	ArtifactBucket string
-	OtelEndpoint   string
-	OtelService    string
-	AuthIssuer     string
-	AuthAudience   string
-	MCPAddr        string
-	AuthzBypass    bool
+	Env                  string
+	DataPlaneURL         string
+	DatabaseDriver       string
+	DatabaseURL          string
+	ArtifactBucket       string
+	OtelEndpoint         string
+	OtelService          string
+	AuthIssuer           string
+	AuthAudience         string
+	MCPAddr              string
+	AuthzBypass          bool
+	JobCallbackToken     string
+	JobReconcileInterval time.Duration
+	SessionTTLInterval   time.Duration
+	PolicyInputRetention time.Duration
 }
 
 func Load() (Config, error) {
 	cfg := Config{
-		Env:            os.Getenv("ENV"),
-		DataPlaneURL:   os.Getenv("DATA_PLANE_URL"),
-		DatabaseDriver: getenv("DATABASE_DRIVER", "postgres"),
-		DatabaseURL:    os.Getenv("DATABASE_URL"),
-		ArtifactBucket: os.Getenv("ARTIFACT_BUCKET"),
-		OtelEndpoint:   os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"),
-		OtelService:    os.Getenv("OTEL_SERVICE_NAME"),
-		AuthIssuer:     os.Getenv("AUTH_ISSUER"),
-		AuthAudience:   os.Getenv("AUTH_AUDIENCE"),
-		MCPAddr:        os.Getenv("MCP_ADDR"),
-		AuthzBypass:    os.Getenv("AUTHZ_BYPASS") == "true",
+		Env:                  os.Getenv("ENV"),
+		DataPlaneURL:         os.Getenv("DATA_PLANE_URL"),
+		DatabaseDriver:       getenv("DATABASE_DRIVER", "postgres"),
+		DatabaseURL:          os.Getenv("DATABASE_URL"),
+		ArtifactBucket:       os.Getenv("ARTIFACT_BUCKET"),
+		OtelEndpoint:         os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"),
+		OtelService:          os.Getenv("OTEL_SERVICE_NAME"),
+		AuthIssuer:           os.Getenv("AUTH_ISSUER"),
+		AuthAudience:         os.Getenv("AUTH_AUDIENCE"),
+		MCPAddr:              os.Getenv("MCP_ADDR"),
+		AuthzBypass:          os.Getenv("AUTHZ_BYPASS") == "true",
+		JobCallbackToken:     os.Getenv("JOB_CALLBACK_TOKEN"),
+		JobReconcileInterval: getenvDuration("JOB_RECONCILE_INTERVAL", 30*time.Second),
+		SessionTTLInterval:   getenvDuration("SESSION_TTL_INTERVAL", 30*time.Second),
+		PolicyInputRetention: getenvDuration("POLICY_INPUT_RETENTION", 7*24*time.Hour),
 	}
 	return cfg, cfg.Validate()
 }
@@ -58,6 +67,18 @@ func (c Config) Validate() error {
 	if c.Env == "production" && c.AuthzBypass {
 		return errors.New("AUTHZ_BYPASS is not allowed in production")
 	}
+	if c.Env == "production" && c.JobCallbackToken == "" {
+		return errors.New("JOB_CALLBACK_TOKEN is required in production")
+	}
+	if c.JobReconcileInterval <= 0 {
+		return errors.New("JOB_RECONCILE_INTERVAL must be a positive duration")
+	}
+	if c.SessionTTLInterval <= 0 {
+		return errors.New("SESSION_TTL_INTERVAL must be a positive duration")
+	}
+	if c.PolicyInputRetention <= 0 {
+		return errors.New("POLICY_INPUT_RETENTION must be a positive duration")
+	}
 	return nil
 }
 
@@ -67,3 +88,15 @@ func getenv(key string, fallback string) string {
 	}
 	return fallback
 }
+
+func getenvDuration(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return 0
+	}
+	return parsed
+}
@@ -1,24 +1,53 @@
 package orchestration
 
+import "control-plane/internal/storage"
+
 type JobStatus string
 
 const (
-	JobQueued   JobStatus = "queued"
-	JobRunning  JobStatus = "running"
-	JobFailed   JobStatus = "failed"
-	JobFinished JobStatus = "finished"
+	JobQueued     JobStatus = "queued"
+	JobRunning    JobStatus = "running"
+	JobFailed     JobStatus = "failed"
+	JobFinished   JobStatus = "finished"
+	JobTimedOut   JobStatus = "timed_out"
+	JobTerminated JobStatus = "terminated"
 )
 
-type Job struct {
-	ID           string
-	TenantID     string
-	AgentID      string
-	PolicyID     string
-	Language     string
-	Code         string
-	Workspace    string
+type JobResult struct {
+	JobID        string
+	RunID        string
 	Status       JobStatus
+	ExitStatus   int
 	OutputRef    string
 	ErrorRef     string
 	ArtifactRefs []string
+	Usage        storage.ResourceUsage
+}
+
+func (s JobStatus) Terminal() bool {
+	return s == JobFinished || s == JobFailed || s == JobTimedOut || s == JobTerminated
+}
+
+type Job struct {
+	ID             string
+	TenantID       string
+	AgentID        string
+	PolicyID       string
+	Language       string
+	Code           string
+	TimeoutSeconds int
+	Workspace      string
+	Deps           []string
+	Status         JobStatus
+	OutputRef      string
+	ErrorRef       string
+	ArtifactRefs   []string
+}
+
+func (j Job) PolicyRef() (string, string) {
+	return j.TenantID, j.PolicyID
+}
+
+func (j Job) WorkloadID() string {
+	return j.ID
 }
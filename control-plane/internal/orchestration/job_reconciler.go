@@ -0,0 +1,61 @@
+package orchestration
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"control-plane/internal/storage"
+	"control-plane/pkg/client"
+)
+
+type RunStatusClient interface {
+	GetRun(ctx context.Context, runID string) (client.RunStatus, error)
+}
+
+// JobReconciler completes running jobs whose completion callback never
+// arrived by polling the data plane for the state of their run.
+type JobReconciler struct {
+	Service JobService
+	Client  RunStatusClient
+}
+
+func (r JobReconciler) Run(ctx context.Context) error {
+	if r.Service.Store == nil {
+		return errors.New("missing job store")
+	}
+	if r.Client == nil {
+		return errors.New("missing data plane client")
+	}
+	jobs, err := r.Service.Store.ListByStatus(ctx, string(JobRunning))
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		if job.RunID == "" {
+			continue
+		}
+		run, err := r.Client.GetRun(ctx, job.RunID)
+		if err != nil {
+			log.Printf("jobs: reconcile job_id=%s run_id=%s error: %v", job.ID, job.RunID, err)
+			continue
+		}
+		status := JobStatus(run.Status)
+		if !status.Terminal() {
+			continue
+		}
+		if err := r.Service.CompleteJob(ctx, JobResult{
+			JobID:        job.ID,
+			RunID:        job.RunID,
+			Status:       status,
+			ExitStatus:   run.ExitStatus,
+			OutputRef:    run.OutputRef,
+			ErrorRef:     run.ErrorRef,
+			ArtifactRefs: run.ArtifactRefs,
+			Usage:        storage.ResourceUsage(run.ResourceUsage),
+		}); err != nil {
+			log.Printf("jobs: reconcile job_id=%s run_id=%s error: %v", job.ID, job.RunID, err)
+		}
+	}
+	return nil
+}
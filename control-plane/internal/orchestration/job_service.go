@@ -7,6 +7,8 @@ import (
 	"sync/atomic"
 	"time"
 
+	"control-plane/internal/audit"
+	"control-plane/internal/policy"
 	"control-plane/internal/storage"
 	"control-plane/pkg/client"
 
@@ -14,6 +16,8 @@ import (
 	"go.opentelemetry.io/otel/metric"
 )
 
+var ErrJobStatusNotTerminal = errors.New("job status is not terminal")
+
 type JobService struct {
 	Store    storage.JobStore
 	Client   client.DataPlaneClient
@@ -43,38 +47,100 @@ func (s JobService) CreateJob(ctx context.Context, job Job) (string, error) {
 	if job.Status == "" {
 		job.Status = JobQueued
 	}
-	if ok, err := s.Enforcer.Evaluate(ctx, job); err != nil {
+	decision, err := s.Enforcer.Decide(ctx, job)
+	if err != nil {
 		return "", err
-	} else if !ok {
+	}
+	if !decision.Allowed || !decision.AllowsDependencies(job.Deps) {
 		if jobDeniedCounter != nil {
 			jobDeniedCounter.Add(ctx, 1)
 		}
-		return "", errors.New("policy denied job")
+		if decision.Allowed {
+			return "", ErrDependencyNotAllowed
+		}
+		return "", &policy.DeniedError{Reason: decision.Reason}
 	}
-	if err := s.Store.Create(ctx, storage.Job{ID: job.ID, Status: string(job.Status)}); err != nil {
+	if err := s.Store.Create(ctx, storage.Job{
+		ID:       job.ID,
+		TenantID: job.TenantID,
+		AgentID:  job.AgentID,
+		PolicyID: job.PolicyID,
+		Language: job.Language,
+		Status:   string(job.Status),
+	}); err != nil {
 		return "", err
 	}
 	jobQueued.Add(1)
+	// Mark the job running before dispatch so a fast completion callback
+	// cannot be overwritten afterwards.
+	if err := s.Store.UpdateStatus(ctx, job.ID, string(JobRunning)); err != nil {
+		jobQueued.Add(-1)
+		return "", err
+	}
 	resp, err := s.Client.StartRun(ctx, client.RunRequest{
-		JobID:        job.ID,
-		PolicyID:     job.PolicyID,
-		Language:     job.Language,
-		Code:         job.Code,
-		WorkspaceRef: job.Workspace,
+		JobID:           job.ID,
+		TenantID:        job.TenantID,
+		PolicyID:        job.PolicyID,
+		Language:        job.Language,
+		Code:            job.Code,
+		WorkspaceRef:    job.Workspace,
+		TimeoutSeconds:  job.TimeoutSeconds,
+		Deps:            job.Deps,
+		DepsAllowlist:   decision.DepsAllowlist,
+		Limits:          DataPlaneLimits(decision.Limits),
+		RuntimeClass:    decision.RuntimeClass,
+		EgressAllowList: decision.EgressAllowlist,
+		Secrets:         decision.Secrets,
 	})
 	if err != nil {
 		_ = s.Store.UpdateStatus(ctx, job.ID, string(JobFailed))
 		jobQueued.Add(-1)
 		return "", err
 	}
-	if err := s.Store.UpdateStatus(ctx, job.ID, string(JobRunning)); err != nil {
-		jobQueued.Add(-1)
+	jobQueued.Add(-1)
+	if err := s.Store.AttachRun(ctx, job.ID, resp.RunID); err != nil {
 		return "", err
 	}
-	jobQueued.Add(-1)
 	return resp.RunID, nil
 }
 
+func (s JobService) CompleteJob(ctx context.Context, result JobResult) error {
+	if result.JobID == "" {
+		return errors.New("missing job id")
+	}
+	if !result.Status.Terminal() {
+		return ErrJobStatusNotTerminal
+	}
+	job, err := s.Store.Get(ctx, result.JobID)
+	if err != nil {
+		return err
+	}
+	job.Status = string(result.Status)
+	if result.RunID != "" {
+		job.RunID = result.RunID
+	}
+	job.ExitStatus = result.ExitStatus
+	job.OutputRef = result.OutputRef
+	job.ErrorRef = result.ErrorRef
+	job.ArtifactRefs = result.ArtifactRefs
+	job.Usage = result.Usage
+	if err := s.Store.Finish(ctx, job); err != nil {
+		// A callback and the reconciler can both complete a job; only the
+		// first one to change the row records usage and audits.
+		if errors.Is(err, storage.ErrJobFinished) {
+			return nil
+		}
+		return err
+	}
+	RecordResourceUsage(ctx, job.TenantID, "job", result.Usage)
+	outcome := "ok"
+	if result.Status != JobFinished {
+		outcome = string(result.Status)
+	}
+	_ = audit.StdoutLogger{}.JobFinished(ctx, job.TenantID, job.ID, outcome)
+	return nil
+}
+
 func initJobMetrics() {
 	meter := otel.Meter("control-plane.orchestration")
 	jobLatencyHistogram, _ = meter.Float64Histogram("controlplane.jobs.latency_ms")
@@ -2,6 +2,9 @@ package orchestration
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"strings"
@@ -13,8 +16,13 @@ import (
 )
 
 type mockJobStore struct {
-	created []storage.Job
-	updated []string
+	created  []storage.Job
+	updated  []string
+	attached []string
+	finished []storage.Job
+	jobs     map[string]storage.Job
+	// failFinish names a job whose Finish fails.
+	failFinish string
 }
 
 func (m *mockJobStore) Create(ctx context.Context, job storage.Job) error {
@@ -28,6 +36,9 @@ func (m *mockJobStore) Create(ctx context.Context, job storage.Job) error {
 
 func (m *mockJobStore) Get(ctx context.Context, id string) (storage.Job, error) {
 	_ = ctx
+	if job, ok := m.jobs[id]; ok {
+		return job, nil
+	}
 	return storage.Job{ID: id, Status: string(JobQueued)}, nil
 }
 
@@ -37,14 +48,47 @@ func (m *mockJobStore) UpdateStatus(ctx context.Context, id string, status strin
 	return nil
 }
 
+func (m *mockJobStore) AttachRun(ctx context.Context, id string, runID string) error {
+	_ = ctx
+	m.attached = append(m.attached, id+":"+runID)
+	return nil
+}
+
+func (m *mockJobStore) Finish(ctx context.Context, job storage.Job) error {
+	_ = ctx
+	if job.ID == m.failFinish {
+		return storageError("finish failed")
+	}
+	if JobStatus(m.jobs[job.ID].Status).Terminal() {
+		return storage.ErrJobFinished
+	}
+	m.finished = append(m.finished, job)
+	if m.jobs != nil {
+		m.jobs[job.ID] = job
+	}
+	return nil
+}
+
+func (m *mockJobStore) ListByStatus(ctx context.Context, status string) ([]storage.Job, error) {
+	_ = ctx
+	var jobs []storage.Job
+	for _, job := range m.jobs {
+		if job.Status == status {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs, nil
+}
+
 type mockEvaluator struct {
-	allowed bool
+	allowed       bool
+	depsAllowlist []string
 }
 
 func (m mockEvaluator) Evaluate(ctx context.Context, input any) (policy.Decision, error) {
 	_ = ctx
 	_ = input
-	return policy.Decision{Allowed: m.allowed}, nil
+	return policy.Decision{Allowed: m.allowed, DepsAllowlist: m.depsAllowlist}, nil
 }
 
 type storageError string
@@ -114,3 +158,159 @@ func TestJobServicePolicyDenied(t *testing.T) {
 		t.Fatalf("expected no job to be created on denied policy")
 	}
 }
+
+func TestJobServiceDependencies(t *testing.T) {
+	var runReq client.RunRequest
+	httpClient := &http.Client{
+		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			_ = json.NewDecoder(req.Body).Decode(&runReq)
+			return &http.Response{
+				StatusCode: http.StatusAccepted,
+				Body:       io.NopCloser(strings.NewReader(`{"run_id":"run-1"}`)),
+				Header:     make(http.Header),
+			}, nil
+		}),
+	}
+	store := &mockJobStore{}
+	svc := JobService{
+		Store:  store,
+		Client: client.DataPlaneClient{BaseURL: "http://data-plane", Client: httpClient},
+		Enforcer: PolicyEnforcer{
+			Evaluator: mockEvaluator{allowed: true, depsAllowlist: []string{"requests"}},
+		},
+	}
+	_, err := svc.CreateJob(context.Background(), Job{ID: "job-1", Language: "python", Deps: []string{"numpy==2.0.0"}})
+	if !errors.Is(err, ErrDependencyNotAllowed) {
+		t.Fatalf("expected dependency denial, got %v", err)
+	}
+	if len(store.created) != 0 {
+		t.Fatalf("expected no job to be created on denied dependency")
+	}
+	if _, err := svc.CreateJob(context.Background(), Job{ID: "job-2", Language: "python", Deps: []string{"requests==2.32.3"}}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(runReq.Deps) != 1 || len(runReq.DepsAllowlist) != 1 || runReq.DepsAllowlist[0] != "requests" {
+		t.Fatalf("expected deps and allowlist forwarded, got %+v", runReq)
+	}
+}
+
+type decisionEvaluator policy.Decision
+
+func (d decisionEvaluator) Evaluate(ctx context.Context, input any) (policy.Decision, error) {
+	return policy.Decision(d), nil
+}
+
+func TestJobServiceForwardsPolicyDecision(t *testing.T) {
+	var body map[string]any
+	httpClient := &http.Client{
+		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			_ = json.NewDecoder(req.Body).Decode(&body)
+			return &http.Response{
+				StatusCode: http.StatusAccepted,
+				Body:       io.NopCloser(strings.NewReader(`{"run_id":"run-1"}`)),
+				Header:     make(http.Header),
+			}, nil
+		}),
+	}
+	svc := JobService{
+		Store:  &mockJobStore{},
+		Client: client.DataPlaneClient{BaseURL: "http://data-plane", Client: httpClient},
+		Enforcer: PolicyEnforcer{Evaluator: decisionEvaluator{
+			Allowed:         true,
+			Limits:          policy.Limits{CPUMillicores: 500, MemoryBytes: 1 << 28},
+			RuntimeClass:    policy.RuntimeClassGVisor,
+			EgressAllowlist: []string{},
+			Secrets:         []string{"API_TOKEN"},
+		}},
+	}
+	if _, err := svc.CreateJob(context.Background(), Job{ID: "job-1", Language: "python"}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	limits, _ := body["limits"].(map[string]any)
+	if limits["cpuMillicores"] != float64(500) || limits["memoryBytes"] != float64(1<<28) {
+		t.Fatalf("expected limits forwarded, got %v", body["limits"])
+	}
+	if body["runtimeClass"] != "gvisor" || fmt.Sprint(body["secrets"]) != "[API_TOKEN]" {
+		t.Fatalf("expected runtime class and secrets forwarded, got %v", body)
+	}
+	// An empty allowlist must reach the data plane as [] to deny all egress.
+	if egress, ok := body["egressAllowList"].([]any); !ok || len(egress) != 0 {
+		t.Fatalf("expected an empty egress allowlist, got %#v", body["egressAllowList"])
+	}
+}
+
+func TestJobServiceCompleteJob(t *testing.T) {
+	store := &mockJobStore{jobs: map[string]storage.Job{
+		"job-1": {ID: "job-1", TenantID: "tenant-1", Status: string(JobRunning), RunID: "job-1-run"},
+	}}
+	svc := JobService{Store: store}
+	result := JobResult{
+		JobID:        "job-1",
+		Status:       JobFailed,
+		ExitStatus:   3,
+		OutputRef:    "out",
+		ErrorRef:     "err",
+		ArtifactRefs: []string{"a.txt"},
+	}
+	if err := svc.CompleteJob(context.Background(), result); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(store.finished) != 1 {
+		t.Fatalf("expected job to be finished once, got %d", len(store.finished))
+	}
+	finished := store.finished[0]
+	if finished.Status != string(JobFailed) || finished.ExitStatus != 3 || finished.RunID != "job-1-run" || len(finished.ArtifactRefs) != 1 {
+		t.Fatalf("unexpected finished job %+v", finished)
+	}
+	if err := svc.CompleteJob(context.Background(), result); err != nil {
+		t.Fatalf("expected repeated completion to succeed, got %v", err)
+	}
+	if len(store.finished) != 1 {
+		t.Fatalf("expected repeated completion to be ignored")
+	}
+	if err := svc.CompleteJob(context.Background(), JobResult{JobID: "job-1", Status: JobRunning}); err != ErrJobStatusNotTerminal {
+		t.Fatalf("expected non-terminal status error, got %v", err)
+	}
+}
+
+type stubRunStatusClient struct {
+	runs map[string]client.RunStatus
+}
+
+func (s stubRunStatusClient) GetRun(ctx context.Context, runID string) (client.RunStatus, error) {
+	_ = ctx
+	run, ok := s.runs[runID]
+	if !ok {
+		return client.RunStatus{}, storageError("run not found")
+	}
+	return run, nil
+}
+
+func TestJobReconcilerCompletesFinishedRuns(t *testing.T) {
+	store := &mockJobStore{jobs: map[string]storage.Job{
+		"job-1": {ID: "job-1", Status: string(JobRunning), RunID: "job-1-run"},
+		"job-2": {ID: "job-2", Status: string(JobRunning), RunID: "job-2-run"},
+		"job-3": {ID: "job-3", Status: string(JobRunning), RunID: "job-3-run"},
+		"job-4": {ID: "job-4", Status: string(JobRunning), RunID: "job-4-run"},
+	}, failFinish: "job-4"}
+	reconciler := JobReconciler{
+		Service: JobService{Store: store},
+		Client: stubRunStatusClient{runs: map[string]client.RunStatus{
+			"job-1-run": {ID: "job-1-run", Status: "finished", OutputRef: "out"},
+			"job-2-run": {ID: "job-2-run", Status: "running"},
+			"job-4-run": {ID: "job-4-run", Status: "failed"},
+		}},
+	}
+	if err := reconciler.Run(context.Background()); err != nil {
+		t.Fatalf("expected a failing job not to stop reconciliation, got %v", err)
+	}
+	if store.jobs["job-1"].Status != string(JobFinished) || store.jobs["job-1"].OutputRef != "out" {
+		t.Fatalf("expected job-1 to be finished, got %+v", store.jobs["job-1"])
+	}
+	if store.jobs["job-2"].Status != string(JobRunning) {
+		t.Fatalf("expected job-2 to stay running")
+	}
+	if store.jobs["job-3"].Status != string(JobRunning) {
+		t.Fatalf("expected job-3 to stay running when run lookup fails")
+	}
+}
@@ -2,18 +2,28 @@ package orchestration
 
 import (
 	"context"
+	"errors"
 
 	"control-plane/internal/policy"
+	"control-plane/pkg/client"
 )
 
+var ErrDependencyNotAllowed = errors.New("dependency not allowed by policy")
+
 type PolicyEnforcer struct {
 	Evaluator policy.Evaluator
 }
 
-func (p PolicyEnforcer) Evaluate(ctx context.Context, input any) (bool, error) {
-	decision, err := p.Evaluator.Evaluate(ctx, input)
-	if err != nil {
-		return false, err
+func (p PolicyEnforcer) Decide(ctx context.Context, input any) (policy.Decision, error) {
+	return p.Evaluator.Evaluate(ctx, input)
+}
+
+// DataPlaneLimits converts policy limits to the data-plane request form.
+func DataPlaneLimits(limits policy.Limits) client.Limits {
+	return client.Limits{
+		CPUMillicores: limits.CPUMillicores,
+		MemoryBytes:   limits.MemoryBytes,
+		DiskBytes:     limits.DiskBytes,
+		PIDs:          limits.PIDs,
 	}
-	return decision.Allowed, nil
 }
@@ -0,0 +1,42 @@
+package orchestration
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"control-plane/internal/storage"
+)
+
+var (
+	usageMetricsOnce     sync.Once
+	usageCPUHistogram    metric.Int64Histogram
+	usageMaxRSSHistogram metric.Int64Histogram
+	usageIOHistogram     metric.Int64Histogram
+)
+
+// RecordResourceUsage exports what a job or session step consumed, tagged
+// with the tenant it is billed to.
+func RecordResourceUsage(ctx context.Context, tenantID string, kind string, usage storage.ResourceUsage) {
+	usageMetricsOnce.Do(initUsageMetrics)
+	attrs := metric.WithAttributes(attribute.String("tenant_id", tenantID), attribute.String("kind", kind))
+	if usageCPUHistogram != nil {
+		usageCPUHistogram.Record(ctx, usage.CPUTimeMs, attrs)
+	}
+	if usageMaxRSSHistogram != nil {
+		usageMaxRSSHistogram.Record(ctx, usage.MaxRSSBytes, attrs)
+	}
+	if usageIOHistogram != nil {
+		usageIOHistogram.Record(ctx, usage.IOBytes, attrs)
+	}
+}
+
+func initUsageMetrics() {
+	meter := otel.Meter("control-plane.orchestration")
+	usageCPUHistogram, _ = meter.Int64Histogram("controlplane.usage.cpu_time_ms", metric.WithUnit("ms"))
+	usageMaxRSSHistogram, _ = meter.Int64Histogram("controlplane.usage.max_rss_bytes", metric.WithUnit("By"))
+	usageIOHistogram, _ = meter.Int64Histogram("controlplane.usage.io_bytes", metric.WithUnit("By"))
+}
@@ -0,0 +1,211 @@
+package policy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"control-plane/internal/storage"
+)
+
+// DecisionRecord is one policy evaluation in the decision log. InputHash is
+// the SHA-256 of the input as the policy saw it, encoded as JSON.
+// WorkloadID is the job or session the input was.
+type DecisionRecord struct {
+	TenantID      string
+	PolicyID      string
+	WorkloadID    string
+	PolicyVersion int
+	InputHash     string
+	Allowed       bool
+	Reason        string
+	Error         string
+	Latency       time.Duration
+	Time          time.Time
+}
+
+const (
+	// DefaultDecisionLimit is how many decisions ListDecisions returns when
+	// the query sets no limit, and MaxDecisionLimit the most it returns.
+	DefaultDecisionLimit = 100
+	MaxDecisionLimit     = 1000
+	// DefaultDecisionLogSize is how many decisions InMemoryDecisionLog
+	// keeps when no Size is set.
+	DefaultDecisionLogSize = 10000
+)
+
+// DecisionQuery selects the latest Limit decisions made at or after Since
+// and before Before; zero times leave that end open.
+type DecisionQuery struct {
+	Since  time.Time
+	Before time.Time
+	Limit  int
+}
+
+// DecisionLog is append-only; List returns the decisions query selects,
+// oldest first.
+type DecisionLog interface {
+	Append(ctx context.Context, record DecisionRecord) error
+	List(ctx context.Context, tenantID string, policyID string, query DecisionQuery) ([]DecisionRecord, error)
+}
+
+// RecordingEvaluator logs every evaluation to Decisions and keeps the
+// evaluated input in Inputs, so candidate rulesets can be dry-run against
+// real job and session requests. Inputs go to the input store only, not to
+// the decision or audit log, since they carry submitted code. An evaluation
+// that cannot be logged fails, so no workload is allowed unlogged.
+type RecordingEvaluator struct {
+	Evaluator Evaluator
+	Decisions DecisionLog
+	Inputs    InputStore
+}
+
+func (e RecordingEvaluator) Evaluate(ctx context.Context, input any) (Decision, error) {
+	start := time.Now()
+	decision, err := e.Evaluator.Evaluate(ctx, input)
+	latency := time.Since(start)
+
+	var tenantID, policyID string
+	if subject, ok := input.(Subject); ok {
+		tenantID, policyID = subject.PolicyRef()
+	}
+	var workloadID string
+	if workload, ok := input.(Workload); ok {
+		workloadID = workload.WorkloadID()
+	}
+	payload, marshalErr := json.Marshal(input)
+	var inputHash string
+	if marshalErr == nil {
+		sum := sha256.Sum256(payload)
+		inputHash = hex.EncodeToString(sum[:])
+	}
+	if e.Decisions != nil {
+		record := DecisionRecord{
+			TenantID:      tenantID,
+			PolicyID:      policyID,
+			WorkloadID:    workloadID,
+			PolicyVersion: decision.PolicyVersion,
+			InputHash:     inputHash,
+			Allowed:       decision.Allowed,
+			Reason:        decision.Reason,
+			Latency:       latency,
+			Time:          start.UTC(),
+		}
+		if err != nil {
+			record.Error = err.Error()
+		}
+		if appendErr := e.Decisions.Append(ctx, record); appendErr != nil {
+			return Decision{}, fmt.Errorf("log policy decision: %w", appendErr)
+		}
+	}
+	// Inputs no stored policy decided are of no use to a dry run.
+	if e.Inputs == nil || marshalErr != nil || errors.Is(err, ErrPolicyNotFound) {
+		return decision, err
+	}
+	if recordErr := e.Inputs.Record(ctx, RecordedInput{TenantID: tenantID, PolicyID: policyID, InputHash: inputHash, Input: payload}); recordErr != nil {
+		log.Printf("policy: record input error: tenant=%s policy=%s: %v", tenantID, policyID, recordErr)
+	}
+	return decision, err
+}
+
+// InMemoryDecisionLog keeps the last Size decisions across all policies,
+// dropping older ones as new ones are appended.
+type InMemoryDecisionLog struct {
+	Size int
+
+	mu      sync.Mutex
+	records []DecisionRecord
+}
+
+func (l *InMemoryDecisionLog) Append(ctx context.Context, record DecisionRecord) error {
+	_ = ctx
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	size := l.Size
+	if size <= 0 {
+		size = DefaultDecisionLogSize
+	}
+	l.records = append(l.records, record)
+	if len(l.records) > size {
+		l.records = append([]DecisionRecord(nil), l.records[len(l.records)-size:]...)
+	}
+	return nil
+}
+
+func (l *InMemoryDecisionLog) List(ctx context.Context, tenantID string, policyID string, query DecisionQuery) ([]DecisionRecord, error) {
+	_ = ctx
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var records []DecisionRecord
+	for i := len(l.records) - 1; i >= 0 && len(records) < query.Limit; i-- {
+		record := l.records[i]
+		if record.TenantID != tenantID || record.PolicyID != policyID {
+			continue
+		}
+		if !query.Since.IsZero() && record.Time.Before(query.Since) {
+			continue
+		}
+		if !query.Before.IsZero() && !record.Time.Before(query.Before) {
+			continue
+		}
+		records = append(records, record)
+	}
+	slices.Reverse(records)
+	return records, nil
+}
+
+// StorageDecisionLog keeps the decision log in a storage.PolicyDecisionStore.
+type StorageDecisionLog struct {
+	Store storage.PolicyDecisionStore
+}
+
+func (l StorageDecisionLog) Append(ctx context.Context, record DecisionRecord) error {
+	if l.Store == nil {
+		return errors.New("missing decision store")
+	}
+	return l.Store.Append(ctx, storage.PolicyDecision{
+		TenantID:      record.TenantID,
+		PolicyID:      record.PolicyID,
+		WorkloadID:    record.WorkloadID,
+		PolicyVersion: record.PolicyVersion,
+		InputHash:     record.InputHash,
+		Allowed:       record.Allowed,
+		Reason:        record.Reason,
+		Error:         record.Error,
+		Latency:       record.Latency,
+		CreatedAt:     record.Time,
+	})
+}
+
+func (l StorageDecisionLog) List(ctx context.Context, tenantID string, policyID string, query DecisionQuery) ([]DecisionRecord, error) {
+	if l.Store == nil {
+		return nil, errors.New("missing decision store")
+	}
+	stored, err := l.Store.List(ctx, tenantID, policyID, storage.DecisionQuery{Since: query.Since, Before: query.Before, Limit: query.Limit})
+	if err != nil {
+		return nil, err
+	}
+	records := make([]DecisionRecord, 0, len(stored))
+	for _, item := range stored {
+		records = append(records, DecisionRecord{
+			TenantID:      item.TenantID,
+			PolicyID:      item.PolicyID,
+			WorkloadID:    item.WorkloadID,
+			PolicyVersion: item.PolicyVersion,
+			InputHash:     item.InputHash,
+			Allowed:       item.Allowed,
+			Reason:        item.Reason,
+			Error:         item.Error,
+			Latency:       item.Latency,
+			Time:          item.CreatedAt,
+		})
+	}
+	return records, nil
+}
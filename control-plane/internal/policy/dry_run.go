@@ -0,0 +1,84 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+)
+
+// DryRunResult is how a candidate ruleset decides one input, next to the
+// decision of the stored version when there is one.
+type DryRunResult struct {
+	Input    json.RawMessage
+	Decision Decision
+	Err      error
+	Active   *Decision
+	// ActiveErr is set when the stored version fails on the input.
+	ActiveErr error
+	// Changed reports a decision, or an error, that differs from the
+	// stored version's.
+	Changed bool
+}
+
+var ErrNoInputs = errors.New("no inputs to evaluate")
+
+// DryRun evaluates ruleset against inputs plus the last recent inputs
+// recorded for the policy, without storing anything.
+func (s Service) DryRun(ctx context.Context, id string, ruleset string, inputs []json.RawMessage, recent int) ([]DryRunResult, error) {
+	if s.Evaluator == nil {
+		return nil, errors.New("missing policy evaluator")
+	}
+	candidate, err := s.Evaluator.Compile(ctx, ruleset)
+	if err != nil {
+		return nil, err
+	}
+	if recent > 0 {
+		recorded, err := s.recordedInputs(ctx, id, recent)
+		if err != nil {
+			return nil, err
+		}
+		inputs = append(inputs, recorded...)
+	}
+	if len(inputs) == 0 {
+		return nil, ErrNoInputs
+	}
+	var active *CompiledRuleset
+	stored, err := s.Store.Get(ctx, id)
+	switch {
+	case err == nil:
+		prepared, err := s.Evaluator.prepare(s.Evaluator.query(), stored.Ruleset)
+		if err != nil {
+			return nil, err
+		}
+		active = &CompiledRuleset{prepared: prepared}
+	case !errors.Is(err, ErrPolicyNotFound):
+		return nil, err
+	}
+	results := make([]DryRunResult, 0, len(inputs))
+	for _, raw := range inputs {
+		var input any
+		if err := json.Unmarshal(raw, &input); err != nil {
+			return nil, err
+		}
+		result := DryRunResult{Input: raw}
+		result.Decision, result.Err = candidate.Evaluate(ctx, input)
+		if active != nil {
+			decision, err := active.Evaluate(ctx, input)
+			result.Active, result.ActiveErr = &decision, err
+			result.Changed = (result.Err != nil) != (err != nil) || (err == nil && !reflect.DeepEqual(result.Decision, decision))
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// recordedInputs returns the last n inputs RecordingEvaluator kept for the
+// policy, oldest first.
+func (s Service) recordedInputs(ctx context.Context, id string, n int) ([]json.RawMessage, error) {
+	if s.Inputs == nil {
+		return nil, errors.New("missing input store")
+	}
+	tenantID, name := SplitKey(id)
+	return s.Inputs.Recent(ctx, tenantID, name, n)
+}
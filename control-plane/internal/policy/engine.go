@@ -2,15 +2,106 @@ package policy
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/open-policy-agent/opa/ast"
 	"github.com/open-policy-agent/opa/rego"
 )
 
+// Decision is what a policy returns for a job or session. Besides allow
+// and reason, a ruleset may set:
+//
+//	limits = {"cpu_millicores": 500, "memory_bytes": 268435456, "disk_bytes": 0, "pids": 64}
+//	runtime_class = "gvisor"            # or "firecracker"
+//	egress_allowlist = ["pypi.org", "*.example.com"]
+//	deps_allowlist = ["requests"]
+//	max_session_ttl_seconds = 3600
+//	secrets = ["API_TOKEN"]
+//
+// The data plane enforces everything but the TTL, which the control plane
+// applies itself.
 type Decision struct {
 	Allowed bool
 	Reason  string
+	// Limits caps the resources of the run or session; unset fields are
+	// left to the data plane's defaults.
+	Limits Limits
+	// RuntimeClass is the sandbox runtime the workload must run under.
+	// Empty means the data plane's default.
+	RuntimeClass string
+	// EgressAllowlist lists the hosts the workload may reach. Nil leaves
+	// the data plane's default allowlist; an empty list allows none.
+	EgressAllowlist []string
+	DepsAllowlist   []string
+	// MaxSessionTTL caps how long a session may live in total, however
+	// often its TTL is extended by activity. Zero means no cap.
+	MaxSessionTTL time.Duration
+	// MaxFileBytes limits each file uploaded to or downloaded from a session
+	// workspace. Zero means no limit.
+	MaxFileBytes int64
+	// Secrets names the data-plane secrets granted to the workload as
+	// environment variables.
+	Secrets []string
+	// PolicyVersion is the stored policy version that decided, zero when
+	// the ruleset did not come from the policy store.
+	PolicyVersion int
+}
+
+// DeniedError is returned for workloads a policy does not allow.
+type DeniedError struct {
+	Reason string
+}
+
+func (e *DeniedError) Error() string {
+	return "policy denied: " + e.Reason
+}
+
+type Limits struct {
+	CPUMillicores int
+	MemoryBytes   int64
+	DiskBytes     int64
+	PIDs          int
+}
+
+const (
+	RuntimeClassGVisor      = "gvisor"
+	RuntimeClassFirecracker = "firecracker"
+)
+
+var ErrUnsupportedRuntimeClass = errors.New("unsupported runtime class")
+
+// AllowsDependencies reports whether every requested package is covered by
+// the allowlist, either by exact spec or by package name.
+func (d Decision) AllowsDependencies(deps []string) bool {
+	allowed := map[string]struct{}{}
+	for _, item := range d.DepsAllowlist {
+		allowed[item] = struct{}{}
+	}
+	for _, dep := range deps {
+		if _, ok := allowed[dep]; ok {
+			continue
+		}
+		if _, ok := allowed[dependencyName(dep)]; ok {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+func dependencyName(dep string) string {
+	if name, _, ok := strings.Cut(dep, "=="); ok {
+		return name
+	}
+	if i := strings.LastIndex(dep, "@"); i > 0 {
+		return dep[:i]
+	}
+	return dep
 }
 
 type Evaluator interface {
@@ -21,6 +112,12 @@ type RulesetResolver interface {
 	Ruleset(ctx context.Context, input any) (string, error)
 }
 
+// PolicyResolver is a RulesetResolver that also knows which stored policy
+// version the ruleset belongs to, for Decision.PolicyVersion.
+type PolicyResolver interface {
+	Policy(ctx context.Context, input any) (Policy, error)
+}
+
 type StaticRulesetResolver struct {
 	RulesetText string
 }
@@ -34,6 +131,60 @@ func (r StaticRulesetResolver) Ruleset(ctx context.Context, input any) (string,
 	return r.RulesetText, nil
 }
 
+// Subject is implemented by evaluation inputs that name the tenant policy
+// governing them.
+type Subject interface {
+	PolicyRef() (tenantID string, policyID string)
+}
+
+// Workload is implemented by evaluation inputs that are a job or a session,
+// whose ID the decision log records.
+type Workload interface {
+	WorkloadID() string
+}
+
+// EvaluationError is returned when a stored policy cannot decide an input:
+// its ruleset fails to compile or evaluate, or returns an unusable result.
+// The workload is not allowed.
+type EvaluationError struct {
+	Err error
+}
+
+func (e *EvaluationError) Error() string {
+	return "policy evaluation failed: " + e.Err.Error()
+}
+
+func (e *EvaluationError) Unwrap() error {
+	return e.Err
+}
+
+// StoreRulesetResolver evaluates each input against the latest version of
+// the policy it names. Inputs without a stored policy fail evaluation, so
+// they are never allowed.
+type StoreRulesetResolver struct {
+	Store Store
+}
+
+func (r StoreRulesetResolver) Ruleset(ctx context.Context, input any) (string, error) {
+	stored, err := r.Policy(ctx, input)
+	if err != nil {
+		return "", err
+	}
+	return stored.Ruleset, nil
+}
+
+func (r StoreRulesetResolver) Policy(ctx context.Context, input any) (Policy, error) {
+	subject, ok := input.(Subject)
+	if !ok {
+		return Policy{}, ErrPolicyNotFound
+	}
+	tenantID, policyID := subject.PolicyRef()
+	if tenantID == "" || policyID == "" {
+		return Policy{}, ErrPolicyNotFound
+	}
+	return r.Store.Get(ctx, Key(tenantID, policyID))
+}
+
 type OPAEvaluator struct {
 	Resolver RulesetResolver
 	Query    string
@@ -45,18 +196,112 @@ func (e *OPAEvaluator) Evaluate(ctx context.Context, input any) (Decision, error
 	if e.Resolver == nil {
 		return Decision{}, errors.New("missing ruleset resolver")
 	}
-	query := e.Query
-	if query == "" {
-		query = "data.policy"
+	var stored Policy
+	var err error
+	if resolver, ok := e.Resolver.(PolicyResolver); ok {
+		stored, err = resolver.Policy(ctx, input)
+	} else {
+		stored.Ruleset, err = e.Resolver.Ruleset(ctx, input)
 	}
-	ruleset, err := e.Resolver.Ruleset(ctx, input)
 	if err != nil {
 		return Decision{}, err
 	}
-	prepared, err := e.prepare(query, ruleset)
+	prepared, err := e.prepare(e.query(), stored.Ruleset)
 	if err != nil {
-		return Decision{}, err
+		return Decision{}, &EvaluationError{Err: err}
+	}
+	decision, err := decide(ctx, prepared, input)
+	if err != nil {
+		return Decision{}, &EvaluationError{Err: err}
+	}
+	decision.PolicyVersion = stored.Version
+	return decision, nil
+}
+
+// CompiledRuleset is a ruleset compiled outside the evaluator's cache, for
+// trying out rulesets that are not stored yet.
+type CompiledRuleset struct {
+	prepared rego.PreparedEvalQuery
+}
+
+// Compile compiles ruleset without caching it. Rulesets that do not
+// compile return a *CompileError.
+func (e *OPAEvaluator) Compile(ctx context.Context, ruleset string) (CompiledRuleset, error) {
+	prepared, err := rego.New(
+		rego.Query(e.query()),
+		rego.Module("policy.rego", ruleset),
+	).PrepareForEval(ctx)
+	if err != nil {
+		return CompiledRuleset{}, newCompileError(err)
+	}
+	return CompiledRuleset{prepared: prepared}, nil
+}
+
+func (c CompiledRuleset) Evaluate(ctx context.Context, input any) (Decision, error) {
+	return decide(ctx, c.prepared, input)
+}
+
+// CompileError lists the problems found in a ruleset that does not compile.
+type CompileError struct {
+	Problems []CompileProblem
+}
+
+// CompileProblem is one compile error; Row and Col are zero when OPA does
+// not locate it.
+type CompileProblem struct {
+	Row     int
+	Col     int
+	Message string
+}
+
+func (e *CompileError) Error() string {
+	messages := make([]string, 0, len(e.Problems))
+	for _, problem := range e.Problems {
+		messages = append(messages, fmt.Sprintf("%d:%d: %s", problem.Row, problem.Col, problem.Message))
+	}
+	return "policy does not compile: " + strings.Join(messages, "; ")
+}
+
+func newCompileError(err error) *CompileError {
+	compileErr := &CompileError{}
+	compileErr.add(err)
+	return compileErr
+}
+
+// add flattens the error lists rego and ast return into problems.
+func (e *CompileError) add(err error) {
+	var regoErrors rego.Errors
+	var astErrors ast.Errors
+	var astErr *ast.Error
+	switch {
+	case errors.As(err, &regoErrors):
+		for _, item := range regoErrors {
+			e.add(item)
+		}
+	case errors.As(err, &astErrors):
+		for _, item := range astErrors {
+			e.add(item)
+		}
+	case errors.As(err, &astErr):
+		problem := CompileProblem{Message: astErr.Message}
+		if astErr.Location != nil {
+			problem.Row = astErr.Location.Row
+			problem.Col = astErr.Location.Col
+		}
+		e.Problems = append(e.Problems, problem)
+	default:
+		e.Problems = append(e.Problems, CompileProblem{Message: err.Error()})
+	}
+}
+
+func (e *OPAEvaluator) query() string {
+	if e.Query == "" {
+		return "data.policy"
 	}
+	return e.Query
+}
+
+func decide(ctx context.Context, prepared rego.PreparedEvalQuery, input any) (Decision, error) {
 	results, err := prepared.Eval(ctx, rego.EvalInput(input))
 	if err != nil {
 		return Decision{}, err
@@ -73,7 +318,65 @@ func (e *OPAEvaluator) Evaluate(ctx context.Context, input any) (Decision, error
 	if reason == "" && !allowed {
 		reason = "denied"
 	}
-	return Decision{Allowed: allowed, Reason: reason}, nil
+	runtimeClass, _ := obj["runtime_class"].(string)
+	switch runtimeClass {
+	case "", RuntimeClassGVisor, RuntimeClassFirecracker:
+	default:
+		return Decision{}, fmt.Errorf("%w %q", ErrUnsupportedRuntimeClass, runtimeClass)
+	}
+	limits, _ := obj["limits"].(map[string]any)
+	return Decision{
+		Allowed: allowed,
+		Reason:  reason,
+		Limits: Limits{
+			CPUMillicores: int(wholeNumber(limits["cpu_millicores"])),
+			MemoryBytes:   wholeNumber(limits["memory_bytes"]),
+			DiskBytes:     wholeNumber(limits["disk_bytes"]),
+			PIDs:          int(wholeNumber(limits["pids"])),
+		},
+		RuntimeClass:    runtimeClass,
+		EgressAllowlist: stringList(obj["egress_allowlist"]),
+		DepsAllowlist:   stringList(obj["deps_allowlist"]),
+		MaxSessionTTL:   time.Duration(wholeNumber(obj["max_session_ttl_seconds"])) * time.Second,
+		MaxFileBytes:    wholeNumber(obj["max_file_bytes"]),
+		Secrets:         stringList(obj["secrets"]),
+	}, nil
+}
+
+// wholeNumber reads a whole number, such as a byte count or a number of
+// seconds, from a policy result.
+func wholeNumber(value any) int64 {
+	switch v := value.(type) {
+	case json.Number:
+		n, _ := v.Float64()
+		return int64(n)
+	case float64:
+		return int64(v)
+	}
+	return 0
+}
+
+// stringList reads a list of strings from a policy result, returning nil
+// when the policy does not set it and an empty list when it is set empty.
+func stringList(value any) []string {
+	items, ok := value.([]any)
+	if !ok {
+		return nil
+	}
+	out := make([]string, 0, len(items))
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// Invalidate drops the compiled query for ruleset.
+func (e *OPAEvaluator) Invalidate(ruleset string) {
+	e.mu.Lock()
+	delete(e.cache, ruleset)
+	e.mu.Unlock()
 }
 
 func (e *OPAEvaluator) prepare(query string, ruleset string) (rego.PreparedEvalQuery, error) {
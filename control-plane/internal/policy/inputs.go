@@ -0,0 +1,135 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"control-plane/internal/storage"
+)
+
+// DefaultInputLimit is how many inputs an input store keeps per policy when
+// no Limit is set.
+const DefaultInputLimit = 100
+
+// DefaultInputRetention is how long StorageInputStore keeps an input when no
+// Retention is set.
+const DefaultInputRetention = 7 * 24 * time.Hour
+
+// RecordedInput is an input RecordingEvaluator evaluated. InputHash matches
+// it to its entry in the decision log.
+type RecordedInput struct {
+	TenantID  string
+	PolicyID  string
+	InputHash string
+	Input     json.RawMessage
+}
+
+// InputStore keeps recently evaluated inputs so candidate rulesets can be
+// dry-run against them. It is separate from the audit log because inputs
+// carry submitted code, and is only read back through the tenant-scoped
+// dry-run of the policy the inputs were evaluated against. Recent returns up to n of a policy's inputs, oldest
+// first.
+type InputStore interface {
+	Record(ctx context.Context, input RecordedInput) error
+	Recent(ctx context.Context, tenantID string, policyID string, n int) ([]json.RawMessage, error)
+}
+
+// InMemoryInputStore keeps the last Limit inputs of each policy, dropping
+// older ones as new ones are recorded.
+type InMemoryInputStore struct {
+	Limit int
+
+	mu     sync.Mutex
+	inputs map[inputKey][]json.RawMessage
+}
+
+type inputKey struct {
+	tenantID string
+	policyID string
+}
+
+func (s *InMemoryInputStore) Record(ctx context.Context, input RecordedInput) error {
+	_ = ctx
+	limit := s.Limit
+	if limit <= 0 {
+		limit = DefaultInputLimit
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.inputs == nil {
+		s.inputs = make(map[inputKey][]json.RawMessage)
+	}
+	key := inputKey{tenantID: input.TenantID, policyID: input.PolicyID}
+	kept := append(s.inputs[key], input.Input)
+	if len(kept) > limit {
+		kept = append([]json.RawMessage(nil), kept[len(kept)-limit:]...)
+	}
+	s.inputs[key] = kept
+	return nil
+}
+
+func (s *InMemoryInputStore) Recent(ctx context.Context, tenantID string, policyID string, n int) ([]json.RawMessage, error) {
+	_ = ctx
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kept := s.inputs[inputKey{tenantID: tenantID, policyID: policyID}]
+	if len(kept) > n {
+		kept = kept[len(kept)-n:]
+	}
+	return append([]json.RawMessage(nil), kept...), nil
+}
+
+// StorageInputStore keeps recorded inputs in a storage.PolicyInputStore, so
+// they are shared by every instance and survive restarts. It keeps the last
+// Limit inputs of each policy and drops any older than Retention.
+type StorageInputStore struct {
+	Store     storage.PolicyInputStore
+	Limit     int
+	Retention time.Duration
+}
+
+func (s StorageInputStore) Record(ctx context.Context, input RecordedInput) error {
+	if s.Store == nil {
+		return errors.New("missing input store")
+	}
+	now := time.Now().UTC()
+	return s.Store.Record(ctx, storage.PolicyInput{
+		TenantID:  input.TenantID,
+		PolicyID:  input.PolicyID,
+		InputHash: input.InputHash,
+		Input:     input.Input,
+		CreatedAt: now,
+	}, s.limit(), now.Add(-s.retention()))
+}
+
+func (s StorageInputStore) Recent(ctx context.Context, tenantID string, policyID string, n int) ([]json.RawMessage, error) {
+	if s.Store == nil {
+		return nil, errors.New("missing input store")
+	}
+	stored, err := s.Store.Recent(ctx, tenantID, policyID, min(n, s.limit()), time.Now().UTC().Add(-s.retention()))
+	if err != nil {
+		return nil, err
+	}
+	inputs := make([]json.RawMessage, 0, len(stored))
+	for _, item := range stored {
+		inputs = append(inputs, item.Input)
+	}
+	return inputs, nil
+}
+
+func (s StorageInputStore) limit() int {
+	if s.Limit <= 0 {
+		return DefaultInputLimit
+	}
+	return s.Limit
+}
+
+func (s StorageInputStore) retention() time.Duration {
+	if s.Retention <= 0 {
+		return DefaultInputRetention
+	}
+	return s.Retention
+}
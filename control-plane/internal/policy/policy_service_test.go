@@ -2,7 +2,12 @@ package policy
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 )
 
 type mockStore struct {
@@ -38,3 +43,198 @@ func TestInMemoryStoreVersioning(t *testing.T) {
 		t.Fatalf("expected version update, got %v", err)
 	}
 }
+
+func TestOPAEvaluatorDepsAllowlist(t *testing.T) {
+	evaluator := &OPAEvaluator{Resolver: StaticRulesetResolver{RulesetText: `package policy
+allow = true
+deps_allowlist = ["requests", "lodash@4.17.21"]
+`}}
+	decision, err := evaluator.Evaluate(context.Background(), map[string]any{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !decision.AllowsDependencies([]string{"requests==2.32.3", "lodash@4.17.21"}) {
+		t.Fatalf("expected allowlisted dependencies, got %v", decision.DepsAllowlist)
+	}
+	if decision.AllowsDependencies([]string{"lodash@4.17.20"}) {
+		t.Fatalf("expected unlisted version to be denied")
+	}
+}
+
+func TestOPAEvaluatorMaxSessionTTL(t *testing.T) {
+	evaluator := &OPAEvaluator{Resolver: StaticRulesetResolver{RulesetText: `package policy
+allow = true
+max_session_ttl_seconds = 3600
+`}}
+	decision, err := evaluator.Evaluate(context.Background(), map[string]any{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if decision.MaxSessionTTL != time.Hour {
+		t.Fatalf("expected one hour max session ttl, got %v", decision.MaxSessionTTL)
+	}
+}
+
+func TestOPAEvaluatorMaxFileBytes(t *testing.T) {
+	evaluator := &OPAEvaluator{Resolver: StaticRulesetResolver{RulesetText: `package policy
+allow = true
+max_file_bytes = 1048576
+`}}
+	decision, err := evaluator.Evaluate(context.Background(), map[string]any{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if decision.MaxFileBytes != 1<<20 {
+		t.Fatalf("expected 1 MiB file limit, got %d", decision.MaxFileBytes)
+	}
+}
+
+func TestOPAEvaluatorStructuredDecision(t *testing.T) {
+	evaluator := &OPAEvaluator{Resolver: StaticRulesetResolver{RulesetText: `package policy
+allow = true
+limits = {"cpu_millicores": 500, "memory_bytes": 268435456, "pids": 64}
+runtime_class = "firecracker"
+egress_allowlist = []
+secrets = ["API_TOKEN"]
+`}}
+	decision, err := evaluator.Evaluate(context.Background(), map[string]any{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if decision.Limits != (Limits{CPUMillicores: 500, MemoryBytes: 256 << 20, PIDs: 64}) {
+		t.Fatalf("unexpected limits %+v", decision.Limits)
+	}
+	if decision.RuntimeClass != RuntimeClassFirecracker || len(decision.Secrets) != 1 {
+		t.Fatalf("unexpected decision %+v", decision)
+	}
+	if decision.EgressAllowlist == nil || len(decision.EgressAllowlist) != 0 {
+		t.Fatalf("expected an empty, non-nil egress allowlist, got %#v", decision.EgressAllowlist)
+	}
+
+	evaluator = &OPAEvaluator{Resolver: StaticRulesetResolver{RulesetText: "package policy\nallow = true\nruntime_class = \"runc\"\n"}}
+	if _, err := evaluator.Evaluate(context.Background(), map[string]any{}); !errors.Is(err, ErrUnsupportedRuntimeClass) {
+		t.Fatalf("expected unsupported runtime class, got %v", err)
+	}
+}
+
+type subject struct{ tenantID, policyID string }
+
+func (s subject) PolicyRef() (string, string) { return s.tenantID, s.policyID }
+
+func TestStoreRulesetResolverUsesLatestPolicy(t *testing.T) {
+	store := NewInMemoryStore()
+	evaluator := &OPAEvaluator{Resolver: StoreRulesetResolver{Store: store}}
+	service := Service{Store: store, Evaluator: evaluator}
+	ctx := context.Background()
+	input := subject{tenantID: "tenant-1", policyID: "default"}
+
+	if _, err := evaluator.Evaluate(ctx, input); !errors.Is(err, ErrPolicyNotFound) {
+		t.Fatalf("expected missing policy to fail closed, got %v", err)
+	}
+	v1 := "package policy\nallow = false\nreason = \"v1\"\n"
+	if _, err := service.Put(ctx, Policy{ID: Key("tenant-1", "default"), Version: 1, Ruleset: v1}); err != nil {
+		t.Fatalf("upsert v1: %v", err)
+	}
+	decision, err := evaluator.Evaluate(ctx, input)
+	if err != nil || decision.Allowed || decision.Reason != "v1" {
+		t.Fatalf("expected v1 to deny, got %+v (%v)", decision, err)
+	}
+	if _, err := service.Put(ctx, Policy{ID: Key("tenant-1", "default"), Version: 2, Ruleset: "package policy\nallow = true\n"}); err != nil {
+		t.Fatalf("upsert v2: %v", err)
+	}
+	if _, ok := evaluator.cache[v1]; ok {
+		t.Fatalf("expected the replaced ruleset to be dropped from the cache")
+	}
+	decision, err = evaluator.Evaluate(ctx, input)
+	if err != nil || !decision.Allowed {
+		t.Fatalf("expected v2 to allow, got %+v (%v)", decision, err)
+	}
+	if _, err := evaluator.Evaluate(ctx, subject{tenantID: "tenant-2", policyID: "default"}); !errors.Is(err, ErrPolicyNotFound) {
+		t.Fatalf("expected another tenant's policy to stay out of reach, got %v", err)
+	}
+}
+
+func TestServiceAtReturnsVersionInForce(t *testing.T) {
+	ctx := context.Background()
+	store := NewInMemoryStore()
+	start := time.Now().UTC()
+	for _, version := range []Policy{
+		{ID: "t:p", Version: 1, Ruleset: "v1", CreatedAt: start},
+		{ID: "t:p", Version: 2, Ruleset: "v2", CreatedAt: start.Add(time.Hour)},
+	} {
+		if err := store.Upsert(ctx, version); err != nil {
+			t.Fatalf("upsert: %v", err)
+		}
+	}
+	if err := store.Delete(ctx, Policy{ID: "t:p", Version: 3, CreatedAt: start.Add(2 * time.Hour)}); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	service := Service{Store: store}
+	if _, err := service.At(ctx, "t:p", start.Add(-time.Minute)); !errors.Is(err, ErrPolicyNotFound) {
+		t.Fatalf("expected no policy before v1, got %v", err)
+	}
+	if policy, err := service.At(ctx, "t:p", start.Add(90*time.Minute)); err != nil || policy.Ruleset != "v2" {
+		t.Fatalf("expected v2 in force, got %+v (%v)", policy, err)
+	}
+	if _, err := service.At(ctx, "t:p", start.Add(3*time.Hour)); !errors.Is(err, ErrPolicyNotFound) {
+		t.Fatalf("expected no policy after delete, got %v", err)
+	}
+}
+
+func TestDiffBoundsLargeChanges(t *testing.T) {
+	if got := Diff("a\nb\nc\n", "a\nx\nc\n"); got != " a\n-b\n+x\n c\n" {
+		t.Fatalf("unexpected diff %q", got)
+	}
+	var old, new strings.Builder
+	for i := 0; i < 5000; i++ {
+		fmt.Fprintf(&old, "old %d\n", i)
+		fmt.Fprintf(&new, "new %d\n", i)
+	}
+	diff := Diff("keep\n"+old.String(), "keep\n"+new.String())
+	if !strings.HasPrefix(diff, " keep\n-old 0\n-old 1\n") {
+		t.Fatalf("expected the common line kept and old lines removed first, got %q", diff[:40])
+	}
+	if len(diff) > maxDiffBytes || !strings.HasSuffix(diff, "... diff truncated\n") {
+		t.Fatalf("expected a diff cut off at %d bytes, got %d", maxDiffBytes, len(diff))
+	}
+}
+
+func TestInMemoryInputStoreKeepsRecentInputsPerPolicy(t *testing.T) {
+	ctx := context.Background()
+	store := &InMemoryInputStore{Limit: 2}
+	for _, input := range []string{`{"n":1}`, `{"n":2}`, `{"n":3}`} {
+		if err := store.Record(ctx, RecordedInput{TenantID: "tenant-1", PolicyID: "default", Input: json.RawMessage(input)}); err != nil {
+			t.Fatalf("record: %v", err)
+		}
+	}
+	recent, err := store.Recent(ctx, "tenant-1", "default", 5)
+	if err != nil || len(recent) != 2 || string(recent[0]) != `{"n":2}` || string(recent[1]) != `{"n":3}` {
+		t.Fatalf("expected the last two inputs, got %s (%v)", recent, err)
+	}
+	if recent, _ := store.Recent(ctx, "tenant-1", "default", 1); len(recent) != 1 || string(recent[0]) != `{"n":3}` {
+		t.Fatalf("expected the latest input, got %s", recent)
+	}
+	if recent, _ := store.Recent(ctx, "tenant-2", "default", 5); len(recent) != 0 {
+		t.Fatalf("expected no inputs for another tenant, got %s", recent)
+	}
+}
+
+func TestInMemoryDecisionLogKeepsRecentDecisions(t *testing.T) {
+	ctx := context.Background()
+	log := &InMemoryDecisionLog{Size: 3}
+	base := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i := 1; i <= 4; i++ {
+		if err := log.Append(ctx, DecisionRecord{TenantID: "tenant-1", PolicyID: "default", PolicyVersion: i, Time: base.Add(time.Duration(i) * time.Second)}); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	service := Service{Decisions: log}
+	records, err := service.ListDecisions(ctx, Key("tenant-1", "default"), DecisionQuery{})
+	if err != nil || len(records) != 3 || records[0].PolicyVersion != 2 || records[2].PolicyVersion != 4 {
+		t.Fatalf("expected the last three decisions oldest first, got %+v (%v)", records, err)
+	}
+	records, _ = service.ListDecisions(ctx, Key("tenant-1", "default"), DecisionQuery{Before: base.Add(4 * time.Second), Limit: 1})
+	if len(records) != 1 || records[0].PolicyVersion != 3 {
+		t.Fatalf("expected the latest decision before the bound, got %+v", records)
+	}
+}
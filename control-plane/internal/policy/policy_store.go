@@ -3,28 +3,60 @@ package policy
 import (
 	"context"
 	"errors"
+	"sort"
+	"strings"
 	"sync"
+	"time"
+
+	"control-plane/internal/storage"
 )
 
+// Policy is one version of a policy. Deleted marks the tombstone version
+// Delete appends, so that version numbers are never reused.
 type Policy struct {
-	ID      string
-	Version int
-	Ruleset string
+	ID        string
+	Version   int
+	Ruleset   string
+	Deleted   bool
+	CreatedAt time.Time
+	CreatedBy string
 }
 
+// Store keeps every version of each policy, keyed by Key. Upsert and
+// Delete only accept versions newer than all stored ones; Get and List
+// return the current version of undeleted policies, and Versions the whole
+// history oldest first.
 type Store interface {
 	Upsert(ctx context.Context, policy Policy) error
+	Delete(ctx context.Context, tombstone Policy) error
+	Get(ctx context.Context, id string) (Policy, error)
+	List(ctx context.Context, tenantID string) ([]Policy, error)
+	Versions(ctx context.Context, id string) ([]Policy, error)
+}
+
+var (
+	ErrStalePolicyVersion = errors.New("stale policy version")
+	ErrPolicyNotFound     = errors.New("policy not found")
+)
+
+// Key is the store ID of a tenant's named policy.
+func Key(tenantID string, name string) string {
+	return tenantID + ":" + name
 }
 
-var ErrStalePolicyVersion = errors.New("stale policy version")
+// SplitKey returns the tenant and policy name of a store ID built by Key.
+func SplitKey(id string) (tenantID string, name string) {
+	tenantID, name, _ = strings.Cut(id, ":")
+	return tenantID, name
+}
 
 type InMemoryStore struct {
-	mu      sync.RWMutex
-	entries map[string]Policy
+	mu       sync.RWMutex
+	versions map[string][]Policy
 }
 
 func NewInMemoryStore() *InMemoryStore {
-	return &InMemoryStore{entries: map[string]Policy{}}
+	return &InMemoryStore{versions: map[string][]Policy{}}
 }
 
 func (s *InMemoryStore) Upsert(ctx context.Context, policy Policy) error {
@@ -38,13 +70,171 @@ func (s *InMemoryStore) Upsert(ctx context.Context, policy Policy) error {
 	if policy.Ruleset == "" {
 		return errors.New("missing ruleset")
 	}
+	policy.Deleted = false
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.appendLocked(policy)
+}
+
+func (s *InMemoryStore) Delete(ctx context.Context, tombstone Policy) error {
+	_ = ctx
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	if existing, ok := s.entries[policy.ID]; ok {
-		if policy.Version <= existing.Version {
-			return ErrStalePolicyVersion
+	if _, ok := s.currentLocked(tombstone.ID); !ok {
+		return ErrPolicyNotFound
+	}
+	tombstone.Ruleset = ""
+	tombstone.Deleted = true
+	return s.appendLocked(tombstone)
+}
+
+func (s *InMemoryStore) Get(ctx context.Context, id string) (Policy, error) {
+	_ = ctx
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	policy, ok := s.currentLocked(id)
+	if !ok {
+		return Policy{}, ErrPolicyNotFound
+	}
+	return policy, nil
+}
+
+func (s *InMemoryStore) List(ctx context.Context, tenantID string) ([]Policy, error) {
+	_ = ctx
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var policies []Policy
+	for id := range s.versions {
+		if !strings.HasPrefix(id, Key(tenantID, "")) {
+			continue
+		}
+		if policy, ok := s.currentLocked(id); ok {
+			policies = append(policies, policy)
 		}
 	}
-	s.entries[policy.ID] = policy
+	sort.Slice(policies, func(i, j int) bool { return policies[i].ID < policies[j].ID })
+	return policies, nil
+}
+
+func (s *InMemoryStore) Versions(ctx context.Context, id string) ([]Policy, error) {
+	_ = ctx
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	versions := s.versions[id]
+	if len(versions) == 0 {
+		return nil, ErrPolicyNotFound
+	}
+	return append([]Policy(nil), versions...), nil
+}
+
+func (s *InMemoryStore) appendLocked(policy Policy) error {
+	versions := s.versions[policy.ID]
+	if len(versions) > 0 && policy.Version <= versions[len(versions)-1].Version {
+		return ErrStalePolicyVersion
+	}
+	if policy.CreatedAt.IsZero() {
+		policy.CreatedAt = time.Now().UTC()
+	}
+	s.versions[policy.ID] = append(versions, policy)
 	return nil
 }
+
+func (s *InMemoryStore) currentLocked(id string) (Policy, bool) {
+	versions := s.versions[id]
+	if len(versions) == 0 || versions[len(versions)-1].Deleted {
+		return Policy{}, false
+	}
+	return versions[len(versions)-1], true
+}
+
+// StorageStore keeps policies in a storage.PolicyStore.
+type StorageStore struct {
+	Store storage.PolicyStore
+}
+
+func (s StorageStore) Upsert(ctx context.Context, policy Policy) error {
+	if s.Store == nil {
+		return errors.New("missing policy store")
+	}
+	return storageError(s.Store.Upsert(ctx, toStorage(policy)))
+}
+
+func (s StorageStore) Delete(ctx context.Context, tombstone Policy) error {
+	if s.Store == nil {
+		return errors.New("missing policy store")
+	}
+	return storageError(s.Store.Delete(ctx, toStorage(tombstone)))
+}
+
+func (s StorageStore) Get(ctx context.Context, id string) (Policy, error) {
+	if s.Store == nil {
+		return Policy{}, errors.New("missing policy store")
+	}
+	stored, err := s.Store.Get(ctx, id)
+	if err != nil {
+		return Policy{}, storageError(err)
+	}
+	return fromStorage(stored), nil
+}
+
+func (s StorageStore) List(ctx context.Context, tenantID string) ([]Policy, error) {
+	if s.Store == nil {
+		return nil, errors.New("missing policy store")
+	}
+	stored, err := s.Store.List(ctx, Key(tenantID, ""))
+	if err != nil {
+		return nil, err
+	}
+	policies := make([]Policy, 0, len(stored))
+	for _, item := range stored {
+		policies = append(policies, fromStorage(item))
+	}
+	return policies, nil
+}
+
+func (s StorageStore) Versions(ctx context.Context, id string) ([]Policy, error) {
+	if s.Store == nil {
+		return nil, errors.New("missing policy store")
+	}
+	stored, err := s.Store.Versions(ctx, id)
+	if err != nil {
+		return nil, storageError(err)
+	}
+	versions := make([]Policy, 0, len(stored))
+	for _, item := range stored {
+		versions = append(versions, fromStorage(item))
+	}
+	return versions, nil
+}
+
+func storageError(err error) error {
+	switch {
+	case errors.Is(err, storage.ErrStalePolicyVersion):
+		return ErrStalePolicyVersion
+	case errors.Is(err, storage.ErrPolicyNotFound):
+		return ErrPolicyNotFound
+	}
+	return err
+}
+
+func toStorage(policy Policy) storage.Policy {
+	return storage.Policy{
+		ID:        policy.ID,
+		Version:   policy.Version,
+		Ruleset:   policy.Ruleset,
+		Deleted:   policy.Deleted,
+		CreatedAt: policy.CreatedAt,
+		CreatedBy: policy.CreatedBy,
+	}
+}
+
+func fromStorage(policy storage.Policy) Policy {
+	return Policy{
+		ID:        policy.ID,
+		Version:   policy.Version,
+		Ruleset:   policy.Ruleset,
+		Deleted:   policy.Deleted,
+		CreatedAt: policy.CreatedAt,
+		CreatedBy: policy.CreatedBy,
+	}
+}
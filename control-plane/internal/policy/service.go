@@ -0,0 +1,276 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"control-plane/internal/audit"
+)
+
+var ErrPolicyVersionNotFound = errors.New("policy version not found")
+
+// Service changes stored policies. Each change is a new version, is audited
+// with the acting agent and a diff of the ruleset, and drops the replaced
+// ruleset's compiled query from Evaluator.
+type Service struct {
+	Store     Store
+	Evaluator *OPAEvaluator
+	Logger    audit.Logger
+	// Inputs is where DryRun finds the inputs RecordingEvaluator recorded.
+	Inputs InputStore
+	// Decisions is the decision log RecordingEvaluator writes.
+	Decisions DecisionLog
+}
+
+// Put stores policy as its next version when policy.Version is zero.
+func (s Service) Put(ctx context.Context, policy Policy) (Policy, error) {
+	previous, err := s.Store.Get(ctx, policy.ID)
+	if err != nil && !errors.Is(err, ErrPolicyNotFound) {
+		return Policy{}, err
+	}
+	action := "policy_updated"
+	if errors.Is(err, ErrPolicyNotFound) {
+		action = "policy_created"
+	}
+	if policy.Version == 0 {
+		if policy.Version, err = s.nextVersion(ctx, policy.ID); err != nil {
+			return Policy{}, err
+		}
+	}
+	policy.Deleted = false
+	policy.CreatedAt = time.Now().UTC()
+	if err := s.Store.Upsert(ctx, policy); err != nil {
+		return Policy{}, err
+	}
+	s.changed(ctx, action, previous, policy, "")
+	return policy, nil
+}
+
+func (s Service) Get(ctx context.Context, id string) (Policy, error) {
+	return s.Store.Get(ctx, id)
+}
+
+// At returns the version of a policy that was in force at t.
+func (s Service) At(ctx context.Context, id string, t time.Time) (Policy, error) {
+	versions, err := s.Store.Versions(ctx, id)
+	if err != nil {
+		return Policy{}, err
+	}
+	var found Policy
+	for _, version := range versions {
+		if version.CreatedAt.After(t) {
+			break
+		}
+		found = version
+	}
+	if found.Version == 0 || found.Deleted {
+		return Policy{}, ErrPolicyNotFound
+	}
+	return found, nil
+}
+
+func (s Service) List(ctx context.Context, tenantID string) ([]Policy, error) {
+	return s.Store.List(ctx, tenantID)
+}
+
+func (s Service) Versions(ctx context.Context, id string) ([]Policy, error) {
+	return s.Store.Versions(ctx, id)
+}
+
+func (s Service) ListDecisions(ctx context.Context, id string, query DecisionQuery) ([]DecisionRecord, error) {
+	if s.Decisions == nil {
+		return nil, errors.New("missing decision log")
+	}
+	if query.Limit <= 0 {
+		query.Limit = DefaultDecisionLimit
+	}
+	query.Limit = min(query.Limit, MaxDecisionLimit)
+	tenantID, name := SplitKey(id)
+	return s.Decisions.List(ctx, tenantID, name, query)
+}
+
+// Rollback makes the ruleset of an earlier version current again as a new
+// version, which also restores a deleted policy.
+func (s Service) Rollback(ctx context.Context, id string, version int, actor string) (Policy, error) {
+	versions, err := s.Store.Versions(ctx, id)
+	if err != nil {
+		return Policy{}, err
+	}
+	var target *Policy
+	for i := range versions {
+		if versions[i].Version == version && !versions[i].Deleted {
+			target = &versions[i]
+		}
+	}
+	if target == nil {
+		return Policy{}, ErrPolicyVersionNotFound
+	}
+	previous := versions[len(versions)-1]
+	policy := Policy{
+		ID:        id,
+		Version:   previous.Version + 1,
+		Ruleset:   target.Ruleset,
+		CreatedAt: time.Now().UTC(),
+		CreatedBy: actor,
+	}
+	if err := s.Store.Upsert(ctx, policy); err != nil {
+		return Policy{}, err
+	}
+	s.changed(ctx, "policy_rolled_back", previous, policy, fmt.Sprintf(" rollback_to=%d", version))
+	return policy, nil
+}
+
+func (s Service) Delete(ctx context.Context, id string, actor string) error {
+	previous, err := s.Store.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	tombstone := Policy{
+		ID:        id,
+		Version:   previous.Version + 1,
+		Deleted:   true,
+		CreatedAt: time.Now().UTC(),
+		CreatedBy: actor,
+	}
+	if err := s.Store.Delete(ctx, tombstone); err != nil {
+		return err
+	}
+	s.changed(ctx, "policy_deleted", previous, tombstone, "")
+	return nil
+}
+
+func (s Service) nextVersion(ctx context.Context, id string) (int, error) {
+	versions, err := s.Store.Versions(ctx, id)
+	if errors.Is(err, ErrPolicyNotFound) {
+		return 1, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return versions[len(versions)-1].Version + 1, nil
+}
+
+func (s Service) changed(ctx context.Context, action string, previous Policy, current Policy, note string) {
+	if s.Evaluator != nil && previous.Ruleset != "" && previous.Ruleset != current.Ruleset {
+		s.Evaluator.Invalidate(previous.Ruleset)
+	}
+	if s.Logger == nil {
+		return
+	}
+	tenantID, name := SplitKey(current.ID)
+	_ = s.Logger.Log(ctx, audit.Event{
+		TenantID: tenantID,
+		ActorID:  current.CreatedBy,
+		Action:   action,
+		Outcome:  "ok",
+		Time:     current.CreatedAt,
+		Detail:   fmt.Sprintf("policy=%s version=%d previous_version=%d%s\n%s", name, current.Version, previous.Version, note, Diff(previous.Ruleset, current.Ruleset)),
+	})
+}
+
+const (
+	// maxDiffCells bounds the table Diff matches changed lines with. Larger
+	// changes are shown as every old line removed and every new one added.
+	maxDiffCells = 1 << 20
+	// maxDiffBytes caps the diff kept in an audit event.
+	maxDiffBytes = 64 << 10
+)
+
+// Diff is a line diff from old to new: removed lines start with "-", added
+// lines with "+" and unchanged lines with a space. It is cut off after
+// maxDiffBytes.
+func Diff(old string, new string) string {
+	a := splitLines(old)
+	b := splitLines(new)
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	var out diffBuilder
+	for _, line := range a[:prefix] {
+		out.line(' ', line)
+	}
+	diffChanged(&out, a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])
+	for _, line := range a[len(a)-suffix:] {
+		out.line(' ', line)
+	}
+	return out.String()
+}
+
+// diffChanged writes the diff of the lines between the common prefix and
+// suffix.
+func diffChanged(out *diffBuilder, a []string, b []string) {
+	if (len(a)+1)*(len(b)+1) > maxDiffCells {
+		for _, line := range a {
+			out.line('-', line)
+		}
+		for _, line := range b {
+			out.line('+', line)
+		}
+		return
+	}
+	// common[i][j] is the longest common subsequence of a[i:] and b[j:].
+	common := make([][]int, len(a)+1)
+	for i := range common {
+		common[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			out.line(' ', a[i])
+			i++
+			j++
+		case i < len(a) && (j == len(b) || common[i+1][j] >= common[i][j+1]):
+			out.line('-', a[i])
+			i++
+		default:
+			out.line('+', b[j])
+			j++
+		}
+	}
+}
+
+const diffTruncated = "... diff truncated\n"
+
+type diffBuilder struct {
+	strings.Builder
+	truncated bool
+}
+
+func (d *diffBuilder) line(mark byte, text string) {
+	if d.truncated {
+		return
+	}
+	if d.Len()+len(text)+2 > maxDiffBytes-len(diffTruncated) {
+		d.truncated = true
+		d.WriteString(diffTruncated)
+		return
+	}
+	d.WriteByte(mark)
+	d.WriteString(text)
+	d.WriteByte('\n')
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
@@ -0,0 +1,79 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"control-plane/internal/audit"
+	"control-plane/internal/policy"
+	"control-plane/pkg/client"
+)
+
+var (
+	ErrSessionNotActive   = errors.New("session is not active")
+	ErrFileTransferDenied = errors.New("policy denied file transfer")
+)
+
+// UploadFile stores body at path in the session workspace, limited to the
+// policy's max_file_bytes.
+func (s Service) UploadFile(ctx context.Context, session Session, path string, body io.Reader) (client.SessionFile, error) {
+	maxBytes, err := s.fileLimit(ctx, session)
+	if err != nil {
+		return client.SessionFile{}, err
+	}
+	file, err := s.Client.UploadSessionFile(ctx, session.ID, path, body, maxBytes)
+	s.logFile(ctx, session, "session_file_uploaded", path, err)
+	if err != nil {
+		return client.SessionFile{}, err
+	}
+	return file, nil
+}
+
+// DownloadFile opens path in the session workspace. The caller closes the
+// returned body.
+func (s Service) DownloadFile(ctx context.Context, session Session, path string) (io.ReadCloser, client.SessionFile, error) {
+	maxBytes, err := s.fileLimit(ctx, session)
+	if err != nil {
+		return nil, client.SessionFile{}, err
+	}
+	body, file, err := s.Client.DownloadSessionFile(ctx, session.ID, path, maxBytes)
+	s.logFile(ctx, session, "session_file_downloaded", path, err)
+	if err != nil {
+		return nil, client.SessionFile{}, err
+	}
+	return body, file, nil
+}
+
+func (s Service) fileLimit(ctx context.Context, session Session) (int64, error) {
+	if session.Status != StatusActive {
+		return 0, ErrSessionNotActive
+	}
+	decision, err := s.Enforcer.Decide(ctx, session)
+	if err != nil {
+		return 0, err
+	}
+	if !decision.Allowed {
+		return 0, fmt.Errorf("%w: %w", ErrFileTransferDenied, &policy.DeniedError{Reason: decision.Reason})
+	}
+	return decision.MaxFileBytes, nil
+}
+
+func (s Service) logFile(ctx context.Context, session Session, action string, path string, err error) {
+	if s.Logger == nil {
+		return
+	}
+	outcome := "ok"
+	if err != nil {
+		outcome = "error"
+	}
+	_ = s.Logger.Log(ctx, audit.Event{
+		TenantID: session.TenantID,
+		Action:   action,
+		Outcome:  outcome,
+		Time:     time.Now(),
+		Detail:   session.ID + ":" + path,
+	})
+}
@@ -1,6 +1,10 @@
 package sessions
 
-import "time"
+import (
+	"time"
+
+	"control-plane/internal/storage"
+)
 
 type Status string
 
@@ -11,19 +15,32 @@ const (
 )
 
 type Session struct {
-	ID           string
-	TenantID     string
-	AgentID      string
-	PolicyID     string
-	Runtime      string
+	ID       string
+	TenantID string
+	AgentID  string
+	PolicyID string
+	Runtime  string
+	Deps     []string
+	// SnapshotID, on creation, restores the new session from a snapshot.
+	SnapshotID   string
 	TTL          time.Duration
 	ExpiresAt    time.Time
+	MaxExpiresAt time.Time
 	Status       Status
 	RuntimeID    string
+	CreatedAt    time.Time
 	LastActivity time.Time
 	Steps        []SessionStep
 }
 
+func (s Session) PolicyRef() (string, string) {
+	return s.TenantID, s.PolicyID
+}
+
+func (s Session) WorkloadID() string {
+	return s.ID
+}
+
 type SessionStep struct {
 	ID         string
 	SessionID  string
@@ -32,4 +49,5 @@ type SessionStep struct {
 	Status     string
 	StartedAt  time.Time
 	FinishedAt time.Time
+	Usage      storage.ResourceUsage
 }
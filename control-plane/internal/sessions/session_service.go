@@ -3,12 +3,16 @@ package sessions
 import (
 	"context"
 	"errors"
+	"log"
+	"strings"
 	"time"
 
 	"control-plane/internal/audit"
 	"control-plane/internal/orchestration"
+	"control-plane/internal/policy"
 	"control-plane/internal/storage"
 	"control-plane/pkg/client"
+	"shared/sessionagent"
 )
 
 type Service struct {
@@ -18,10 +22,34 @@ type Service struct {
 	Logger   audit.Logger
 }
 
+// StepRequest is one step to run in a session. A zero Timeout leaves the
+// session agent's default step timeout.
+type StepRequest struct {
+	Command string
+	Timeout time.Duration
+}
+
 type StepRunner interface {
-	RunStep(ctx context.Context, sessionID string, command string) (StepResult, error)
+	RunStep(ctx context.Context, sessionID string, req StepRequest) (StepResult, error)
+}
+
+// StepStreamer is implemented by step runners that can relay output while
+// the step runs.
+type StepStreamer interface {
+	StreamStep(ctx context.Context, sessionID string, req StepRequest, emit func(stream string, data string)) (StepResult, error)
+}
+
+// StepInterrupter is implemented by step runners that can stop a running
+// step.
+type StepInterrupter interface {
+	Interrupt(ctx context.Context, sessionID string) (bool, error)
 }
 
+var (
+	ErrStreamingUnsupported = errors.New("step runner does not support streaming")
+	ErrInterruptUnsupported = errors.New("session does not support interrupts")
+)
+
 type StepStore interface {
 	AppendStep(ctx context.Context, step SessionStep) error
 	ListSteps(ctx context.Context, sessionID string) ([]SessionStep, error)
@@ -31,12 +59,19 @@ type StepService struct {
 	Runner StepRunner
 	Store  StepStore
 	Logger audit.Logger
+	// Sessions, when set, has each step extend its session's TTL.
+	Sessions storage.SessionStore
 }
 
 type StepResult struct {
 	ID     string
+	Status string
 	Stdout string
 	Stderr string
+	// StateLost reports that the session interpreter was restarted before
+	// or during the step, dropping the variables earlier steps defined.
+	StateLost bool
+	Usage     storage.ResourceUsage
 }
 
 func (s Service) CreateSession(ctx context.Context, session Session) (string, error) {
@@ -46,25 +81,73 @@ func (s Service) CreateSession(ctx context.Context, session Session) (string, er
 	if session.Status == "" {
 		session.Status = StatusActive
 	}
-	if ok, err := s.Enforcer.Evaluate(ctx, session); err != nil {
+	if session.SnapshotID != "" {
+		snapshot, err := s.snapshot(ctx, session.TenantID, session.SnapshotID)
+		if err != nil {
+			return "", err
+		}
+		if session.Runtime == "" {
+			session.Runtime = snapshot.Runtime
+		}
+		if !strings.EqualFold(session.Runtime, snapshot.Runtime) {
+			return "", ErrSnapshotRuntimeMismatch
+		}
+	}
+	decision, err := s.Enforcer.Decide(ctx, session)
+	if err != nil {
 		return "", err
-	} else if !ok {
-		return "", errors.New("policy denied session")
+	}
+	if !decision.Allowed {
+		return "", &policy.DeniedError{Reason: decision.Reason}
+	}
+	if !decision.AllowsDependencies(session.Deps) {
+		return "", orchestration.ErrDependencyNotAllowed
+	}
+	now := time.Now().UTC()
+	if session.TTL <= 0 {
+		session.TTL = defaultTTL
+	}
+	if max := decision.MaxSessionTTL; max > 0 {
+		if session.TTL > max {
+			session.TTL = max
+		}
+		session.MaxExpiresAt = now.Add(max)
 	}
 	if session.ExpiresAt.IsZero() {
-		session.ExpiresAt = sessionExpires(session, time.Now())
+		session.ExpiresAt = sessionExpires(session, now)
 	}
 	resp, err := s.Client.StartSession(ctx, client.SessionCreateRequest{
-		SessionID:    session.ID,
-		PolicyID:     session.PolicyID,
-		WorkspaceRef: session.ID,
-		Runtime:      session.Runtime,
+		SessionID:       session.ID,
+		TenantID:        session.TenantID,
+		PolicyID:        session.PolicyID,
+		WorkspaceRef:    session.ID,
+		Runtime:         session.Runtime,
+		Deps:            session.Deps,
+		DepsAllowlist:   decision.DepsAllowlist,
+		SnapshotID:      session.SnapshotID,
+		Limits:          orchestration.DataPlaneLimits(decision.Limits),
+		RuntimeClass:    decision.RuntimeClass,
+		EgressAllowList: decision.EgressAllowlist,
+		Secrets:         decision.Secrets,
 	})
 	if err != nil {
 		return "", err
 	}
 	session.RuntimeID = resp.RuntimeID
-	if err := s.Store.Create(ctx, storage.Session{ID: session.ID, Status: string(session.Status), RuntimeID: session.RuntimeID}); err != nil {
+	if err := s.Store.Create(ctx, storage.Session{
+		ID:           session.ID,
+		TenantID:     session.TenantID,
+		AgentID:      session.AgentID,
+		PolicyID:     session.PolicyID,
+		Runtime:      session.Runtime,
+		Status:       string(session.Status),
+		RuntimeID:    session.RuntimeID,
+		TTLSeconds:   int(session.TTL / time.Second),
+		CreatedAt:    now,
+		LastActivity: now,
+		ExpiresAt:    session.ExpiresAt,
+		MaxExpiresAt: session.MaxExpiresAt,
+	}); err != nil {
 		return "", err
 	}
 	if err := s.Store.UpdateStatus(ctx, session.ID, string(StatusActive)); err != nil {
@@ -82,37 +165,186 @@ func (s Service) CreateSession(ctx context.Context, session Session) (string, er
 	return resp.RuntimeID, nil
 }
 
+func (s Service) Get(ctx context.Context, id string) (Session, error) {
+	if id == "" {
+		return Session{}, errors.New("missing session id")
+	}
+	stored, err := s.Store.Get(ctx, id)
+	if err != nil {
+		return Session{}, err
+	}
+	return fromStorage(stored), nil
+}
+
+func (s Service) List(ctx context.Context, tenantID string) ([]Session, error) {
+	stored, err := s.Store.ListByTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Session, 0, len(stored))
+	for _, session := range stored {
+		result = append(result, fromStorage(session))
+	}
+	return result, nil
+}
+
+// Terminate ends a session: the data plane tears down its runtime and the
+// session is marked terminated. Sessions that already ended are returned
+// unchanged.
+func (s Service) Terminate(ctx context.Context, id string) (Session, error) {
+	session, err := s.Get(ctx, id)
+	if err != nil {
+		return Session{}, err
+	}
+	if session.Status != StatusActive {
+		return session, nil
+	}
+	if err := s.Client.TerminateSession(ctx, session.ID); err != nil {
+		return Session{}, err
+	}
+	if err := s.Store.UpdateStatus(ctx, session.ID, string(StatusTerminated)); err != nil {
+		return Session{}, err
+	}
+	session.Status = StatusTerminated
+	if s.Logger != nil {
+		_ = s.Logger.Log(ctx, audit.Event{
+			TenantID: session.TenantID,
+			Action:   "session_terminated",
+			Outcome:  "ok",
+			Time:     time.Now(),
+			Detail:   session.ID,
+		})
+	}
+	return session, nil
+}
+
+func fromStorage(session storage.Session) Session {
+	return Session{
+		ID:           session.ID,
+		TenantID:     session.TenantID,
+		AgentID:      session.AgentID,
+		PolicyID:     session.PolicyID,
+		Runtime:      session.Runtime,
+		TTL:          time.Duration(session.TTLSeconds) * time.Second,
+		Status:       Status(session.Status),
+		RuntimeID:    session.RuntimeID,
+		CreatedAt:    session.CreatedAt,
+		LastActivity: session.LastActivity,
+		ExpiresAt:    session.ExpiresAt,
+		MaxExpiresAt: session.MaxExpiresAt,
+	}
+}
+
+const defaultTTL = 15 * time.Minute
+
+// sessionExpires is when a session idle since now expires: one TTL later,
+// but no later than the policy's cap on the session's lifetime.
 func sessionExpires(session Session, now time.Time) time.Time {
 	ttl := session.TTL
 	if ttl <= 0 {
-		ttl = 15 * time.Minute
+		ttl = defaultTTL
+	}
+	expires := now.Add(ttl)
+	if !session.MaxExpiresAt.IsZero() && expires.After(session.MaxExpiresAt) {
+		return session.MaxExpiresAt
 	}
-	return now.Add(ttl)
+	return expires
 }
 
-func (s StepService) Run(ctx context.Context, sessionID string, command string) (StepResult, error) {
+// extendSession records activity on an active session and pushes its
+// expiry out by another TTL.
+func extendSession(ctx context.Context, store storage.SessionStore, id string, now time.Time) error {
+	stored, err := store.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	session := fromStorage(stored)
+	if session.Status != StatusActive {
+		return nil
+	}
+	return store.Touch(ctx, id, now, sessionExpires(session, now))
+}
+
+func (s StepService) Run(ctx context.Context, sessionID string, req StepRequest) (StepResult, error) {
 	if sessionID == "" {
 		return StepResult{}, errors.New("missing session id")
 	}
-	if command == "" {
+	if req.Command == "" {
 		return StepResult{}, errors.New("missing command")
 	}
 	if s.Runner == nil {
 		return StepResult{}, errors.New("missing runner")
 	}
-	result, err := s.Runner.RunStep(ctx, sessionID, command)
+	started := time.Now()
+	result, err := s.Runner.RunStep(ctx, sessionID, req)
+	if err != nil {
+		return StepResult{}, err
+	}
+	if result.Status == "" {
+		result.Status = sessionagent.StepStatusCompleted
+	}
+	s.record(ctx, sessionID, req.Command, started, result)
+	return result, nil
+}
+
+// Stream runs a step like Run, passing output chunks to emit as the data
+// plane relays them. The step is recorded once it has finished.
+func (s StepService) Stream(ctx context.Context, sessionID string, req StepRequest, emit func(stream string, data string)) (StepResult, error) {
+	if sessionID == "" {
+		return StepResult{}, errors.New("missing session id")
+	}
+	if req.Command == "" {
+		return StepResult{}, errors.New("missing command")
+	}
+	streamer, ok := s.Runner.(StepStreamer)
+	if !ok {
+		return StepResult{}, ErrStreamingUnsupported
+	}
+	started := time.Now()
+	result, err := streamer.StreamStep(ctx, sessionID, req, emit)
 	if err != nil {
 		return StepResult{}, err
 	}
+	if result.Status == "" {
+		result.Status = sessionagent.StepStatusCompleted
+	}
+	s.record(ctx, sessionID, req.Command, started, result)
+	return result, nil
+}
+
+// Interrupt stops the step running in a session and reports whether one
+// was running.
+func (s StepService) Interrupt(ctx context.Context, sessionID string) (bool, error) {
+	interrupter, ok := s.Runner.(StepInterrupter)
+	if !ok {
+		return false, ErrInterruptUnsupported
+	}
+	return interrupter.Interrupt(ctx, sessionID)
+}
+
+func (s StepService) List(ctx context.Context, sessionID string) ([]SessionStep, error) {
+	if s.Store == nil {
+		return nil, errors.New("missing step store")
+	}
+	return s.Store.ListSteps(ctx, sessionID)
+}
+
+func (s StepService) record(ctx context.Context, sessionID string, command string, started time.Time, result StepResult) {
 	if s.Store != nil {
 		_ = s.Store.AppendStep(ctx, SessionStep{
 			ID:        result.ID,
 			SessionID: sessionID,
 			Command:   command,
-			Status:    "accepted",
-			StartedAt: time.Now(),
+			Status:    result.Status,
+			StartedAt: started,
+			Usage:     result.Usage,
 		})
 	}
+	if s.Sessions != nil {
+		if err := extendSession(ctx, s.Sessions, sessionID, time.Now().UTC()); err != nil {
+			log.Printf("sessions: extend ttl session_id=%s error: %v", sessionID, err)
+		}
+	}
 	if s.Logger != nil {
 		_ = s.Logger.Log(ctx, audit.Event{
 			Action:  "session_step_accepted",
@@ -121,5 +353,4 @@ func (s StepService) Run(ctx context.Context, sessionID string, command string)
 			Detail:  result.ID,
 		})
 	}
-	return result, nil
 }
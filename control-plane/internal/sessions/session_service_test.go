@@ -36,6 +36,26 @@ func (m *mockSessionStore) UpdateStatus(ctx context.Context, id string, status s
 	return nil
 }
 
+func (m *mockSessionStore) ListByTenant(ctx context.Context, tenantID string) ([]storage.Session, error) {
+	_ = ctx
+	_ = tenantID
+	return nil, nil
+}
+
+func (m *mockSessionStore) ListExpired(ctx context.Context, before time.Time) ([]storage.Session, error) {
+	_ = ctx
+	_ = before
+	return nil, nil
+}
+
+func (m *mockSessionStore) Touch(ctx context.Context, id string, lastActivity time.Time, expiresAt time.Time) error {
+	_ = ctx
+	_ = id
+	_ = lastActivity
+	_ = expiresAt
+	return nil
+}
+
 type storageError string
 
 func (e storageError) Error() string { return string(e) }
@@ -104,3 +124,136 @@ func TestSessionPolicyDenied(t *testing.T) {
 		t.Fatalf("expected policy denial error")
 	}
 }
+
+func TestSessionExpiryCappedByPolicy(t *testing.T) {
+	now := time.Now()
+	session := Session{TTL: 10 * time.Minute, MaxExpiresAt: now.Add(5 * time.Minute)}
+	if expires := sessionExpires(session, now); !expires.Equal(session.MaxExpiresAt) {
+		t.Fatalf("expected expiry capped at %s, got %s", session.MaxExpiresAt, expires)
+	}
+}
+
+type memorySessionStore struct {
+	mockSessionStore
+	sessions map[string]storage.Session
+}
+
+func (m *memorySessionStore) Get(ctx context.Context, id string) (storage.Session, error) {
+	_ = ctx
+	session, ok := m.sessions[id]
+	if !ok {
+		return storage.Session{}, storageError("session not found")
+	}
+	return session, nil
+}
+
+func (m *memorySessionStore) UpdateStatus(ctx context.Context, id string, status string) error {
+	_ = ctx
+	session := m.sessions[id]
+	session.Status = status
+	m.sessions[id] = session
+	return nil
+}
+
+func (m *memorySessionStore) ListExpired(ctx context.Context, before time.Time) ([]storage.Session, error) {
+	_ = ctx
+	var expired []storage.Session
+	for _, session := range m.sessions {
+		if session.Status == string(StatusActive) && session.ExpiresAt.Before(before) {
+			expired = append(expired, session)
+		}
+	}
+	return expired, nil
+}
+
+func (m *memorySessionStore) Touch(ctx context.Context, id string, lastActivity time.Time, expiresAt time.Time) error {
+	_ = ctx
+	session := m.sessions[id]
+	session.LastActivity = lastActivity
+	session.ExpiresAt = expiresAt
+	m.sessions[id] = session
+	return nil
+}
+
+type stubStepRunner struct{}
+
+func (stubStepRunner) RunStep(ctx context.Context, sessionID string, req StepRequest) (StepResult, error) {
+	_ = ctx
+	_ = req
+	return StepResult{ID: sessionID + "-step", Status: "completed"}, nil
+}
+
+func TestStepExtendsSessionTTL(t *testing.T) {
+	now := time.Now().UTC()
+	store := &memorySessionStore{sessions: map[string]storage.Session{
+		"s-1": {ID: "s-1", Status: string(StatusActive), TTLSeconds: 600, ExpiresAt: now.Add(time.Minute), MaxExpiresAt: now.Add(5 * time.Minute)},
+	}}
+	stepper := StepService{Runner: stubStepRunner{}, Sessions: store}
+	if _, err := stepper.Run(context.Background(), "s-1", StepRequest{Command: "print(1)"}); err != nil {
+		t.Fatalf("run step: %v", err)
+	}
+	got := store.sessions["s-1"]
+	if got.LastActivity.Before(now) {
+		t.Fatalf("expected last activity to be recorded, got %s", got.LastActivity)
+	}
+	if !got.ExpiresAt.Equal(now.Add(5 * time.Minute)) {
+		t.Fatalf("expected expiry extended up to the policy cap, got %s", got.ExpiresAt.Sub(now))
+	}
+}
+
+type recordingLock struct {
+	acquire bool
+	names   []string
+}
+
+func (l *recordingLock) WithLock(ctx context.Context, name string, fn func(context.Context) error) (bool, error) {
+	l.names = append(l.names, name)
+	if !l.acquire {
+		return false, nil
+	}
+	return true, fn(ctx)
+}
+
+func TestTTLWorkerExpiresSessionsAsLeader(t *testing.T) {
+	now := time.Now()
+	store := &memorySessionStore{sessions: map[string]storage.Session{
+		"expired":   {ID: "expired", Status: string(StatusActive), ExpiresAt: now.Add(-time.Minute)},
+		"unreached": {ID: "unreached", Status: string(StatusActive), ExpiresAt: now.Add(-time.Minute)},
+		"live":      {ID: "live", Status: string(StatusActive), ExpiresAt: now.Add(time.Minute)},
+	}}
+	var cleaned []string
+	lock := &recordingLock{}
+	worker := TTLWorker{
+		Store: StorageSessionStore{Store: store},
+		Cleanup: func(ctx context.Context, sessionID string) error {
+			if sessionID == "unreached" {
+				return storageError("data plane unavailable")
+			}
+			cleaned = append(cleaned, sessionID)
+			return nil
+		},
+		Now:  func() time.Time { return now },
+		Lock: lock,
+	}
+
+	if err := worker.Run(context.Background()); err != nil {
+		t.Fatalf("run without lock: %v", err)
+	}
+	if len(cleaned) != 0 || len(lock.names) != 1 {
+		t.Fatalf("expected no cleanup without the leader lock, got %v", cleaned)
+	}
+
+	lock.acquire = true
+	if err := worker.Run(context.Background()); err != nil {
+		t.Fatalf("run as leader: %v", err)
+	}
+	if len(cleaned) != 1 || cleaned[0] != "expired" {
+		t.Fatalf("expected expired session cleaned up, got %v", cleaned)
+	}
+	if store.sessions["expired"].Status != string(StatusExpired) {
+		t.Fatalf("expected session marked expired, got %s", store.sessions["expired"].Status)
+	}
+	if store.sessions["unreached"].Status != string(StatusActive) || store.sessions["live"].Status != string(StatusActive) {
+		t.Fatalf("expected failed cleanup and live sessions to stay active")
+	}
+}
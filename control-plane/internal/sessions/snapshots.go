@@ -0,0 +1,48 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"control-plane/internal/audit"
+	"control-plane/pkg/client"
+)
+
+var ErrSnapshotRuntimeMismatch = errors.New("snapshot was taken from a different runtime")
+
+// Snapshot stores an encrypted archive of the session workspace, and of the
+// Python interpreter's globals when includeState is set.
+func (s Service) Snapshot(ctx context.Context, session Session, includeState bool) (client.SessionSnapshot, error) {
+	if session.Status != StatusActive {
+		return client.SessionSnapshot{}, ErrSessionNotActive
+	}
+	snapshot, err := s.Client.SnapshotSession(ctx, session.ID, includeState)
+	if err != nil {
+		return client.SessionSnapshot{}, err
+	}
+	if s.Logger != nil {
+		_ = s.Logger.Log(ctx, audit.Event{
+			TenantID: session.TenantID,
+			Action:   "session_snapshotted",
+			Outcome:  "ok",
+			Time:     time.Now(),
+			Detail:   session.ID + ":" + snapshot.ID,
+		})
+	}
+	return snapshot, nil
+}
+
+// snapshot looks up a snapshot on the data plane. Snapshots of sessions
+// that belong to another tenant are reported as not found.
+func (s Service) snapshot(ctx context.Context, tenantID string, snapshotID string) (client.SessionSnapshot, error) {
+	snapshot, err := s.Client.GetSnapshot(ctx, snapshotID)
+	if err != nil {
+		return client.SessionSnapshot{}, err
+	}
+	source, err := s.Store.Get(ctx, snapshot.SessionID)
+	if err != nil || source.TenantID != tenantID {
+		return client.SessionSnapshot{}, client.ErrSnapshotNotFound
+	}
+	return snapshot, nil
+}
@@ -2,23 +2,70 @@ package sessions
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"time"
 
+	"control-plane/internal/storage"
 	"control-plane/pkg/client"
+	"shared/sessionagent"
 )
 
 type DataPlaneStepRunner struct {
 	Client client.DataPlaneClient
 }
 
-func (r DataPlaneStepRunner) RunStep(ctx context.Context, sessionID string, command string) (StepResult, error) {
-	resp, err := r.Client.RunSessionStep(ctx, sessionID, command)
+func (r DataPlaneStepRunner) RunStep(ctx context.Context, sessionID string, req StepRequest) (StepResult, error) {
+	resp, err := r.Client.RunSessionStep(ctx, sessionID, req.dataPlane())
 	if err != nil {
 		return StepResult{}, err
 	}
 	return StepResult{
-		ID:     "step-" + time.Now().UTC().Format("20060102150405.000000000"),
-		Stdout: resp.Stdout,
-		Stderr: resp.Stderr,
+		ID:        "step-" + time.Now().UTC().Format("20060102150405.000000000"),
+		Status:    resp.Status,
+		Stdout:    resp.Stdout,
+		Stderr:    resp.Stderr,
+		StateLost: resp.StateLost,
+		Usage:     storage.ResourceUsage(resp.ResourceUsage),
 	}, nil
 }
+
+func (r DataPlaneStepRunner) StreamStep(ctx context.Context, sessionID string, req StepRequest, emit func(stream string, data string)) (StepResult, error) {
+	var stdout, stderr strings.Builder
+	status, err := r.Client.StreamSessionStep(ctx, sessionID, req.dataPlane(), func(event sessionagent.StepEvent) error {
+		if event.Type == sessionagent.StepEventStderr {
+			stderr.WriteString(event.Data)
+		} else {
+			stdout.WriteString(event.Data)
+		}
+		emit(event.Type, event.Data)
+		return nil
+	})
+	if err != nil {
+		return StepResult{}, err
+	}
+	id := status.StepID
+	if id == "" {
+		id = "step-" + time.Now().UTC().Format("20060102150405.000000000")
+	}
+	return StepResult{
+		ID:        id,
+		Status:    status.Status,
+		Stdout:    stdout.String(),
+		Stderr:    stderr.String(),
+		StateLost: status.StateLost,
+		Usage:     storage.ResourceUsage(status.ResourceUsage),
+	}, nil
+}
+
+func (r DataPlaneStepRunner) Interrupt(ctx context.Context, sessionID string) (bool, error) {
+	interrupted, err := r.Client.InterruptSession(ctx, sessionID)
+	if errors.Is(err, client.ErrInterruptUnsupported) {
+		return false, ErrInterruptUnsupported
+	}
+	return interrupted, err
+}
+
+func (req StepRequest) dataPlane() client.SessionStepRequest {
+	return client.SessionStepRequest{Command: req.Command, TimeoutMs: req.Timeout.Milliseconds()}
+}
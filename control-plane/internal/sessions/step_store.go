@@ -20,6 +20,8 @@ func (s StorageStepStore) AppendStep(ctx context.Context, step SessionStep) erro
 		SessionID: step.SessionID,
 		Command:   step.Command,
 		Status:    step.Status,
+		StartedAt: step.StartedAt,
+		Usage:     step.Usage,
 	})
 }
 
@@ -32,12 +34,15 @@ func (s StorageStepStore) ListSteps(ctx context.Context, sessionID string) ([]Se
 		return nil, err
 	}
 	result := make([]SessionStep, 0, len(steps))
-	for _, step := range steps {
+	for i, step := range steps {
 		result = append(result, SessionStep{
 			ID:        step.ID,
 			SessionID: step.SessionID,
+			Sequence:  i + 1,
 			Command:   step.Command,
 			Status:    step.Status,
+			StartedAt: step.StartedAt,
+			Usage:     step.Usage,
 		})
 	}
 	return result, nil
@@ -3,7 +3,10 @@ package sessions
 import (
 	"context"
 	"errors"
+	"log"
 	"time"
+
+	"control-plane/internal/storage"
 )
 
 type ExpiredSessionStore interface {
@@ -13,16 +16,29 @@ type ExpiredSessionStore interface {
 
 type CleanupFunc func(ctx context.Context, sessionID string) error
 
+// ttlLockName is the advisory lock that elects the replica expiring sessions.
+const ttlLockName = "sessions.ttl_worker"
+
 type TTLWorker struct {
 	Store   ExpiredSessionStore
 	Cleanup CleanupFunc
 	Now     func() time.Time
+	// Lock, when set, restricts each run to the replica holding the lock.
+	Lock storage.AdvisoryLock
 }
 
 func (w TTLWorker) Run(ctx context.Context) error {
 	if w.Store == nil {
 		return errors.New("missing session store")
 	}
+	if w.Lock != nil {
+		_, err := w.Lock.WithLock(ctx, ttlLockName, w.expire)
+		return err
+	}
+	return w.expire(ctx)
+}
+
+func (w TTLWorker) expire(ctx context.Context) error {
 	now := time.Now
 	if w.Now != nil {
 		now = w.Now
@@ -33,7 +49,11 @@ func (w TTLWorker) Run(ctx context.Context) error {
 	}
 	for _, session := range expired {
 		if w.Cleanup != nil {
-			_ = w.Cleanup(ctx, session.ID)
+			if err := w.Cleanup(ctx, session.ID); err != nil {
+				// Leave the session active so the next run retries the cleanup.
+				log.Printf("sessions: expire session_id=%s cleanup error: %v", session.ID, err)
+				continue
+			}
 		}
 		if err := w.Store.UpdateStatus(ctx, session.ID, string(StatusExpired)); err != nil {
 			return err
@@ -41,3 +61,30 @@ func (w TTLWorker) Run(ctx context.Context) error {
 	}
 	return nil
 }
+
+// StorageSessionStore adapts a storage.SessionStore for the TTL worker.
+type StorageSessionStore struct {
+	Store storage.SessionStore
+}
+
+func (s StorageSessionStore) ListExpired(ctx context.Context, before time.Time) ([]Session, error) {
+	if s.Store == nil {
+		return nil, errors.New("missing session store")
+	}
+	stored, err := s.Store.ListExpired(ctx, before)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Session, 0, len(stored))
+	for _, session := range stored {
+		result = append(result, fromStorage(session))
+	}
+	return result, nil
+}
+
+func (s StorageSessionStore) UpdateStatus(ctx context.Context, id string, status string) error {
+	if s.Store == nil {
+		return errors.New("missing session store")
+	}
+	return s.Store.UpdateStatus(ctx, id, status)
+}
@@ -19,7 +19,10 @@ type StoreSet struct {
 	SessionStore     storage.SessionStore
 	SessionStepStore storage.SessionStepStore
 	PolicyStore      storage.PolicyStore
+	DecisionStore    storage.PolicyDecisionStore
+	InputStore       storage.PolicyInputStore
 	AuditStore       storage.AuditStore
+	Lock             storage.AdvisoryLock
 	DB               *sql.DB
 	Close            func() error
 }
@@ -39,7 +42,10 @@ func NewStoreSet(ctx context.Context, driver string, dsn string) (StoreSet, erro
 			SessionStore:     postgres.SessionStore{Pool: pool},
 			SessionStepStore: postgres.SessionStepStore{Pool: pool},
 			PolicyStore:      postgres.PolicyStore{Pool: pool},
+			DecisionStore:    postgres.PolicyDecisionStore{Pool: pool},
+			InputStore:       postgres.PolicyInputStore{Pool: pool},
 			AuditStore:       postgres.AuditStore{Pool: pool},
+			Lock:             postgres.AdvisoryLock{Pool: pool},
 			Close: func() error {
 				pool.Close()
 				return nil
@@ -62,7 +68,10 @@ func NewStoreSet(ctx context.Context, driver string, dsn string) (StoreSet, erro
 			SessionStore:     sqlite.SessionStore{DB: db},
 			SessionStepStore: sqlite.SessionStepStore{DB: db},
 			PolicyStore:      sqlite.PolicyStore{DB: db},
+			DecisionStore:    sqlite.PolicyDecisionStore{DB: db},
+			InputStore:       sqlite.PolicyInputStore{DB: db},
 			AuditStore:       sqlite.AuditStore{DB: db},
+			Lock:             sqlite.NewAdvisoryLock(),
 			DB:               db,
 			Close:            db.Close,
 		}, nil
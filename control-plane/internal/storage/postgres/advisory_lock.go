@@ -0,0 +1,49 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// AdvisoryLock takes a session-level pg_try_advisory_lock on a dedicated
+// connection for the duration of fn, so only one replica runs it at a time.
+type AdvisoryLock struct {
+	Pool *pgxpool.Pool
+}
+
+func (l AdvisoryLock) WithLock(ctx context.Context, name string, fn func(context.Context) error) (bool, error) {
+	if l.Pool == nil {
+		return false, errors.New("nil pool")
+	}
+	conn, err := l.Pool.Acquire(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Release()
+	key := lockKey(name)
+	var acquired bool
+	if err := conn.QueryRow(ctx, `select pg_try_advisory_lock($1)`, key).Scan(&acquired); err != nil {
+		return false, err
+	}
+	if !acquired {
+		return false, nil
+	}
+	defer func() {
+		// Unlock on a fresh context so a cancelled run still releases it.
+		if _, err := conn.Exec(context.WithoutCancel(ctx), `select pg_advisory_unlock($1)`, key); err != nil {
+			// A connection that cannot unlock must not go back to the pool
+			// still holding the lock.
+			_ = conn.Conn().Close(context.WithoutCancel(ctx))
+		}
+	}()
+	return true, fn(ctx)
+}
+
+func lockKey(name string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(name))
+	return int64(h.Sum64())
+}
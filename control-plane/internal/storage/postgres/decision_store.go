@@ -0,0 +1,65 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"control-plane/internal/storage"
+)
+
+type PolicyDecisionStore struct {
+	Pool *pgxpool.Pool
+}
+
+const decisionColumns = `tenant_id, policy_id, workload_id, policy_version, input_hash, allowed, reason, error, latency_us, created_at`
+
+func (s PolicyDecisionStore) Append(ctx context.Context, decision storage.PolicyDecision) error {
+	if s.Pool == nil {
+		return errors.New("nil pool")
+	}
+	if decision.CreatedAt.IsZero() {
+		decision.CreatedAt = time.Now().UTC()
+	}
+	_, err := s.Pool.Exec(ctx, `insert into policy_decisions (`+decisionColumns+`) values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		decision.TenantID, decision.PolicyID, decision.WorkloadID, decision.PolicyVersion, decision.InputHash, decision.Allowed, decision.Reason, decision.Error,
+		decision.Latency.Microseconds(), decision.CreatedAt)
+	return err
+}
+
+func (s PolicyDecisionStore) List(ctx context.Context, tenantID string, policyID string, query storage.DecisionQuery) ([]storage.PolicyDecision, error) {
+	if s.Pool == nil {
+		return nil, errors.New("nil pool")
+	}
+	var since, before *time.Time
+	if !query.Since.IsZero() {
+		since = &query.Since
+	}
+	if !query.Before.IsZero() {
+		before = &query.Before
+	}
+	rows, err := s.Pool.Query(ctx, `select `+decisionColumns+` from (select `+decisionColumns+` from policy_decisions
+		where tenant_id = $1 and policy_id = $2 and ($3::timestamptz is null or created_at >= $3) and ($4::timestamptz is null or created_at < $4)
+		order by created_at desc limit $5) latest order by created_at`, tenantID, policyID, since, before, query.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var decisions []storage.PolicyDecision
+	for rows.Next() {
+		var decision storage.PolicyDecision
+		var latencyUs int64
+		if err := rows.Scan(&decision.TenantID, &decision.PolicyID, &decision.WorkloadID, &decision.PolicyVersion, &decision.InputHash, &decision.Allowed,
+			&decision.Reason, &decision.Error, &latencyUs, &decision.CreatedAt); err != nil {
+			return nil, err
+		}
+		decision.Latency = time.Duration(latencyUs) * time.Microsecond
+		decisions = append(decisions, decision)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return decisions, nil
+}
@@ -0,0 +1,69 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"control-plane/internal/storage"
+)
+
+type PolicyInputStore struct {
+	Pool *pgxpool.Pool
+}
+
+const inputColumns = `tenant_id, policy_id, input_hash, input, created_at`
+
+func (s PolicyInputStore) Record(ctx context.Context, input storage.PolicyInput, keep int, expiry time.Time) error {
+	if s.Pool == nil {
+		return errors.New("nil pool")
+	}
+	if input.CreatedAt.IsZero() {
+		input.CreatedAt = time.Now().UTC()
+	}
+	tx, err := s.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if _, err := tx.Exec(ctx, `insert into policy_inputs (`+inputColumns+`) values ($1, $2, $3, $4, $5)`,
+		input.TenantID, input.PolicyID, input.InputHash, input.Input, input.CreatedAt); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `delete from policy_inputs where tenant_id = $1 and policy_id = $2 and ctid not in
+		(select ctid from policy_inputs where tenant_id = $1 and policy_id = $2 order by created_at desc limit $3)`,
+		input.TenantID, input.PolicyID, keep); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `delete from policy_inputs where created_at < $1`, expiry); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (s PolicyInputStore) Recent(ctx context.Context, tenantID string, policyID string, n int, since time.Time) ([]storage.PolicyInput, error) {
+	if s.Pool == nil {
+		return nil, errors.New("nil pool")
+	}
+	rows, err := s.Pool.Query(ctx, `select `+inputColumns+` from (select `+inputColumns+` from policy_inputs
+		where tenant_id = $1 and policy_id = $2 and created_at >= $3 order by created_at desc limit $4) latest order by created_at`,
+		tenantID, policyID, since, n)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var inputs []storage.PolicyInput
+	for rows.Next() {
+		var input storage.PolicyInput
+		if err := rows.Scan(&input.TenantID, &input.PolicyID, &input.InputHash, &input.Input, &input.CreatedAt); err != nil {
+			return nil, err
+		}
+		inputs = append(inputs, input)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return inputs, nil
+}
@@ -3,6 +3,7 @@ package postgres
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -14,15 +15,24 @@ type JobStore struct {
 	Pool *pgxpool.Pool
 }
 
-type SessionStore struct {
-	Pool *pgxpool.Pool
-}
+const jobColumns = `id, tenant_id, agent_id, policy_id, language, status, run_id, exit_status, output_ref, error_ref, artifact_refs, created_at, updated_at, finished_at, cpu_time_ms, max_rss_bytes, io_bytes`
+
+// terminalJobStatuses are the statuses Finish never overwrites.
+const terminalJobStatuses = `'finished', 'failed', 'timed_out', 'terminated'`
 
 func (s JobStore) Create(ctx context.Context, job storage.Job) error {
 	if s.Pool == nil {
 		return errors.New("nil pool")
 	}
-	_, err := s.Pool.Exec(ctx, `insert into jobs (id, status) values ($1, $2)`, job.ID, job.Status)
+	if job.CreatedAt.IsZero() {
+		job.CreatedAt = time.Now().UTC()
+	}
+	if job.UpdatedAt.IsZero() {
+		job.UpdatedAt = job.CreatedAt
+	}
+	_, err := s.Pool.Exec(ctx, `insert into jobs (`+jobColumns+`) values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)`,
+		job.ID, job.TenantID, job.AgentID, job.PolicyID, job.Language, job.Status, job.RunID, job.ExitStatus, job.OutputRef, job.ErrorRef,
+		artifactRefs(job.ArtifactRefs), job.CreatedAt, job.UpdatedAt, nullableTime(job.FinishedAt), job.Usage.CPUTimeMs, job.Usage.MaxRSSBytes, job.Usage.IOBytes)
 	return err
 }
 
@@ -30,8 +40,7 @@ func (s JobStore) Get(ctx context.Context, id string) (storage.Job, error) {
 	if s.Pool == nil {
 		return storage.Job{}, errors.New("nil pool")
 	}
-	var job storage.Job
-	err := s.Pool.QueryRow(ctx, `select id, status from jobs where id = $1`, id).Scan(&job.ID, &job.Status)
+	job, err := scanJob(s.Pool.QueryRow(ctx, `select `+jobColumns+` from jobs where id = $1`, id))
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return storage.Job{}, errors.New("job not found")
@@ -45,37 +54,82 @@ func (s JobStore) UpdateStatus(ctx context.Context, id string, status string) er
 	if s.Pool == nil {
 		return errors.New("nil pool")
 	}
-	_, err := s.Pool.Exec(ctx, `update jobs set status = $1 where id = $2`, status, id)
+	_, err := s.Pool.Exec(ctx, `update jobs set status = $1, updated_at = now() where id = $2`, status, id)
 	return err
 }
 
-func (s SessionStore) Create(ctx context.Context, session storage.Session) error {
+func (s JobStore) AttachRun(ctx context.Context, id string, runID string) error {
 	if s.Pool == nil {
 		return errors.New("nil pool")
 	}
-	_, err := s.Pool.Exec(ctx, `insert into sessions (id, status, runtime_id) values ($1, $2, $3)`, session.ID, session.Status, session.RuntimeID)
+	_, err := s.Pool.Exec(ctx, `update jobs set run_id = $1, updated_at = now() where id = $2`, runID, id)
 	return err
 }
 
-func (s SessionStore) Get(ctx context.Context, id string) (storage.Session, error) {
+func (s JobStore) Finish(ctx context.Context, job storage.Job) error {
 	if s.Pool == nil {
-		return storage.Session{}, errors.New("nil pool")
+		return errors.New("nil pool")
+	}
+	if job.FinishedAt.IsZero() {
+		job.FinishedAt = time.Now().UTC()
 	}
-	var session storage.Session
-	err := s.Pool.QueryRow(ctx, `select id, status, runtime_id from sessions where id = $1`, id).Scan(&session.ID, &session.Status, &session.RuntimeID)
+	tag, err := s.Pool.Exec(ctx, `update jobs set status = $1, exit_status = $2, output_ref = $3, error_ref = $4, artifact_refs = $5, updated_at = now(), finished_at = $6, cpu_time_ms = $7, max_rss_bytes = $8, io_bytes = $9 where id = $10 and status not in (`+terminalJobStatuses+`)`,
+		job.Status, job.ExitStatus, job.OutputRef, job.ErrorRef, artifactRefs(job.ArtifactRefs), job.FinishedAt, job.Usage.CPUTimeMs, job.Usage.MaxRSSBytes, job.Usage.IOBytes, job.ID)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return storage.Session{}, errors.New("session not found")
-		}
-		return storage.Session{}, err
+		return err
 	}
-	return session, nil
+	if tag.RowsAffected() == 0 {
+		return storage.ErrJobFinished
+	}
+	return nil
 }
 
-func (s SessionStore) UpdateStatus(ctx context.Context, id string, status string) error {
+func (s JobStore) ListByStatus(ctx context.Context, status string) ([]storage.Job, error) {
 	if s.Pool == nil {
-		return errors.New("nil pool")
+		return nil, errors.New("nil pool")
 	}
-	_, err := s.Pool.Exec(ctx, `update sessions set status = $1 where id = $2`, status, id)
-	return err
+	rows, err := s.Pool.Query(ctx, `select `+jobColumns+` from jobs where status = $1 order by id`, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var jobs []storage.Job
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+func scanJob(row pgx.Row) (storage.Job, error) {
+	var job storage.Job
+	var finishedAt *time.Time
+	if err := row.Scan(&job.ID, &job.TenantID, &job.AgentID, &job.PolicyID, &job.Language, &job.Status, &job.RunID, &job.ExitStatus,
+		&job.OutputRef, &job.ErrorRef, &job.ArtifactRefs, &job.CreatedAt, &job.UpdatedAt, &finishedAt, &job.Usage.CPUTimeMs, &job.Usage.MaxRSSBytes, &job.Usage.IOBytes); err != nil {
+		return storage.Job{}, err
+	}
+	if finishedAt != nil {
+		job.FinishedAt = *finishedAt
+	}
+	return job, nil
+}
+
+func nullableTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func artifactRefs(refs []string) []string {
+	if refs == nil {
+		return []string{}
+	}
+	return refs
 }
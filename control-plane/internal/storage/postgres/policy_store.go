@@ -3,7 +3,9 @@ package postgres
 import (
 	"context"
 	"errors"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"control-plane/internal/storage"
@@ -17,19 +19,158 @@ type AuditStore struct {
 	Pool *pgxpool.Pool
 }
 
+const (
+	policyColumns        = `id, version, ruleset, created_at, created_by`
+	policyVersionColumns = `id, version, ruleset, deleted, created_at, created_by`
+)
+
 func (s PolicyStore) Upsert(ctx context.Context, policy storage.Policy) error {
 	if s.Pool == nil {
 		return errors.New("nil pool")
 	}
-	_, err := s.Pool.Exec(ctx, `insert into policies (id, version) values ($1, $2) on conflict (id) do update set version = excluded.version`, policy.ID, policy.Version)
-	return err
+	if policy.CreatedAt.IsZero() {
+		policy.CreatedAt = time.Now().UTC()
+	}
+	policy.Deleted = false
+	tx, err := s.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if err := appendPolicyVersion(ctx, tx, policy); err != nil {
+		return err
+	}
+	tag, err := tx.Exec(ctx, `insert into policies (`+policyColumns+`) values ($1, $2, $3, $4, $5)
+on conflict (id) do update set version = excluded.version, ruleset = excluded.ruleset, created_at = excluded.created_at, created_by = excluded.created_by
+where excluded.version > policies.version`, policy.ID, policy.Version, policy.Ruleset, policy.CreatedAt, policy.CreatedBy)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrStalePolicyVersion
+	}
+	return tx.Commit(ctx)
+}
+
+func (s PolicyStore) Delete(ctx context.Context, tombstone storage.Policy) error {
+	if s.Pool == nil {
+		return errors.New("nil pool")
+	}
+	if tombstone.CreatedAt.IsZero() {
+		tombstone.CreatedAt = time.Now().UTC()
+	}
+	tombstone.Ruleset = ""
+	tombstone.Deleted = true
+	tx, err := s.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if err := appendPolicyVersion(ctx, tx, tombstone); err != nil {
+		return err
+	}
+	tag, err := tx.Exec(ctx, `delete from policies where id = $1 and version < $2`, tombstone.ID, tombstone.Version)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrPolicyNotFound
+	}
+	return tx.Commit(ctx)
+}
+
+func (s PolicyStore) Get(ctx context.Context, id string) (storage.Policy, error) {
+	if s.Pool == nil {
+		return storage.Policy{}, errors.New("nil pool")
+	}
+	var policy storage.Policy
+	row := s.Pool.QueryRow(ctx, `select `+policyColumns+` from policies where id = $1`, id)
+	if err := row.Scan(&policy.ID, &policy.Version, &policy.Ruleset, &policy.CreatedAt, &policy.CreatedBy); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.Policy{}, storage.ErrPolicyNotFound
+		}
+		return storage.Policy{}, err
+	}
+	return policy, nil
+}
+
+func (s PolicyStore) List(ctx context.Context, prefix string) ([]storage.Policy, error) {
+	if s.Pool == nil {
+		return nil, errors.New("nil pool")
+	}
+	rows, err := s.Pool.Query(ctx, `select `+policyColumns+` from policies where left(id, length($1)) = $1 order by id`, prefix)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var policies []storage.Policy
+	for rows.Next() {
+		var policy storage.Policy
+		if err := rows.Scan(&policy.ID, &policy.Version, &policy.Ruleset, &policy.CreatedAt, &policy.CreatedBy); err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return policies, nil
+}
+
+func (s PolicyStore) Versions(ctx context.Context, id string) ([]storage.Policy, error) {
+	if s.Pool == nil {
+		return nil, errors.New("nil pool")
+	}
+	rows, err := s.Pool.Query(ctx, `select `+policyVersionColumns+` from policy_versions where id = $1 order by version`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var versions []storage.Policy
+	for rows.Next() {
+		var policy storage.Policy
+		if err := rows.Scan(&policy.ID, &policy.Version, &policy.Ruleset, &policy.Deleted, &policy.CreatedAt, &policy.CreatedBy); err != nil {
+			return nil, err
+		}
+		versions = append(versions, policy)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, storage.ErrPolicyNotFound
+	}
+	return versions, nil
+}
+
+// appendPolicyVersion records a version in the history, which only accepts
+// versions newer than every one already recorded for the policy.
+func appendPolicyVersion(ctx context.Context, tx pgx.Tx, policy storage.Policy) error {
+	tag, err := tx.Exec(ctx, `insert into policy_versions (`+policyVersionColumns+`)
+select $1, $2, $3, $4, $5, $6
+where $2 > (select coalesce(max(version), 0) from policy_versions where id = $1)
+on conflict (id, version) do nothing`,
+		policy.ID, policy.Version, policy.Ruleset, policy.Deleted, policy.CreatedAt, policy.CreatedBy)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrStalePolicyVersion
+	}
+	return nil
 }
 
+const auditColumns = `id, tenant_id, actor_id, action, outcome, detail, created_at`
+
 func (s AuditStore) Append(ctx context.Context, event storage.AuditEvent) error {
 	if s.Pool == nil {
 		return errors.New("nil pool")
 	}
-	_, err := s.Pool.Exec(ctx, `insert into audit_events (id, action, outcome) values ($1, $2, $3)`, event.ID, event.Action, event.Outcome)
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now().UTC()
+	}
+	_, err := s.Pool.Exec(ctx, `insert into audit_events (`+auditColumns+`) values ($1, $2, $3, $4, $5, $6, $7)`,
+		event.ID, event.TenantID, event.ActorID, event.Action, event.Outcome, event.Detail, event.CreatedAt)
 	return err
 }
 
@@ -37,7 +178,7 @@ func (s AuditStore) List(ctx context.Context) ([]storage.AuditEvent, error) {
 	if s.Pool == nil {
 		return nil, errors.New("nil pool")
 	}
-	rows, err := s.Pool.Query(ctx, `select id, action, outcome from audit_events order by id desc`)
+	rows, err := s.Pool.Query(ctx, `select `+auditColumns+` from audit_events order by created_at`)
 	if err != nil {
 		return nil, err
 	}
@@ -46,7 +187,7 @@ func (s AuditStore) List(ctx context.Context) ([]storage.AuditEvent, error) {
 	var events []storage.AuditEvent
 	for rows.Next() {
 		var event storage.AuditEvent
-		if err := rows.Scan(&event.ID, &event.Action, &event.Outcome); err != nil {
+		if err := rows.Scan(&event.ID, &event.TenantID, &event.ActorID, &event.Action, &event.Outcome, &event.Detail, &event.CreatedAt); err != nil {
 			return nil, err
 		}
 		events = append(events, event)
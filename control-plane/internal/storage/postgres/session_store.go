@@ -0,0 +1,114 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"control-plane/internal/storage"
+)
+
+type SessionStore struct {
+	Pool *pgxpool.Pool
+}
+
+const sessionColumns = `id, tenant_id, agent_id, policy_id, runtime, status, runtime_id, ttl_seconds, created_at, last_activity, expires_at, max_expires_at`
+
+func (s SessionStore) Create(ctx context.Context, session storage.Session) error {
+	if s.Pool == nil {
+		return errors.New("nil pool")
+	}
+	if session.CreatedAt.IsZero() {
+		session.CreatedAt = time.Now().UTC()
+	}
+	if session.LastActivity.IsZero() {
+		session.LastActivity = session.CreatedAt
+	}
+	_, err := s.Pool.Exec(ctx, `insert into sessions (`+sessionColumns+`) values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+		session.ID, session.TenantID, session.AgentID, session.PolicyID, session.Runtime, session.Status, session.RuntimeID, session.TTLSeconds,
+		session.CreatedAt, session.LastActivity, nullableTime(session.ExpiresAt), nullableTime(session.MaxExpiresAt))
+	return err
+}
+
+func (s SessionStore) Get(ctx context.Context, id string) (storage.Session, error) {
+	if s.Pool == nil {
+		return storage.Session{}, errors.New("nil pool")
+	}
+	session, err := scanSession(s.Pool.QueryRow(ctx, `select `+sessionColumns+` from sessions where id = $1`, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.Session{}, errors.New("session not found")
+		}
+		return storage.Session{}, err
+	}
+	return session, nil
+}
+
+func (s SessionStore) UpdateStatus(ctx context.Context, id string, status string) error {
+	if s.Pool == nil {
+		return errors.New("nil pool")
+	}
+	_, err := s.Pool.Exec(ctx, `update sessions set status = $1 where id = $2`, status, id)
+	return err
+}
+
+func (s SessionStore) Touch(ctx context.Context, id string, lastActivity time.Time, expiresAt time.Time) error {
+	if s.Pool == nil {
+		return errors.New("nil pool")
+	}
+	_, err := s.Pool.Exec(ctx, `update sessions set last_activity = $1, expires_at = $2 where id = $3`, lastActivity, nullableTime(expiresAt), id)
+	return err
+}
+
+func (s SessionStore) ListByTenant(ctx context.Context, tenantID string) ([]storage.Session, error) {
+	if s.Pool == nil {
+		return nil, errors.New("nil pool")
+	}
+	return s.list(ctx, `select `+sessionColumns+` from sessions where tenant_id = $1 order by created_at, id`, tenantID)
+}
+
+func (s SessionStore) ListExpired(ctx context.Context, before time.Time) ([]storage.Session, error) {
+	if s.Pool == nil {
+		return nil, errors.New("nil pool")
+	}
+	return s.list(ctx, `select `+sessionColumns+` from sessions where status = 'active' and expires_at < $1 order by expires_at, id`, before)
+}
+
+func (s SessionStore) list(ctx context.Context, query string, args ...any) ([]storage.Session, error) {
+	rows, err := s.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var sessions []storage.Session
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func scanSession(row pgx.Row) (storage.Session, error) {
+	var session storage.Session
+	var expiresAt, maxExpiresAt *time.Time
+	if err := row.Scan(&session.ID, &session.TenantID, &session.AgentID, &session.PolicyID, &session.Runtime, &session.Status, &session.RuntimeID,
+		&session.TTLSeconds, &session.CreatedAt, &session.LastActivity, &expiresAt, &maxExpiresAt); err != nil {
+		return storage.Session{}, err
+	}
+	if expiresAt != nil {
+		session.ExpiresAt = *expiresAt
+	}
+	if maxExpiresAt != nil {
+		session.MaxExpiresAt = *maxExpiresAt
+	}
+	return session, nil
+}
@@ -3,8 +3,8 @@ package postgres
 import (
 	"context"
 	"errors"
+	"time"
 
-	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"control-plane/internal/storage"
@@ -18,7 +18,11 @@ func (s SessionStepStore) Append(ctx context.Context, step storage.SessionStep)
 	if s.Pool == nil {
 		return errors.New("nil pool")
 	}
-	_, err := s.Pool.Exec(ctx, `insert into session_steps (id, session_id, command, status) values ($1, $2, $3, $4)`, step.ID, step.SessionID, step.Command, step.Status)
+	if step.StartedAt.IsZero() {
+		step.StartedAt = time.Now().UTC()
+	}
+	_, err := s.Pool.Exec(ctx, `insert into session_steps (id, session_id, command, status, started_at, cpu_time_ms, max_rss_bytes, io_bytes) values ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		step.ID, step.SessionID, step.Command, step.Status, step.StartedAt, step.Usage.CPUTimeMs, step.Usage.MaxRSSBytes, step.Usage.IOBytes)
 	return err
 }
 
@@ -26,7 +30,7 @@ func (s SessionStepStore) List(ctx context.Context, sessionID string) ([]storage
 	if s.Pool == nil {
 		return nil, errors.New("nil pool")
 	}
-	rows, err := s.Pool.Query(ctx, `select id, session_id, command, status from session_steps where session_id = $1 order by id`, sessionID)
+	rows, err := s.Pool.Query(ctx, `select id, session_id, command, status, started_at, cpu_time_ms, max_rss_bytes, io_bytes from session_steps where session_id = $1 order by started_at, id`, sessionID)
 	if err != nil {
 		return nil, err
 	}
@@ -34,7 +38,7 @@ func (s SessionStepStore) List(ctx context.Context, sessionID string) ([]storage
 	var steps []storage.SessionStep
 	for rows.Next() {
 		var step storage.SessionStep
-		if err := rows.Scan(&step.ID, &step.SessionID, &step.Command, &step.Status); err != nil {
+		if err := rows.Scan(&step.ID, &step.SessionID, &step.Command, &step.Status, &step.StartedAt, &step.Usage.CPUTimeMs, &step.Usage.MaxRSSBytes, &step.Usage.IOBytes); err != nil {
 			return nil, err
 		}
 		steps = append(steps, step)
@@ -42,8 +46,5 @@ func (s SessionStepStore) List(ctx context.Context, sessionID string) ([]storage
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
-	if len(steps) == 0 {
-		return nil, pgx.ErrNoRows
-	}
 	return steps, nil
 }
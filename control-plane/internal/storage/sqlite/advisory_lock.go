@@ -0,0 +1,28 @@
+package sqlite
+
+import (
+	"context"
+	"sync"
+)
+
+// AdvisoryLock serialises work within the process. SQLite is only used by
+// single-replica deployments, so the process is always the leader.
+type AdvisoryLock struct {
+	mu *sync.Mutex
+}
+
+func NewAdvisoryLock() AdvisoryLock {
+	return AdvisoryLock{mu: &sync.Mutex{}}
+}
+
+func (l AdvisoryLock) WithLock(ctx context.Context, name string, fn func(context.Context) error) (bool, error) {
+	_ = name
+	if l.mu == nil {
+		return true, fn(ctx)
+	}
+	if !l.mu.TryLock() {
+		return false, nil
+	}
+	defer l.mu.Unlock()
+	return true, fn(ctx)
+}
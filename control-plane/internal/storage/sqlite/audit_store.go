@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"time"
 
 	"control-plane/internal/storage"
 )
@@ -12,11 +13,17 @@ type AuditStore struct {
 	DB *sql.DB
 }
 
+const auditColumns = `id, tenant_id, actor_id, action, outcome, detail, created_at`
+
 func (s AuditStore) Append(ctx context.Context, event storage.AuditEvent) error {
 	if s.DB == nil {
 		return errors.New("nil db")
 	}
-	_, err := s.DB.ExecContext(ctx, `insert into audit_events (id, action, outcome) values (?, ?, ?)`, event.ID, event.Action, event.Outcome)
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now().UTC()
+	}
+	_, err := s.DB.ExecContext(ctx, `insert into audit_events (`+auditColumns+`) values (?, ?, ?, ?, ?, ?, ?)`,
+		event.ID, event.TenantID, event.ActorID, event.Action, event.Outcome, event.Detail, formatTime(event.CreatedAt))
 	return err
 }
 
@@ -24,7 +31,7 @@ func (s AuditStore) List(ctx context.Context) ([]storage.AuditEvent, error) {
 	if s.DB == nil {
 		return nil, errors.New("nil db")
 	}
-	rows, err := s.DB.QueryContext(ctx, `select id, action, outcome from audit_events order by id desc`)
+	rows, err := s.DB.QueryContext(ctx, `select `+auditColumns+` from audit_events order by rowid`)
 	if err != nil {
 		return nil, err
 	}
@@ -33,7 +40,11 @@ func (s AuditStore) List(ctx context.Context) ([]storage.AuditEvent, error) {
 	var events []storage.AuditEvent
 	for rows.Next() {
 		var event storage.AuditEvent
-		if err := rows.Scan(&event.ID, &event.Action, &event.Outcome); err != nil {
+		var createdAt string
+		if err := rows.Scan(&event.ID, &event.TenantID, &event.ActorID, &event.Action, &event.Outcome, &event.Detail, &createdAt); err != nil {
+			return nil, err
+		}
+		if event.CreatedAt, err = parseTime(createdAt); err != nil {
 			return nil, err
 		}
 		events = append(events, event)
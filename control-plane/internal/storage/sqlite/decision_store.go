@@ -0,0 +1,72 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"control-plane/internal/storage"
+)
+
+type PolicyDecisionStore struct {
+	DB *sql.DB
+}
+
+const decisionColumns = `tenant_id, policy_id, workload_id, policy_version, input_hash, allowed, reason, error, latency_us, created_at`
+
+func (s PolicyDecisionStore) Append(ctx context.Context, decision storage.PolicyDecision) error {
+	if s.DB == nil {
+		return errors.New("nil db")
+	}
+	if decision.CreatedAt.IsZero() {
+		decision.CreatedAt = time.Now().UTC()
+	}
+	_, err := s.DB.ExecContext(ctx, `insert into policy_decisions (`+decisionColumns+`) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		decision.TenantID, decision.PolicyID, decision.WorkloadID, decision.PolicyVersion, decision.InputHash, decision.Allowed, decision.Reason, decision.Error,
+		decision.Latency.Microseconds(), formatTime(decision.CreatedAt))
+	return err
+}
+
+func (s PolicyDecisionStore) List(ctx context.Context, tenantID string, policyID string, query storage.DecisionQuery) ([]storage.PolicyDecision, error) {
+	if s.DB == nil {
+		return nil, errors.New("nil db")
+	}
+	// created_at is RFC 3339 text, which does not sort by time, so bounds
+	// are compared as julian days.
+	where := `tenant_id = ? and policy_id = ?`
+	args := []any{tenantID, policyID}
+	if !query.Since.IsZero() {
+		where += ` and julianday(created_at) >= julianday(?)`
+		args = append(args, formatTime(query.Since))
+	}
+	if !query.Before.IsZero() {
+		where += ` and julianday(created_at) < julianday(?)`
+		args = append(args, formatTime(query.Before))
+	}
+	args = append(args, query.Limit)
+	rows, err := s.DB.QueryContext(ctx, `select `+decisionColumns+` from (select rowid, `+decisionColumns+` from policy_decisions where `+where+` order by rowid desc limit ?) order by rowid`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var decisions []storage.PolicyDecision
+	for rows.Next() {
+		var decision storage.PolicyDecision
+		var latencyUs int64
+		var createdAt string
+		if err := rows.Scan(&decision.TenantID, &decision.PolicyID, &decision.WorkloadID, &decision.PolicyVersion, &decision.InputHash, &decision.Allowed,
+			&decision.Reason, &decision.Error, &latencyUs, &createdAt); err != nil {
+			return nil, err
+		}
+		decision.Latency = time.Duration(latencyUs) * time.Microsecond
+		if decision.CreatedAt, err = parseTime(createdAt); err != nil {
+			return nil, err
+		}
+		decisions = append(decisions, decision)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return decisions, nil
+}
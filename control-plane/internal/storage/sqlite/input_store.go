@@ -0,0 +1,74 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"control-plane/internal/storage"
+)
+
+type PolicyInputStore struct {
+	DB *sql.DB
+}
+
+const inputColumns = `tenant_id, policy_id, input_hash, input, created_at`
+
+func (s PolicyInputStore) Record(ctx context.Context, input storage.PolicyInput, keep int, expiry time.Time) error {
+	if s.DB == nil {
+		return errors.New("nil db")
+	}
+	if input.CreatedAt.IsZero() {
+		input.CreatedAt = time.Now().UTC()
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `insert into policy_inputs (`+inputColumns+`) values (?, ?, ?, ?, ?)`,
+		input.TenantID, input.PolicyID, input.InputHash, string(input.Input), formatTime(input.CreatedAt)); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `delete from policy_inputs where tenant_id = ? and policy_id = ? and rowid not in
+		(select rowid from policy_inputs where tenant_id = ? and policy_id = ? order by rowid desc limit ?)`,
+		input.TenantID, input.PolicyID, input.TenantID, input.PolicyID, keep); err != nil {
+		return err
+	}
+	// created_at is RFC 3339 text, which does not sort by time.
+	if _, err := tx.ExecContext(ctx, `delete from policy_inputs where julianday(created_at) < julianday(?)`, formatTime(expiry)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s PolicyInputStore) Recent(ctx context.Context, tenantID string, policyID string, n int, since time.Time) ([]storage.PolicyInput, error) {
+	if s.DB == nil {
+		return nil, errors.New("nil db")
+	}
+	rows, err := s.DB.QueryContext(ctx, `select `+inputColumns+` from (select rowid, `+inputColumns+` from policy_inputs
+		where tenant_id = ? and policy_id = ? and julianday(created_at) >= julianday(?) order by rowid desc limit ?) order by rowid`,
+		tenantID, policyID, formatTime(since), n)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var inputs []storage.PolicyInput
+	for rows.Next() {
+		var input storage.PolicyInput
+		var payload, createdAt string
+		if err := rows.Scan(&input.TenantID, &input.PolicyID, &input.InputHash, &payload, &createdAt); err != nil {
+			return nil, err
+		}
+		input.Input = []byte(payload)
+		if input.CreatedAt, err = parseTime(createdAt); err != nil {
+			return nil, err
+		}
+		inputs = append(inputs, input)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return inputs, nil
+}
@@ -3,7 +3,9 @@ package sqlite
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"time"
 
 	"control-plane/internal/storage"
 )
@@ -12,11 +14,28 @@ type JobStore struct {
 	DB *sql.DB
 }
 
+const jobColumns = `id, tenant_id, agent_id, policy_id, language, status, run_id, exit_status, output_ref, error_ref, artifact_refs, created_at, updated_at, finished_at, cpu_time_ms, max_rss_bytes, io_bytes`
+
+// terminalJobStatuses are the statuses Finish never overwrites.
+const terminalJobStatuses = `'finished', 'failed', 'timed_out', 'terminated'`
+
 func (s JobStore) Create(ctx context.Context, job storage.Job) error {
 	if s.DB == nil {
 		return errors.New("nil db")
 	}
-	_, err := s.DB.ExecContext(ctx, `insert into jobs (id, status) values (?, ?)`, job.ID, job.Status)
+	refs, err := encodeRefs(job.ArtifactRefs)
+	if err != nil {
+		return err
+	}
+	if job.CreatedAt.IsZero() {
+		job.CreatedAt = time.Now().UTC()
+	}
+	if job.UpdatedAt.IsZero() {
+		job.UpdatedAt = job.CreatedAt
+	}
+	_, err = s.DB.ExecContext(ctx, `insert into jobs (`+jobColumns+`) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		job.ID, job.TenantID, job.AgentID, job.PolicyID, job.Language, job.Status, job.RunID, job.ExitStatus, job.OutputRef, job.ErrorRef, refs,
+		formatTime(job.CreatedAt), formatTime(job.UpdatedAt), formatTime(job.FinishedAt), job.Usage.CPUTimeMs, job.Usage.MaxRSSBytes, job.Usage.IOBytes)
 	return err
 }
 
@@ -24,8 +43,7 @@ func (s JobStore) Get(ctx context.Context, id string) (storage.Job, error) {
 	if s.DB == nil {
 		return storage.Job{}, errors.New("nil db")
 	}
-	var job storage.Job
-	err := s.DB.QueryRowContext(ctx, `select id, status from jobs where id = ?`, id).Scan(&job.ID, &job.Status)
+	job, err := scanJob(s.DB.QueryRowContext(ctx, `select `+jobColumns+` from jobs where id = ?`, id))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return storage.Job{}, errors.New("job not found")
@@ -39,6 +57,114 @@ func (s JobStore) UpdateStatus(ctx context.Context, id string, status string) er
 	if s.DB == nil {
 		return errors.New("nil db")
 	}
-	_, err := s.DB.ExecContext(ctx, `update jobs set status = ? where id = ?`, status, id)
+	_, err := s.DB.ExecContext(ctx, `update jobs set status = ?, updated_at = ? where id = ?`, status, formatTime(time.Now()), id)
 	return err
 }
+
+func (s JobStore) AttachRun(ctx context.Context, id string, runID string) error {
+	if s.DB == nil {
+		return errors.New("nil db")
+	}
+	_, err := s.DB.ExecContext(ctx, `update jobs set run_id = ?, updated_at = ? where id = ?`, runID, formatTime(time.Now()), id)
+	return err
+}
+
+func (s JobStore) Finish(ctx context.Context, job storage.Job) error {
+	if s.DB == nil {
+		return errors.New("nil db")
+	}
+	refs, err := encodeRefs(job.ArtifactRefs)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if job.FinishedAt.IsZero() {
+		job.FinishedAt = now
+	}
+	result, err := s.DB.ExecContext(ctx, `update jobs set status = ?, exit_status = ?, output_ref = ?, error_ref = ?, artifact_refs = ?, updated_at = ?, finished_at = ?, cpu_time_ms = ?, max_rss_bytes = ?, io_bytes = ? where id = ? and status not in (`+terminalJobStatuses+`)`,
+		job.Status, job.ExitStatus, job.OutputRef, job.ErrorRef, refs, formatTime(now), formatTime(job.FinishedAt), job.Usage.CPUTimeMs, job.Usage.MaxRSSBytes, job.Usage.IOBytes, job.ID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return storage.ErrJobFinished
+	}
+	return nil
+}
+
+func (s JobStore) ListByStatus(ctx context.Context, status string) ([]storage.Job, error) {
+	if s.DB == nil {
+		return nil, errors.New("nil db")
+	}
+	rows, err := s.DB.QueryContext(ctx, `select `+jobColumns+` from jobs where status = ? order by rowid`, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var jobs []storage.Job
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanJob(row rowScanner) (storage.Job, error) {
+	var job storage.Job
+	var refs, createdAt, updatedAt, finishedAt string
+	if err := row.Scan(&job.ID, &job.TenantID, &job.AgentID, &job.PolicyID, &job.Language, &job.Status, &job.RunID, &job.ExitStatus,
+		&job.OutputRef, &job.ErrorRef, &refs, &createdAt, &updatedAt, &finishedAt, &job.Usage.CPUTimeMs, &job.Usage.MaxRSSBytes, &job.Usage.IOBytes); err != nil {
+		return storage.Job{}, err
+	}
+	if refs != "" {
+		if err := json.Unmarshal([]byte(refs), &job.ArtifactRefs); err != nil {
+			return storage.Job{}, err
+		}
+	}
+	var err error
+	if job.CreatedAt, err = parseTime(createdAt); err != nil {
+		return storage.Job{}, err
+	}
+	if job.UpdatedAt, err = parseTime(updatedAt); err != nil {
+		return storage.Job{}, err
+	}
+	if job.FinishedAt, err = parseTime(finishedAt); err != nil {
+		return storage.Job{}, err
+	}
+	return job, nil
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+func encodeRefs(refs []string) (string, error) {
+	if len(refs) == 0 {
+		return "", nil
+	}
+	payload, err := json.Marshal(refs)
+	if err != nil {
+		return "", err
+	}
+	return string(payload), nil
+}
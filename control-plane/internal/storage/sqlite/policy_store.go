@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"time"
 
 	"control-plane/internal/storage"
 )
@@ -12,10 +13,158 @@ type PolicyStore struct {
 	DB *sql.DB
 }
 
+const (
+	policyColumns        = `id, version, ruleset, created_at, created_by`
+	policyVersionColumns = `id, version, ruleset, deleted, created_at, created_by`
+)
+
 func (s PolicyStore) Upsert(ctx context.Context, policy storage.Policy) error {
 	if s.DB == nil {
 		return errors.New("nil db")
 	}
-	_, err := s.DB.ExecContext(ctx, `insert into policies (id, version) values (?, ?) on conflict(id) do update set version = excluded.version`, policy.ID, policy.Version)
-	return err
+	if policy.CreatedAt.IsZero() {
+		policy.CreatedAt = time.Now().UTC()
+	}
+	policy.Deleted = false
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := appendPolicyVersion(ctx, tx, policy); err != nil {
+		return err
+	}
+	result, err := tx.ExecContext(ctx, `insert into policies (`+policyColumns+`) values (?, ?, ?, ?, ?)
+on conflict(id) do update set version = excluded.version, ruleset = excluded.ruleset, created_at = excluded.created_at, created_by = excluded.created_by
+where excluded.version > policies.version`, policy.ID, policy.Version, policy.Ruleset, formatTime(policy.CreatedAt), policy.CreatedBy)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return storage.ErrStalePolicyVersion
+	}
+	return tx.Commit()
+}
+
+func (s PolicyStore) Delete(ctx context.Context, tombstone storage.Policy) error {
+	if s.DB == nil {
+		return errors.New("nil db")
+	}
+	if tombstone.CreatedAt.IsZero() {
+		tombstone.CreatedAt = time.Now().UTC()
+	}
+	tombstone.Ruleset = ""
+	tombstone.Deleted = true
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := appendPolicyVersion(ctx, tx, tombstone); err != nil {
+		return err
+	}
+	result, err := tx.ExecContext(ctx, `delete from policies where id = ? and version < ?`, tombstone.ID, tombstone.Version)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return storage.ErrPolicyNotFound
+	}
+	return tx.Commit()
+}
+
+func (s PolicyStore) Get(ctx context.Context, id string) (storage.Policy, error) {
+	if s.DB == nil {
+		return storage.Policy{}, errors.New("nil db")
+	}
+	policy, err := scanPolicy(s.DB.QueryRowContext(ctx, `select `+policyColumns+` from policies where id = ?`, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.Policy{}, storage.ErrPolicyNotFound
+		}
+		return storage.Policy{}, err
+	}
+	return policy, nil
+}
+
+func (s PolicyStore) List(ctx context.Context, prefix string) ([]storage.Policy, error) {
+	if s.DB == nil {
+		return nil, errors.New("nil db")
+	}
+	rows, err := s.DB.QueryContext(ctx, `select `+policyColumns+` from policies where substr(id, 1, length(?)) = ? order by id`, prefix, prefix)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var policies []storage.Policy
+	for rows.Next() {
+		policy, err := scanPolicy(rows)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return policies, nil
+}
+
+func (s PolicyStore) Versions(ctx context.Context, id string) ([]storage.Policy, error) {
+	if s.DB == nil {
+		return nil, errors.New("nil db")
+	}
+	rows, err := s.DB.QueryContext(ctx, `select `+policyVersionColumns+` from policy_versions where id = ? order by version`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var versions []storage.Policy
+	for rows.Next() {
+		var policy storage.Policy
+		var createdAt string
+		if err := rows.Scan(&policy.ID, &policy.Version, &policy.Ruleset, &policy.Deleted, &createdAt, &policy.CreatedBy); err != nil {
+			return nil, err
+		}
+		if policy.CreatedAt, err = parseTime(createdAt); err != nil {
+			return nil, err
+		}
+		versions = append(versions, policy)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, storage.ErrPolicyNotFound
+	}
+	return versions, nil
+}
+
+// appendPolicyVersion records a version in the history, which only accepts
+// versions newer than every one already recorded for the policy.
+func appendPolicyVersion(ctx context.Context, tx *sql.Tx, policy storage.Policy) error {
+	result, err := tx.ExecContext(ctx, `insert into policy_versions (`+policyVersionColumns+`)
+select ?, ?, ?, ?, ?, ?
+where ? > (select coalesce(max(version), 0) from policy_versions where id = ?)`,
+		policy.ID, policy.Version, policy.Ruleset, policy.Deleted, formatTime(policy.CreatedAt), policy.CreatedBy, policy.Version, policy.ID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return storage.ErrStalePolicyVersion
+	}
+	return nil
+}
+
+func scanPolicy(row rowScanner) (storage.Policy, error) {
+	var policy storage.Policy
+	var createdAt string
+	if err := row.Scan(&policy.ID, &policy.Version, &policy.Ruleset, &createdAt, &policy.CreatedBy); err != nil {
+		return storage.Policy{}, err
+	}
+	var err error
+	if policy.CreatedAt, err = parseTime(createdAt); err != nil {
+		return storage.Policy{}, err
+	}
+	return policy, nil
 }
@@ -1,30 +1,98 @@
 create table if not exists jobs (
   id text primary key,
-  status text not null
+  tenant_id text not null default '',
+  agent_id text not null default '',
+  policy_id text not null default '',
+  language text not null default '',
+  status text not null,
+  run_id text not null default '',
+  exit_status integer not null default 0,
+  output_ref text not null default '',
+  error_ref text not null default '',
+  artifact_refs text not null default '',
+  created_at text not null default '',
+  updated_at text not null default '',
+  finished_at text not null default '',
+  cpu_time_ms integer not null default 0,
+  max_rss_bytes integer not null default 0,
+  io_bytes integer not null default 0
 );
 
 create table if not exists sessions (
   id text primary key,
+  tenant_id text not null default '',
+  agent_id text not null default '',
+  policy_id text not null default '',
+  runtime text not null default '',
   status text not null,
-  runtime_id text
+  runtime_id text not null default '',
+  ttl_seconds integer not null default 0,
+  created_at text not null default '',
+  last_activity text not null default '',
+  expires_at text not null default '',
+  max_expires_at text not null default ''
 );
 
 create table if not exists session_steps (
   id text primary key,
   session_id text not null,
   command text not null,
-  status text not null
+  status text not null,
+  started_at text not null default '',
+  cpu_time_ms integer not null default 0,
+  max_rss_bytes integer not null default 0,
+  io_bytes integer not null default 0
 );
 
 create table if not exists policies (
   id text primary key,
-  version integer not null
+  version integer not null,
+  ruleset text not null default '',
+  created_at text not null default '',
+  created_by text not null default ''
 );
 
+create table if not exists policy_versions (
+  id text not null,
+  version integer not null,
+  ruleset text not null default '',
+  deleted integer not null default 0,
+  created_at text not null default '',
+  created_by text not null default '',
+  primary key (id, version)
+);
+
+create table if not exists policy_decisions (
+  tenant_id text not null default '',
+  policy_id text not null default '',
+  workload_id text not null default '',
+  policy_version integer not null default 0,
+  input_hash text not null default '',
+  allowed integer not null default 0,
+  reason text not null default '',
+  error text not null default '',
+  latency_us integer not null default 0,
+  created_at text not null default ''
+);
+
+create table if not exists policy_inputs (
+  tenant_id text not null default '',
+  policy_id text not null default '',
+  input_hash text not null default '',
+  input text not null default '',
+  created_at text not null default ''
+);
+
+create index if not exists policy_inputs_policy on policy_inputs (tenant_id, policy_id);
+
 create table if not exists audit_events (
   id text primary key,
+  tenant_id text not null default '',
+  actor_id text not null default '',
   action text not null,
-  outcome text not null
+  outcome text not null,
+  detail text not null default '',
+  created_at text not null default ''
 );
 
 create table if not exists idempotency_keys (
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"time"
 
 	"control-plane/internal/storage"
 )
@@ -12,11 +13,21 @@ type SessionStore struct {
 	DB *sql.DB
 }
 
+const sessionColumns = `id, tenant_id, agent_id, policy_id, runtime, status, runtime_id, ttl_seconds, created_at, last_activity, expires_at, max_expires_at`
+
 func (s SessionStore) Create(ctx context.Context, session storage.Session) error {
 	if s.DB == nil {
 		return errors.New("nil db")
 	}
-	_, err := s.DB.ExecContext(ctx, `insert into sessions (id, status, runtime_id) values (?, ?, ?)`, session.ID, session.Status, session.RuntimeID)
+	if session.CreatedAt.IsZero() {
+		session.CreatedAt = time.Now().UTC()
+	}
+	if session.LastActivity.IsZero() {
+		session.LastActivity = session.CreatedAt
+	}
+	_, err := s.DB.ExecContext(ctx, `insert into sessions (`+sessionColumns+`) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		session.ID, session.TenantID, session.AgentID, session.PolicyID, session.Runtime, session.Status, session.RuntimeID, session.TTLSeconds,
+		formatTime(session.CreatedAt), formatTime(session.LastActivity), formatTime(session.ExpiresAt), formatTime(session.MaxExpiresAt))
 	return err
 }
 
@@ -24,8 +35,7 @@ func (s SessionStore) Get(ctx context.Context, id string) (storage.Session, erro
 	if s.DB == nil {
 		return storage.Session{}, errors.New("nil db")
 	}
-	var session storage.Session
-	err := s.DB.QueryRowContext(ctx, `select id, status, runtime_id from sessions where id = ?`, id).Scan(&session.ID, &session.Status, &session.RuntimeID)
+	session, err := scanSession(s.DB.QueryRowContext(ctx, `select `+sessionColumns+` from sessions where id = ?`, id))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return storage.Session{}, errors.New("session not found")
@@ -42,3 +52,81 @@ func (s SessionStore) UpdateStatus(ctx context.Context, id string, status string
 	_, err := s.DB.ExecContext(ctx, `update sessions set status = ? where id = ?`, status, id)
 	return err
 }
+
+func (s SessionStore) Touch(ctx context.Context, id string, lastActivity time.Time, expiresAt time.Time) error {
+	if s.DB == nil {
+		return errors.New("nil db")
+	}
+	_, err := s.DB.ExecContext(ctx, `update sessions set last_activity = ?, expires_at = ? where id = ?`,
+		formatTime(lastActivity), formatTime(expiresAt), id)
+	return err
+}
+
+func (s SessionStore) ListByTenant(ctx context.Context, tenantID string) ([]storage.Session, error) {
+	if s.DB == nil {
+		return nil, errors.New("nil db")
+	}
+	return s.list(ctx, `select `+sessionColumns+` from sessions where tenant_id = ? order by rowid`, tenantID)
+}
+
+func (s SessionStore) ListExpired(ctx context.Context, before time.Time) ([]storage.Session, error) {
+	if s.DB == nil {
+		return nil, errors.New("nil db")
+	}
+	// Timestamps are text with a variable number of fractional digits, so
+	// they are compared after parsing rather than in SQL.
+	active, err := s.list(ctx, `select `+sessionColumns+` from sessions where status = 'active' and expires_at != '' order by rowid`)
+	if err != nil {
+		return nil, err
+	}
+	var expired []storage.Session
+	for _, session := range active {
+		if session.ExpiresAt.Before(before) {
+			expired = append(expired, session)
+		}
+	}
+	return expired, nil
+}
+
+func (s SessionStore) list(ctx context.Context, query string, args ...any) ([]storage.Session, error) {
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var sessions []storage.Session
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func scanSession(row rowScanner) (storage.Session, error) {
+	var session storage.Session
+	var createdAt, lastActivity, expiresAt, maxExpiresAt string
+	if err := row.Scan(&session.ID, &session.TenantID, &session.AgentID, &session.PolicyID, &session.Runtime, &session.Status, &session.RuntimeID,
+		&session.TTLSeconds, &createdAt, &lastActivity, &expiresAt, &maxExpiresAt); err != nil {
+		return storage.Session{}, err
+	}
+	var err error
+	if session.CreatedAt, err = parseTime(createdAt); err != nil {
+		return storage.Session{}, err
+	}
+	if session.LastActivity, err = parseTime(lastActivity); err != nil {
+		return storage.Session{}, err
+	}
+	if session.ExpiresAt, err = parseTime(expiresAt); err != nil {
+		return storage.Session{}, err
+	}
+	if session.MaxExpiresAt, err = parseTime(maxExpiresAt); err != nil {
+		return storage.Session{}, err
+	}
+	return session, nil
+}
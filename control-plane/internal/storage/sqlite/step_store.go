@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"time"
 
 	"control-plane/internal/storage"
 )
@@ -16,7 +17,11 @@ func (s SessionStepStore) Append(ctx context.Context, step storage.SessionStep)
 	if s.DB == nil {
 		return errors.New("nil db")
 	}
-	_, err := s.DB.ExecContext(ctx, `insert into session_steps (id, session_id, command, status) values (?, ?, ?, ?)`, step.ID, step.SessionID, step.Command, step.Status)
+	if step.StartedAt.IsZero() {
+		step.StartedAt = time.Now().UTC()
+	}
+	_, err := s.DB.ExecContext(ctx, `insert into session_steps (id, session_id, command, status, started_at, cpu_time_ms, max_rss_bytes, io_bytes) values (?, ?, ?, ?, ?, ?, ?, ?)`,
+		step.ID, step.SessionID, step.Command, step.Status, formatTime(step.StartedAt), step.Usage.CPUTimeMs, step.Usage.MaxRSSBytes, step.Usage.IOBytes)
 	return err
 }
 
@@ -24,7 +29,7 @@ func (s SessionStepStore) List(ctx context.Context, sessionID string) ([]storage
 	if s.DB == nil {
 		return nil, errors.New("nil db")
 	}
-	rows, err := s.DB.QueryContext(ctx, `select id, session_id, command, status from session_steps where session_id = ? order by rowid`, sessionID)
+	rows, err := s.DB.QueryContext(ctx, `select id, session_id, command, status, started_at, cpu_time_ms, max_rss_bytes, io_bytes from session_steps where session_id = ? order by rowid`, sessionID)
 	if err != nil {
 		return nil, err
 	}
@@ -32,7 +37,11 @@ func (s SessionStepStore) List(ctx context.Context, sessionID string) ([]storage
 	var steps []storage.SessionStep
 	for rows.Next() {
 		var step storage.SessionStep
-		if err := rows.Scan(&step.ID, &step.SessionID, &step.Command, &step.Status); err != nil {
+		var startedAt string
+		if err := rows.Scan(&step.ID, &step.SessionID, &step.Command, &step.Status, &startedAt, &step.Usage.CPUTimeMs, &step.Usage.MaxRSSBytes, &step.Usage.IOBytes); err != nil {
+			return nil, err
+		}
+		if step.StartedAt, err = parseTime(startedAt); err != nil {
 			return nil, err
 		}
 		steps = append(steps, step)
@@ -1,27 +1,107 @@
 package storage
 
-import "context"
+import (
+	"context"
+	"errors"
+	"time"
+)
 
 type Job struct {
-	ID     string
-	Status string
+	ID           string
+	TenantID     string
+	AgentID      string
+	PolicyID     string
+	Language     string
+	Status       string
+	RunID        string
+	ExitStatus   int
+	OutputRef    string
+	ErrorRef     string
+	ArtifactRefs []string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	FinishedAt   time.Time
+	Usage        ResourceUsage
 }
 
+// ResourceUsage is what a run or step consumed, as reported by the data plane.
+type ResourceUsage struct {
+	CPUTimeMs   int64
+	MaxRSSBytes int64
+	IOBytes     int64
+}
+
+// Session expiry slides: each step moves ExpiresAt to LastActivity plus
+// TTLSeconds, but never past MaxExpiresAt when the policy sets a limit.
 type Session struct {
-	ID     string
-	Status string
-	RuntimeID string
+	ID           string
+	TenantID     string
+	AgentID      string
+	PolicyID     string
+	Runtime      string
+	Status       string
+	RuntimeID    string
+	TTLSeconds   int
+	CreatedAt    time.Time
+	LastActivity time.Time
+	ExpiresAt    time.Time
+	MaxExpiresAt time.Time
 }
 
+// Policy is one version of a policy. Deleted marks the tombstone version
+// a delete appends, so that version numbers are never reused.
 type Policy struct {
-	ID      string
-	Version int
+	ID        string
+	Version   int
+	Ruleset   string
+	Deleted   bool
+	CreatedAt time.Time
+	CreatedBy string
+}
+
+// ErrJobFinished is returned by JobStore.Finish when the job has already
+// reached a terminal status.
+var ErrJobFinished = errors.New("job already finished")
+
+var (
+	ErrPolicyNotFound     = errors.New("policy not found")
+	ErrStalePolicyVersion = errors.New("stale policy version")
+)
+
+// PolicyDecision is one entry of the policy decision log, which is only
+// ever appended to. PolicyID is the policy name within the tenant and
+// WorkloadID the job or session that was evaluated.
+type PolicyDecision struct {
+	TenantID      string
+	PolicyID      string
+	WorkloadID    string
+	PolicyVersion int
+	InputHash     string
+	Allowed       bool
+	Reason        string
+	Error         string
+	Latency       time.Duration
+	CreatedAt     time.Time
+}
+
+// PolicyInput is an input a policy was evaluated against, kept so
+// candidate rulesets can be dry-run against it.
+type PolicyInput struct {
+	TenantID  string
+	PolicyID  string
+	InputHash string
+	Input     []byte
+	CreatedAt time.Time
 }
 
 type AuditEvent struct {
-	ID      string
-	Action  string
-	Outcome string
+	ID        string
+	TenantID  string
+	ActorID   string
+	Action    string
+	Outcome   string
+	Detail    string
+	CreatedAt time.Time
 }
 
 type Artifact struct {
@@ -36,12 +116,28 @@ type JobStore interface {
 	Create(ctx context.Context, job Job) error
 	Get(ctx context.Context, id string) (Job, error)
 	UpdateStatus(ctx context.Context, id string, status string) error
+	AttachRun(ctx context.Context, id string, runID string) error
+	// Finish records the result of a job that is not yet in a terminal
+	// status and returns ErrJobFinished otherwise.
+	Finish(ctx context.Context, job Job) error
+	ListByStatus(ctx context.Context, status string) ([]Job, error)
 }
 
 type SessionStore interface {
 	Create(ctx context.Context, session Session) error
 	Get(ctx context.Context, id string) (Session, error)
 	UpdateStatus(ctx context.Context, id string, status string) error
+	ListByTenant(ctx context.Context, tenantID string) ([]Session, error)
+	// ListExpired returns active sessions whose ExpiresAt is before the given time.
+	ListExpired(ctx context.Context, before time.Time) ([]Session, error)
+	Touch(ctx context.Context, id string, lastActivity time.Time, expiresAt time.Time) error
+}
+
+// AdvisoryLock elects a single leader for periodic work shared by control
+// plane replicas. WithLock runs fn only if the named lock could be taken and
+// reports whether it ran.
+type AdvisoryLock interface {
+	WithLock(ctx context.Context, name string, fn func(context.Context) error) (bool, error)
 }
 
 type SessionStepStore interface {
@@ -54,12 +150,50 @@ type SessionStep struct {
 	SessionID string
 	Command   string
 	Status    string
+	StartedAt time.Time
+	Usage     ResourceUsage
 }
 
+// PolicyStore keeps every version of each policy. Upsert and Delete append
+// a version and return ErrStalePolicyVersion unless it is newer than all
+// stored ones. Get and List return current versions of undeleted policies;
+// List matches IDs by prefix. Versions returns the history oldest first,
+// tombstones included.
 type PolicyStore interface {
 	Upsert(ctx context.Context, policy Policy) error
+	Delete(ctx context.Context, tombstone Policy) error
+	Get(ctx context.Context, id string) (Policy, error)
+	List(ctx context.Context, prefix string) ([]Policy, error)
+	Versions(ctx context.Context, id string) ([]Policy, error)
+}
+
+// DecisionQuery selects part of a policy's decision log: the latest Limit
+// decisions made at or after Since and before Before. Zero times leave that
+// end open; Limit must be positive.
+type DecisionQuery struct {
+	Since  time.Time
+	Before time.Time
+	Limit  int
+}
+
+// PolicyDecisionStore keeps the policy decision log. List returns the
+// decisions query selects, oldest first.
+type PolicyDecisionStore interface {
+	Append(ctx context.Context, decision PolicyDecision) error
+	List(ctx context.Context, tenantID string, policyID string, query DecisionQuery) ([]PolicyDecision, error)
+}
+
+// PolicyInputStore keeps recently evaluated inputs. Record adds one, then
+// deletes all but the policy's newest keep inputs and every input recorded
+// before expiry. Recent returns the newest n of a policy's inputs recorded
+// at or after since, oldest first.
+type PolicyInputStore interface {
+	Record(ctx context.Context, input PolicyInput, keep int, expiry time.Time) error
+	Recent(ctx context.Context, tenantID string, policyID string, n int, since time.Time) ([]PolicyInput, error)
 }
 
+// AuditStore keeps audit events, which are only ever appended to. List
+// returns them oldest first.
 type AuditStore interface {
 	Append(ctx context.Context, event AuditEvent) error
 	List(ctx context.Context) ([]AuditEvent, error)
@@ -12,22 +12,67 @@ import (
 )
 
 type RunRequest struct {
-	JobID        string `json:"jobId"`
-	PolicyID     string `json:"policyId,omitempty"`
-	Language     string `json:"language"`
-	Code         string `json:"code"`
-	WorkspaceRef string `json:"workspaceRef"`
+	JobID          string   `json:"jobId"`
+	TenantID       string   `json:"tenantId,omitempty"`
+	PolicyID       string   `json:"policyId,omitempty"`
+	Language       string   `json:"language"`
+	Code           string   `json:"code"`
+	WorkspaceRef   string   `json:"workspaceRef"`
+	TimeoutSeconds int      `json:"timeout_seconds,omitempty"`
+	Deps           []string `json:"deps,omitempty"`
+	DepsAllowlist  []string `json:"depsAllowlist,omitempty"`
+	Limits         Limits   `json:"limits"`
+	RuntimeClass   string   `json:"runtimeClass,omitempty"`
+	// EgressAllowList is sent even when empty: null keeps the data
+	// plane's default allowlist, [] allows no egress.
+	EgressAllowList []string `json:"egressAllowList"`
+	Secrets         []string `json:"secrets,omitempty"`
+}
+
+// Limits caps the resources of a run or session. Zero fields are filled
+// from the data plane's defaults.
+type Limits struct {
+	CPUMillicores int   `json:"cpuMillicores,omitempty"`
+	MemoryBytes   int64 `json:"memoryBytes,omitempty"`
+	DiskBytes     int64 `json:"diskBytes,omitempty"`
+	PIDs          int   `json:"pids,omitempty"`
 }
 
 type RunResponse struct {
 	RunID string `json:"run_id"`
 }
 
+type RunStatus struct {
+	ID            string        `json:"id"`
+	JobID         string        `json:"jobId"`
+	Status        string        `json:"status"`
+	ExitStatus    int           `json:"exitStatus"`
+	OutputRef     string        `json:"outputRef"`
+	ErrorRef      string        `json:"errorRef"`
+	ArtifactRefs  []string      `json:"artifactRefs"`
+	ResourceUsage ResourceUsage `json:"resourceUsage"`
+}
+
+type ResourceUsage struct {
+	CPUTimeMs   int64 `json:"cpuTimeMs"`
+	MaxRSSBytes int64 `json:"maxRssBytes"`
+	IOBytes     int64 `json:"ioBytes"`
+}
+
 type SessionCreateRequest struct {
-	SessionID    string `json:"sessionId"`
-	PolicyID     string `json:"policyId,omitempty"`
-	WorkspaceRef string `json:"workspaceRef"`
-	Runtime      string `json:"runtime,omitempty"`
+	SessionID     string   `json:"sessionId"`
+	TenantID      string   `json:"tenantId,omitempty"`
+	PolicyID      string   `json:"policyId,omitempty"`
+	WorkspaceRef  string   `json:"workspaceRef"`
+	Runtime       string   `json:"runtime,omitempty"`
+	Deps          []string `json:"deps,omitempty"`
+	DepsAllowlist []string `json:"depsAllowlist,omitempty"`
+	SnapshotID    string   `json:"snapshotId,omitempty"`
+	Limits        Limits   `json:"limits"`
+	RuntimeClass  string   `json:"runtimeClass,omitempty"`
+	// EgressAllowList follows RunRequest.EgressAllowList.
+	EgressAllowList []string `json:"egressAllowList"`
+	Secrets         []string `json:"secrets,omitempty"`
 }
 
 type SessionResponse struct {
@@ -36,14 +81,19 @@ type SessionResponse struct {
 	Status    string `json:"status"`
 }
 
+// SessionStepRequest runs Command in a session. A zero TimeoutMs leaves the
+// session agent's default step timeout.
 type SessionStepRequest struct {
-	Command string `json:"command"`
+	Command   string `json:"command"`
+	TimeoutMs int64  `json:"timeoutMs,omitempty"`
 }
 
 type SessionStepResponse struct {
-	Status string `json:"status"`
-	Stdout string `json:"stdout"`
-	Stderr string `json:"stderr"`
+	Status        string        `json:"status"`
+	Stdout        string        `json:"stdout"`
+	Stderr        string        `json:"stderr"`
+	StateLost     bool          `json:"stateLost"`
+	ResourceUsage ResourceUsage `json:"resourceUsage"`
 }
 
 type DataPlaneClient struct {
@@ -88,6 +138,40 @@ func (c DataPlaneClient) StartRun(ctx context.Context, req RunRequest) (RunRespo
 	return decoded, nil
 }
 
+func (c DataPlaneClient) GetRun(ctx context.Context, runID string) (RunStatus, error) {
+	if c.BaseURL == "" {
+		return RunStatus{}, errors.New("missing base url")
+	}
+	if runID == "" {
+		return RunStatus{}, errors.New("missing run id")
+	}
+	client := c.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	url := strings.TrimRight(c.BaseURL, "/") + "/runs/" + runID
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return RunStatus{}, err
+	}
+	if c.AuthToken != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.AuthToken)
+	}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return RunStatus{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return RunStatus{}, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	var decoded RunStatus
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return RunStatus{}, err
+	}
+	return decoded, nil
+}
+
 func (c DataPlaneClient) StartSession(ctx context.Context, req SessionCreateRequest) (SessionResponse, error) {
 	if c.BaseURL == "" {
 		return SessionResponse{}, errors.New("missing base url")
@@ -124,21 +208,22 @@ func (c DataPlaneClient) StartSession(ctx context.Context, req SessionCreateRequ
 	return decoded, nil
 }
 
-func (c DataPlaneClient) RunSessionStep(ctx context.Context, sessionID string, command string) (SessionStepResponse, error) {
+func (c DataPlaneClient) RunSessionStep(ctx context.Context, sessionID string, req SessionStepRequest) (SessionStepResponse, error) {
 	if c.BaseURL == "" {
 		return SessionStepResponse{}, errors.New("missing base url")
 	}
 	if sessionID == "" {
 		return SessionStepResponse{}, errors.New("missing session id")
 	}
-	if command == "" {
+	if req.Command == "" {
 		return SessionStepResponse{}, errors.New("missing command")
 	}
 	client := c.Client
 	if client == nil {
-		client = &http.Client{Timeout: 10 * time.Second}
+		// Leave the step its own timeout before giving up on the response.
+		client = &http.Client{Timeout: 10*time.Second + time.Duration(req.TimeoutMs)*time.Millisecond}
 	}
-	body, err := json.Marshal(SessionStepRequest{Command: command})
+	body, err := json.Marshal(req)
 	if err != nil {
 		return SessionStepResponse{}, err
 	}
@@ -165,3 +250,80 @@ func (c DataPlaneClient) RunSessionStep(ctx context.Context, sessionID string, c
 	}
 	return decoded, nil
 }
+
+var ErrInterruptUnsupported = errors.New("session runtime does not support interrupts")
+
+// InterruptSession stops the step running in a session and reports whether
+// one was running. It returns ErrInterruptUnsupported for sessions that are
+// not backed by a session agent.
+func (c DataPlaneClient) InterruptSession(ctx context.Context, sessionID string) (bool, error) {
+	if c.BaseURL == "" {
+		return false, errors.New("missing base url")
+	}
+	if sessionID == "" {
+		return false, errors.New("missing session id")
+	}
+	client := c.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	url := strings.TrimRight(c.BaseURL, "/") + "/sessions/" + sessionID + "/interrupt"
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
+	if err != nil {
+		return false, err
+	}
+	if c.AuthToken != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.AuthToken)
+	}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusAccepted:
+	case http.StatusNotImplemented:
+		return false, ErrInterruptUnsupported
+	default:
+		return false, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	var decoded struct {
+		Interrupted bool `json:"interrupted"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return false, err
+	}
+	return decoded.Interrupted, nil
+}
+
+// TerminateSession tears down a session runtime. A session the data plane
+// no longer knows about counts as terminated.
+func (c DataPlaneClient) TerminateSession(ctx context.Context, sessionID string) error {
+	if c.BaseURL == "" {
+		return errors.New("missing base url")
+	}
+	if sessionID == "" {
+		return errors.New("missing session id")
+	}
+	client := c.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	url := strings.TrimRight(c.BaseURL, "/") + "/sessions/" + sessionID + "/terminate"
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
+	if err != nil {
+		return err
+	}
+	if c.AuthToken != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.AuthToken)
+	}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// FileChecksumHeader carries the hex SHA-256 of a downloaded file.
+const FileChecksumHeader = "X-Checksum-Sha256"
+
+var (
+	ErrInvalidFilePath      = errors.New("invalid session file path")
+	ErrFileNotFound         = errors.New("session file not found")
+	ErrFileTooLarge         = errors.New("session file exceeds the size limit")
+	ErrWorkspaceUnavailable = errors.New("session has no workspace")
+)
+
+type SessionFile struct {
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	Checksum string `json:"checksum"`
+}
+
+// UploadSessionFile streams body into path under the session workspace. A
+// maxBytes of zero leaves the size unlimited.
+func (c DataPlaneClient) UploadSessionFile(ctx context.Context, sessionID string, path string, body io.Reader, maxBytes int64) (SessionFile, error) {
+	resp, err := c.doFileRequest(ctx, http.MethodPut, sessionID, path, body, maxBytes)
+	if err != nil {
+		return SessionFile{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return SessionFile{}, fileStatusError(resp)
+	}
+	var decoded SessionFile
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return SessionFile{}, err
+	}
+	return decoded, nil
+}
+
+// DownloadSessionFile opens path under the session workspace. The caller
+// closes the returned body. Size is -1 when the data plane sent no length.
+func (c DataPlaneClient) DownloadSessionFile(ctx context.Context, sessionID string, path string, maxBytes int64) (io.ReadCloser, SessionFile, error) {
+	resp, err := c.doFileRequest(ctx, http.MethodGet, sessionID, path, nil, maxBytes)
+	if err != nil {
+		return nil, SessionFile{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, SessionFile{}, fileStatusError(resp)
+	}
+	return resp.Body, SessionFile{
+		Path:     path,
+		Size:     resp.ContentLength,
+		Checksum: resp.Header.Get(FileChecksumHeader),
+	}, nil
+}
+
+func (c DataPlaneClient) doFileRequest(ctx context.Context, method string, sessionID string, path string, body io.Reader, maxBytes int64) (*http.Response, error) {
+	if c.BaseURL == "" {
+		return nil, errors.New("missing base url")
+	}
+	if sessionID == "" {
+		return nil, errors.New("missing session id")
+	}
+	if path == "" {
+		return nil, errors.New("missing file path")
+	}
+	// A configured client timeout would cut large transfers short; ctx bounds them.
+	client := &http.Client{}
+	if c.Client != nil {
+		client.Transport = c.Client.Transport
+	}
+	query := url.Values{"path": {path}}
+	if maxBytes > 0 {
+		query.Set("maxBytes", strconv.FormatInt(maxBytes, 10))
+	}
+	target := strings.TrimRight(c.BaseURL, "/") + "/sessions/" + sessionID + "/files?" + query.Encode()
+	httpReq, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/octet-stream")
+	}
+	if c.AuthToken != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.AuthToken)
+	}
+	return client.Do(httpReq)
+}
+
+func fileStatusError(resp *http.Response) error {
+	switch resp.StatusCode {
+	case http.StatusBadRequest:
+		return ErrInvalidFilePath
+	case http.StatusNotFound:
+		return ErrFileNotFound
+	case http.StatusRequestEntityTooLarge:
+		return ErrFileTooLarge
+	case http.StatusConflict:
+		return ErrWorkspaceUnavailable
+	}
+	return fmt.Errorf("unexpected status: %s", resp.Status)
+}
@@ -0,0 +1,107 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	ErrSnapshotNotFound         = errors.New("snapshot not found")
+	ErrSnapshotStateUnavailable = errors.New("interpreter state could not be captured")
+	ErrSnapshotsUnsupported     = errors.New("data plane does not support session snapshots")
+)
+
+type SessionSnapshot struct {
+	ID        string    `json:"id"`
+	SessionID string    `json:"sessionId"`
+	Runtime   string    `json:"runtime"`
+	HasState  bool      `json:"hasState"`
+	Size      int64     `json:"size"`
+	Checksum  string    `json:"checksum"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type sessionSnapshotRequest struct {
+	IncludeState bool `json:"includeState"`
+}
+
+// SnapshotSession stores an encrypted archive of the session workspace on
+// the data plane, with the interpreter globals when includeState is set.
+func (c DataPlaneClient) SnapshotSession(ctx context.Context, sessionID string, includeState bool) (SessionSnapshot, error) {
+	if sessionID == "" {
+		return SessionSnapshot{}, errors.New("missing session id")
+	}
+	body, err := json.Marshal(sessionSnapshotRequest{IncludeState: includeState})
+	if err != nil {
+		return SessionSnapshot{}, err
+	}
+	resp, err := c.doSnapshotRequest(ctx, http.MethodPost, "/sessions/"+sessionID+"/snapshot", body)
+	if err != nil {
+		return SessionSnapshot{}, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusCreated:
+	case http.StatusUnprocessableEntity:
+		return SessionSnapshot{}, ErrSnapshotStateUnavailable
+	case http.StatusNotImplemented:
+		return SessionSnapshot{}, ErrSnapshotsUnsupported
+	default:
+		return SessionSnapshot{}, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	var decoded SessionSnapshot
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return SessionSnapshot{}, err
+	}
+	return decoded, nil
+}
+
+func (c DataPlaneClient) GetSnapshot(ctx context.Context, snapshotID string) (SessionSnapshot, error) {
+	if snapshotID == "" {
+		return SessionSnapshot{}, errors.New("missing snapshot id")
+	}
+	resp, err := c.doSnapshotRequest(ctx, http.MethodGet, "/snapshots/"+snapshotID, nil)
+	if err != nil {
+		return SessionSnapshot{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return SessionSnapshot{}, ErrSnapshotNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return SessionSnapshot{}, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	var decoded SessionSnapshot
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return SessionSnapshot{}, err
+	}
+	return decoded, nil
+}
+
+func (c DataPlaneClient) doSnapshotRequest(ctx context.Context, method string, path string, body []byte) (*http.Response, error) {
+	if c.BaseURL == "" {
+		return nil, errors.New("missing base url")
+	}
+	// Archiving a large workspace can outlast the default client timeout.
+	client := &http.Client{}
+	if c.Client != nil {
+		client.Transport = c.Client.Transport
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(c.BaseURL, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	if c.AuthToken != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.AuthToken)
+	}
+	return client.Do(httpReq)
+}
@@ -0,0 +1,60 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"shared/sessionagent"
+)
+
+// StreamSessionStep runs a step through the data plane's streaming endpoint
+// and calls emit for every output chunk as it arrives.
+func (c DataPlaneClient) StreamSessionStep(ctx context.Context, sessionID string, req SessionStepRequest, emit func(sessionagent.StepEvent) error) (sessionagent.StepResult, error) {
+	if c.BaseURL == "" {
+		return sessionagent.StepResult{}, errors.New("missing base url")
+	}
+	if sessionID == "" {
+		return sessionagent.StepResult{}, errors.New("missing session id")
+	}
+	if req.Command == "" {
+		return sessionagent.StepResult{}, errors.New("missing command")
+	}
+	// A configured client timeout would cut long steps short; ctx bounds them.
+	client := &http.Client{}
+	if c.Client != nil {
+		client.Transport = c.Client.Transport
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return sessionagent.StepResult{}, err
+	}
+	url := strings.TrimRight(c.BaseURL, "/") + "/sessions/" + sessionID + "/steps/stream"
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return sessionagent.StepResult{}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "text/event-stream")
+	if c.AuthToken != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.AuthToken)
+	}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return sessionagent.StepResult{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return sessionagent.StepResult{}, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return sessionagent.ReadStepEvents(resp.Body, func(event sessionagent.StepEvent) error {
+		if event.Type == sessionagent.StepEventStatus || emit == nil {
+			return nil
+		}
+		return emit(event)
+	})
+}
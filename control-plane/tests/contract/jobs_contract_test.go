@@ -4,21 +4,30 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v5"
 
+	"control-plane/internal/api"
 	"control-plane/internal/api/handlers"
+	"control-plane/internal/api/middleware"
 	"control-plane/internal/orchestration"
 	"control-plane/internal/policy"
 	"control-plane/internal/storage"
 	"control-plane/pkg/client"
+	"shared/pkg/contracts"
 )
 
 func TestJobsContractCreate(t *testing.T) {
+	var runReq client.RunRequest
 	dataPlane := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&runReq)
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusAccepted)
 		_, _ = w.Write([]byte(`{"run_id":"run-1"}`))
@@ -36,11 +45,12 @@ func TestJobsContractCreate(t *testing.T) {
 	handler := handlers.JobHandler{Service: service, Store: store}
 
 	payload := map[string]any{
-		"tenantId": "tenant-1",
-		"agentId":  "agent-1",
-		"policyId": "policy-1",
-		"language": "python",
-		"code":     "print('ok')",
+		"tenantId":       "tenant-1",
+		"agentId":        "agent-1",
+		"policyId":       "policy-1",
+		"language":       "python",
+		"code":           "print('ok')",
+		"timeoutSeconds": 30,
 	}
 	body, err := json.Marshal(payload)
 	if err != nil {
@@ -61,6 +71,19 @@ func TestJobsContractCreate(t *testing.T) {
 	if resp["id"] == "" || resp["status"] == "" {
 		t.Fatalf("expected id and status")
 	}
+	if runReq.TimeoutSeconds != 30 {
+		t.Fatalf("expected timeout to be forwarded, got %d", runReq.TimeoutSeconds)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/jobs", bytes.NewReader(body)))
+	var second map[string]string
+	if err := json.NewDecoder(rec.Body).Decode(&second); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if second["id"] == "" || second["id"] == resp["id"] {
+		t.Fatalf("expected a distinct job id, got %q twice", resp["id"])
+	}
 }
 
 func TestJobsContractGet(t *testing.T) {
@@ -98,6 +121,25 @@ func (m *mockStore) UpdateStatus(ctx context.Context, id string, status string)
 	return nil
 }
 
+func (m *mockStore) AttachRun(ctx context.Context, id string, runID string) error {
+	_ = id
+	m.job.RunID = runID
+	return nil
+}
+
+func (m *mockStore) Finish(ctx context.Context, job storage.Job) error {
+	m.job = job
+	return nil
+}
+
+func (m *mockStore) ListByStatus(ctx context.Context, status string) ([]storage.Job, error) {
+	_ = ctx
+	if m.job.Status != status {
+		return nil, nil
+	}
+	return []storage.Job{m.job}, nil
+}
+
 type allowAllEvaluator struct{}
 
 func (allowAllEvaluator) Evaluate(ctx context.Context, input any) (policy.Decision, error) {
@@ -105,3 +147,218 @@ func (allowAllEvaluator) Evaluate(ctx context.Context, input any) (policy.Decisi
 	_ = input
 	return policy.Decision{Allowed: true}, nil
 }
+
+func TestJobsContractCompletionCallback(t *testing.T) {
+	store := &mockStore{job: storage.Job{ID: "job-1", Status: "running", RunID: "job-1-run"}}
+	service := orchestration.JobService{Store: store}
+	router := api.InternalRouter(api.InternalDependencies{JobService: &service, CallbackToken: "secret"})
+
+	body := []byte(`{"runId":"job-1-run","status":"finished","exitStatus":0,"outputRef":"out","errorRef":"err","artifactRefs":["report.csv"],"resourceUsage":{"cpuTimeMs":120,"maxRssBytes":4096,"ioBytes":512}}`)
+
+	req := httptest.NewRequest(http.MethodPost, "/jobs/job-1/complete", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected %d without token, got %d", http.StatusUnauthorized, rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/jobs/job-1/complete", bytes.NewReader(body))
+	req.Header.Set("X-Callback-Token", "secret")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected %d, got %d", http.StatusNoContent, rec.Code)
+	}
+	if store.job.Status != "finished" || store.job.OutputRef != "out" || len(store.job.ArtifactRefs) != 1 {
+		t.Fatalf("expected job to be finished, got %+v", store.job)
+	}
+	if store.job.Usage != (storage.ResourceUsage{CPUTimeMs: 120, MaxRSSBytes: 4096, IOBytes: 512}) {
+		t.Fatalf("expected resource usage to be stored, got %+v", store.job.Usage)
+	}
+}
+
+func signedToken(t *testing.T, secret string, tenantID string) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, middleware.Claims{TenantID: tenantID, AgentID: "agent-1"})
+	signed, err := token.SignedString([]byte(secret))
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return signed
+}
+
+func TestJobsContractGetScopedToTenant(t *testing.T) {
+	t.Setenv("AUTH_JWT_SECRET", "test-secret")
+	finishedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	store := &mockStore{job: storage.Job{
+		ID:           "job-1",
+		TenantID:     "tenant-1",
+		AgentID:      "agent-1",
+		PolicyID:     "policy-1",
+		Language:     "python",
+		Status:       "finished",
+		RunID:        "job-1-run",
+		ExitStatus:   1,
+		OutputRef:    "out",
+		ErrorRef:     "err",
+		ArtifactRefs: []string{"report.csv"},
+		FinishedAt:   finishedAt,
+		Usage:        storage.ResourceUsage{CPUTimeMs: 120, MaxRSSBytes: 4096, IOBytes: 512},
+	}}
+	router := api.RouterWithDependencies(api.Dependencies{JobStore: store})
+
+	req := httptest.NewRequest(http.MethodGet, "/jobs/job-1", nil)
+	req.Header.Set("Authorization", "Bearer "+signedToken(t, "test-secret", "tenant-1"))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, rec.Code)
+	}
+	var resp map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp["tenant_id"] != "tenant-1" || resp["language"] != "python" || resp["output_ref"] != "out" || resp["exit_status"] != float64(1) {
+		t.Fatalf("expected full job state, got %v", resp)
+	}
+	if resp["finished_at"] != finishedAt.Format(time.RFC3339) {
+		t.Fatalf("expected finished_at, got %v", resp["finished_at"])
+	}
+	usage, _ := resp["resource_usage"].(map[string]any)
+	if usage["cpu_time_ms"] != float64(120) || usage["max_rss_bytes"] != float64(4096) || usage["io_bytes"] != float64(512) {
+		t.Fatalf("expected resource usage, got %v", resp["resource_usage"])
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/jobs/job-1", nil)
+	req.Header.Set("Authorization", "Bearer "+signedToken(t, "test-secret", "tenant-2"))
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected %d for other tenant, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestJobsContractDeniedByPolicy(t *testing.T) {
+	t.Setenv("AUTH_JWT_SECRET", "test-secret")
+	ctx := context.Background()
+	policies := policy.NewInMemoryStore()
+	if err := policies.Upsert(ctx, policy.Policy{ID: policy.Key("tenant-1", "policy-1"), Version: 4, Ruleset: "package policy\nallow = false\nreason = \"bash is not allowed\"\n"}); err != nil {
+		t.Fatalf("upsert policy: %v", err)
+	}
+	decisions := &policy.InMemoryDecisionLog{}
+	evaluator := &policy.OPAEvaluator{Resolver: policy.StoreRulesetResolver{Store: policies}}
+	service := orchestration.JobService{
+		Store:    &mockStore{},
+		Enforcer: orchestration.PolicyEnforcer{Evaluator: policy.RecordingEvaluator{Evaluator: evaluator, Decisions: decisions}},
+	}
+	policyService := policy.Service{Store: policies, Evaluator: evaluator, Decisions: decisions}
+	router := api.RouterWithDependencies(api.Dependencies{JobService: &service, PolicyService: &policyService})
+	token := signedToken(t, "test-secret", "tenant-1")
+
+	req := httptest.NewRequest(http.MethodPost, "/jobs", bytes.NewReader([]byte(`{"policyId":"policy-1","language":"bash","code":"echo hi"}`)))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected %d, got %d", http.StatusForbidden, rec.Code)
+	}
+	var denied contracts.Error
+	if err := json.NewDecoder(rec.Body).Decode(&denied); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if denied.Code != contracts.ErrForbidden || denied.Message != "bash is not allowed" {
+		t.Fatalf("expected a forbidden error with the policy's reason, got %+v", denied)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/policies/policy-1/decisions", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	var logged []map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&logged); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(logged) != 1 || logged[0]["policy_version"] != float64(4) || logged[0]["allowed"] != false || logged[0]["reason"] != "bash is not allowed" {
+		t.Fatalf("expected the denial in the decision log, got %v", logged)
+	}
+	if hash, _ := logged[0]["input_hash"].(string); len(hash) != 64 {
+		t.Fatalf("expected a sha-256 input hash, got %v", logged[0]["input_hash"])
+	}
+	if _, ok := logged[0]["latency_ms"].(float64); !ok {
+		t.Fatalf("expected latency, got %v", logged[0])
+	}
+	if workload, _ := logged[0]["workload_id"].(string); !strings.HasPrefix(workload, "job-") {
+		t.Fatalf("expected the job id in the decision log, got %v", logged[0]["workload_id"])
+	}
+	for _, query := range []string{"limit=0", "limit=x", "since=yesterday"} {
+		req = httptest.NewRequest(http.MethodGet, "/policies/policy-1/decisions?"+query, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected %d for %s, got %d", http.StatusBadRequest, query, rec.Code)
+		}
+	}
+	req = httptest.NewRequest(http.MethodGet, "/policies/policy-1/decisions?before="+logged[0]["time"].(string), nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if err := json.NewDecoder(rec.Body).Decode(&logged); err != nil || len(logged) != 0 {
+		t.Fatalf("expected no decisions before the only one, got %v (%v)", logged, err)
+	}
+
+	if err := policies.Upsert(ctx, policy.Policy{ID: policy.Key("tenant-1", "broken"), Version: 1, Ruleset: "package policy\nallow = true\nruntime_class = \"kvm\"\n"}); err != nil {
+		t.Fatalf("upsert policy: %v", err)
+	}
+	for _, tc := range []struct {
+		policyID string
+		status   int
+		code     contracts.ErrorCode
+		message  string
+	}{
+		{policyID: "missing", status: http.StatusNotFound, code: contracts.ErrNotFound, message: "policy not found"},
+		{policyID: "broken", status: http.StatusForbidden, code: contracts.ErrForbidden, message: "policy evaluation failed"},
+	} {
+		req = httptest.NewRequest(http.MethodPost, "/jobs", strings.NewReader(`{"policyId":"`+tc.policyID+`","language":"bash","code":"echo hi"}`))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		var failed contracts.Error
+		if err := json.NewDecoder(rec.Body).Decode(&failed); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		if rec.Code != tc.status || failed.Code != tc.code || !strings.HasPrefix(failed.Message, tc.message) {
+			t.Fatalf("expected %d %s for policy %s, got %d %+v", tc.status, tc.code, tc.policyID, rec.Code, failed)
+		}
+	}
+}
+
+type failingDecisionLog struct{}
+
+func (failingDecisionLog) Append(ctx context.Context, record policy.DecisionRecord) error {
+	return errors.New("decision store unavailable")
+}
+
+func (failingDecisionLog) List(ctx context.Context, tenantID string, policyID string, query policy.DecisionQuery) ([]policy.DecisionRecord, error) {
+	return nil, nil
+}
+
+func TestJobsContractRefusedWhenDecisionNotLogged(t *testing.T) {
+	ctx := context.Background()
+	policies := policy.NewInMemoryStore()
+	if err := policies.Upsert(ctx, policy.Policy{ID: policy.Key("tenant-1", "policy-1"), Version: 1, Ruleset: "package policy\nallow = true\n"}); err != nil {
+		t.Fatalf("upsert policy: %v", err)
+	}
+	store := &mockStore{}
+	service := orchestration.JobService{
+		Store: store,
+		Enforcer: orchestration.PolicyEnforcer{Evaluator: policy.RecordingEvaluator{
+			Evaluator: &policy.OPAEvaluator{Resolver: policy.StoreRulesetResolver{Store: policies}},
+			Decisions: failingDecisionLog{},
+		}},
+	}
+	_, err := service.CreateJob(ctx, orchestration.Job{ID: "job-1", TenantID: "tenant-1", PolicyID: "policy-1", Language: "bash"})
+	if err == nil || store.job.ID != "" {
+		t.Fatalf("expected an allowed job to be refused when its decision cannot be logged, got %v", err)
+	}
+}
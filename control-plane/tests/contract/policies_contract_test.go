@@ -2,18 +2,23 @@ package contract
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
+	"control-plane/internal/api"
 	"control-plane/internal/api/handlers"
+	"control-plane/internal/audit"
+	"control-plane/internal/orchestration"
 	"control-plane/internal/policy"
 )
 
 func TestPoliciesContract(t *testing.T) {
 	store := policy.NewInMemoryStore()
-	handler := handlers.PolicyHandler{Store: store}
+	handler := handlers.PolicyHandler{Service: policy.Service{Store: store}}
 
 	payload := map[string]any{
 		"tenantId": "tenant-1",
@@ -28,8 +33,198 @@ func TestPoliciesContract(t *testing.T) {
 
 	req := httptest.NewRequest(http.MethodPost, "/policies", bytes.NewReader(body))
 	rec := httptest.NewRecorder()
-	handler.ServeHTTP(rec, req)
+	handler.Create(rec, req)
 	if rec.Code != http.StatusOK {
 		t.Fatalf("expected %d, got %d", http.StatusOK, rec.Code)
 	}
 }
+
+func TestPoliciesContractVersionsRollbackAndDelete(t *testing.T) {
+	t.Setenv("AUTH_JWT_SECRET", "test-secret")
+	auditStore := &audit.InMemoryStore{}
+	service := policy.Service{Store: policy.NewInMemoryStore(), Logger: audit.StoreLogger{Store: auditStore}}
+	router := api.RouterWithDependencies(api.Dependencies{PolicyService: &service, AuditStore: auditStore})
+	token := signedToken(t, "test-secret", "tenant-1")
+	do := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	v1 := "package policy\nallow = true\n"
+	v2 := "package policy\nallow = false\n"
+	if rec := do(http.MethodPost, "/policies", `{"name":"default","ruleset":"`+strings.ReplaceAll(v1, "\n", `\n`)+`"}`); rec.Code != http.StatusOK {
+		t.Fatalf("expected %d creating v1, got %d", http.StatusOK, rec.Code)
+	}
+	rec := do(http.MethodPost, "/policies", `{"name":"default","ruleset":"`+strings.ReplaceAll(v2, "\n", `\n`)+`"}`)
+	var created map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&created); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if created["tenant_id"] != "tenant-1" || created["name"] != "default" || created["version"] != float64(2) || created["created_by"] != "agent-1" {
+		t.Fatalf("expected the next version, got %v", created)
+	}
+	if rec := do(http.MethodPost, "/policies", `{"name":"default","version":2,"ruleset":"stale"}`); rec.Code != http.StatusConflict {
+		t.Fatalf("expected %d for a stale version, got %d", http.StatusConflict, rec.Code)
+	}
+
+	rec = do(http.MethodPost, "/policies/default/rollback", `{"version":1}`)
+	var rolledBack map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&rolledBack); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if rolledBack["version"] != float64(3) || rolledBack["ruleset"] != v1 {
+		t.Fatalf("expected rollback to add version 3 with the v1 ruleset, got %v", rolledBack)
+	}
+	if rec := do(http.MethodPost, "/policies/default/rollback", `{"version":7}`); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected %d for an unknown version, got %d", http.StatusNotFound, rec.Code)
+	}
+
+	rec = do(http.MethodGet, "/policies", "")
+	var list []map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(list) != 1 || list[0]["version"] != float64(3) {
+		t.Fatalf("expected the current version listed, got %v", list)
+	}
+
+	if rec := do(http.MethodDelete, "/policies/default", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected %d, got %d", http.StatusNoContent, rec.Code)
+	}
+	if rec := do(http.MethodGet, "/policies/default", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected %d after delete, got %d", http.StatusNotFound, rec.Code)
+	}
+	rec = do(http.MethodGet, "/policies/default/versions", "")
+	var versions []map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&versions); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(versions) != 4 || versions[1]["ruleset"] != v2 || versions[3]["deleted"] != true {
+		t.Fatalf("expected full history with a tombstone, got %v", versions)
+	}
+
+	events, err := auditStore.List(context.Background())
+	if err != nil {
+		t.Fatalf("list audit: %v", err)
+	}
+	var actions []string
+	for _, event := range events {
+		if event.TenantID != "tenant-1" || event.ActorID != "agent-1" {
+			t.Fatalf("expected tenant and actor on %+v", event)
+		}
+		actions = append(actions, event.Action)
+	}
+	if strings.Join(actions, ",") != "policy_created,policy_updated,policy_rolled_back,policy_deleted" {
+		t.Fatalf("unexpected audit actions %v", actions)
+	}
+	if !strings.Contains(events[1].Detail, "-allow = true\n+allow = false\n") {
+		t.Fatalf("expected a ruleset diff, got %q", events[1].Detail)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/policies/default/versions", nil)
+	req.Header.Set("Authorization", "Bearer "+signedToken(t, "test-secret", "tenant-2"))
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected %d for other tenant, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestPoliciesContractEvaluateDryRun(t *testing.T) {
+	t.Setenv("AUTH_JWT_SECRET", "test-secret")
+	ctx := context.Background()
+	auditStore := &audit.InMemoryStore{}
+	store := policy.NewInMemoryStore()
+	evaluator := &policy.OPAEvaluator{Resolver: policy.StoreRulesetResolver{Store: store}}
+	inputs := &policy.InMemoryInputStore{}
+	service := policy.Service{Store: store, Evaluator: evaluator, Inputs: inputs}
+	router := api.RouterWithDependencies(api.Dependencies{PolicyService: &service, AuditStore: auditStore})
+
+	active := "package policy\nallow = true\n"
+	if _, err := service.Put(ctx, policy.Policy{ID: policy.Key("tenant-1", "default"), Ruleset: active}); err != nil {
+		t.Fatalf("put policy: %v", err)
+	}
+	// A production evaluation records its input for later dry runs.
+	recording := policy.RecordingEvaluator{Evaluator: evaluator, Inputs: inputs}
+	job := orchestration.Job{ID: "job-1", TenantID: "tenant-1", PolicyID: "default", Language: "bash"}
+	if decision, err := recording.Evaluate(ctx, job); err != nil || !decision.Allowed {
+		t.Fatalf("expected active policy to allow, got %+v (%v)", decision, err)
+	}
+
+	candidate := `package policy
+default allow = false
+allow { input.Language == "python" }
+reason = "only python" { not allow }
+`
+	body, err := json.Marshal(map[string]any{
+		"ruleset": candidate,
+		"inputs":  []any{map[string]any{"Language": "python"}},
+		"recent":  5,
+	})
+	if err != nil {
+		t.Fatalf("marshal payload: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/policies/default/evaluate", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+signedToken(t, "test-secret", "tenant-1"))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, rec.Code)
+	}
+	var resp struct {
+		Results []struct {
+			Input    map[string]any `json:"input"`
+			Decision struct {
+				Allowed bool   `json:"allowed"`
+				Reason  string `json:"reason"`
+			} `json:"decision"`
+			Active *struct {
+				Allowed bool `json:"allowed"`
+			} `json:"active"`
+			Changed bool `json:"changed"`
+		} `json:"results"`
+		Changed int `json:"changed"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(resp.Results) != 2 || resp.Changed != 1 {
+		t.Fatalf("expected the supplied and the recorded input, one changed, got %+v", resp)
+	}
+	if first := resp.Results[0]; !first.Decision.Allowed || first.Changed || first.Active == nil || !first.Active.Allowed {
+		t.Fatalf("expected python input to stay allowed, got %+v", first)
+	}
+	if second := resp.Results[1]; second.Input["ID"] != "job-1" || second.Decision.Allowed || second.Decision.Reason != "only python" || !second.Changed {
+		t.Fatalf("expected recorded bash job to be flagged as newly denied, got %+v", second)
+	}
+	if stored, err := store.Get(ctx, policy.Key("tenant-1", "default")); err != nil || stored.Ruleset != active {
+		t.Fatalf("expected dry run to leave the stored policy alone, got %+v (%v)", stored, err)
+	}
+	if events, err := auditStore.List(ctx); err != nil || len(events) != 0 {
+		t.Fatalf("expected recorded inputs to stay out of the audit log, got %+v (%v)", events, err)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/policies/default/evaluate", strings.NewReader(`{"ruleset":"package policy\nallow {","inputs":[{}]}`))
+	req.Header.Set("Authorization", "Bearer "+signedToken(t, "test-secret", "tenant-1"))
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected %d for a ruleset that does not compile, got %d", http.StatusBadRequest, rec.Code)
+	}
+	var compileErr struct {
+		Code     string `json:"code"`
+		Problems []struct {
+			Row     int    `json:"row"`
+			Message string `json:"message"`
+		} `json:"problems"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&compileErr); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if compileErr.Code != "invalid_ruleset" || len(compileErr.Problems) == 0 || compileErr.Problems[0].Row == 0 {
+		t.Fatalf("expected located compile problems, got %+v", compileErr)
+	}
+}
@@ -4,12 +4,17 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 
+	"control-plane/internal/api"
 	"control-plane/internal/api/handlers"
 	"control-plane/internal/orchestration"
 	"control-plane/internal/policy"
@@ -19,25 +24,66 @@ import (
 )
 
 type mockSessionStore struct {
-	session storage.Session
+	sessions []storage.Session
 }
 
 func (m *mockSessionStore) Create(ctx context.Context, session storage.Session) error {
-	m.session = session
+	m.sessions = append(m.sessions, session)
 	return nil
 }
 
 func (m *mockSessionStore) Get(ctx context.Context, id string) (storage.Session, error) {
 	_ = ctx
-	return m.session, nil
+	for _, session := range m.sessions {
+		if session.ID == id {
+			return session, nil
+		}
+	}
+	return storage.Session{}, errors.New("session not found")
 }
 
 func (m *mockSessionStore) UpdateStatus(ctx context.Context, id string, status string) error {
-	_ = id
-	m.session.Status = status
+	for i := range m.sessions {
+		if m.sessions[i].ID == id {
+			m.sessions[i].Status = status
+		}
+	}
+	return nil
+}
+
+func (m *mockSessionStore) ListExpired(ctx context.Context, before time.Time) ([]storage.Session, error) {
+	_ = ctx
+	var result []storage.Session
+	for _, session := range m.sessions {
+		if session.Status == "active" && session.ExpiresAt.Before(before) {
+			result = append(result, session)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockSessionStore) Touch(ctx context.Context, id string, lastActivity time.Time, expiresAt time.Time) error {
+	_ = ctx
+	for i := range m.sessions {
+		if m.sessions[i].ID == id {
+			m.sessions[i].LastActivity = lastActivity
+			m.sessions[i].ExpiresAt = expiresAt
+		}
+	}
 	return nil
 }
 
+func (m *mockSessionStore) ListByTenant(ctx context.Context, tenantID string) ([]storage.Session, error) {
+	_ = ctx
+	var result []storage.Session
+	for _, session := range m.sessions {
+		if session.TenantID == tenantID {
+			result = append(result, session)
+		}
+	}
+	return result, nil
+}
+
 type allowAllSessionEvaluator struct{}
 
 func (allowAllSessionEvaluator) Evaluate(ctx context.Context, input any) (policy.Decision, error) {
@@ -82,10 +128,20 @@ func TestSessionsContractCreate(t *testing.T) {
 	if rec.Code != http.StatusCreated {
 		t.Fatalf("expected %d, got %d", http.StatusCreated, rec.Code)
 	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/sessions", bytes.NewReader(body)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected %d, got %d", http.StatusCreated, rec.Code)
+	}
+	if len(store.sessions) != 2 || store.sessions[0].ID == store.sessions[1].ID {
+		t.Fatalf("expected distinct session ids, got %+v", store.sessions)
+	}
 }
 
 func TestSessionsContractStep(t *testing.T) {
 	handler := handlers.SessionHandler{
+		Service: activeSessionService(),
 		Stepper: sessions.StepService{
 			Runner: mockStepRunner{stepID: "step-1"},
 		},
@@ -99,22 +155,471 @@ func TestSessionsContractStep(t *testing.T) {
 	if rec.Code != http.StatusAccepted {
 		t.Fatalf("expected %d, got %d", http.StatusAccepted, rec.Code)
 	}
-	var stepResp map[string]string
+	var stepResp map[string]any
 	if err := json.NewDecoder(rec.Body).Decode(&stepResp); err != nil {
 		t.Fatalf("decode step response: %v", err)
 	}
 	if stepResp["stdout"] != "ok" {
 		t.Fatalf("expected stdout to be propagated")
 	}
+	if usage, _ := stepResp["resource_usage"].(map[string]any); usage["cpu_time_ms"] != float64(15) {
+		t.Fatalf("expected resource usage to be propagated, got %v", stepResp["resource_usage"])
+	}
+}
+
+func TestSessionsContractStepStream(t *testing.T) {
+	dataPlane := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/sessions/session-1/steps/stream" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("event: stdout\ndata: {\"type\":\"stdout\",\"data\":\"one\\n\"}\n\n" +
+			"event: stderr\ndata: {\"type\":\"stderr\",\"data\":\"warn\\n\"}\n\n" +
+			"event: status\ndata: {\"type\":\"status\",\"result\":{\"stepId\":\"step-9\",\"status\":\"completed\",\"resourceUsage\":{\"cpuTimeMs\":7}}}\n\n"))
+	}))
+	t.Cleanup(dataPlane.Close)
+
+	handler := handlers.SessionHandler{
+		Service: activeSessionService(),
+		Stepper: sessions.StepService{
+			Runner: sessions.DataPlaneStepRunner{Client: client.DataPlaneClient{BaseURL: dataPlane.URL}},
+		},
+	}
+	req := httptest.NewRequest(http.MethodPost, "/sessions/session-1/steps/stream", bytes.NewReader([]byte(`{"command":"print(1)"}`)))
+	routeCtx := chi.NewRouteContext()
+	routeCtx.URLParams.Add("sessionId", "session-1")
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, routeCtx))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected event stream, got %q", ct)
+	}
+	want := []string{
+		"event: stdout\ndata: {\"data\":\"one\\n\"}",
+		"event: stderr\ndata: {\"data\":\"warn\\n\"}",
+		"event: status\ndata: {\"id\":\"step-9\",\"status\":\"completed\",\"resource_usage\":{\"cpu_time_ms\":7",
+	}
+	body := rec.Body.String()
+	last := -1
+	for _, frame := range want {
+		idx := strings.Index(body, frame)
+		if idx <= last {
+			t.Fatalf("expected %q in order, got %q", frame, body)
+		}
+		last = idx
+	}
+}
+
+func TestSessionsContractStepTimeoutAndInterrupt(t *testing.T) {
+	var timeoutMs any
+	var interrupted []string
+	dataPlane := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sessions/session-1/steps":
+			var body map[string]any
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			timeoutMs = body["timeoutMs"]
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusAccepted)
+			_, _ = w.Write([]byte(`{"stepId":"step-1","status":"timed_out","stateLost":true}`))
+		case "/sessions/session-1/interrupt":
+			interrupted = append(interrupted, r.Method)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusAccepted)
+			_, _ = w.Write([]byte(`{"interrupted":true}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(dataPlane.Close)
+
+	store := &mockSessionStore{sessions: []storage.Session{
+		{ID: "session-1", TenantID: "tenant-1", Runtime: "python", Status: "active"},
+		{ID: "session-2", TenantID: "tenant-1", Runtime: "python", Status: "terminated"},
+		{ID: "session-3", TenantID: "tenant-1", Runtime: "python", Status: "expired"},
+	}}
+	handler := handlers.SessionHandler{
+		Service: sessions.Service{Store: store},
+		Stepper: sessions.StepService{Runner: sessions.DataPlaneStepRunner{Client: client.DataPlaneClient{BaseURL: dataPlane.URL}}},
+	}
+	do := func(path string, sessionID string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		routeCtx := chi.NewRouteContext()
+		routeCtx.URLParams.Add("sessionId", sessionID)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, routeCtx))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do("/sessions/session-1/steps", "session-1", `{"command":"while True: pass","timeoutMs":250}`)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected %d, got %d", http.StatusAccepted, rec.Code)
+	}
+	var stepResp map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&stepResp); err != nil {
+		t.Fatalf("decode step response: %v", err)
+	}
+	if timeoutMs != float64(250) || stepResp["status"] != "timed_out" || stepResp["state_lost"] != true {
+		t.Fatalf("expected the timeout forwarded and the step status and state loss reported, got timeoutMs=%v %v", timeoutMs, stepResp)
+	}
+	if rec := do("/sessions/session-1/steps", "session-1", `{"command":"ls","timeoutMs":-1}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected %d for a negative timeout, got %d", http.StatusBadRequest, rec.Code)
+	}
+
+	rec = do("/sessions/session-1/interrupt", "session-1", "")
+	if rec.Code != http.StatusAccepted || len(interrupted) != 1 || interrupted[0] != http.MethodPost {
+		t.Fatalf("expected the interrupt forwarded, got %d (%v)", rec.Code, interrupted)
+	}
+	var interruptResp map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&interruptResp); err != nil {
+		t.Fatalf("decode interrupt response: %v", err)
+	}
+	if interruptResp["id"] != "session-1" || interruptResp["interrupted"] != true {
+		t.Fatalf("unexpected interrupt response %v", interruptResp)
+	}
+	if rec := do("/sessions/session-2/interrupt", "session-2", ""); rec.Code != http.StatusConflict {
+		t.Fatalf("expected %d for a terminated session, got %d", http.StatusConflict, rec.Code)
+	}
+	if rec := do("/sessions/session-2/steps", "session-2", `{"command":"ls"}`); rec.Code != http.StatusConflict {
+		t.Fatalf("expected %d stepping a terminated session, got %d", http.StatusConflict, rec.Code)
+	}
+	if rec := do("/sessions/session-3/steps/stream", "session-3", `{"command":"ls"}`); rec.Code != http.StatusGone {
+		t.Fatalf("expected %d streaming an expired session, got %d", http.StatusGone, rec.Code)
+	}
+	if rec := do("/sessions/missing/steps", "missing", `{"command":"ls"}`); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected %d for an unknown session, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestSessionsContractStepStreamUnsupported(t *testing.T) {
+	handler := handlers.SessionHandler{
+		Service: activeSessionService(),
+		Stepper: sessions.StepService{Runner: mockStepRunner{stepID: "step-1"}},
+	}
+	req := httptest.NewRequest(http.MethodPost, "/sessions/session-1/steps/stream", bytes.NewReader([]byte(`{"command":"ls"}`)))
+	routeCtx := chi.NewRouteContext()
+	routeCtx.URLParams.Add("sessionId", "session-1")
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, routeCtx))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotImplemented {
+		t.Fatalf("expected %d, got %d", http.StatusNotImplemented, rec.Code)
+	}
+}
+
+func activeSessionService() sessions.Service {
+	return sessions.Service{Store: &mockSessionStore{sessions: []storage.Session{
+		{ID: "session-1", TenantID: "tenant-1", Runtime: "python", Status: "active"},
+	}}}
 }
 
 type mockStepRunner struct {
 	stepID string
 }
 
-func (m mockStepRunner) RunStep(ctx context.Context, sessionID string, command string) (sessions.StepResult, error) {
+func (m mockStepRunner) RunStep(ctx context.Context, sessionID string, req sessions.StepRequest) (sessions.StepResult, error) {
 	_ = ctx
 	_ = sessionID
-	_ = command
-	return sessions.StepResult{ID: m.stepID, Stdout: "ok", Stderr: ""}, nil
+	_ = req
+	return sessions.StepResult{ID: m.stepID, Stdout: "ok", Stderr: "", Usage: storage.ResourceUsage{CPUTimeMs: 15}}, nil
+}
+
+type mockStepStore struct {
+	steps []sessions.SessionStep
+}
+
+func (m *mockStepStore) AppendStep(ctx context.Context, step sessions.SessionStep) error {
+	_ = ctx
+	m.steps = append(m.steps, step)
+	return nil
+}
+
+func (m *mockStepStore) ListSteps(ctx context.Context, sessionID string) ([]sessions.SessionStep, error) {
+	_ = ctx
+	var result []sessions.SessionStep
+	for _, step := range m.steps {
+		if step.SessionID == sessionID {
+			result = append(result, step)
+		}
+	}
+	return result, nil
+}
+
+func TestSessionsContractReadAndDeleteScopedToTenant(t *testing.T) {
+	t.Setenv("AUTH_JWT_SECRET", "test-secret")
+	var terminated []string
+	dataPlane := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		terminated = append(terminated, r.URL.Path)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	t.Cleanup(dataPlane.Close)
+
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	store := &mockSessionStore{sessions: []storage.Session{
+		{ID: "session-1", TenantID: "tenant-1", AgentID: "agent-1", Runtime: "python", Status: "active", RuntimeID: "runtime-1", CreatedAt: createdAt},
+		{ID: "session-2", TenantID: "tenant-2", Runtime: "node", Status: "active"},
+	}}
+	steps := &mockStepStore{steps: []sessions.SessionStep{
+		{ID: "step-1", SessionID: "session-1", Command: "x = 1", Status: "completed", StartedAt: createdAt, Usage: storage.ResourceUsage{CPUTimeMs: 3}},
+	}}
+	router := api.RouterWithDependencies(api.Dependencies{
+		SessionService: &sessions.Service{Store: store, Client: client.DataPlaneClient{BaseURL: dataPlane.URL, Client: dataPlane.Client()}},
+		Stepper:        &sessions.StepService{Store: steps},
+	})
+	token := signedToken(t, "test-secret", "tenant-1")
+	do := func(method string, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodGet, "/sessions")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, rec.Code)
+	}
+	var list []map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatalf("decode list: %v", err)
+	}
+	if len(list) != 1 || list[0]["id"] != "session-1" || list[0]["runtime"] != "python" || list[0]["created_at"] != createdAt.Format(time.RFC3339) {
+		t.Fatalf("expected only tenant-1 sessions, got %v", list)
+	}
+
+	rec = do(http.MethodGet, "/sessions/session-1/steps")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, rec.Code)
+	}
+	var history []map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&history); err != nil {
+		t.Fatalf("decode steps: %v", err)
+	}
+	if len(history) != 1 || history[0]["command"] != "x = 1" || history[0]["started_at"] != createdAt.Format(time.RFC3339) {
+		t.Fatalf("expected step history, got %v", history)
+	}
+
+	for _, path := range []string{"/sessions/session-2", "/sessions/session-2/steps", "/sessions/missing"} {
+		if rec := do(http.MethodGet, path); rec.Code != http.StatusNotFound {
+			t.Fatalf("expected %d for %s, got %d", http.StatusNotFound, path, rec.Code)
+		}
+	}
+	if rec := do(http.MethodDelete, "/sessions/session-2"); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected %d deleting other tenant's session, got %d", http.StatusNotFound, rec.Code)
+	}
+	for _, path := range []string{"/sessions/session-2/steps", "/sessions/session-2/steps/stream", "/sessions/session-2/interrupt"} {
+		if rec := do(http.MethodPost, path); rec.Code != http.StatusNotFound {
+			t.Fatalf("expected %d posting to %s, got %d", http.StatusNotFound, path, rec.Code)
+		}
+	}
+
+	rec = do(http.MethodDelete, "/sessions/session-1")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, rec.Code)
+	}
+	if len(terminated) != 1 || terminated[0] != "/sessions/session-1/terminate" {
+		t.Fatalf("expected data plane terminate call, got %v", terminated)
+	}
+	rec = do(http.MethodGet, "/sessions/session-1")
+	var got map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decode session: %v", err)
+	}
+	if got["status"] != "terminated" || got["runtime_id"] != "runtime-1" {
+		t.Fatalf("expected terminated session, got %v", got)
+	}
+}
+
+type fileLimitEvaluator struct {
+	maxBytes int64
+}
+
+func (e fileLimitEvaluator) Evaluate(ctx context.Context, input any) (policy.Decision, error) {
+	_ = ctx
+	_ = input
+	return policy.Decision{Allowed: true, MaxFileBytes: e.maxBytes}, nil
+}
+
+func TestSessionsContractFiles(t *testing.T) {
+	t.Setenv("AUTH_JWT_SECRET", "test-secret")
+	files := map[string]string{}
+	dataPlane := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/sessions/session-1/files" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		path := r.URL.Query().Get("path")
+		if strings.HasPrefix(path, "../") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch r.Method {
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			if r.URL.Query().Get("maxBytes") != "8" {
+				t.Errorf("expected policy file limit, got %q", r.URL.Query().Get("maxBytes"))
+			}
+			if len(body) > 8 {
+				w.WriteHeader(http.StatusRequestEntityTooLarge)
+				return
+			}
+			files[path] = string(body)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(w).Encode(client.SessionFile{Path: path, Size: int64(len(body)), Checksum: "abc123"})
+		case http.MethodGet:
+			content, ok := files[path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set(client.FileChecksumHeader, "abc123")
+			_, _ = io.WriteString(w, content)
+		}
+	}))
+	t.Cleanup(dataPlane.Close)
+
+	store := &mockSessionStore{sessions: []storage.Session{
+		{ID: "session-1", TenantID: "tenant-1", Status: "active"},
+		{ID: "session-2", TenantID: "tenant-2", Status: "active"},
+	}}
+	router := api.RouterWithDependencies(api.Dependencies{
+		SessionService: &sessions.Service{
+			Store:    store,
+			Client:   client.DataPlaneClient{BaseURL: dataPlane.URL, Client: dataPlane.Client()},
+			Enforcer: orchestration.PolicyEnforcer{Evaluator: fileLimitEvaluator{maxBytes: 8}},
+		},
+	})
+	token := signedToken(t, "test-secret", "tenant-1")
+	do := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodPut, "/sessions/session-1/files?path=data/in.txt", "hello")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected %d, got %d", http.StatusCreated, rec.Code)
+	}
+	var uploaded map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&uploaded); err != nil {
+		t.Fatalf("decode upload: %v", err)
+	}
+	if uploaded["path"] != "data/in.txt" || uploaded["size"] != float64(5) || uploaded["checksum"] != "abc123" {
+		t.Fatalf("unexpected upload response: %v", uploaded)
+	}
+
+	rec = do(http.MethodGet, "/sessions/session-1/files?path=data/in.txt", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, rec.Code)
+	}
+	if rec.Body.String() != "hello" || rec.Header().Get(client.FileChecksumHeader) != "abc123" {
+		t.Fatalf("unexpected download: %q checksum %q", rec.Body.String(), rec.Header().Get(client.FileChecksumHeader))
+	}
+
+	cases := []struct {
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{http.MethodPut, "/sessions/session-1/files?path=big.bin", "more than eight bytes", http.StatusRequestEntityTooLarge},
+		{http.MethodPut, "/sessions/session-1/files?path=../escape", "x", http.StatusBadRequest},
+		{http.MethodPut, "/sessions/session-1/files", "x", http.StatusBadRequest},
+		{http.MethodGet, "/sessions/session-1/files?path=missing.txt", "", http.StatusNotFound},
+		{http.MethodGet, "/sessions/session-2/files?path=data/in.txt", "", http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		if rec := do(tc.method, tc.path, tc.body); rec.Code != tc.status {
+			t.Fatalf("expected %d for %s %s, got %d", tc.status, tc.method, tc.path, rec.Code)
+		}
+	}
+}
+
+func TestSessionsContractSnapshotRestore(t *testing.T) {
+	t.Setenv("AUTH_JWT_SECRET", "test-secret")
+	var started []client.SessionCreateRequest
+	dataPlane := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/sessions/session-1/snapshot":
+			var req map[string]bool
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			w.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(w).Encode(client.SessionSnapshot{ID: "snap-1", SessionID: "session-1", Runtime: "python", HasState: req["includeState"], Size: 10, Checksum: "abc"})
+		case r.Method == http.MethodGet && r.URL.Path == "/snapshots/snap-1":
+			_ = json.NewEncoder(w).Encode(client.SessionSnapshot{ID: "snap-1", SessionID: "session-1", Runtime: "python"})
+		case r.Method == http.MethodGet && r.URL.Path == "/snapshots/snap-2":
+			_ = json.NewEncoder(w).Encode(client.SessionSnapshot{ID: "snap-2", SessionID: "session-2", Runtime: "python"})
+		case r.Method == http.MethodPost && r.URL.Path == "/sessions":
+			var req client.SessionCreateRequest
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			started = append(started, req)
+			w.WriteHeader(http.StatusAccepted)
+			_ = json.NewEncoder(w).Encode(client.SessionResponse{ID: req.SessionID, RuntimeID: "runtime-new", Status: "running"})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(dataPlane.Close)
+
+	store := &mockSessionStore{sessions: []storage.Session{
+		{ID: "session-1", TenantID: "tenant-1", Runtime: "python", Status: "active"},
+		{ID: "session-2", TenantID: "tenant-2", Runtime: "python", Status: "active"},
+	}}
+	router := api.RouterWithDependencies(api.Dependencies{
+		SessionService: &sessions.Service{
+			Store:    store,
+			Client:   client.DataPlaneClient{BaseURL: dataPlane.URL, Client: dataPlane.Client()},
+			Enforcer: orchestration.PolicyEnforcer{Evaluator: allowAllSessionEvaluator{}},
+		},
+	})
+	token := signedToken(t, "test-secret", "tenant-1")
+	do := func(path string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do("/sessions/session-1/snapshot", `{"includeState":true}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected %d, got %d", http.StatusCreated, rec.Code)
+	}
+	var snapshot map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&snapshot); err != nil {
+		t.Fatalf("decode snapshot: %v", err)
+	}
+	if snapshot["id"] != "snap-1" || snapshot["session_id"] != "session-1" || snapshot["has_state"] != true {
+		t.Fatalf("unexpected snapshot response: %v", snapshot)
+	}
+	if rec := do("/sessions/session-2/snapshot", ``); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected %d snapshotting another tenant's session, got %d", http.StatusNotFound, rec.Code)
+	}
+
+	rec = do("/sessions", `{"snapshotId":"snap-1"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected %d restoring, got %d", http.StatusCreated, rec.Code)
+	}
+	if len(started) != 1 || started[0].SnapshotID != "snap-1" || started[0].Runtime != "python" {
+		t.Fatalf("expected the data plane to restore snap-1 into a python session, got %+v", started)
+	}
+
+	if rec := do("/sessions", `{"snapshotId":"snap-2","runtime":"python"}`); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected %d restoring another tenant's snapshot, got %d", http.StatusNotFound, rec.Code)
+	}
+	if rec := do("/sessions", `{"snapshotId":"snap-1","runtime":"node"}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected %d for a runtime mismatch, got %d", http.StatusBadRequest, rec.Code)
+	}
+	if len(started) != 1 {
+		t.Fatalf("expected rejected restores not to start sessions, got %+v", started)
+	}
 }
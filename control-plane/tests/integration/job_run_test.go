@@ -37,6 +37,25 @@ func (m *mockStore) UpdateStatus(ctx context.Context, id string, status string)
 	return nil
 }
 
+func (m *mockStore) AttachRun(ctx context.Context, id string, runID string) error {
+	_ = ctx
+	_ = id
+	_ = runID
+	return nil
+}
+
+func (m *mockStore) Finish(ctx context.Context, job storage.Job) error {
+	_ = ctx
+	m.updated = append(m.updated, job.ID+":"+job.Status)
+	return nil
+}
+
+func (m *mockStore) ListByStatus(ctx context.Context, status string) ([]storage.Job, error) {
+	_ = ctx
+	_ = status
+	return nil, nil
+}
+
 type allowAllEvaluator struct{}
 
 func (allowAllEvaluator) Evaluate(ctx context.Context, input any) (policy.Decision, error) {
@@ -46,11 +65,16 @@ func (allowAllEvaluator) Evaluate(ctx context.Context, input any) (policy.Decisi
 }
 
 func TestJobRunIntegration(t *testing.T) {
+	var run client.RunRequest
 	dataPlane := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost || r.URL.Path != "/runs" {
 			w.WriteHeader(http.StatusNotFound)
 			return
 		}
+		if err := json.NewDecoder(r.Body).Decode(&run); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusAccepted)
 		_, _ = w.Write([]byte(`{"run_id":"run-123"}`))
@@ -89,4 +113,7 @@ func TestJobRunIntegration(t *testing.T) {
 	if len(store.created) != 1 {
 		t.Fatalf("expected job to be created")
 	}
+	if run.TenantID != "tenant-1" {
+		t.Fatalf("expected the run to carry its tenant for secret lookup, got %q", run.TenantID)
+	}
 }
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"control-plane/internal/api/handlers"
 	"control-plane/internal/mcp"
@@ -37,6 +38,25 @@ func (m *mcpJobStore) UpdateStatus(ctx context.Context, id string, status string
 	return nil
 }
 
+func (m *mcpJobStore) AttachRun(ctx context.Context, id string, runID string) error {
+	_ = id
+	m.job.RunID = runID
+	return nil
+}
+
+func (m *mcpJobStore) Finish(ctx context.Context, job storage.Job) error {
+	m.job = job
+	return nil
+}
+
+func (m *mcpJobStore) ListByStatus(ctx context.Context, status string) ([]storage.Job, error) {
+	_ = ctx
+	if m.job.Status != status {
+		return nil, nil
+	}
+	return []storage.Job{m.job}, nil
+}
+
 type mcpSessionStore struct {
 	session storage.Session
 }
@@ -57,6 +77,26 @@ func (m *mcpSessionStore) UpdateStatus(ctx context.Context, id string, status st
 	return nil
 }
 
+func (m *mcpSessionStore) ListByTenant(ctx context.Context, tenantID string) ([]storage.Session, error) {
+	_ = ctx
+	_ = tenantID
+	return []storage.Session{m.session}, nil
+}
+
+func (m *mcpSessionStore) ListExpired(ctx context.Context, before time.Time) ([]storage.Session, error) {
+	_ = ctx
+	_ = before
+	return nil, nil
+}
+
+func (m *mcpSessionStore) Touch(ctx context.Context, id string, lastActivity time.Time, expiresAt time.Time) error {
+	_ = ctx
+	_ = id
+	_ = lastActivity
+	_ = expiresAt
+	return nil
+}
+
 type mcpArtifactStore struct {
 	artifacts map[string]storage.Artifact
 }
@@ -94,10 +134,10 @@ type mcpStepRunner struct {
 	stepID string
 }
 
-func (m mcpStepRunner) RunStep(ctx context.Context, sessionID string, command string) (sessions.StepResult, error) {
+func (m mcpStepRunner) RunStep(ctx context.Context, sessionID string, req sessions.StepRequest) (sessions.StepResult, error) {
 	_ = ctx
 	_ = sessionID
-	_ = command
+	_ = req
 	return sessions.StepResult{ID: m.stepID}, nil
 }
 
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 
@@ -39,6 +40,26 @@ func (m *mockSessionStore) UpdateStatus(ctx context.Context, id string, status s
 	return nil
 }
 
+func (m *mockSessionStore) ListByTenant(ctx context.Context, tenantID string) ([]storage.Session, error) {
+	_ = ctx
+	_ = tenantID
+	return m.created, nil
+}
+
+func (m *mockSessionStore) ListExpired(ctx context.Context, before time.Time) ([]storage.Session, error) {
+	_ = ctx
+	_ = before
+	return nil, nil
+}
+
+func (m *mockSessionStore) Touch(ctx context.Context, id string, lastActivity time.Time, expiresAt time.Time) error {
+	_ = ctx
+	_ = id
+	_ = lastActivity
+	_ = expiresAt
+	return nil
+}
+
 type allowAllSessionEvaluator struct{}
 
 func (allowAllSessionEvaluator) Evaluate(ctx context.Context, input any) (policy.Decision, error) {
@@ -103,7 +124,7 @@ func TestSessionFlowIntegration(t *testing.T) {
 	if stepRec.Code != http.StatusAccepted {
 		t.Fatalf("expected %d, got %d", http.StatusAccepted, stepRec.Code)
 	}
-	var stepResp map[string]string
+	var stepResp map[string]any
 	if err := json.NewDecoder(stepRec.Body).Decode(&stepResp); err != nil {
 		t.Fatalf("decode step response: %v", err)
 	}
@@ -120,10 +141,10 @@ type mockStepRunner struct {
 	stepID string
 }
 
-func (m mockStepRunner) RunStep(ctx context.Context, sessionID string, command string) (sessions.StepResult, error) {
+func (m mockStepRunner) RunStep(ctx context.Context, sessionID string, req sessions.StepRequest) (sessions.StepResult, error) {
 	_ = ctx
 	_ = sessionID
-	_ = command
+	_ = req
 	return sessions.StepResult{ID: m.stepID, Stdout: "out", Stderr: ""}, nil
 }
 
@@ -2,9 +2,15 @@ package integration
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"testing"
+	"time"
 
+	"control-plane/internal/audit"
 	"control-plane/internal/orchestration"
+	"control-plane/internal/policy"
 	"control-plane/internal/storage"
 	storefactory "control-plane/internal/storage/factory"
 )
@@ -26,7 +32,7 @@ func TestSQLiteStoresIntegration(t *testing.T) {
 		t.Fatalf("expected sqlite db handle")
 	}
 
-	job := storage.Job{ID: "job-1", Status: "queued"}
+	job := storage.Job{ID: "job-1", TenantID: "tenant-1", AgentID: "agent-1", PolicyID: "policy-1", Language: "python", Status: "queued"}
 	if err := stores.JobStore.Create(ctx, job); err != nil {
 		t.Fatalf("create job: %v", err)
 	}
@@ -40,15 +46,83 @@ func TestSQLiteStoresIntegration(t *testing.T) {
 	if gotJob.Status != "running" {
 		t.Fatalf("expected job status running, got %s", gotJob.Status)
 	}
+	if gotJob.TenantID != "tenant-1" || gotJob.Language != "python" || gotJob.CreatedAt.IsZero() || gotJob.UpdatedAt.IsZero() {
+		t.Fatalf("expected job metadata and timestamps, got %+v", gotJob)
+	}
+	if err := stores.JobStore.AttachRun(ctx, job.ID, "job-1-run"); err != nil {
+		t.Fatalf("attach run: %v", err)
+	}
+	running, err := stores.JobStore.ListByStatus(ctx, "running")
+	if err != nil {
+		t.Fatalf("list running jobs: %v", err)
+	}
+	if len(running) != 1 || running[0].RunID != "job-1-run" {
+		t.Fatalf("expected running job with run id, got %+v", running)
+	}
+	finished := running[0]
+	finished.Status = "finished"
+	finished.OutputRef = "out"
+	finished.ArtifactRefs = []string{"report.csv"}
+	finished.Usage = storage.ResourceUsage{CPUTimeMs: 120, MaxRSSBytes: 4096, IOBytes: 512}
+	if err := stores.JobStore.Finish(ctx, finished); err != nil {
+		t.Fatalf("finish job: %v", err)
+	}
+	gotJob, err = stores.JobStore.Get(ctx, job.ID)
+	if err != nil {
+		t.Fatalf("get finished job: %v", err)
+	}
+	if gotJob.Status != "finished" || gotJob.OutputRef != "out" || len(gotJob.ArtifactRefs) != 1 || gotJob.FinishedAt.IsZero() || gotJob.Usage != finished.Usage {
+		t.Fatalf("expected finished job with results, got %+v", gotJob)
+	}
+	finished.Status = "failed"
+	if err := stores.JobStore.Finish(ctx, finished); !errors.Is(err, storage.ErrJobFinished) {
+		t.Fatalf("expected a finished job not to be overwritten, got %v", err)
+	}
 
-	session := storage.Session{ID: "session-1", Status: "active"}
+	expiresAt := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	session := storage.Session{ID: "session-1", TenantID: "tenant-1", AgentID: "agent-1", Runtime: "python", Status: "active", ExpiresAt: expiresAt}
 	if err := stores.SessionStore.Create(ctx, session); err != nil {
 		t.Fatalf("create session: %v", err)
 	}
+	if err := stores.SessionStore.Create(ctx, storage.Session{ID: "session-2", TenantID: "tenant-2", Status: "active"}); err != nil {
+		t.Fatalf("create other tenant session: %v", err)
+	}
+	listed, err := stores.SessionStore.ListByTenant(ctx, "tenant-1")
+	if err != nil {
+		t.Fatalf("list sessions: %v", err)
+	}
+	if len(listed) != 1 || listed[0].ID != "session-1" || listed[0].Runtime != "python" || !listed[0].ExpiresAt.Equal(expiresAt) || listed[0].CreatedAt.IsZero() {
+		t.Fatalf("expected tenant-1 session with metadata, got %+v", listed)
+	}
+	if expired, err := stores.SessionStore.ListExpired(ctx, expiresAt.Add(time.Second)); err != nil || len(expired) != 1 || expired[0].ID != "session-1" {
+		t.Fatalf("expected session-1 to be expired, got %+v (%v)", expired, err)
+	}
+	activity := expiresAt.Add(time.Minute)
+	if err := stores.SessionStore.Touch(ctx, session.ID, activity, activity.Add(time.Hour)); err != nil {
+		t.Fatalf("touch session: %v", err)
+	}
+	if expired, err := stores.SessionStore.ListExpired(ctx, expiresAt.Add(time.Second)); err != nil || len(expired) != 0 {
+		t.Fatalf("expected extended session not to be expired, got %+v (%v)", expired, err)
+	}
+	gotSession, err := stores.SessionStore.Get(ctx, session.ID)
+	if err != nil || !gotSession.LastActivity.Equal(activity) {
+		t.Fatalf("expected last activity to be stored, got %+v (%v)", gotSession, err)
+	}
+	startedAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := stores.SessionStepStore.Append(ctx, storage.SessionStep{ID: "step-1", SessionID: session.ID, Command: "print(1)", Status: "completed", StartedAt: startedAt}); err != nil {
+		t.Fatalf("append step: %v", err)
+	}
+	steps, err := stores.SessionStepStore.List(ctx, session.ID)
+	if err != nil {
+		t.Fatalf("list steps: %v", err)
+	}
+	if len(steps) != 1 || steps[0].Command != "print(1)" || !steps[0].StartedAt.Equal(startedAt) {
+		t.Fatalf("expected recorded step, got %+v", steps)
+	}
 	if err := stores.SessionStore.UpdateStatus(ctx, session.ID, "expired"); err != nil {
 		t.Fatalf("update session: %v", err)
 	}
-	gotSession, err := stores.SessionStore.Get(ctx, session.ID)
+	gotSession, err = stores.SessionStore.Get(ctx, session.ID)
 	if err != nil {
 		t.Fatalf("get session: %v", err)
 	}
@@ -56,9 +130,101 @@ func TestSQLiteStoresIntegration(t *testing.T) {
 		t.Fatalf("expected session status expired, got %s", gotSession.Status)
 	}
 
-	if err := stores.PolicyStore.Upsert(ctx, storage.Policy{ID: "tenant-1:default", Version: 1}); err != nil {
+	if err := stores.PolicyStore.Upsert(ctx, storage.Policy{ID: "tenant-1:default", Version: 1, Ruleset: "package policy"}); err != nil {
 		t.Fatalf("upsert policy: %v", err)
 	}
+	if err := stores.PolicyStore.Upsert(ctx, storage.Policy{ID: "tenant-1:default", Version: 1, Ruleset: "stale"}); !errors.Is(err, storage.ErrStalePolicyVersion) {
+		t.Fatalf("expected stale version error, got %v", err)
+	}
+	if policy, err := stores.PolicyStore.Get(ctx, "tenant-1:default"); err != nil || policy.Ruleset != "package policy" {
+		t.Fatalf("expected stored ruleset, got %+v (%v)", policy, err)
+	}
+	if _, err := stores.PolicyStore.Get(ctx, "tenant-1:missing"); !errors.Is(err, storage.ErrPolicyNotFound) {
+		t.Fatalf("expected policy not found, got %v", err)
+	}
+	if err := stores.PolicyStore.Upsert(ctx, storage.Policy{ID: "tenant-1:default", Version: 2, Ruleset: "package policy.v2", CreatedBy: "agent-1"}); err != nil {
+		t.Fatalf("upsert policy v2: %v", err)
+	}
+	if policies, err := stores.PolicyStore.List(ctx, "tenant-1:"); err != nil || len(policies) != 1 || policies[0].Version != 2 || policies[0].CreatedBy != "agent-1" {
+		t.Fatalf("expected current version listed, got %+v (%v)", policies, err)
+	}
+	if err := stores.PolicyStore.Delete(ctx, storage.Policy{ID: "tenant-1:default", Version: 2}); !errors.Is(err, storage.ErrStalePolicyVersion) {
+		t.Fatalf("expected stale tombstone error, got %v", err)
+	}
+	if err := stores.PolicyStore.Delete(ctx, storage.Policy{ID: "tenant-1:default", Version: 3}); err != nil {
+		t.Fatalf("delete policy: %v", err)
+	}
+	if _, err := stores.PolicyStore.Get(ctx, "tenant-1:default"); !errors.Is(err, storage.ErrPolicyNotFound) {
+		t.Fatalf("expected deleted policy not found, got %v", err)
+	}
+	versions, err := stores.PolicyStore.Versions(ctx, "tenant-1:default")
+	if err != nil || len(versions) != 3 || versions[0].Ruleset != "package policy" || !versions[2].Deleted || versions[1].CreatedAt.IsZero() {
+		t.Fatalf("expected full policy history, got %+v (%v)", versions, err)
+	}
+	if err := stores.PolicyStore.Upsert(ctx, storage.Policy{ID: "tenant-1:default", Version: 3, Ruleset: "reused"}); !errors.Is(err, storage.ErrStalePolicyVersion) {
+		t.Fatalf("expected tombstone version to stay taken, got %v", err)
+	}
+
+	decision := storage.PolicyDecision{TenantID: "tenant-1", PolicyID: "default", PolicyVersion: 2, InputHash: "abc", Reason: "denied", Latency: 1500 * time.Microsecond}
+	if err := stores.DecisionStore.Append(ctx, decision); err != nil {
+		t.Fatalf("append decision: %v", err)
+	}
+	if decisions, err := stores.DecisionStore.List(ctx, "tenant-1", "default", storage.DecisionQuery{Limit: 10}); err != nil || len(decisions) != 1 || decisions[0].PolicyVersion != 2 ||
+		decisions[0].Latency != decision.Latency || decisions[0].Allowed || decisions[0].CreatedAt.IsZero() {
+		t.Fatalf("expected logged decision, got %+v (%v)", decisions, err)
+	}
+	base := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		// Whole and fractional seconds, whose RFC 3339 text does not sort.
+		at := base.Add(time.Duration(i) * 500 * time.Millisecond)
+		if err := stores.DecisionStore.Append(ctx, storage.PolicyDecision{TenantID: "tenant-1", PolicyID: "paged", PolicyVersion: i + 1, CreatedAt: at}); err != nil {
+			t.Fatalf("append decision: %v", err)
+		}
+	}
+	for _, tc := range []struct {
+		query    storage.DecisionQuery
+		versions []int
+	}{
+		{storage.DecisionQuery{Limit: 2}, []int{2, 3}},
+		{storage.DecisionQuery{Before: base.Add(time.Second), Limit: 10}, []int{1, 2}},
+		{storage.DecisionQuery{Since: base.Add(500 * time.Millisecond), Limit: 10}, []int{2, 3}},
+		{storage.DecisionQuery{Since: base, Before: base.Add(500 * time.Millisecond), Limit: 10}, []int{1}},
+	} {
+		decisions, err := stores.DecisionStore.List(ctx, "tenant-1", "paged", tc.query)
+		var versions []int
+		for _, decision := range decisions {
+			versions = append(versions, decision.PolicyVersion)
+		}
+		if err != nil || fmt.Sprint(versions) != fmt.Sprint(tc.versions) {
+			t.Fatalf("query %+v: expected versions %v, got %v (%v)", tc.query, tc.versions, versions, err)
+		}
+	}
+
+	inputs := policy.StorageInputStore{Store: stores.InputStore, Limit: 2}
+	for _, input := range []string{`{"n":1}`, `{"n":2}`, `{"n":3}`} {
+		if err := inputs.Record(ctx, policy.RecordedInput{TenantID: "tenant-1", PolicyID: "default", Input: json.RawMessage(input)}); err != nil {
+			t.Fatalf("record input: %v", err)
+		}
+	}
+	if recent, err := inputs.Recent(ctx, "tenant-1", "default", 5); err != nil || len(recent) != 2 || string(recent[0]) != `{"n":2}` || string(recent[1]) != `{"n":3}` {
+		t.Fatalf("expected the last two inputs, got %s (%v)", recent, err)
+	}
+	if recent, _ := inputs.Recent(ctx, "tenant-2", "default", 5); len(recent) != 0 {
+		t.Fatalf("expected no inputs for another tenant, got %s", recent)
+	}
+	old := storage.PolicyInput{TenantID: "tenant-1", PolicyID: "expired", Input: []byte(`{"n":0}`), CreatedAt: base}
+	if err := stores.InputStore.Record(ctx, old, 10, base.Add(-time.Hour)); err != nil {
+		t.Fatalf("record input: %v", err)
+	}
+	if recent, err := inputs.Recent(ctx, "tenant-1", "expired", 5); err != nil || len(recent) != 0 {
+		t.Fatalf("expected inputs past retention hidden, got %s (%v)", recent, err)
+	}
+	if err := inputs.Record(ctx, policy.RecordedInput{TenantID: "tenant-1", PolicyID: "default", Input: json.RawMessage(`{"n":4}`)}); err != nil {
+		t.Fatalf("record input: %v", err)
+	}
+	if recent, err := stores.InputStore.Recent(ctx, "tenant-1", "expired", 5, time.Time{}); err != nil || len(recent) != 0 {
+		t.Fatalf("expected inputs past retention deleted, got %+v (%v)", recent, err)
+	}
 
 	if err := stores.AuditStore.Append(ctx, storage.AuditEvent{ID: "event-1", Action: "job_accepted", Outcome: "ok"}); err != nil {
 		t.Fatalf("append audit: %v", err)
@@ -70,6 +236,18 @@ func TestSQLiteStoresIntegration(t *testing.T) {
 	if len(events) != 1 {
 		t.Fatalf("expected 1 audit event, got %d", len(events))
 	}
+	auditLog := audit.StorageAdapter{Store: stores.AuditStore}
+	changed := audit.Event{TenantID: "tenant-1", ActorID: "agent-1", Action: "policy_updated", Outcome: "ok", Detail: "-allow = true\n+allow = false\n", Time: time.Now().UTC().Truncate(time.Second)}
+	if err := auditLog.Append(ctx, changed); err != nil {
+		t.Fatalf("append policy audit: %v", err)
+	}
+	if err := auditLog.Append(ctx, changed); err != nil {
+		t.Fatalf("append a second identical event: %v", err)
+	}
+	logged, err := auditLog.List(ctx)
+	if err != nil || len(logged) != 3 || logged[1] != changed || logged[2] != changed {
+		t.Fatalf("expected policy audit events kept in full, oldest first, got %+v (%v)", logged, err)
+	}
 
 	idempotency := orchestration.SQLiteIdempotencyStore{DB: stores.DB}
 	if _, ok, err := idempotency.Get(ctx, "key-1"); err != nil || ok {
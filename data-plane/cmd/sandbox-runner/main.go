@@ -6,11 +6,14 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
 	"time"
 
 	"data-plane/internal/config"
 	"data-plane/internal/execution"
+	"data-plane/internal/isolation"
 	"data-plane/internal/runtime"
+	"data-plane/internal/workspace"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -52,17 +55,58 @@ func main() {
 		}
 	}()
 
+	runStore, err := buildRunStore(cfg)
+	if err != nil {
+		log.Fatalf("run store error: %v", err)
+	}
+	limiter, err := buildLimiter(cfg)
+	if err != nil {
+		log.Fatalf("cgroup limiter error: %v", err)
+	}
+	installer, err := buildInstaller(cfg)
+	if err != nil {
+		log.Fatalf("dependency installer error: %v", err)
+	}
+	languages, err := buildLanguages(cfg)
+	if err != nil {
+		log.Fatalf("adapter config error: %v", err)
+	}
+	secrets := buildSecretStore(cfg)
+	runner := execution.Runner{
+		Registry: runtime.NewRegistryFromLanguages(languages, runtime.ExecIsolation{
+			Limiter:    limiter,
+			DenyEgress: cfg.EgressMode == "deny",
+		}),
+		Installer:     installer,
+		WorkspaceRoot: cfg.ArtifactRoot,
+		DefaultEgress: cfg.EgressAllowList,
+		Secrets:       secrets,
+	}
+	if limiter != nil {
+		runner.DefaultLimits = defaultLimits(cfg)
+	}
+	runQueue := runtime.NewRunQueue(runner, runStore, cfg.RunWorkers, cfg.RunQueueSize)
+	if cfg.CallbackURL != "" {
+		runQueue.Notifier = runtime.NewJobCallbackClient(cfg.CallbackURL, cfg.CallbackToken)
+	}
+	if err := runQueue.Start(context.Background()); err != nil {
+		log.Fatalf("run queue error: %v", err)
+	}
 	runHandler := runtime.RunHandler{
-		Runner: execution.Runner{
-			Registry: runtime.DefaultRegistry(),
-			Deps:     runtime.DependencyPolicy{},
+		Runner: runner,
+		Store:  runStore,
+		Queue:  runQueue,
+		Enforcement: runtime.Enforcement{
+			Limits:  limiter != nil,
+			Egress:  cfg.EgressMode == "deny",
+			Secrets: secrets,
 		},
 	}
 	sessionRegistry, err := buildSessionRegistry(cfg)
 	if err != nil {
 		log.Fatalf("session registry error: %v", err)
 	}
-	sessionRuntime, err := buildSessionRuntime(cfg)
+	sessionRuntime, err := buildSessionRuntime(cfg, sessionRegistry, limiter, installer, languages, secrets)
 	if err != nil {
 		log.Fatalf("session runtime error: %v", err)
 	}
@@ -72,6 +116,9 @@ func main() {
 		Agent:       runtime.NewAgentClient(),
 		AgentPrefer: cfg.AgentPrefer,
 	}
+	if cfg.ArtifactRoot != "" {
+		sessionHandler.Snapshots = &workspace.SnapshotStore{Root: filepath.Join(cfg.ArtifactRoot, "snapshots")}
+	}
 	apiHandler := runtime.RouterWithDependencies(runtime.Dependencies{
 		RunHandler:     runHandler,
 		SessionHandler: sessionHandler,
@@ -142,27 +189,94 @@ func initTelemetry(ctx context.Context, serviceName string, endpoint string) (te
 	}, nil
 }
 
-func buildSessionRuntime(cfg config.Config) (runtime.SessionRuntime, error) {
+func buildSessionRuntime(cfg config.Config, registry runtime.SessionRegistry, limiter *isolation.CgroupLimiter, installer *runtime.DependencyInstaller, languages []runtime.LanguageConfig, secrets *workspace.SecretStore) (runtime.SessionRuntime, error) {
 	switch cfg.SessionRuntime {
 	case "k8s":
 		restConfig, clientset, err := buildKubeClient()
 		if err != nil {
 			return nil, err
 		}
-		return runtime.KubernetesSessionRuntime{
-			Client:        clientset,
-			Config:        restConfig,
-			Namespace:     cfg.RuntimeNamespace,
-			RuntimeClass:  cfg.RuntimeClass,
-			Image:         cfg.SessionImage,
-			PythonImage:   cfg.SessionImagePython,
-			NodeImage:     cfg.SessionImageNode,
-			Env:           cfg.Env,
-			AgentAddr:     getenv("SESSION_AGENT_ADDR", ":9000"),
-			AgentAuthMode: cfg.AgentAuthMode,
-		}, nil
+		sessionRuntime := runtime.KubernetesSessionRuntime{
+			Client:           clientset,
+			Config:           restConfig,
+			Namespace:        cfg.RuntimeNamespace,
+			RuntimeClass:     cfg.RuntimeClass,
+			Image:            cfg.SessionImage,
+			PythonImage:      cfg.SessionImagePython,
+			NodeImage:        cfg.SessionImageNode,
+			Images:           runtime.LanguageImages(languages),
+			Env:              cfg.Env,
+			AgentAddr:        getenv("SESSION_AGENT_ADDR", ":9000"),
+			AgentAuthMode:    cfg.AgentAuthMode,
+			RuntimeClasses:   cfg.RuntimeClasses,
+			AgentAdminSecret: cfg.AgentAdminSecret,
+		}
+		if cfg.AgentCapacity > 1 {
+			packing := runtime.NewAgentPacking(cfg.AgentCapacity, registry)
+			if cfg.AgentCgroupRoot != "" {
+				packing.CgroupRoot = cfg.AgentCgroupRoot
+				packing.CPUMillicores = cfg.CPUMillicores
+				packing.MemoryMB = cfg.MemoryMB
+				packing.PIDsMax = cfg.PIDsMax
+			}
+			sessionRuntime.Packing = packing
+		}
+		if len(cfg.SessionWarmPool) > 0 {
+			if err := sessionRuntime.PruneWarm(context.Background()); err != nil {
+				log.Printf("warm pool: prune error: %v", err)
+			}
+			sessionRuntime.Pool = runtime.NewWarmPool(sessionRuntime, cfg.SessionWarmPool)
+			sessionRuntime.Pool.Start(context.Background())
+		}
+		return sessionRuntime, nil
 	default:
-		return runtime.NewLocalSessionRuntime(), nil
+		sessionRuntime := runtime.NewLocalSessionRuntime()
+		if limiter != nil {
+			sessionRuntime.Limiter = limiter
+			sessionRuntime.Limits = defaultLimits(cfg)
+		}
+		sessionRuntime.DenyEgress = cfg.EgressMode == "deny"
+		sessionRuntime.EgressAllowList = cfg.EgressAllowList
+		sessionRuntime.Installer = installer
+		sessionRuntime.RestartCrashed = cfg.SessionRestartREPL
+		sessionRuntime.Secrets = secrets
+		return sessionRuntime, nil
+	}
+}
+
+func buildSecretStore(cfg config.Config) *workspace.SecretStore {
+	if cfg.SecretsDir == "" {
+		return nil
+	}
+	return &workspace.SecretStore{Dir: cfg.SecretsDir}
+}
+
+func buildLimiter(cfg config.Config) (*isolation.CgroupLimiter, error) {
+	if cfg.CgroupRoot == "" {
+		return nil, nil
+	}
+	return isolation.NewCgroupLimiter(cfg.CgroupRoot)
+}
+
+func buildLanguages(cfg config.Config) ([]runtime.LanguageConfig, error) {
+	if cfg.AdapterConfig == "" {
+		return runtime.DefaultLanguages, nil
+	}
+	return runtime.LoadLanguages(cfg.AdapterConfig)
+}
+
+func buildInstaller(cfg config.Config) (*runtime.DependencyInstaller, error) {
+	if cfg.DepsCacheDir == "" {
+		return nil, nil
+	}
+	return runtime.NewDependencyInstaller(cfg.DepsCacheDir, cfg.PipIndexURL, cfg.NpmRegistryURL)
+}
+
+func defaultLimits(cfg config.Config) isolation.Limits {
+	return isolation.Limits{
+		CPU:    cfg.CPUMillicores,
+		Memory: cfg.MemoryMB << 20,
+		PIDs:   cfg.PIDsMax,
 	}
 }
 
@@ -175,6 +289,15 @@ func buildSessionRegistry(cfg config.Config) (runtime.SessionRegistry, error) {
 	}
 }
 
+func buildRunStore(cfg config.Config) (runtime.RunStore, error) {
+	switch cfg.RunStore {
+	case "file":
+		return runtime.NewFileRunStore(cfg.RunStorePath)
+	default:
+		return runtime.NewInMemoryRunStore(), nil
+	}
+}
+
 func buildKubeClient() (*rest.Config, kubernetes.Interface, error) {
 	if cfg, err := rest.InClusterConfig(); err == nil {
 		clientset, err := kubernetes.NewForConfig(cfg)
@@ -3,12 +3,15 @@ package config
 import (
 	"errors"
 	"os"
+	"strconv"
+	"strings"
 )
 
 type Config struct {
 	Env                 string
 	RuntimeNamespace    string
 	RuntimeClass        string
+	RuntimeClasses      map[string]string
 	ArtifactRoot        string
 	SessionRuntime      string
 	SessionRegistry     string
@@ -16,8 +19,30 @@ type Config struct {
 	SessionImage        string
 	SessionImagePython  string
 	SessionImageNode    string
+	SessionRestartREPL  bool
+	SessionWarmPool     map[string]int
+	AgentCapacity       int
+	AgentCgroupRoot     string
+	RunStore            string
+	RunStorePath        string
+	RunWorkers          int
+	RunQueueSize        int
+	CgroupRoot          string
+	CPUMillicores       int
+	MemoryMB            int
+	PIDsMax             int
+	EgressMode          string
+	EgressAllowList     []string
+	SecretsDir          string
+	DepsCacheDir        string
+	AdapterConfig       string
+	PipIndexURL         string
+	NpmRegistryURL      string
+	CallbackURL         string
+	CallbackToken       string
 	AgentEndpoint       string
 	AgentAuthMode       string
+	AgentAdminSecret    string
 	AgentPrefer         bool
 	OtelEndpoint        string
 	OtelService         string
@@ -31,6 +56,7 @@ func Load() (Config, error) {
 		Env:                 os.Getenv("ENV"),
 		RuntimeNamespace:    os.Getenv("RUNTIME_NAMESPACE"),
 		RuntimeClass:        os.Getenv("RUNTIME_CLASS"),
+		RuntimeClasses:      getenvMap("RUNTIME_CLASSES"),
 		ArtifactRoot:        os.Getenv("ARTIFACT_ROOT"),
 		SessionRuntime:      getenv("SESSION_RUNTIME_BACKEND", "local"),
 		SessionRegistry:     getenv("SESSION_REGISTRY_BACKEND", "memory"),
@@ -38,8 +64,30 @@ func Load() (Config, error) {
 		SessionImage:        os.Getenv("SESSION_RUNTIME_IMAGE"),
 		SessionImagePython:  os.Getenv("SESSION_RUNTIME_IMAGE_PYTHON"),
 		SessionImageNode:    os.Getenv("SESSION_RUNTIME_IMAGE_NODE"),
+		SessionRestartREPL:  os.Getenv("SESSION_RESTART_REPL") != "false",
+		SessionWarmPool:     getenvSizes("SESSION_WARM_POOL"),
+		AgentCapacity:       getenvInt("SESSION_AGENT_CAPACITY", 1),
+		AgentCgroupRoot:     os.Getenv("SESSION_AGENT_CGROUP_ROOT"),
+		RunStore:            getenv("RUN_STORE_BACKEND", "memory"),
+		RunStorePath:        os.Getenv("RUN_STORE_PATH"),
+		RunWorkers:          getenvInt("RUN_WORKERS", 4),
+		RunQueueSize:        getenvInt("RUN_QUEUE_SIZE", 100),
+		CgroupRoot:          os.Getenv("CGROUP_ROOT"),
+		CPUMillicores:       getenvInt("RUN_CPU_MILLICORES", 1000),
+		MemoryMB:            getenvInt("RUN_MEMORY_MB", 512),
+		PIDsMax:             getenvInt("RUN_PIDS_MAX", 256),
+		EgressMode:          getenv("EGRESS_MODE", "allow"),
+		EgressAllowList:     getenvList("EGRESS_ALLOWLIST"),
+		SecretsDir:          os.Getenv("SECRETS_DIR"),
+		DepsCacheDir:        os.Getenv("DEPS_CACHE_DIR"),
+		AdapterConfig:       os.Getenv("ADAPTER_CONFIG"),
+		PipIndexURL:         os.Getenv("PIP_INDEX_URL"),
+		NpmRegistryURL:      os.Getenv("NPM_REGISTRY_URL"),
+		CallbackURL:         os.Getenv("CONTROL_PLANE_CALLBACK_URL"),
+		CallbackToken:       os.Getenv("JOB_CALLBACK_TOKEN"),
 		AgentEndpoint:       os.Getenv("SESSION_AGENT_ENDPOINT"),
 		AgentAuthMode:       getenv("SESSION_AGENT_AUTH_MODE", "enforced"),
+		AgentAdminSecret:    os.Getenv("SESSION_AGENT_ADMIN_SECRET"),
 		AgentPrefer:         getenv("SESSION_AGENT_PREFER", "true") == "true",
 		OtelEndpoint:        os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"),
 		OtelService:         os.Getenv("OTEL_SERVICE_NAME"),
@@ -57,6 +105,23 @@ func (c Config) Validate() error {
 	if c.SessionRuntime != "local" && c.SessionRuntime != "k8s" {
 		return errors.New("SESSION_RUNTIME_BACKEND must be local or k8s")
 	}
+	for _, size := range c.SessionWarmPool {
+		if size < 0 {
+			return errors.New("SESSION_WARM_POOL must be a list of runtime=size with non-negative sizes")
+		}
+	}
+	if len(c.SessionWarmPool) > 0 && c.SessionRuntime != "k8s" {
+		return errors.New("SESSION_WARM_POOL requires SESSION_RUNTIME_BACKEND=k8s")
+	}
+	if c.AgentCapacity <= 0 {
+		return errors.New("SESSION_AGENT_CAPACITY must be a positive integer")
+	}
+	if c.AgentCapacity > 1 && c.SessionRuntime != "k8s" {
+		return errors.New("SESSION_AGENT_CAPACITY above 1 requires SESSION_RUNTIME_BACKEND=k8s")
+	}
+	if c.AgentCapacity > 1 && len(c.SessionWarmPool) > 0 {
+		return errors.New("SESSION_WARM_POOL cannot be combined with SESSION_AGENT_CAPACITY above 1")
+	}
 	if c.SessionRegistry != "memory" && c.SessionRegistry != "file" {
 		return errors.New("SESSION_REGISTRY_BACKEND must be memory or file")
 	}
@@ -66,6 +131,38 @@ func (c Config) Validate() error {
 	if c.Env == "production" && c.SessionRegistry == "memory" {
 		return errors.New("SESSION_REGISTRY_BACKEND must be persistent in production")
 	}
+	if c.RunStore != "memory" && c.RunStore != "file" {
+		return errors.New("RUN_STORE_BACKEND must be memory or file")
+	}
+	if c.RunStore == "file" && c.RunStorePath == "" {
+		return errors.New("RUN_STORE_PATH is required for file run store backend")
+	}
+	if c.Env == "production" && c.RunStore == "memory" {
+		return errors.New("RUN_STORE_BACKEND must be persistent in production")
+	}
+	if c.RunWorkers <= 0 {
+		return errors.New("RUN_WORKERS must be a positive integer")
+	}
+	if c.RunQueueSize <= 0 {
+		return errors.New("RUN_QUEUE_SIZE must be a positive integer")
+	}
+	if c.CgroupRoot != "" && (c.CPUMillicores <= 0 || c.MemoryMB <= 0 || c.PIDsMax <= 0) {
+		return errors.New("RUN_CPU_MILLICORES, RUN_MEMORY_MB and RUN_PIDS_MAX must be positive integers when CGROUP_ROOT is set")
+	}
+	for class, name := range c.RuntimeClasses {
+		if (class != "gvisor" && class != "firecracker") || name == "" {
+			return errors.New("RUNTIME_CLASSES must map gvisor and/or firecracker to RuntimeClass names")
+		}
+	}
+	if len(c.RuntimeClasses) > 0 && c.SessionRuntime != "k8s" {
+		return errors.New("RUNTIME_CLASSES requires SESSION_RUNTIME_BACKEND=k8s")
+	}
+	if c.EgressMode != "allow" && c.EgressMode != "deny" {
+		return errors.New("EGRESS_MODE must be allow or deny")
+	}
+	if c.CallbackURL != "" && c.CallbackToken == "" {
+		return errors.New("JOB_CALLBACK_TOKEN is required when CONTROL_PLANE_CALLBACK_URL is set")
+	}
 	if c.Env == "production" && c.AuthzBypass {
 		return errors.New("AUTHZ_BYPASS is not allowed in production")
 	}
@@ -75,6 +172,9 @@ func (c Config) Validate() error {
 	if c.Env == "production" && c.AgentAuthMode == "bypass" {
 		return errors.New("SESSION_AGENT_AUTH_MODE=bypass is not allowed in production")
 	}
+	if c.SessionRuntime == "k8s" && c.AgentAuthMode == "enforced" && c.AgentAdminSecret == "" {
+		return errors.New("SESSION_AGENT_ADMIN_SECRET is required for SESSION_RUNTIME_BACKEND=k8s unless SESSION_AGENT_AUTH_MODE=bypass")
+	}
 	return nil
 }
 
@@ -84,3 +184,52 @@ func getenv(key string, fallback string) string {
 	}
 	return fallback
 }
+
+func getenvInt(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return 0
+	}
+	return parsed
+}
+
+func getenvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// getenvSizes parses "python=2,node=1". Malformed entries get size -1 so
+// Validate rejects them.
+func getenvSizes(key string) map[string]int {
+	sizes := map[string]int{}
+	for _, entry := range getenvList(key) {
+		name, value, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		size, err := strconv.Atoi(strings.TrimSpace(value))
+		if !ok || name == "" || err != nil {
+			size = -1
+		}
+		sizes[name] = size
+	}
+	return sizes
+}
+
+// getenvMap parses "gvisor=gvisor,firecracker=kata-fc". Malformed entries
+// map to "" so Validate rejects them.
+func getenvMap(key string) map[string]string {
+	values := map[string]string{}
+	for _, entry := range getenvList(key) {
+		name, value, _ := strings.Cut(entry, "=")
+		values[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	return values
+}
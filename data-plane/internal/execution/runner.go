@@ -8,7 +8,9 @@ import (
 	"sync"
 	"time"
 
+	"data-plane/internal/isolation"
 	"data-plane/internal/runtime"
+	"data-plane/internal/workspace"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/metric"
@@ -16,8 +18,12 @@ import (
 
 type Runner struct {
 	Registry      runtime.Registry
-	Deps          runtime.DependencyPolicy
+	Installer     *runtime.DependencyInstaller
 	WorkspaceRoot string
+	DefaultLimits isolation.Limits
+	DefaultEgress []string
+	// Secrets provides the secrets a run's policy grants it.
+	Secrets *workspace.SecretStore
 }
 
 var (
@@ -26,7 +32,7 @@ var (
 	runDeniedCounter    metric.Int64Counter
 )
 
-func (r Runner) Run(ctx context.Context, jobID string, language string, code string) (string, error) {
+func (r Runner) Run(ctx context.Context, req runtime.Run) (runtime.Run, error) {
 	runMetricsOnce.Do(initRunMetrics)
 	start := time.Now()
 	defer func() {
@@ -35,37 +41,136 @@ func (r Runner) Run(ctx context.Context, jobID string, language string, code str
 		}
 	}()
 
+	jobID := req.JobID
 	if jobID == "" {
-		return "", errors.New("missing job id")
+		return runtime.Run{}, errors.New("missing job id")
 	}
-	if err := r.ensureWorkspace(jobID); err != nil {
-		return "", err
+	workspaceDir, err := r.ensureWorkspace(jobID)
+	if err != nil {
+		return runtime.Run{}, err
 	}
-	if err := runtime.ValidateDependencies(r.Deps); err != nil {
+	if err := runtime.ValidateDependencies(runtime.DependencyPolicy{Allowlist: req.DepsAllowlist, Requested: req.Deps}); err != nil {
 		if runDeniedCounter != nil {
 			runDeniedCounter.Add(ctx, 1)
 		}
-		return "", err
+		return runtime.Run{}, err
 	}
-	adapter, ok := r.Registry.Adapter(language)
+	adapter, ok := r.Registry.Adapter(req.Language)
 	if !ok {
 		if runDeniedCounter != nil {
 			runDeniedCounter.Add(ctx, 1)
 		}
-		return "", errors.New("unsupported language")
+		return runtime.Run{}, errors.New("unsupported language")
 	}
-	if err := adapter.Run(code); err != nil {
-		return "", err
+	limits := req.Limits.WithDefaults(r.DefaultLimits)
+	egress := req.EgressAllowList
+	if egress == nil {
+		egress = r.DefaultEgress
+	}
+	env, err := r.installDeps(ctx, req)
+	if err != nil {
+		return runtime.Run{}, err
+	}
+	if len(req.Secrets) > 0 {
+		if r.Secrets == nil {
+			return runtime.Run{}, runtime.ErrSecretsUnsupported
+		}
+		secrets, err := r.Secrets.Env(req.TenantID, req.Secrets)
+		if err != nil {
+			return runtime.Run{}, err
+		}
+		env = append(env, secrets...)
+	}
+	result, err := adapter.Run(ctx, runtime.ExecRequest{
+		ID:              runtime.RunIDForJob(jobID),
+		Code:            req.Code,
+		Limits:          limits,
+		EgressAllowList: egress,
+		Env:             env,
+	})
+	stopped := stoppedStatus(ctx)
+	if err != nil && stopped == "" {
+		return runtime.Run{}, err
+	}
+	run := runtime.Run{
+		ID:              runtime.RunIDForJob(jobID),
+		JobID:           jobID,
+		Language:        req.Language,
+		TimeoutSeconds:  req.TimeoutSeconds,
+		Limits:          limits,
+		EgressAllowList: egress,
+		Deps:            req.Deps,
+		Secrets:         req.Secrets,
+		Status:          runtime.RunStatusFinished,
+		ExitStatus:      result.ExitCode,
+		Stdout:          result.Stdout,
+		Stderr:          result.Stderr,
+		Truncated:       result.Truncated,
+		WallTimeMs:      result.WallTime.Milliseconds(),
+		FailureReason:   result.FailureReason,
+		CompileOutput:   result.CompileOutput,
+		ResourceUsage:   result.Usage,
+	}
+	runtime.RecordResourceUsage(ctx, "run", result.Usage)
+	switch {
+	case stopped != "":
+		run.Status = stopped
+	case result.ExitCode != 0 || result.FailureReason != "":
+		run.Status = runtime.RunStatusFailed
+	}
+	if workspaceDir != "" {
+		outputRef, err := writeOutput(workspaceDir, "stdout.log", result.Stdout)
+		if err != nil {
+			return runtime.Run{}, err
+		}
+		errorRef, err := writeOutput(workspaceDir, "stderr.log", result.Stderr)
+		if err != nil {
+			return runtime.Run{}, err
+		}
+		run.OutputRef = outputRef
+		run.ErrorRef = errorRef
 	}
-	return jobID + "-run", nil
+	return run, nil
 }
 
-func (r Runner) ensureWorkspace(jobID string) error {
+func (r Runner) installDeps(ctx context.Context, req runtime.Run) ([]string, error) {
+	if len(req.Deps) == 0 {
+		return nil, nil
+	}
+	if r.Installer == nil {
+		return nil, runtime.ErrDependenciesNotConfigured
+	}
+	return r.Installer.Install(ctx, req.Language, req.Deps)
+}
+
+// stoppedStatus reports why the run context ended early, if it did.
+func stoppedStatus(ctx context.Context) string {
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return runtime.RunStatusTimedOut
+	case errors.Is(ctx.Err(), context.Canceled):
+		return runtime.RunStatusTerminated
+	}
+	return ""
+}
+
+func (r Runner) ensureWorkspace(jobID string) (string, error) {
 	if r.WorkspaceRoot == "" {
-		return nil
+		return "", nil
 	}
 	path := filepath.Join(r.WorkspaceRoot, jobID)
-	return os.MkdirAll(path, 0o750)
+	if err := os.MkdirAll(path, 0o750); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func writeOutput(dir string, name string, content string) (string, error) {
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o640); err != nil {
+		return "", err
+	}
+	return path, nil
 }
 
 func initRunMetrics() {
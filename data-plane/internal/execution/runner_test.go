@@ -2,19 +2,26 @@ package execution
 
 import (
 	"context"
+	"errors"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"data-plane/internal/isolation"
 	"data-plane/internal/runtime"
+	"data-plane/internal/workspace"
 )
 
 type mockAdapter struct {
-	seen string
-	err  error
+	seen   string
+	result runtime.Result
+	err    error
 }
 
-func (m *mockAdapter) Run(code string) error {
-	m.seen = code
-	return m.err
+func (m *mockAdapter) Run(ctx context.Context, req runtime.ExecRequest) (runtime.Result, error) {
+	m.seen = req.Code
+	return m.result, m.err
 }
 
 func TestRunnerExecutesCode(t *testing.T) {
@@ -22,7 +29,7 @@ func TestRunnerExecutesCode(t *testing.T) {
 	reg := runtime.NewRegistry()
 	reg.Register("go", adapter)
 	runner := Runner{Registry: reg}
-	_, err := runner.Run(context.Background(), "job-1", "go", "print")
+	_, err := runner.Run(context.Background(), runtime.Run{JobID: "job-1", Language: "go", Code: "print"})
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -31,9 +38,33 @@ func TestRunnerExecutesCode(t *testing.T) {
 	}
 }
 
+func TestRunnerRecordsResult(t *testing.T) {
+	adapter := &mockAdapter{result: runtime.Result{Stdout: "out", Stderr: "err", ExitCode: 2}}
+	reg := runtime.NewRegistry()
+	reg.Register("go", adapter)
+	runner := Runner{Registry: reg, WorkspaceRoot: t.TempDir()}
+	run, err := runner.Run(context.Background(), runtime.Run{JobID: "job-1", Language: "go", Code: "print"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if run.Status != runtime.RunStatusFailed || run.ExitStatus != 2 {
+		t.Fatalf("expected failed run with exit status 2, got %s/%d", run.Status, run.ExitStatus)
+	}
+	if run.Stdout != "out" || run.Stderr != "err" {
+		t.Fatalf("expected output on run record")
+	}
+	content, err := os.ReadFile(run.OutputRef)
+	if err != nil {
+		t.Fatalf("read output ref: %v", err)
+	}
+	if string(content) != "out" {
+		t.Fatalf("expected output ref to hold stdout, got %q", content)
+	}
+}
+
 func TestRunnerUnsupportedLanguage(t *testing.T) {
 	runner := Runner{Registry: runtime.NewRegistry()}
-	_, err := runner.Run(context.Background(), "job-1", "missing", "print")
+	_, err := runner.Run(context.Background(), runtime.Run{JobID: "job-1", Language: "missing", Code: "print"})
 	if err == nil {
 		t.Fatalf("expected unsupported language error")
 	}
@@ -44,14 +75,107 @@ func TestRunnerAdapterFailure(t *testing.T) {
 	reg := runtime.NewRegistry()
 	reg.Register("go", adapter)
 	runner := Runner{Registry: reg}
-	_, err := runner.Run(context.Background(), "job-1", "go", "print")
+	_, err := runner.Run(context.Background(), runtime.Run{JobID: "job-1", Language: "go", Code: "print"})
 	if err == nil {
 		t.Fatalf("expected adapter error")
 	}
 }
 
+type blockingAdapter struct{}
+
+func (blockingAdapter) Run(ctx context.Context, req runtime.ExecRequest) (runtime.Result, error) {
+	_ = req
+	<-ctx.Done()
+	return runtime.Result{ExitCode: -1}, nil
+}
+
+func TestRunnerRecordsStoppedStatus(t *testing.T) {
+	reg := runtime.NewRegistry()
+	reg.Register("go", blockingAdapter{})
+	runner := Runner{Registry: reg}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	run, err := runner.Run(ctx, runtime.Run{JobID: "job-1", Language: "go", Code: "loop"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if run.Status != runtime.RunStatusTimedOut {
+		t.Fatalf("expected timed_out status, got %s", run.Status)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	run, err = runner.Run(ctx, runtime.Run{JobID: "job-2", Language: "go", Code: "loop"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if run.Status != runtime.RunStatusTerminated {
+		t.Fatalf("expected terminated status, got %s", run.Status)
+	}
+}
+
 var errTest = runtimeError("adapter failed")
 
 type runtimeError string
 
 func (e runtimeError) Error() string { return string(e) }
+
+func TestRunnerReportsOOMKill(t *testing.T) {
+	adapter := &mockAdapter{result: runtime.Result{ExitCode: 137, FailureReason: runtime.FailureOOMKilled}}
+	reg := runtime.NewRegistry()
+	reg.Register("go", adapter)
+	runner := Runner{Registry: reg, DefaultLimits: isolation.Limits{Memory: 1 << 20}}
+	run, err := runner.Run(context.Background(), runtime.Run{JobID: "job-1", Language: "go", Code: "alloc"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if run.Status != runtime.RunStatusFailed || run.FailureReason != runtime.FailureOOMKilled {
+		t.Fatalf("expected oom failure, got %s/%s", run.Status, run.FailureReason)
+	}
+	if run.Limits.Memory != 1<<20 {
+		t.Fatalf("expected default limits on run, got %+v", run.Limits)
+	}
+}
+
+type envAdapter struct {
+	env []string
+}
+
+func (a *envAdapter) Run(ctx context.Context, req runtime.ExecRequest) (runtime.Result, error) {
+	a.env = req.Env
+	return runtime.Result{}, nil
+}
+
+func TestRunnerGrantsPolicySecrets(t *testing.T) {
+	dir := t.TempDir()
+	for _, tenant := range []string{"tenant-1", "tenant-2"} {
+		if err := os.MkdirAll(filepath.Join(dir, tenant), 0o700); err != nil {
+			t.Fatalf("create tenant dir: %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "tenant-1", "API_TOKEN"), []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatalf("write secret: %v", err)
+	}
+	adapter := &envAdapter{}
+	reg := runtime.NewRegistry()
+	reg.Register("go", adapter)
+	runner := Runner{Registry: reg, Secrets: &workspace.SecretStore{Dir: dir}}
+	if _, err := runner.Run(context.Background(), runtime.Run{JobID: "job-1", TenantID: "tenant-1", Language: "go", Secrets: []string{"API_TOKEN"}}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if len(adapter.env) != 1 || adapter.env[0] != "API_TOKEN=s3cret" {
+		t.Fatalf("expected the granted secret in the environment, got %v", adapter.env)
+	}
+	if _, err := runner.Run(context.Background(), runtime.Run{JobID: "job-2", TenantID: "tenant-1", Language: "go", Secrets: []string{"MISSING"}}); !errors.Is(err, workspace.ErrSecretNotFound) {
+		t.Fatalf("expected missing secret error, got %v", err)
+	}
+	if _, err := runner.Run(context.Background(), runtime.Run{JobID: "job-3", TenantID: "tenant-2", Language: "go", Secrets: []string{"API_TOKEN"}}); !errors.Is(err, workspace.ErrSecretNotFound) {
+		t.Fatalf("expected another tenant's secret to be missing, got %v", err)
+	}
+	for _, tenant := range []string{"", "..", "tenant-1/.."} {
+		if _, err := runner.Run(context.Background(), runtime.Run{JobID: "job-4", TenantID: tenant, Language: "go", Secrets: []string{"API_TOKEN"}}); err == nil {
+			t.Fatalf("expected tenant %q to be refused", tenant)
+		}
+	}
+}
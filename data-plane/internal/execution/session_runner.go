@@ -3,6 +3,8 @@ package execution
 import (
 	"context"
 	"time"
+
+	"data-plane/internal/runtime"
 )
 
 type SessionRunner struct {
@@ -10,7 +12,7 @@ type SessionRunner struct {
 }
 
 func (s SessionRunner) RunStep(ctx context.Context, sessionID string, command string) (string, error) {
-	_, err := s.Runner.Run(ctx, sessionID, "session", command)
+	_, err := s.Runner.Run(ctx, runtime.Run{JobID: sessionID, Language: "session", Code: command})
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,181 @@
+package isolation
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	cpuPeriodMicros   = 100000
+	cgroupRemoveTries = 20
+)
+
+var ErrInvalidCgroupName = errors.New("invalid cgroup name")
+
+// CgroupLimiter creates one cgroup v2 group per run or session under Root,
+// which must be a delegated cgroup v2 directory the data plane can write to.
+type CgroupLimiter struct {
+	Root string
+}
+
+type Cgroup struct {
+	Path string
+}
+
+// CgroupStats is what every process that ever ran in the group consumed.
+type CgroupStats struct {
+	CPUUsage   time.Duration
+	MemoryPeak int64
+	IOBytes    int64
+}
+
+func NewCgroupLimiter(root string) (*CgroupLimiter, error) {
+	if root == "" {
+		return nil, errors.New("missing cgroup root")
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(filepath.Join(root, "cgroup.controllers")); err != nil {
+		return nil, fmt.Errorf("cgroup v2 not available at %s: %w", root, err)
+	}
+	// Children can only use controllers enabled on the parent.
+	if err := os.WriteFile(filepath.Join(root, "cgroup.subtree_control"), []byte("+cpu +memory +pids"), 0o644); err != nil {
+		return nil, fmt.Errorf("enable cgroup controllers at %s: %w", root, err)
+	}
+	// io is only needed for usage reporting and is missing on some hosts.
+	if err := os.WriteFile(filepath.Join(root, "cgroup.subtree_control"), []byte("+io"), 0o644); err != nil {
+		log.Printf("cgroups: io controller unavailable, io usage will not be reported: %v", err)
+	}
+	return &CgroupLimiter{Root: root}, nil
+}
+
+func (l *CgroupLimiter) Create(name string, limits Limits) (*Cgroup, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return nil, ErrInvalidCgroupName
+	}
+	path := filepath.Join(l.Root, name)
+	if err := os.Mkdir(path, 0o755); err != nil {
+		return nil, err
+	}
+	group := &Cgroup{Path: path}
+	if err := group.apply(limits); err != nil {
+		_ = group.Close()
+		return nil, err
+	}
+	return group, nil
+}
+
+func (g *Cgroup) apply(limits Limits) error {
+	if limits.CPU > 0 {
+		quota := limits.CPU * cpuPeriodMicros / 1000
+		if err := g.write("cpu.max", fmt.Sprintf("%d %d", quota, cpuPeriodMicros)); err != nil {
+			return err
+		}
+	}
+	if limits.Memory > 0 {
+		if err := g.write("memory.max", strconv.Itoa(limits.Memory)); err != nil {
+			return err
+		}
+		// Without swap the memory limit would only push pages out instead of
+		// triggering the OOM killer.
+		_ = g.write("memory.swap.max", "0")
+	}
+	if limits.PIDs > 0 {
+		if err := g.write("pids.max", strconv.Itoa(limits.PIDs)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *Cgroup) AddProcess(pid int) error {
+	return g.write("cgroup.procs", strconv.Itoa(pid))
+}
+
+// OOMKilled reports whether the kernel OOM killer fired inside the group.
+func (g *Cgroup) OOMKilled() (bool, error) {
+	count, err := g.readKey("memory.events", "oom_kill")
+	if errors.Is(err, errCgroupKeyMissing) {
+		return false, nil
+	}
+	return count > 0, err
+}
+
+// Stats reads accumulated usage. memory.peak needs Linux 5.19; on older
+// kernels MemoryPeak stays zero.
+func (g *Cgroup) Stats() (CgroupStats, error) {
+	var stats CgroupStats
+	usec, err := g.readKey("cpu.stat", "usage_usec")
+	if err != nil {
+		return CgroupStats{}, err
+	}
+	stats.CPUUsage = time.Duration(usec) * time.Microsecond
+	if peak, err := os.ReadFile(filepath.Join(g.Path, "memory.peak")); err == nil {
+		stats.MemoryPeak, _ = strconv.ParseInt(strings.TrimSpace(string(peak)), 10, 64)
+	}
+	if content, err := os.ReadFile(filepath.Join(g.Path, "io.stat")); err == nil {
+		// One line per device: "8:0 rbytes=1 wbytes=2 rios=3 ...".
+		for _, field := range strings.Fields(string(content)) {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok || (key != "rbytes" && key != "wbytes") {
+				continue
+			}
+			bytes, err := strconv.ParseInt(value, 10, 64)
+			if err == nil {
+				stats.IOBytes += bytes
+			}
+		}
+	}
+	return stats, nil
+}
+
+// Close kills anything left in the group and removes it.
+func (g *Cgroup) Close() error {
+	_ = g.write("cgroup.kill", "1")
+	var err error
+	for i := 0; i < cgroupRemoveTries; i++ {
+		err = os.Remove(g.Path)
+		if err == nil || errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return err
+}
+
+var errCgroupKeyMissing = errors.New("cgroup key missing")
+
+// readKey reads a value from a flat keyed file such as memory.events.
+func (g *Cgroup) readKey(file string, key string) (int64, error) {
+	handle, err := os.Open(filepath.Join(g.Path, file))
+	if err != nil {
+		return 0, err
+	}
+	defer handle.Close()
+	scanner := bufio.NewScanner(handle)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == key {
+			return strconv.ParseInt(fields[1], 10, 64)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("%w: %s in %s", errCgroupKeyMissing, key, file)
+}
+
+func (g *Cgroup) write(file string, value string) error {
+	if err := os.WriteFile(filepath.Join(g.Path, file), []byte(value), 0o644); err != nil {
+		return fmt.Errorf("write %s: %w", file, err)
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+//go:build linux
+
+package isolation
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// Start runs start, which must start cmd, with the child cloned straight
+// into the group, so nothing it does escapes the limits before a move.
+func (g *Cgroup) Start(cmd *exec.Cmd, start func() error) error {
+	dir, err := os.Open(g.Path)
+	if err != nil {
+		return fmt.Errorf("open cgroup: %w", err)
+	}
+	defer dir.Close()
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(dir.Fd())
+	return start()
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package isolation
+
+import (
+	"errors"
+	"os/exec"
+)
+
+var errCgroupUnsupported = errors.New("cgroups require linux")
+
+func (g *Cgroup) Start(cmd *exec.Cmd, start func() error) error {
+	return errCgroupUnsupported
+}
@@ -0,0 +1,102 @@
+package isolation
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func fakeCgroupRoot(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "cgroup.controllers"), []byte("cpu memory pids"), 0o644); err != nil {
+		t.Fatalf("write controllers: %v", err)
+	}
+	return root
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	return string(content)
+}
+
+func TestCgroupLimiterWritesLimits(t *testing.T) {
+	limiter, err := NewCgroupLimiter(fakeCgroupRoot(t))
+	if err != nil {
+		t.Fatalf("new limiter: %v", err)
+	}
+	group, err := limiter.Create("run-job-1", Limits{CPU: 500, Memory: 64 << 20, PIDs: 32})
+	if err != nil {
+		t.Fatalf("create cgroup: %v", err)
+	}
+	if got := readFile(t, filepath.Join(group.Path, "cpu.max")); got != "50000 100000" {
+		t.Fatalf("unexpected cpu.max %q", got)
+	}
+	if got := readFile(t, filepath.Join(group.Path, "memory.max")); got != "67108864" {
+		t.Fatalf("unexpected memory.max %q", got)
+	}
+	if got := readFile(t, filepath.Join(group.Path, "pids.max")); got != "32" {
+		t.Fatalf("unexpected pids.max %q", got)
+	}
+	if err := group.AddProcess(1234); err != nil {
+		t.Fatalf("add process: %v", err)
+	}
+	if got := readFile(t, filepath.Join(group.Path, "cgroup.procs")); got != "1234" {
+		t.Fatalf("unexpected cgroup.procs %q", got)
+	}
+}
+
+func TestCgroupLimiterRejectsPathNames(t *testing.T) {
+	limiter, err := NewCgroupLimiter(fakeCgroupRoot(t))
+	if err != nil {
+		t.Fatalf("new limiter: %v", err)
+	}
+	for _, name := range []string{"", "..", "a/b"} {
+		if _, err := limiter.Create(name, Limits{PIDs: 1}); err != ErrInvalidCgroupName {
+			t.Fatalf("expected ErrInvalidCgroupName for %q, got %v", name, err)
+		}
+	}
+}
+
+func TestCgroupOOMKilled(t *testing.T) {
+	group := &Cgroup{Path: t.TempDir()}
+	events := filepath.Join(group.Path, "memory.events")
+	if err := os.WriteFile(events, []byte("low 0\nhigh 0\nmax 3\noom 1\noom_kill 0\n"), 0o644); err != nil {
+		t.Fatalf("write events: %v", err)
+	}
+	if oom, err := group.OOMKilled(); err != nil || oom {
+		t.Fatalf("expected no oom kill, got %v %v", oom, err)
+	}
+	if err := os.WriteFile(events, []byte("low 0\nhigh 0\nmax 3\noom 1\noom_kill 1\n"), 0o644); err != nil {
+		t.Fatalf("write events: %v", err)
+	}
+	if oom, err := group.OOMKilled(); err != nil || !oom {
+		t.Fatalf("expected oom kill, got %v %v", oom, err)
+	}
+}
+
+func TestCgroupStats(t *testing.T) {
+	group := &Cgroup{Path: t.TempDir()}
+	files := map[string]string{
+		"cpu.stat":    "usage_usec 250000\nuser_usec 200000\nsystem_usec 50000\n",
+		"memory.peak": "67108864\n",
+		"io.stat":     "8:0 rbytes=4096 wbytes=8192 rios=1 wios=2 dbytes=0 dios=0\n8:16 rbytes=1024 wbytes=0 rios=1 wios=0 dbytes=0 dios=0\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(group.Path, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	stats, err := group.Stats()
+	if err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+	if stats.CPUUsage != 250*time.Millisecond || stats.MemoryPeak != 64<<20 || stats.IOBytes != 13312 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
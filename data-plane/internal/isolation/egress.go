@@ -3,29 +3,49 @@ package isolation
 import (
 	"context"
 	"errors"
+	"strings"
 )
 
+// EgressPolicy lists the hostnames a sandbox may reach. Entries match
+// exactly or, when written as "*.example.com", any subdomain.
 type EgressPolicy struct {
 	AllowList []string
 	Requested []string
 }
 
-func EnforceEgress(ctx context.Context, policy EgressPolicy) error {
-	_ = ctx
-	if len(policy.Requested) == 0 {
-		return nil
-	}
-	if len(policy.AllowList) == 0 {
-		return errors.New("egress denied")
+func (p EgressPolicy) Allows(host string) bool {
+	host = normalizeHost(host)
+	if host == "" {
+		return false
 	}
-	allowed := map[string]struct{}{}
-	for _, host := range policy.AllowList {
-		allowed[host] = struct{}{}
+	for _, entry := range p.AllowList {
+		entry = normalizeHost(entry)
+		if entry == "" {
+			continue
+		}
+		if suffix, ok := strings.CutPrefix(entry, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+			continue
+		}
+		if host == entry {
+			return true
+		}
 	}
+	return false
+}
+
+func EnforceEgress(ctx context.Context, policy EgressPolicy) error {
+	_ = ctx
 	for _, host := range policy.Requested {
-		if _, ok := allowed[host]; !ok {
+		if !policy.Allows(host) {
 			return errors.New("egress denied")
 		}
 	}
 	return nil
 }
+
+func normalizeHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+}
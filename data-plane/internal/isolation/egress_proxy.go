@@ -0,0 +1,194 @@
+package isolation
+
+import (
+	"context"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"data-plane/internal/telemetry"
+)
+
+const egressDialTimeout = 10 * time.Second
+
+var hopByHopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// EgressProxy is the only route out of an isolated network. It forwards
+// plain HTTP requests and CONNECT tunnels to hosts the policy allows and
+// logs every decision against the owning run or session.
+type EgressProxy struct {
+	Policy    EgressPolicy
+	RunID     string
+	SessionID string
+	Logger    telemetry.Logger
+
+	once      sync.Once
+	server    *http.Server
+	transport *http.Transport
+}
+
+func NewEgressProxy(policy EgressPolicy, runID string, sessionID string) *EgressProxy {
+	return &EgressProxy{Policy: policy, RunID: runID, SessionID: sessionID, Logger: telemetry.StdoutLogger{}}
+}
+
+func (p *EgressProxy) Serve(ln net.Listener) error {
+	p.init()
+	err := p.server.Serve(ln)
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+func (p *EgressProxy) Close() error {
+	p.init()
+	p.transport.CloseIdleConnections()
+	return p.server.Close()
+}
+
+func (p *EgressProxy) init() {
+	p.once.Do(func() {
+		p.server = &http.Server{Handler: p, ReadHeaderTimeout: egressDialTimeout}
+		p.transport = &http.Transport{
+			Proxy:       nil,
+			DialContext: (&net.Dialer{Timeout: egressDialTimeout}).DialContext,
+		}
+	})
+}
+
+func (p *EgressProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.init()
+	host := r.URL.Hostname()
+	if r.Method == http.MethodConnect {
+		host, _, _ = net.SplitHostPort(r.Host)
+	}
+	allowed := p.Policy.Allows(host)
+	p.logDecision(r.Context(), host, allowed)
+	if !allowed {
+		http.Error(w, "egress denied", http.StatusForbidden)
+		return
+	}
+	if r.Method == http.MethodConnect {
+		p.tunnel(w, r)
+		return
+	}
+	if !r.URL.IsAbs() {
+		http.Error(w, "proxy requests must use an absolute url", http.StatusBadRequest)
+		return
+	}
+	p.forward(w, r)
+}
+
+func (p *EgressProxy) tunnel(w http.ResponseWriter, r *http.Request) {
+	upstream, err := net.DialTimeout("tcp", r.Host, egressDialTimeout)
+	if err != nil {
+		http.Error(w, "upstream unreachable", http.StatusBadGateway)
+		return
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		_ = upstream.Close()
+		http.Error(w, "tunneling unsupported", http.StatusInternalServerError)
+		return
+	}
+	client, buffered, err := hijacker.Hijack()
+	if err != nil {
+		_ = upstream.Close()
+		return
+	}
+	if _, err := client.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n")); err != nil {
+		_ = client.Close()
+		_ = upstream.Close()
+		return
+	}
+	done := make(chan struct{}, 2)
+	go func() {
+		// Bytes the client sent after the CONNECT line may already be buffered.
+		_, _ = io.Copy(upstream, buffered)
+		closeWrite(upstream)
+		done <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(client, upstream)
+		closeWrite(client)
+		done <- struct{}{}
+	}()
+	<-done
+	<-done
+	_ = client.Close()
+	_ = upstream.Close()
+}
+
+func (p *EgressProxy) forward(w http.ResponseWriter, r *http.Request) {
+	out := r.Clone(r.Context())
+	out.RequestURI = ""
+	removeHopByHop(out.Header)
+	resp, err := p.transport.RoundTrip(out)
+	if err != nil {
+		http.Error(w, "upstream unreachable", http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	removeHopByHop(resp.Header)
+	for key, values := range resp.Header {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	_, _ = io.Copy(w, resp.Body)
+}
+
+func (p *EgressProxy) logDecision(ctx context.Context, host string, allowed bool) {
+	outcome := "allowed"
+	if !allowed {
+		outcome = "denied"
+	}
+	if p.Logger == nil {
+		return
+	}
+	err := p.Logger.Log(ctx, telemetry.Event{
+		RunID:     p.RunID,
+		SessionID: p.SessionID,
+		Action:    "egress",
+		Outcome:   outcome,
+		Detail:    host,
+		Time:      time.Now(),
+	})
+	if err != nil {
+		log.Printf("egress: telemetry error host=%s: %v", host, err)
+	}
+}
+
+func removeHopByHop(header http.Header) {
+	for _, value := range header.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			header.Del(strings.TrimSpace(name))
+		}
+	}
+	for _, name := range hopByHopHeaders {
+		header.Del(name)
+	}
+}
+
+func closeWrite(conn net.Conn) {
+	if tcp, ok := conn.(interface{ CloseWrite() error }); ok {
+		_ = tcp.CloseWrite()
+		return
+	}
+	_ = conn.Close()
+}
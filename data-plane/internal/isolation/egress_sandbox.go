@@ -0,0 +1,62 @@
+package isolation
+
+import (
+	"log"
+	"os/exec"
+)
+
+// EgressSandbox pairs an isolated network with the proxy listening inside
+// it. Processes started through it see no interfaces besides loopback, so
+// their only way out is the proxy advertised in Env.
+type EgressSandbox struct {
+	network  *Network
+	proxy    *EgressProxy
+	proxyURL string
+}
+
+func NewEgressSandbox(policy EgressPolicy, runID string, sessionID string) (*EgressSandbox, error) {
+	network, err := NewIsolatedNetwork()
+	if err != nil {
+		return nil, err
+	}
+	listener, err := network.Listen("127.0.0.1:0")
+	if err != nil {
+		_ = network.Close()
+		return nil, err
+	}
+	proxy := NewEgressProxy(policy, runID, sessionID)
+	go func() {
+		if err := proxy.Serve(listener); err != nil {
+			log.Printf("egress: proxy error run_id=%s session_id=%s: %v", runID, sessionID, err)
+		}
+	}()
+	return &EgressSandbox{
+		network:  network,
+		proxy:    proxy,
+		proxyURL: "http://" + listener.Addr().String(),
+	}, nil
+}
+
+// Env returns the proxy variables understood by common HTTP clients.
+func (s *EgressSandbox) Env() []string {
+	return []string{
+		"HTTP_PROXY=" + s.proxyURL,
+		"HTTPS_PROXY=" + s.proxyURL,
+		"http_proxy=" + s.proxyURL,
+		"https_proxy=" + s.proxyURL,
+		"NO_PROXY=",
+		"no_proxy=",
+	}
+}
+
+func (s *EgressSandbox) Start(cmd *exec.Cmd) error {
+	return s.network.Start(cmd)
+}
+
+func (s *EgressSandbox) Close() error {
+	err := s.proxy.Close()
+	if closeErr := s.network.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,168 @@
+package isolation
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+
+	"data-plane/internal/telemetry"
+)
+
+type recordingLogger struct {
+	mu     sync.Mutex
+	events []telemetry.Event
+}
+
+func (l *recordingLogger) Log(ctx context.Context, event telemetry.Event) error {
+	_ = ctx
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, event)
+	return nil
+}
+
+func (l *recordingLogger) outcomes() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var outcomes []string
+	for _, event := range l.events {
+		outcomes = append(outcomes, event.Detail+"="+event.Outcome)
+	}
+	return outcomes
+}
+
+func TestEgressPolicyAllows(t *testing.T) {
+	policy := EgressPolicy{AllowList: []string{"pypi.org", "*.example.com"}}
+	cases := map[string]bool{
+		"pypi.org":          true,
+		"PyPI.org.":         true,
+		"files.pypi.org":    false,
+		"api.example.com":   true,
+		"a.b.example.com":   true,
+		"example.com":       false,
+		"badexample.com":    false,
+		"":                  false,
+		"pypi.org.evil.com": false,
+	}
+	for host, want := range cases {
+		if got := policy.Allows(host); got != want {
+			t.Fatalf("Allows(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
+
+func startProxy(t *testing.T, allow []string) (*url.URL, *recordingLogger) {
+	t.Helper()
+	logger := &recordingLogger{}
+	proxy := NewEgressProxy(EgressPolicy{AllowList: allow}, "run-1", "")
+	proxy.Logger = logger
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go func() { _ = proxy.Serve(listener) }()
+	t.Cleanup(func() { _ = proxy.Close() })
+	return &url.URL{Scheme: "http", Host: listener.Addr().String()}, logger
+}
+
+func TestEgressProxyForwardsAllowedHTTP(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "hello")
+	}))
+	defer upstream.Close()
+	proxyURL, logger := startProxy(t, []string{"127.0.0.1"})
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+
+	resp, err := client.Get(upstream.URL)
+	if err != nil {
+		t.Fatalf("get allowed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "hello" {
+		t.Fatalf("unexpected response %d %q", resp.StatusCode, body)
+	}
+
+	denied := strings.Replace(upstream.URL, "127.0.0.1", "localhost", 1)
+	resp, err = client.Get(denied)
+	if err != nil {
+		t.Fatalf("get denied: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", resp.StatusCode)
+	}
+	got := strings.Join(logger.outcomes(), ",")
+	if got != "127.0.0.1=allowed,localhost=denied" {
+		t.Fatalf("unexpected egress events %q", got)
+	}
+}
+
+func TestEgressProxyTunnelsAllowedTLS(t *testing.T) {
+	upstream := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "secure")
+	}))
+	defer upstream.Close()
+	proxyURL, logger := startProxy(t, []string{"127.0.0.1"})
+	transport := upstream.Client().Transport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyURL(proxyURL)
+	client := &http.Client{Transport: transport}
+
+	resp, err := client.Get(upstream.URL)
+	if err != nil {
+		t.Fatalf("get through tunnel: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "secure" {
+		t.Fatalf("unexpected body %q", body)
+	}
+
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	denied := strings.Replace(upstream.URL, "127.0.0.1", "localhost", 1)
+	if _, err := client.Get(denied); err == nil {
+		t.Fatalf("expected tunnel to denied host to fail")
+	}
+	got := strings.Join(logger.outcomes(), ",")
+	if got != "127.0.0.1=allowed,localhost=denied" {
+		t.Fatalf("unexpected egress events %q", got)
+	}
+}
+
+func TestEgressSandboxBlocksDirectConnections(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "hello")
+	}))
+	defer upstream.Close()
+	if _, err := exec.LookPath("curl"); err != nil {
+		t.Skip("curl not installed")
+	}
+	sandbox, err := NewEgressSandbox(EgressPolicy{AllowList: []string{"127.0.0.1"}}, "run-1", "")
+	if err != nil {
+		t.Skipf("network namespaces unavailable: %v", err)
+	}
+	defer sandbox.Close()
+
+	run := func(args ...string) error {
+		cmd := exec.Command("curl", append([]string{"-sf", "--max-time", "5"}, args...)...)
+		cmd.Env = sandbox.Env()
+		if err := sandbox.Start(cmd); err != nil {
+			t.Fatalf("start curl: %v", err)
+		}
+		return cmd.Wait()
+	}
+	if err := run("--noproxy", "*", upstream.URL); err == nil {
+		t.Fatalf("expected direct connection from sandbox to fail")
+	}
+	if err := run(upstream.URL); err != nil {
+		t.Fatalf("expected proxied connection to succeed: %v", err)
+	}
+}
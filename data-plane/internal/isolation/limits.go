@@ -5,11 +5,34 @@ import (
 	"errors"
 )
 
+// Limits caps the resources of a run or session. CPU is in millicores,
+// Memory and Disk are in bytes. Zero means unlimited.
 type Limits struct {
-	CPU    int
-	Memory int
-	Disk   int
-	PIDs   int
+	CPU    int `json:"cpuMillicores,omitempty"`
+	Memory int `json:"memoryBytes,omitempty"`
+	Disk   int `json:"diskBytes,omitempty"`
+	PIDs   int `json:"pids,omitempty"`
+}
+
+func (l Limits) IsZero() bool {
+	return l == Limits{}
+}
+
+// WithDefaults fills unset fields from defaults.
+func (l Limits) WithDefaults(defaults Limits) Limits {
+	if l.CPU <= 0 {
+		l.CPU = defaults.CPU
+	}
+	if l.Memory <= 0 {
+		l.Memory = defaults.Memory
+	}
+	if l.Disk <= 0 {
+		l.Disk = defaults.Disk
+	}
+	if l.PIDs <= 0 {
+		l.PIDs = defaults.PIDs
+	}
+	return l
 }
 
 func EnforceLimits(ctx context.Context, limits Limits) error {
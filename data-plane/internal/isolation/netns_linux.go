@@ -0,0 +1,122 @@
+//go:build linux
+
+package isolation
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+
+	"golang.org/x/sys/unix"
+)
+
+// Network is a private network namespace with only a loopback interface.
+// Processes started in it cannot reach anything except listeners opened
+// through Listen, such as the egress proxy.
+type Network struct {
+	ns *os.File
+}
+
+func NewIsolatedNetwork() (*Network, error) {
+	var network *Network
+	err := inNewThread(func() error {
+		if err := unix.Unshare(unix.CLONE_NEWNET); err != nil {
+			return fmt.Errorf("unshare network namespace: %w", err)
+		}
+		if err := loopbackUp(); err != nil {
+			return err
+		}
+		ns, err := os.Open(threadNetNSPath())
+		if err != nil {
+			return err
+		}
+		network = &Network{ns: ns}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return network, nil
+}
+
+// Listen opens a TCP listener inside the namespace. Connections accepted on
+// it are served by the data plane, which still dials out from the host
+// namespace.
+func (n *Network) Listen(addr string) (net.Listener, error) {
+	var listener net.Listener
+	err := n.do(func() error {
+		var err error
+		listener, err = net.Listen("tcp", addr)
+		return err
+	})
+	return listener, err
+}
+
+// Start starts cmd inside the namespace. The child inherits the namespace
+// of the thread that forks it.
+func (n *Network) Start(cmd *exec.Cmd) error {
+	return n.do(cmd.Start)
+}
+
+func (n *Network) Close() error {
+	return n.ns.Close()
+}
+
+func (n *Network) do(fn func() error) error {
+	runtime.LockOSThread()
+	host, err := os.Open(threadNetNSPath())
+	if err != nil {
+		runtime.UnlockOSThread()
+		return err
+	}
+	defer host.Close()
+	if err := unix.Setns(int(n.ns.Fd()), unix.CLONE_NEWNET); err != nil {
+		runtime.UnlockOSThread()
+		return fmt.Errorf("enter network namespace: %w", err)
+	}
+	fnErr := fn()
+	if err := unix.Setns(int(host.Fd()), unix.CLONE_NEWNET); err != nil {
+		// Leave the thread locked so the runtime discards it instead of
+		// reusing a thread stuck in the sandbox namespace.
+		return fmt.Errorf("restore network namespace: %w", err)
+	}
+	runtime.UnlockOSThread()
+	return fnErr
+}
+
+// inNewThread runs fn on a dedicated OS thread that is thrown away
+// afterwards, since unshare cannot be undone on the calling thread.
+func inNewThread(fn func() error) error {
+	done := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+		done <- fn()
+	}()
+	return <-done
+}
+
+func loopbackUp() error {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+	ifr, err := unix.NewIfreq("lo")
+	if err != nil {
+		return err
+	}
+	if err := unix.IoctlIfreq(fd, unix.SIOCGIFFLAGS, ifr); err != nil {
+		return fmt.Errorf("read loopback flags: %w", err)
+	}
+	ifr.SetUint16(ifr.Uint16() | unix.IFF_UP)
+	if err := unix.IoctlIfreq(fd, unix.SIOCSIFFLAGS, ifr); err != nil {
+		return fmt.Errorf("bring loopback up: %w", err)
+	}
+	return nil
+}
+
+func threadNetNSPath() string {
+	return fmt.Sprintf("/proc/self/task/%d/ns/net", unix.Gettid())
+}
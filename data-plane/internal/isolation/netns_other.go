@@ -0,0 +1,29 @@
+//go:build !linux
+
+package isolation
+
+import (
+	"errors"
+	"net"
+	"os/exec"
+)
+
+var errNetworkIsolationUnsupported = errors.New("network isolation requires linux")
+
+type Network struct{}
+
+func NewIsolatedNetwork() (*Network, error) {
+	return nil, errNetworkIsolationUnsupported
+}
+
+func (n *Network) Listen(addr string) (net.Listener, error) {
+	return nil, errNetworkIsolationUnsupported
+}
+
+func (n *Network) Start(cmd *exec.Cmd) error {
+	return errNetworkIsolationUnsupported
+}
+
+func (n *Network) Close() error {
+	return nil
+}
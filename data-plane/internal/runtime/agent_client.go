@@ -6,7 +6,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 
 	"shared/sessionagent"
@@ -14,6 +18,9 @@ import (
 
 type AgentClient struct {
 	HTTPClient *http.Client
+	// AdminToken authenticates the agent-wide routes, such as session
+	// registration.
+	AdminToken string
 }
 
 type AgentRoute struct {
@@ -62,6 +69,9 @@ func (c *AgentClient) RegisterSession(ctx context.Context, route AgentRoute, req
 	if route.AuthMode != "bypass" && request.Token != "" {
 		req.Header.Set("X-Session-Token", request.Token)
 	}
+	if c.AdminToken != "" {
+		req.Header.Set("X-Agent-Token", c.AdminToken)
+	}
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("session register failed: %w", err)
@@ -107,6 +117,44 @@ func (c *AgentClient) RunStep(ctx context.Context, route AgentRoute, request ses
 	return sessionagent.StepResult{}, runtimeUnreachableError{Err: lastErr}
 }
 
+// StreamStep runs a step through the agent's streaming endpoint, passing
+// every event, including the final status, to emit. emit is only called
+// once the agent has accepted the step, so a failure before the first
+// event leaves the caller free to report it another way.
+func (c *AgentClient) StreamStep(ctx context.Context, route AgentRoute, request sessionagent.StepRequest, emit func(sessionagent.StepEvent) error) (sessionagent.StepResult, error) {
+	if route.Endpoint == "" {
+		return sessionagent.StepResult{}, errors.New("agent endpoint not configured")
+	}
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return sessionagent.StepResult{}, fmt.Errorf("encode step request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, route.Endpoint+"/v1/steps/"+request.StepID+"/stream", bytes.NewReader(payload))
+	if err != nil {
+		return sessionagent.StepResult{}, fmt.Errorf("create agent request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	if route.AuthMode != "bypass" && route.Token != "" {
+		req.Header.Set("X-Session-Token", route.Token)
+	}
+	// Steps may run far longer than the client timeout; ctx bounds them.
+	client := &http.Client{Transport: c.HTTPClient.Transport}
+	resp, err := client.Do(req)
+	if err != nil {
+		return sessionagent.StepResult{}, runtimeUnreachableError{Err: err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		err := agentStatusError{Status: resp.StatusCode}
+		if isRetryableAgentError(err) {
+			return sessionagent.StepResult{}, runtimeUnreachableError{Err: err}
+		}
+		return sessionagent.StepResult{}, err
+	}
+	return sessionagent.ReadStepEvents(resp.Body, emit)
+}
+
 func (c *AgentClient) TerminateSession(ctx context.Context, route AgentRoute, sessionID string) error {
 	if route.Endpoint == "" {
 		return errors.New("agent endpoint not configured")
@@ -132,6 +180,37 @@ func (c *AgentClient) TerminateSession(ctx context.Context, route AgentRoute, se
 	return nil
 }
 
+// InterruptSession asks the agent to stop the step running in a session and
+// reports whether one was running.
+func (c *AgentClient) InterruptSession(ctx context.Context, route AgentRoute, sessionID string) (bool, error) {
+	if route.Endpoint == "" {
+		return false, errors.New("agent endpoint not configured")
+	}
+	if sessionID == "" {
+		return false, errors.New("missing session id")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, route.Endpoint+"/v1/sessions/"+sessionID+"/interrupt", nil)
+	if err != nil {
+		return false, fmt.Errorf("create interrupt request: %w", err)
+	}
+	if route.AuthMode != "bypass" && route.Token != "" {
+		req.Header.Set("X-Session-Token", route.Token)
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("interrupt request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("interrupt returned status %d", resp.StatusCode)
+	}
+	var result sessionagent.SessionInterruptResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, fmt.Errorf("decode interrupt response: %w", err)
+	}
+	return result.Interrupted, nil
+}
+
 func (c *AgentClient) runStepOnce(ctx context.Context, route AgentRoute, request sessionagent.StepRequest) (sessionagent.StepResult, error) {
 	payload, err := json.Marshal(request)
 	if err != nil {
@@ -204,3 +283,224 @@ func (c *AgentClient) WaitForHealth(ctx context.Context, endpoint string, interv
 		}
 	}
 }
+
+var errAgentStatusUnsupported = errors.New("agent does not report status")
+
+// Status fetches the agent's report of its sessions, their interpreter
+// PIDs and memory use.
+func (c *AgentClient) Status(ctx context.Context, endpoint string) (sessionagent.AgentStatus, error) {
+	if endpoint == "" {
+		return sessionagent.AgentStatus{}, errors.New("agent endpoint not configured")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"/v1/status", nil)
+	if err != nil {
+		return sessionagent.AgentStatus{}, err
+	}
+	if c.AdminToken != "" {
+		req.Header.Set("X-Agent-Token", c.AdminToken)
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return sessionagent.AgentStatus{}, runtimeUnreachableError{Err: err}
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return sessionagent.AgentStatus{}, errAgentStatusUnsupported
+	case resp.StatusCode != http.StatusOK:
+		return sessionagent.AgentStatus{}, agentStatusError{Status: resp.StatusCode}
+	}
+	var status sessionagent.AgentStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return sessionagent.AgentStatus{}, fmt.Errorf("decode agent status: %w", err)
+	}
+	return status, nil
+}
+
+// WaitForReady polls the agent until it reports its status with room for
+// another session. Agents without /v1/status fall back to WaitForHealth.
+func (c *AgentClient) WaitForReady(ctx context.Context, endpoint string, interval time.Duration) error {
+	if endpoint == "" {
+		return errors.New("agent endpoint not configured")
+	}
+	if interval <= 0 {
+		interval = 500 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		status, err := c.Status(ctx, endpoint)
+		if errors.Is(err, errAgentStatusUnsupported) {
+			return c.WaitForHealth(ctx, endpoint, interval)
+		}
+		if err == nil && status.HasCapacity() {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// UploadFile streams body into a session workspace on the agent.
+func (c *AgentClient) UploadFile(ctx context.Context, route AgentRoute, sessionID string, name string, body io.Reader, maxBytes int64) (sessionagent.FileInfo, error) {
+	req, err := c.fileRequest(ctx, http.MethodPut, route, sessionID, name, maxBytes, body)
+	if err != nil {
+		return sessionagent.FileInfo{}, err
+	}
+	resp, err := c.doFileRequest(req)
+	if err != nil {
+		return sessionagent.FileInfo{}, err
+	}
+	defer resp.Body.Close()
+	var info sessionagent.FileInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return sessionagent.FileInfo{}, fmt.Errorf("decode upload response: %w", err)
+	}
+	return info, nil
+}
+
+// DownloadFile opens a workspace file on the agent. The caller closes the
+// returned body.
+func (c *AgentClient) DownloadFile(ctx context.Context, route AgentRoute, sessionID string, name string, maxBytes int64) (io.ReadCloser, sessionagent.FileInfo, error) {
+	req, err := c.fileRequest(ctx, http.MethodGet, route, sessionID, name, maxBytes, nil)
+	if err != nil {
+		return nil, sessionagent.FileInfo{}, err
+	}
+	resp, err := c.doFileRequest(req)
+	if err != nil {
+		return nil, sessionagent.FileInfo{}, err
+	}
+	return resp.Body, sessionagent.FileInfo{
+		Path:     name,
+		Size:     resp.ContentLength,
+		Checksum: resp.Header.Get(sessionagent.FileChecksumHeader),
+	}, nil
+}
+
+func (c *AgentClient) fileRequest(ctx context.Context, method string, route AgentRoute, sessionID string, name string, maxBytes int64, body io.Reader) (*http.Request, error) {
+	if route.Endpoint == "" {
+		return nil, errors.New("agent endpoint not configured")
+	}
+	if sessionID == "" {
+		return nil, errors.New("missing session id")
+	}
+	query := url.Values{"path": {name}}
+	if maxBytes > 0 {
+		query.Set(sessionagent.FileMaxBytesParam, strconv.FormatInt(maxBytes, 10))
+	}
+	req, err := http.NewRequestWithContext(ctx, method, route.Endpoint+"/v1/sessions/"+sessionID+"/files?"+query.Encode(), body)
+	if err != nil {
+		return nil, fmt.Errorf("create file request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+	if route.AuthMode != "bypass" && route.Token != "" {
+		req.Header.Set("X-Session-Token", route.Token)
+	}
+	return req, nil
+}
+
+// doFileRequest sends a file transfer, mapping the agent's rejections onto
+// the shared file errors.
+func (c *AgentClient) doFileRequest(req *http.Request) (*http.Response, error) {
+	// Transfers are bounded by the request context, not the client timeout.
+	client := &http.Client{Transport: c.HTTPClient.Transport}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, runtimeUnreachableError{Err: err}
+	}
+	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusCreated {
+		return resp, nil
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusBadRequest:
+		return nil, sessionagent.ErrInvalidFilePath
+	case http.StatusNotFound:
+		return nil, fs.ErrNotExist
+	case http.StatusConflict:
+		return nil, sessionagent.ErrWorkspaceUnavailable
+	case http.StatusRequestEntityTooLarge:
+		return nil, sessionagent.ErrFileTooLarge
+	}
+	return nil, agentStatusError{Status: resp.StatusCode}
+}
+
+// SnapshotSession fetches a gzip-compressed tar archive of the session
+// workspace from the agent, optionally with the pickled interpreter state.
+func (c *AgentClient) SnapshotSession(ctx context.Context, route AgentRoute, sessionID string, includeState bool) ([]byte, error) {
+	query := url.Values{sessionagent.SnapshotStateParam: {strconv.FormatBool(includeState)}}
+	req, err := c.snapshotRequest(ctx, route, sessionID, "/snapshot?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.doSnapshotRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// RestoreSession unpacks a snapshot archive into the session workspace on
+// the agent.
+func (c *AgentClient) RestoreSession(ctx context.Context, route AgentRoute, sessionID string, archive io.Reader) error {
+	req, err := c.snapshotRequest(ctx, route, sessionID, "/restore", archive)
+	if err != nil {
+		return err
+	}
+	resp, err := c.doSnapshotRequest(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (c *AgentClient) snapshotRequest(ctx context.Context, route AgentRoute, sessionID string, suffix string, body io.Reader) (*http.Request, error) {
+	if route.Endpoint == "" {
+		return nil, errors.New("agent endpoint not configured")
+	}
+	if sessionID == "" {
+		return nil, errors.New("missing session id")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, route.Endpoint+"/v1/sessions/"+sessionID+suffix, body)
+	if err != nil {
+		return nil, fmt.Errorf("create snapshot request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/gzip")
+	}
+	if route.AuthMode != "bypass" && route.Token != "" {
+		req.Header.Set("X-Session-Token", route.Token)
+	}
+	return req, nil
+}
+
+func (c *AgentClient) doSnapshotRequest(req *http.Request) (*http.Response, error) {
+	// Archives are bounded by the request context, not the client timeout.
+	client := &http.Client{Transport: c.HTTPClient.Transport}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, runtimeUnreachableError{Err: err}
+	}
+	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusNoContent {
+		return resp, nil
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusBadRequest:
+		return nil, sessionagent.ErrInvalidSnapshot
+	case http.StatusNotFound:
+		return nil, ErrRuntimeNotFound
+	case http.StatusConflict:
+		return nil, sessionagent.ErrWorkspaceUnavailable
+	case http.StatusUnprocessableEntity:
+		return nil, sessionagent.ErrReplStateFailed
+	}
+	return nil, agentStatusError{Status: resp.StatusCode}
+}
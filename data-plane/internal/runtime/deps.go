@@ -1,7 +1,35 @@
 package runtime
 
-import "errors"
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
 
+var (
+	ErrDependencyNotAllowed      = errors.New("dependency not allowlisted")
+	ErrDependencyNotPinned       = errors.New("dependency version must be pinned")
+	ErrDependenciesUnsupported   = errors.New("dependencies are not supported for this language")
+	ErrDependenciesNotConfigured = errors.New("dependency installation is not configured")
+	ErrDependencyInstallFailed   = errors.New("dependency installation failed")
+)
+
+var (
+	packageNamePattern    = regexp.MustCompile(`^(@[A-Za-z0-9][\w.-]*/)?[A-Za-z0-9][\w.-]*(\[[\w,.-]+\])?$`)
+	packageVersionPattern = regexp.MustCompile(`^[A-Za-z0-9][\w.+-]*$`)
+)
+
+// DependencyPolicy checks requested packages against an allowlist. Entries
+// name a package ("pandas") or a pinned release ("pandas==2.2.2").
 type DependencyPolicy struct {
 	Allowlist []string
 	Requested []string
@@ -13,9 +41,174 @@ func ValidateDependencies(policy DependencyPolicy) error {
 		allowed[item] = struct{}{}
 	}
 	for _, req := range policy.Requested {
-		if _, ok := allowed[req]; !ok {
-			return errors.New("dependency not allowlisted")
+		if _, ok := allowed[req]; ok {
+			continue
+		}
+		if name, _, ok := splitPinned(req); ok {
+			if _, ok := allowed[name]; ok {
+				continue
+			}
+		}
+		return ErrDependencyNotAllowed
+	}
+	return nil
+}
+
+// ValidatePinned rejects dependencies without an exact version, since the
+// install cache is keyed by the dependency set and must be reproducible.
+func ValidatePinned(language string, deps []string) error {
+	if len(deps) == 0 {
+		return nil
+	}
+	manager := packageManager(language)
+	if manager == "" {
+		return ErrDependenciesUnsupported
+	}
+	for _, dep := range deps {
+		name, version, ok := splitPinned(dep)
+		if (manager == "pip") != strings.Contains(dep, "==") {
+			ok = false
+		}
+		// The patterns also keep flags, URLs and local paths out of the
+		// package manager's argument list.
+		if !ok || !packageNamePattern.MatchString(name) || !packageVersionPattern.MatchString(version) {
+			return fmt.Errorf("%w: %s", ErrDependencyNotPinned, dep)
+		}
+	}
+	return nil
+}
+
+// splitPinned splits "name==1.0" (pip) or "name@1.0" / "@scope/name@1.0"
+// (npm) into name and version.
+func splitPinned(dep string) (string, string, bool) {
+	if name, version, ok := strings.Cut(dep, "=="); ok {
+		return name, version, name != ""
+	}
+	if i := strings.LastIndex(dep, "@"); i > 0 {
+		return dep[:i], dep[i+1:], true
+	}
+	return "", "", false
+}
+
+func packageManager(language string) string {
+	switch strings.ToLower(strings.TrimSpace(language)) {
+	case "python", "python3":
+		return "pip"
+	case "node", "javascript":
+		return "npm"
+	}
+	return ""
+}
+
+// DependencyInstaller installs pinned packages from the configured index
+// into a cache directory per dependency set and hands the sandbox the
+// environment that puts them on its import path.
+type DependencyInstaller struct {
+	CacheDir    string
+	PipIndexURL string
+	NpmRegistry string
+
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+func NewDependencyInstaller(cacheDir string, pipIndexURL string, npmRegistry string) (*DependencyInstaller, error) {
+	if cacheDir == "" {
+		return nil, errors.New("missing dependency cache dir")
+	}
+	if err := os.MkdirAll(cacheDir, 0o755); err != nil {
+		return nil, err
+	}
+	return &DependencyInstaller{CacheDir: cacheDir, PipIndexURL: pipIndexURL, NpmRegistry: npmRegistry}, nil
+}
+
+func (i *DependencyInstaller) Install(ctx context.Context, language string, deps []string) ([]string, error) {
+	if len(deps) == 0 {
+		return nil, nil
+	}
+	if err := ValidatePinned(language, deps); err != nil {
+		return nil, err
+	}
+	manager := packageManager(language)
+	key := i.cacheKey(manager, deps)
+	dir := filepath.Join(i.CacheDir, key)
+
+	lock := i.lock(key)
+	lock.Lock()
+	defer lock.Unlock()
+	if _, err := os.Stat(dir); err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		// Install next to the final path and rename, so a crash mid-install
+		// never leaves a half-populated cache entry behind.
+		staging, err := os.MkdirTemp(i.CacheDir, key+".tmp-")
+		if err != nil {
+			return nil, err
+		}
+		if err := i.install(ctx, manager, staging, deps); err != nil {
+			_ = os.RemoveAll(staging)
+			return nil, err
 		}
+		if err := os.Rename(staging, dir); err != nil {
+			_ = os.RemoveAll(staging)
+			return nil, err
+		}
+	}
+	if manager == "npm" {
+		return []string{"NODE_PATH=" + filepath.Join(dir, "node_modules")}, nil
+	}
+	return []string{"PYTHONPATH=" + dir}, nil
+}
+
+func (i *DependencyInstaller) install(ctx context.Context, manager string, dir string, deps []string) error {
+	var cmd *exec.Cmd
+	switch manager {
+	case "pip":
+		// Wheels only: building an sdist runs its setup.py here, outside
+		// the sandbox, just as npm install scripts would.
+		args := []string{"-m", "pip", "install", "--disable-pip-version-check", "--no-input", "--only-binary=:all:", "--target", dir}
+		if i.PipIndexURL != "" {
+			args = append(args, "--index-url", i.PipIndexURL)
+		}
+		cmd = exec.CommandContext(ctx, "python3", append(args, deps...)...)
+	case "npm":
+		args := []string{"install", "--no-audit", "--no-fund", "--ignore-scripts", "--prefix", dir}
+		if i.NpmRegistry != "" {
+			args = append(args, "--registry", i.NpmRegistry)
+		}
+		cmd = exec.CommandContext(ctx, "npm", append(args, deps...)...)
+	default:
+		return ErrDependenciesUnsupported
+	}
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%w: %v: %s", ErrDependencyInstallFailed, err, strings.TrimSpace(string(output)))
 	}
 	return nil
 }
+
+func (i *DependencyInstaller) cacheKey(manager string, deps []string) string {
+	sorted := append([]string{}, deps...)
+	sort.Strings(sorted)
+	index := i.PipIndexURL
+	if manager == "npm" {
+		index = i.NpmRegistry
+	}
+	sum := sha256.Sum256([]byte(manager + "\n" + index + "\n" + strings.Join(sorted, "\n")))
+	return manager + "-" + hex.EncodeToString(sum[:16])
+}
+
+func (i *DependencyInstaller) lock(key string) *sync.Mutex {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.locks == nil {
+		i.locks = map[string]*sync.Mutex{}
+	}
+	lock, ok := i.locks[key]
+	if !ok {
+		lock = &sync.Mutex{}
+		i.locks[key] = lock
+	}
+	return lock
+}
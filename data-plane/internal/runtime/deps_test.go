@@ -1,6 +1,13 @@
 package runtime
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
 
 func TestDependencyAllowlist(t *testing.T) {
 	policy := DependencyPolicy{Allowlist: []string{"a"}, Requested: []string{"a"}}
@@ -16,3 +23,80 @@ func TestDependencyAllowlist(t *testing.T) {
 		t.Fatalf("expected empty allowlist to fail")
 	}
 }
+
+func TestDependencyAllowlistMatchesPackageName(t *testing.T) {
+	policy := DependencyPolicy{Allowlist: []string{"pandas", "lodash"}, Requested: []string{"pandas==2.2.2", "lodash@4.17.21"}}
+	if err := ValidateDependencies(policy); err != nil {
+		t.Fatalf("expected package name to allow pinned versions: %v", err)
+	}
+	policy = DependencyPolicy{Allowlist: []string{"pandas==2.2.2"}, Requested: []string{"pandas==2.1.0"}}
+	if err := ValidateDependencies(policy); err == nil {
+		t.Fatalf("expected other version of pinned entry to fail")
+	}
+}
+
+func TestValidatePinned(t *testing.T) {
+	cases := []struct {
+		language string
+		dep      string
+		ok       bool
+	}{
+		{"python", "requests==2.32.3", true},
+		{"python", "requests", false},
+		{"python", "requests>=2", false},
+		{"python", "--index-url==x", false},
+		{"node", "lodash@4.17.21", true},
+		{"node", "@types/node@20.1.0", true},
+		{"node", "lodash", false},
+		{"node", "lodash==4.17.21", false},
+	}
+	for _, tc := range cases {
+		err := ValidatePinned(tc.language, []string{tc.dep})
+		if (err == nil) != tc.ok {
+			t.Fatalf("ValidatePinned(%q, %q) = %v", tc.language, tc.dep, err)
+		}
+	}
+	if err := ValidatePinned("ruby", []string{"rails==7.0"}); !errors.Is(err, ErrDependenciesUnsupported) {
+		t.Fatalf("expected unsupported language, got %v", err)
+	}
+}
+
+func TestDependencyInstallerCachesBySet(t *testing.T) {
+	bin := t.TempDir()
+	calls := filepath.Join(t.TempDir(), "calls")
+	// The fake pip records each invocation and drops a module into --target.
+	script := "#!/bin/sh\necho \"$@\" >> " + calls + "\nwhile [ \"$1\" != --target ]; do shift; done\ntouch \"$2/fake.py\"\n"
+	if err := os.WriteFile(filepath.Join(bin, "python3"), []byte(script), 0o755); err != nil {
+		t.Fatalf("write fake pip: %v", err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	installer, err := NewDependencyInstaller(t.TempDir(), "http://mirror.local/simple", "")
+	if err != nil {
+		t.Fatalf("installer: %v", err)
+	}
+
+	env, err := installer.Install(context.Background(), "python", []string{"b==1.0", "a==2.0"})
+	if err != nil {
+		t.Fatalf("install: %v", err)
+	}
+	if len(env) != 1 || !strings.HasPrefix(env[0], "PYTHONPATH=") {
+		t.Fatalf("unexpected env %v", env)
+	}
+	if _, err := os.Stat(filepath.Join(strings.TrimPrefix(env[0], "PYTHONPATH="), "fake.py")); err != nil {
+		t.Fatalf("expected installed package: %v", err)
+	}
+	again, err := installer.Install(context.Background(), "python", []string{"a==2.0", "b==1.0"})
+	if err != nil || again[0] != env[0] {
+		t.Fatalf("expected cached install, got %v %v", again, err)
+	}
+	data, _ := os.ReadFile(calls)
+	if lines := strings.Count(string(data), "\n"); lines != 1 {
+		t.Fatalf("expected one pip invocation, got %d", lines)
+	}
+	if !strings.Contains(string(data), "--index-url http://mirror.local/simple") {
+		t.Fatalf("expected mirror index url, got %q", data)
+	}
+	if !strings.Contains(string(data), "--only-binary=:all:") {
+		t.Fatalf("expected pip to be limited to wheels, got %q", data)
+	}
+}
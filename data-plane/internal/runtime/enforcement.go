@@ -0,0 +1,75 @@
+package runtime
+
+import (
+	"errors"
+	"net/http"
+
+	"data-plane/internal/isolation"
+	"data-plane/internal/workspace"
+)
+
+var (
+	ErrRuntimeClassUnsupported = errors.New("runtime class is not available on this backend")
+	ErrLimitsUnsupported       = errors.New("resource limits require CGROUP_ROOT")
+	ErrDiskLimitUnsupported    = errors.New("disk limits are not enforced on this backend")
+	ErrEgressUnenforced        = errors.New("egress allowlists require EGRESS_MODE=deny")
+	ErrSecretsUnsupported      = errors.New("secrets are not available on this backend")
+)
+
+// WorkloadPolicy is the part of a control-plane policy decision that the
+// data plane enforces on a run or session. A nil EgressAllowList leaves
+// the configured default; an empty one allows no egress.
+type WorkloadPolicy struct {
+	Limits          isolation.Limits
+	RuntimeClass    string
+	EgressAllowList []string
+	Secrets         []string
+}
+
+// Enforcement describes what a backend can enforce. A workload whose
+// policy asks for more is refused rather than run without it.
+type Enforcement struct {
+	Limits bool
+	// Disk is set by backends that cap workspace storage; cgroups cannot.
+	Disk   bool
+	Egress bool
+	// RuntimeClasses maps policy runtime classes ("gvisor", "firecracker")
+	// to Kubernetes RuntimeClass names.
+	RuntimeClasses map[string]string
+	Secrets        *workspace.SecretStore
+}
+
+func (e Enforcement) Check(policy WorkloadPolicy) error {
+	if policy.RuntimeClass != "" && e.RuntimeClasses[policy.RuntimeClass] == "" {
+		return ErrRuntimeClassUnsupported
+	}
+	if !policy.Limits.IsZero() && !e.Limits {
+		return ErrLimitsUnsupported
+	}
+	if policy.Limits.Disk > 0 && !e.Disk {
+		return ErrDiskLimitUnsupported
+	}
+	if policy.EgressAllowList != nil && !e.Egress {
+		return ErrEgressUnenforced
+	}
+	if len(policy.Secrets) > 0 && e.Secrets == nil {
+		return ErrSecretsUnsupported
+	}
+	return nil
+}
+
+// writePolicyError answers 422 for workloads whose policy cannot be
+// enforced here and reports whether err was one of those.
+func writePolicyError(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, ErrRuntimeClassUnsupported),
+		errors.Is(err, ErrLimitsUnsupported),
+		errors.Is(err, ErrDiskLimitUnsupported),
+		errors.Is(err, ErrEgressUnenforced),
+		errors.Is(err, ErrSecretsUnsupported),
+		errors.Is(err, workspace.ErrSecretNotFound):
+		writeJSONError(w, http.StatusUnprocessableEntity, "policy_unenforceable", err.Error())
+		return true
+	}
+	return false
+}
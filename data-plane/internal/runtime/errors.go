@@ -3,6 +3,9 @@ package runtime
 import "errors"
 
 var (
-	ErrRuntimeNotFound    = errors.New("runtime not found")
-	ErrRuntimeUnavailable = errors.New("runtime unavailable")
+	ErrRuntimeNotFound        = errors.New("runtime not found")
+	ErrRuntimeUnavailable     = errors.New("runtime unavailable")
+	ErrRuntimeOOMKilled       = errors.New("runtime killed after exceeding its memory limit")
+	ErrRuntimeExited          = errors.New("session interpreter exited")
+	ErrEgressAgentUnsupported = errors.New("egress isolation cannot be combined with SESSION_AGENT_LAUNCH")
 )
@@ -11,39 +11,93 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"shared/sessionagent"
+
+	"data-plane/internal/isolation"
+	"data-plane/internal/workspace"
 )
 
 type RunHandler struct {
 	Runner Runner
 	Store  RunStore
+	Queue  *RunQueue
+	// Enforcement is what the run adapters can enforce of a policy.
+	Enforcement Enforcement
 }
 
 type runRequest struct {
-	JobID        string `json:"jobId"`
-	PolicyID     string `json:"policyId"`
-	Language     string `json:"language"`
-	Code         string `json:"code"`
-	WorkspaceRef string `json:"workspaceRef"`
+	JobID           string           `json:"jobId"`
+	TenantID        string           `json:"tenantId,omitempty"`
+	PolicyID        string           `json:"policyId"`
+	Language        string           `json:"language"`
+	Code            string           `json:"code"`
+	WorkspaceRef    string           `json:"workspaceRef"`
+	TimeoutSeconds  int              `json:"timeout_seconds,omitempty"`
+	Limits          isolation.Limits `json:"limits"`
+	EgressAllowList []string         `json:"egressAllowList,omitempty"`
+	Deps            []string         `json:"deps,omitempty"`
+	DepsAllowlist   []string         `json:"depsAllowlist,omitempty"`
+	RuntimeClass    string           `json:"runtimeClass,omitempty"`
+	Secrets         []string         `json:"secrets,omitempty"`
 }
 
 type runResponse struct {
-	RunID string `json:"run_id"`
+	RunID  string `json:"run_id"`
+	Status string `json:"status"`
 }
 
 type Run struct {
-	ID           string
-	JobID        string
-	Status       string
-	ExitStatus   int
-	OutputRef    string
-	ErrorRef     string
-	ArtifactRefs []string
+	ID              string                     `json:"id"`
+	JobID           string                     `json:"jobId"`
+	TenantID        string                     `json:"tenantId,omitempty"`
+	Language        string                     `json:"language"`
+	Code            string                     `json:"code,omitempty"`
+	TimeoutSeconds  int                        `json:"timeoutSeconds,omitempty"`
+	Limits          isolation.Limits           `json:"limits"`
+	EgressAllowList []string                   `json:"egressAllowList"`
+	Deps            []string                   `json:"deps,omitempty"`
+	DepsAllowlist   []string                   `json:"depsAllowlist,omitempty"`
+	RuntimeClass    string                     `json:"runtimeClass,omitempty"`
+	Secrets         []string                   `json:"secrets,omitempty"`
+	Status          string                     `json:"status"`
+	FailureReason   string                     `json:"failureReason,omitempty"`
+	CompileOutput   string                     `json:"compileOutput,omitempty"`
+	ExitStatus      int                        `json:"exitStatus"`
+	OutputRef       string                     `json:"outputRef,omitempty"`
+	ErrorRef        string                     `json:"errorRef,omitempty"`
+	ArtifactRefs    []string                   `json:"artifactRefs,omitempty"`
+	Stdout          string                     `json:"stdout"`
+	Stderr          string                     `json:"stderr"`
+	Truncated       bool                       `json:"truncated"`
+	WallTimeMs      int64                      `json:"wallTimeMs"`
+	ResourceUsage   sessionagent.ResourceUsage `json:"resourceUsage"`
+}
+
+const (
+	RunStatusQueued     = "queued"
+	RunStatusRunning    = "running"
+	RunStatusFinished   = "finished"
+	RunStatusFailed     = "failed"
+	RunStatusTimedOut   = "timed_out"
+	RunStatusTerminated = "terminated"
+)
+
+func RunStatusTerminal(status string) bool {
+	switch status {
+	case RunStatusFinished, RunStatusFailed, RunStatusTimedOut, RunStatusTerminated:
+		return true
+	}
+	return false
 }
 
 type RunStore interface {
 	Create(ctx context.Context, run Run) error
 	Get(ctx context.Context, id string) (Run, error)
+	Update(ctx context.Context, run Run) error
 	UpdateStatus(ctx context.Context, id string, status string) error
+	// Transition sets the run's status to to if it is still from, and
+	// returns ErrRunStatusChanged otherwise.
+	Transition(ctx context.Context, id string, from string, to string) error
+	List(ctx context.Context) ([]Run, error)
 }
 
 type SessionHandler struct {
@@ -51,13 +105,24 @@ type SessionHandler struct {
 	Registry    SessionRegistry
 	Agent       *AgentClient
 	AgentPrefer bool
+	// Snapshots stores session snapshots; snapshot and restore are
+	// unsupported without it.
+	Snapshots *workspace.SnapshotStore
 }
 
 type sessionRequest struct {
-	SessionID    string `json:"sessionId"`
-	PolicyID     string `json:"policyId"`
-	WorkspaceRef string `json:"workspaceRef"`
-	Runtime      string `json:"runtime"`
+	SessionID       string           `json:"sessionId"`
+	TenantID        string           `json:"tenantId,omitempty"`
+	PolicyID        string           `json:"policyId"`
+	WorkspaceRef    string           `json:"workspaceRef"`
+	Runtime         string           `json:"runtime"`
+	Deps            []string         `json:"deps"`
+	DepsAllowlist   []string         `json:"depsAllowlist"`
+	SnapshotID      string           `json:"snapshotId,omitempty"`
+	Limits          isolation.Limits `json:"limits"`
+	RuntimeClass    string           `json:"runtimeClass,omitempty"`
+	EgressAllowList []string         `json:"egressAllowList"`
+	Secrets         []string         `json:"secrets,omitempty"`
 }
 
 type sessionResponse struct {
@@ -67,13 +132,21 @@ type sessionResponse struct {
 }
 
 type sessionStepRequest struct {
-	Command string `json:"command"`
+	Command   string `json:"command"`
+	TimeoutMs int64  `json:"timeoutMs"`
+}
+
+type sessionInterruptResponse struct {
+	SessionID   string `json:"sessionId"`
+	Interrupted bool   `json:"interrupted"`
 }
 
 type sessionStepResponse struct {
-	Status string `json:"status"`
-	Stdout string `json:"stdout"`
-	Stderr string `json:"stderr"`
+	Status        string                     `json:"status"`
+	Stdout        string                     `json:"stdout"`
+	Stderr        string                     `json:"stderr"`
+	StateLost     bool                       `json:"stateLost,omitempty"`
+	ResourceUsage sessionagent.ResourceUsage `json:"resourceUsage"`
 }
 
 type errorResponse struct {
@@ -82,10 +155,37 @@ type errorResponse struct {
 }
 
 func (h SessionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasSuffix(r.URL.Path, "/files") {
+		switch r.Method {
+		case http.MethodPut:
+			h.handleUpload(w, r)
+		case http.MethodGet:
+			h.handleDownload(w, r)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+		return
+	}
 	if r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/terminate") {
 		h.handleTerminate(w, r)
 		return
 	}
+	if r.Method == http.MethodGet && chi.URLParam(r, "snapshotId") != "" {
+		h.handleGetSnapshot(w, r)
+		return
+	}
+	if r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/snapshot") {
+		h.handleSnapshot(w, r)
+		return
+	}
+	if r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/interrupt") {
+		h.handleInterrupt(w, r)
+		return
+	}
+	if r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/steps/stream") {
+		h.handleStepStream(w, r)
+		return
+	}
 	if r.Method == http.MethodPost && strings.Contains(r.URL.Path, "/steps") {
 		h.handleStep(w, r)
 		return
@@ -112,9 +212,39 @@ func (h SessionHandler) handleCreate(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	route, err := h.Runtime.StartSession(r.Context(), req.SessionID, req.PolicyID, req.WorkspaceRef, req.Runtime)
+	if !writeDependencyError(w, req.Runtime, req.Deps, req.DepsAllowlist) {
+		return
+	}
+	var archive []byte
+	if req.SnapshotID != "" {
+		var ok bool
+		if archive, ok = h.loadSnapshot(w, req.SnapshotID, req.Runtime); !ok {
+			return
+		}
+	}
+	route, err := h.Runtime.StartSession(r.Context(), SessionSpec{
+		ID:           req.SessionID,
+		TenantID:     req.TenantID,
+		PolicyID:     req.PolicyID,
+		WorkspaceRef: req.WorkspaceRef,
+		Runtime:      req.Runtime,
+		Deps:         req.Deps,
+		Policy: WorkloadPolicy{
+			Limits:          req.Limits,
+			RuntimeClass:    req.RuntimeClass,
+			EgressAllowList: req.EgressAllowList,
+			Secrets:         req.Secrets,
+		},
+	})
 	if err != nil {
 		log.Printf("sessions: start error: %v", err)
+		if writePolicyError(w, err) {
+			return
+		}
+		if errors.Is(err, ErrDependencyInstallFailed) {
+			writeJSONError(w, http.StatusUnprocessableEntity, FailureDependencyInstall, err.Error())
+			return
+		}
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -123,6 +253,17 @@ func (h SessionHandler) handleCreate(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+	if archive != nil {
+		if err := h.restoreSnapshot(r.Context(), req.SessionID, route, archive); err != nil {
+			log.Printf("sessions: restore session_id=%s snapshot_id=%s error: %v", req.SessionID, req.SnapshotID, err)
+			if stopErr := h.stopSession(r.Context(), req.SessionID, route); stopErr != nil {
+				log.Printf("sessions: restore cleanup session_id=%s error: %v", req.SessionID, stopErr)
+			}
+			writeSnapshotError(w, err)
+			return
+		}
+		log.Printf("sessions: restore session_id=%s snapshot_id=%s", req.SessionID, req.SnapshotID)
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusAccepted)
 	_ = json.NewEncoder(w).Encode(sessionResponse{ID: req.SessionID, RuntimeID: route.RuntimeID, Status: "running"})
@@ -163,15 +304,19 @@ func (h SessionHandler) handleStep(w http.ResponseWriter, r *http.Request) {
 			StepID:    stepID,
 			Code:      req.Command,
 			Runtime:   route.Runtime,
+			TimeoutMs: req.TimeoutMs,
 		})
 		if agentErr == nil {
 			log.Printf("sessions: route session_id=%s runtime_id=%s endpoint=%s auth_mode=%s step_id=%s status=%s", sessionID, route.RuntimeID, route.Endpoint, route.AuthMode, stepID, agentResult.Status)
+			RecordResourceUsage(r.Context(), "step", agentResult.ResourceUsage)
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusAccepted)
 			_ = json.NewEncoder(w).Encode(sessionStepResponse{
-				Status: agentResult.Status,
-				Stdout: agentResult.Stdout,
-				Stderr: agentResult.Stderr,
+				Status:        agentResult.Status,
+				Stdout:        agentResult.Stdout,
+				Stderr:        agentResult.Stderr,
+				StateLost:     agentResult.StateLost,
+				ResourceUsage: agentResult.ResourceUsage,
 			})
 			return
 		}
@@ -183,29 +328,167 @@ func (h SessionHandler) handleStep(w http.ResponseWriter, r *http.Request) {
 		}
 		log.Printf("sessions: agent step error: %v", agentErr)
 	}
-	output, err := h.Runtime.RunStep(r.Context(), route.RuntimeID, req.Command)
+	output, err := h.Runtime.RunStep(r.Context(), route.RuntimeID, req.Command, stepTimeout(req))
 	if err != nil {
-		if errors.Is(err, ErrRuntimeNotFound) {
-			writeJSONError(w, http.StatusServiceUnavailable, "runtime_crashed", "session runtime is not available")
+		log.Printf("sessions: route session_id=%s runtime_id=%s endpoint=%s auth_mode=%s step_id=%s status=failed", sessionID, route.RuntimeID, route.Endpoint, route.AuthMode, stepID)
+		writeStepError(w, err)
+		return
+	}
+	status := localStepStatus(output)
+	log.Printf("sessions: route session_id=%s runtime_id=%s endpoint=%s auth_mode=%s step_id=%s status=%s", sessionID, route.RuntimeID, route.Endpoint, route.AuthMode, stepID, status)
+	RecordResourceUsage(r.Context(), "step", output.Usage)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(sessionStepResponse{
+		Status:        status,
+		Stdout:        output.Stdout,
+		Stderr:        output.Stderr,
+		StateLost:     output.StateLost,
+		ResourceUsage: output.Usage,
+	})
+}
+
+// handleStepStream runs a step like handleStep but relays output as
+// server-sent events while it runs, ending with a status event. Errors
+// before the first event get the same JSON responses as handleStep.
+func (h SessionHandler) handleStepStream(w http.ResponseWriter, r *http.Request) {
+	if h.Runtime == nil || h.Registry == nil {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+	sessionID := chi.URLParam(r, "sessionId")
+	if sessionID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var req sessionStepRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Command == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	stepID := "step-" + time.Now().UTC().Format("20060102150405.000")
+	route, ok := h.Registry.Get(sessionID)
+	if !ok {
+		writeJSONError(w, http.StatusGone, "session_expired", "session not found")
+		return
+	}
+	events := sessionagent.NewEventStream(w)
+	if h.Agent != nil && route.Endpoint != "" && h.AgentPrefer {
+		result, err := h.Agent.StreamStep(r.Context(), AgentRoute{
+			Endpoint: route.Endpoint,
+			Token:    route.Token,
+			AuthMode: route.AuthMode,
+		}, sessionagent.StepRequest{
+			SessionID: sessionID,
+			StepID:    stepID,
+			Code:      req.Command,
+			Runtime:   route.Runtime,
+			TimeoutMs: req.TimeoutMs,
+		}, events.Write)
+		if err == nil {
+			log.Printf("sessions: route session_id=%s runtime_id=%s endpoint=%s auth_mode=%s step_id=%s status=%s stream=true", sessionID, route.RuntimeID, route.Endpoint, route.AuthMode, stepID, result.Status)
+			RecordResourceUsage(r.Context(), "step", result.ResourceUsage)
 			return
 		}
-		if errors.Is(err, ErrRuntimeUnavailable) {
+		if events.Started() {
+			log.Printf("sessions: agent step stream broken step_id=%s: %v", stepID, err)
+			failStepStream(events, stepID, err)
+			return
+		}
+		var unreachable runtimeUnreachableError
+		if errors.As(err, &unreachable) {
+			log.Printf("sessions: agent step unreachable: %v", err)
 			writeJSONError(w, http.StatusServiceUnavailable, "runtime_unreachable", "runtime agent is not reachable")
 			return
 		}
-		log.Printf("sessions: route session_id=%s runtime_id=%s endpoint=%s auth_mode=%s step_id=%s status=failed", sessionID, route.RuntimeID, route.Endpoint, route.AuthMode, stepID)
+		log.Printf("sessions: agent step error: %v", err)
+	}
+	emit := func(stream string, data string) {
+		_ = events.Write(sessionagent.StepEvent{Type: stream, Data: data})
+	}
+	var output StepOutput
+	var err error
+	if streamer, ok := h.Runtime.(StepStreamer); ok {
+		output, err = streamer.StreamStep(r.Context(), route.RuntimeID, req.Command, stepTimeout(req), emit)
+	} else if output, err = h.Runtime.RunStep(r.Context(), route.RuntimeID, req.Command, stepTimeout(req)); err == nil {
+		if output.Stdout != "" {
+			emit(sessionagent.StepEventStdout, output.Stdout)
+		}
+		if output.Stderr != "" {
+			emit(sessionagent.StepEventStderr, output.Stderr)
+		}
+	}
+	if err != nil {
+		log.Printf("sessions: route session_id=%s runtime_id=%s endpoint=%s auth_mode=%s step_id=%s status=failed stream=true", sessionID, route.RuntimeID, route.Endpoint, route.AuthMode, stepID)
+		if events.Started() {
+			failStepStream(events, stepID, err)
+			return
+		}
+		writeStepError(w, err)
+		return
+	}
+	status := localStepStatus(output)
+	log.Printf("sessions: route session_id=%s runtime_id=%s endpoint=%s auth_mode=%s step_id=%s status=%s stream=true", sessionID, route.RuntimeID, route.Endpoint, route.AuthMode, stepID, status)
+	RecordResourceUsage(r.Context(), "step", output.Usage)
+	_ = events.Write(sessionagent.StepEvent{Type: sessionagent.StepEventStatus, Result: &sessionagent.StepResult{
+		StepID:        stepID,
+		Status:        status,
+		StateLost:     output.StateLost,
+		ResourceUsage: output.Usage,
+	}})
+}
+
+// stepTimeout is the step's timeout; zero leaves it unbounded.
+func stepTimeout(req sessionStepRequest) time.Duration {
+	return time.Duration(req.TimeoutMs) * time.Millisecond
+}
+
+// localStepStatus is the status of a step the session runtime ran itself.
+func localStepStatus(output StepOutput) string {
+	if output.TimedOut {
+		return sessionagent.StepStatusTimedOut
+	}
+	return sessionagent.StepStatusCompleted
+}
+
+// failStepStream ends a stream that has already started with the error
+// and a failed status, since the response status can no longer change.
+func failStepStream(events *sessionagent.EventStream, stepID string, err error) {
+	_ = events.Write(sessionagent.StepEvent{Type: sessionagent.StepEventStderr, Data: err.Error()})
+	_ = events.Write(sessionagent.StepEvent{Type: sessionagent.StepEventStatus, Result: &sessionagent.StepResult{
+		StepID: stepID,
+		Status: sessionagent.StepStatusFailed,
+	}})
+}
+
+func writeStepError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrRuntimeNotFound):
+		writeJSONError(w, http.StatusServiceUnavailable, "runtime_crashed", "session runtime is not available")
+	case errors.Is(err, ErrRuntimeUnavailable):
+		writeJSONError(w, http.StatusServiceUnavailable, "runtime_unreachable", "runtime agent is not reachable")
+	case errors.Is(err, ErrRuntimeOOMKilled):
+		writeJSONError(w, http.StatusServiceUnavailable, "runtime_oom_killed", "session runtime exceeded its memory limit")
+	case errors.Is(err, ErrRuntimeExited):
+		writeJSONError(w, http.StatusServiceUnavailable, "runtime_exited", err.Error())
+	default:
 		log.Printf("sessions: step error: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
-		return
 	}
-	log.Printf("sessions: route session_id=%s runtime_id=%s endpoint=%s auth_mode=%s step_id=%s status=completed", sessionID, route.RuntimeID, route.Endpoint, route.AuthMode, stepID)
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusAccepted)
-	_ = json.NewEncoder(w).Encode(sessionStepResponse{
-		Status: "accepted",
-		Stdout: output.Stdout,
-		Stderr: output.Stderr,
-	})
+}
+
+// writeDependencyError rejects dependency sets that can never be installed
+// before any work is queued. It reports whether the request may proceed.
+func writeDependencyError(w http.ResponseWriter, language string, deps []string, allowlist []string) bool {
+	if err := ValidateDependencies(DependencyPolicy{Allowlist: allowlist, Requested: deps}); err != nil {
+		writeJSONError(w, http.StatusForbidden, "dependency_not_allowed", err.Error())
+		return false
+	}
+	if err := ValidatePinned(language, deps); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_dependencies", err.Error())
+		return false
+	}
+	return true
 }
 
 func writeJSONError(w http.ResponseWriter, status int, code string, message string) {
@@ -217,6 +500,43 @@ func writeJSONError(w http.ResponseWriter, status int, code string, message stri
 	})
 }
 
+// handleInterrupt asks the session agent to stop the running step. Only
+// agent-backed sessions can be interrupted.
+func (h SessionHandler) handleInterrupt(w http.ResponseWriter, r *http.Request) {
+	if h.Registry == nil {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+	sessionID := chi.URLParam(r, "sessionId")
+	if sessionID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	route, ok := h.Registry.Get(sessionID)
+	if !ok {
+		writeJSONError(w, http.StatusGone, "session_expired", "session not found")
+		return
+	}
+	if h.Agent == nil || route.Endpoint == "" {
+		writeJSONError(w, http.StatusNotImplemented, "interrupt_unsupported", "session runtime does not support interrupts")
+		return
+	}
+	interrupted, err := h.Agent.InterruptSession(r.Context(), AgentRoute{
+		Endpoint: route.Endpoint,
+		Token:    route.Token,
+		AuthMode: route.AuthMode,
+	}, sessionID)
+	if err != nil {
+		log.Printf("sessions: interrupt agent error session_id=%s: %v", sessionID, err)
+		writeJSONError(w, http.StatusServiceUnavailable, "runtime_unreachable", "runtime agent is not reachable")
+		return
+	}
+	log.Printf("sessions: interrupt session_id=%s interrupted=%t", sessionID, interrupted)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(sessionInterruptResponse{SessionID: sessionID, Interrupted: interrupted})
+}
+
 func (h SessionHandler) handleTerminate(w http.ResponseWriter, r *http.Request) {
 	if h.Runtime == nil || h.Registry == nil {
 		w.WriteHeader(http.StatusNotImplemented)
@@ -232,8 +552,17 @@ func (h SessionHandler) handleTerminate(w http.ResponseWriter, r *http.Request)
 		w.WriteHeader(http.StatusNotFound)
 		return
 	}
+	if err := h.stopSession(r.Context(), sessionID, route); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// stopSession tears down a session's agent and runtime and forgets its route.
+func (h SessionHandler) stopSession(ctx context.Context, sessionID string, route SessionRoute) error {
 	if h.Agent != nil && route.Endpoint != "" {
-		if err := h.Agent.TerminateSession(r.Context(), AgentRoute{
+		if err := h.Agent.TerminateSession(ctx, AgentRoute{
 			Endpoint: route.Endpoint,
 			Token:    route.Token,
 			AuthMode: route.AuthMode,
@@ -241,12 +570,11 @@ func (h SessionHandler) handleTerminate(w http.ResponseWriter, r *http.Request)
 			log.Printf("sessions: terminate agent error: %v", err)
 		}
 	}
-	if err := h.Runtime.TerminateSession(r.Context(), route.RuntimeID); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+	if err := h.Runtime.TerminateSession(ctx, route.RuntimeID); err != nil {
+		return err
 	}
 	h.Registry.Delete(sessionID)
-	w.WriteHeader(http.StatusAccepted)
+	return nil
 }
 
 func (h RunHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -271,19 +599,92 @@ func (h RunHandler) handleCreate(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	runID, err := h.Runner.Run(r.Context(), req.JobID, req.Language, req.Code)
+	if req.TimeoutSeconds < 0 {
+		writeJSONError(w, http.StatusBadRequest, "invalid_timeout", "timeout_seconds must not be negative")
+		return
+	}
+	if !writeDependencyError(w, req.Language, req.Deps, req.DepsAllowlist) {
+		return
+	}
+	if err := h.Enforcement.Check(WorkloadPolicy{
+		Limits:          req.Limits,
+		RuntimeClass:    req.RuntimeClass,
+		EgressAllowList: req.EgressAllowList,
+		Secrets:         req.Secrets,
+	}); err != nil {
+		writePolicyError(w, err)
+		return
+	}
+	if h.Queue != nil {
+		h.handleEnqueue(w, r, req)
+		return
+	}
+	ctx := r.Context()
+	if req.TimeoutSeconds > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(req.TimeoutSeconds)*time.Second)
+		defer cancel()
+	}
+	run, err := h.Runner.Run(ctx, runFromRequest(req))
 	if err != nil {
 		log.Printf("runs: run error: %v", err)
+		if writePolicyError(w, err) {
+			return
+		}
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 	if h.Store != nil {
-		_ = h.Store.Create(r.Context(), Run{ID: runID, JobID: req.JobID, Status: "running"})
+		if err := h.Store.Create(r.Context(), run); err != nil {
+			log.Printf("runs: store error: %v", err)
+		}
 	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusAccepted)
-	_ = json.NewEncoder(w).Encode(runResponse{RunID: runID})
-	log.Printf("runs: accepted job_id=%s run_id=%s ts=%s", req.JobID, runID, time.Now().UTC().Format(time.RFC3339))
+	_ = json.NewEncoder(w).Encode(runResponse{RunID: run.ID, Status: run.Status})
+	log.Printf("runs: accepted job_id=%s run_id=%s status=%s exit_status=%d ts=%s", req.JobID, run.ID, run.Status, run.ExitStatus, time.Now().UTC().Format(time.RFC3339))
+}
+
+func (h RunHandler) handleEnqueue(w http.ResponseWriter, r *http.Request, req runRequest) {
+	if req.JobID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	run := runFromRequest(req)
+	if err := h.Queue.Enqueue(r.Context(), run); err != nil {
+		log.Printf("runs: enqueue error: %v", err)
+		switch {
+		case errors.Is(err, ErrRunQueueFull):
+			writeJSONError(w, http.StatusServiceUnavailable, "queue_full", "run queue is full")
+			return
+		case errors.Is(err, ErrRunExists):
+			writeJSONError(w, http.StatusConflict, "run_exists", "a run already exists for this job")
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(runResponse{RunID: run.ID, Status: RunStatusQueued})
+	log.Printf("runs: queued job_id=%s run_id=%s ts=%s", req.JobID, run.ID, time.Now().UTC().Format(time.RFC3339))
+}
+
+func runFromRequest(req runRequest) Run {
+	return Run{
+		ID:              RunIDForJob(req.JobID),
+		JobID:           req.JobID,
+		TenantID:        req.TenantID,
+		Language:        req.Language,
+		Code:            req.Code,
+		TimeoutSeconds:  req.TimeoutSeconds,
+		Limits:          req.Limits,
+		EgressAllowList: req.EgressAllowList,
+		Deps:            req.Deps,
+		DepsAllowlist:   req.DepsAllowlist,
+		RuntimeClass:    req.RuntimeClass,
+		Secrets:         req.Secrets,
+	}
 }
 
 func (h RunHandler) handleGet(w http.ResponseWriter, r *http.Request) {
@@ -315,7 +716,23 @@ func (h RunHandler) handleTerminate(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	if err := h.Store.UpdateStatus(r.Context(), runID, "terminated"); err != nil {
+	if h.Queue != nil {
+		if err := h.Queue.Terminate(r.Context(), runID); err != nil {
+			switch {
+			case errors.Is(err, ErrRunNotFound):
+				w.WriteHeader(http.StatusNotFound)
+			case errors.Is(err, ErrRunNotActive):
+				writeJSONError(w, http.StatusConflict, "run_not_active", "run has already completed")
+			default:
+				log.Printf("runs: terminate error run_id=%s: %v", runID, err)
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	if err := h.Store.UpdateStatus(r.Context(), runID, RunStatusTerminated); err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -0,0 +1,133 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"shared/sessionagent"
+)
+
+type RunNotifier interface {
+	Notify(ctx context.Context, run Run) error
+}
+
+// JobCallbackClient reports terminal run state to the control plane.
+type JobCallbackClient struct {
+	URL        string
+	Token      string
+	HTTPClient *http.Client
+}
+
+type jobCallbackRequest struct {
+	RunID         string                     `json:"runId"`
+	Status        string                     `json:"status"`
+	ExitStatus    int                        `json:"exitStatus"`
+	OutputRef     string                     `json:"outputRef"`
+	ErrorRef      string                     `json:"errorRef"`
+	ArtifactRefs  []string                   `json:"artifactRefs"`
+	ResourceUsage sessionagent.ResourceUsage `json:"resourceUsage"`
+}
+
+type callbackStatusError struct {
+	Status int
+}
+
+func (e callbackStatusError) Error() string {
+	return fmt.Sprintf("callback returned status %d", e.Status)
+}
+
+func NewJobCallbackClient(url string, token string) *JobCallbackClient {
+	return &JobCallbackClient{
+		URL:        url,
+		Token:      token,
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (c *JobCallbackClient) Notify(ctx context.Context, run Run) error {
+	if c.URL == "" {
+		return errors.New("callback url not configured")
+	}
+	if run.JobID == "" {
+		return errors.New("missing job id")
+	}
+	payload, err := json.Marshal(jobCallbackRequest{
+		RunID:         run.ID,
+		Status:        run.Status,
+		ExitStatus:    run.ExitStatus,
+		OutputRef:     run.OutputRef,
+		ErrorRef:      run.ErrorRef,
+		ArtifactRefs:  run.ArtifactRefs,
+		ResourceUsage: run.ResourceUsage,
+	})
+	if err != nil {
+		return fmt.Errorf("encode callback request: %w", err)
+	}
+
+	const maxAttempts = 3
+	const baseDelay = 200 * time.Millisecond
+	var lastErr error
+
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		err := c.notifyOnce(ctx, run.JobID, payload)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if !isRetryableCallbackError(err) || attempt == maxAttempts {
+			return err
+		}
+		delay := baseDelay * time.Duration(1<<(attempt-1))
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	return lastErr
+}
+
+func (c *JobCallbackClient) notifyOnce(ctx context.Context, jobID string, payload []byte) error {
+	url := strings.TrimRight(c.URL, "/") + "/internal/jobs/" + jobID + "/complete"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("create callback request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.Token != "" {
+		req.Header.Set("X-Callback-Token", c.Token)
+	}
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("callback request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return callbackStatusError{Status: resp.StatusCode}
+	}
+	return nil
+}
+
+func isRetryableCallbackError(err error) bool {
+	var statusErr callbackStatusError
+	if errors.As(err, &statusErr) {
+		if statusErr.Status == http.StatusTooManyRequests {
+			return true
+		}
+		return statusErr.Status >= http.StatusInternalServerError
+	}
+	return true
+}
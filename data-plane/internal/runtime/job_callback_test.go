@@ -0,0 +1,49 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestJobCallbackClientPostsRunState(t *testing.T) {
+	var attempts atomic.Int32
+	var received jobCallbackRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.URL.Path != "/internal/jobs/job-1/complete" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if r.Header.Get("X-Callback-Token") != "secret" {
+			t.Errorf("missing callback token")
+		}
+		_ = json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client := NewJobCallbackClient(server.URL, "secret")
+	err := client.Notify(context.Background(), Run{
+		ID:         "job-1-run",
+		JobID:      "job-1",
+		Status:     RunStatusFailed,
+		ExitStatus: 2,
+		OutputRef:  "/out/stdout.log",
+		ErrorRef:   "/out/stderr.log",
+	})
+	if err != nil {
+		t.Fatalf("notify: %v", err)
+	}
+	if attempts.Load() != 2 {
+		t.Fatalf("expected retry after 503, got %d attempts", attempts.Load())
+	}
+	if received.RunID != "job-1-run" || received.Status != RunStatusFailed || received.ExitStatus != 2 || received.ErrorRef != "/out/stderr.log" {
+		t.Fatalf("unexpected callback body %+v", received)
+	}
+}
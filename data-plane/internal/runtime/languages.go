@@ -0,0 +1,122 @@
+package runtime
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// LanguageConfig declares how one-shot runs of a language are built and
+// executed. Compile and Run are argv templates in which {src} is the source
+// file, {bin} the compiled output and {dir} the per-run scratch directory.
+// A Run template without {src} or {bin} gets the source file appended.
+type LanguageConfig struct {
+	Name      string   `json:"name"`
+	Extension string   `json:"extension"`
+	Compile   []string `json:"compile,omitempty"`
+	Run       []string `json:"run"`
+	Image     string   `json:"image,omitempty"`
+}
+
+type languageFile struct {
+	Languages []LanguageConfig `json:"languages"`
+}
+
+// DefaultLanguages is the curated toolchain set available without an
+// adapter config file.
+var DefaultLanguages = []LanguageConfig{
+	{Name: "python", Extension: ".py", Run: []string{"python3"}, Image: "python:3.12-slim"},
+	{Name: "node", Extension: ".js", Run: []string{"node"}, Image: "node:20-alpine"},
+	{Name: "bash", Extension: ".sh", Run: []string{"bash"}, Image: "bash:5.2"},
+	{Name: "ruby", Extension: ".rb", Run: []string{"ruby"}, Image: "ruby:3.3-slim"},
+	{
+		Name:      "go",
+		Extension: ".go",
+		Compile:   []string{"go", "build", "-o", "{bin}", "{src}"},
+		Run:       []string{"{bin}"},
+		Image:     "golang:1.23-alpine",
+	},
+	{
+		Name:      "rust",
+		Extension: ".rs",
+		Compile:   []string{"rustc", "-O", "-o", "{bin}", "{src}"},
+		Run:       []string{"{bin}"},
+		Image:     "rust:1.80-slim",
+	},
+}
+
+// LoadLanguages reads a JSON adapter config and merges it over
+// DefaultLanguages; entries with a built-in name replace the built-in.
+func LoadLanguages(path string) ([]LanguageConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file languageFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse adapter config: %w", err)
+	}
+	languages := append([]LanguageConfig{}, DefaultLanguages...)
+	for _, lang := range file.Languages {
+		if err := lang.Validate(); err != nil {
+			return nil, err
+		}
+		replaced := false
+		for i := range languages {
+			if languages[i].Name == lang.Name {
+				languages[i] = lang
+				replaced = true
+			}
+		}
+		if !replaced {
+			languages = append(languages, lang)
+		}
+	}
+	return languages, nil
+}
+
+func (c LanguageConfig) Validate() error {
+	if c.Name == "" {
+		return errors.New("adapter config: missing language name")
+	}
+	if len(c.Run) == 0 || c.Run[0] == "" {
+		return fmt.Errorf("adapter config: %s: missing run command", c.Name)
+	}
+	if c.Extension != "" && !strings.HasPrefix(c.Extension, ".") {
+		return fmt.Errorf("adapter config: %s: extension must start with a dot", c.Name)
+	}
+	if len(c.Compile) > 0 && c.Extension == "" {
+		// {src} and {bin} would otherwise name the same file.
+		return fmt.Errorf("adapter config: %s: compiled languages need an extension", c.Name)
+	}
+	return nil
+}
+
+// NewRegistryFromLanguages registers an ExecAdapter per configured language.
+func NewRegistryFromLanguages(languages []LanguageConfig, iso ExecIsolation) Registry {
+	registry := NewRegistry()
+	for _, lang := range languages {
+		registry.Register(lang.Name, ExecAdapter{
+			Command:    lang.Run[0],
+			Args:       lang.Run[1:],
+			Compile:    lang.Compile,
+			Extension:  lang.Extension,
+			Limiter:    iso.Limiter,
+			DenyEgress: iso.DenyEgress,
+		})
+	}
+	return registry
+}
+
+// LanguageImages maps language names to their default runtime image.
+func LanguageImages(languages []LanguageConfig) map[string]string {
+	images := map[string]string{}
+	for _, lang := range languages {
+		if lang.Image != "" {
+			images[lang.Name] = lang.Image
+		}
+	}
+	return images
+}
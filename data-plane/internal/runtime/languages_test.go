@@ -0,0 +1,75 @@
+package runtime
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadLanguagesMergesDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "adapters.json")
+	config := `{"languages": [
+		{"name": "python", "extension": ".py", "run": ["python3", "-I"]},
+		{"name": "lua", "extension": ".lua", "run": ["lua", "{src}"], "image": "lua:5.4"}
+	]}`
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	languages, err := LoadLanguages(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	registry := NewRegistryFromLanguages(languages, ExecIsolation{})
+	for _, name := range []string{"python", "node", "bash", "go", "rust", "ruby", "lua"} {
+		if !registry.Supports(name) {
+			t.Fatalf("expected %s to be registered", name)
+		}
+	}
+	adapter, _ := registry.Adapter("python")
+	if args := adapter.(ExecAdapter).Args; len(args) != 1 || args[0] != "-I" {
+		t.Fatalf("expected python override, got %v", args)
+	}
+	if LanguageImages(languages)["lua"] != "lua:5.4" {
+		t.Fatalf("expected lua image")
+	}
+	if image := imageForRuntime("lua", "", "", "sandbox:latest", LanguageImages(languages)); image != "lua:5.4" {
+		t.Fatalf("expected the language image ahead of the fallback, got %s", image)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"languages": [{"name": "c", "compile": ["cc", "{src}"], "run": ["{bin}"]}]}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if _, err := LoadLanguages(path); err == nil {
+		t.Fatalf("expected compiled language without extension to be rejected")
+	}
+}
+
+func TestExecAdapterCompilesBeforeRunning(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not installed")
+	}
+	registry := NewRegistryFromLanguages(DefaultLanguages, ExecIsolation{})
+	adapter, _ := registry.Adapter("go")
+
+	result, err := adapter.Run(context.Background(), ExecRequest{Code: "package main\n\nimport \"os\"\n\nfunc main() {\n\tprintln(\"hi\")\n\tos.Exit(2)\n}\n"})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if result.FailureReason != "" || result.ExitCode != 2 || strings.TrimSpace(result.Stderr) != "hi" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	result, err = adapter.Run(context.Background(), ExecRequest{Code: "package main\n\nfunc main() { undefined() }\n"})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if result.FailureReason != FailureCompileError || result.ExitCode == 0 {
+		t.Fatalf("expected compile error, got %+v", result)
+	}
+	if !strings.Contains(result.CompileOutput, "undefined") || result.Stderr != "" {
+		t.Fatalf("expected compiler output kept apart from stderr, got compile=%q stderr=%q", result.CompileOutput, result.Stderr)
+	}
+}
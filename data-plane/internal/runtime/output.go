@@ -0,0 +1,70 @@
+package runtime
+
+import (
+	"bytes"
+	"time"
+
+	"shared/sessionagent"
+)
+
+const DefaultMaxOutputBytes = 1 << 20
+
+// FailureOOMKilled marks a run whose process tree hit its memory limit.
+const FailureOOMKilled = "oom_killed"
+
+// FailureDependencyInstall marks a run whose requested packages could not be
+// installed, so its code never started.
+const FailureDependencyInstall = "dependency_install_failed"
+
+// FailureCompileError marks a run whose compile step failed; the compiler's
+// output is in CompileOutput and the program never started.
+const FailureCompileError = "compile_error"
+
+type Result struct {
+	Stdout        string
+	Stderr        string
+	ExitCode      int
+	WallTime      time.Duration
+	Truncated     bool
+	FailureReason string
+	CompileOutput string
+	Usage         sessionagent.ResourceUsage
+}
+
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func newLimitedBuffer(limit int) *limitedBuffer {
+	if limit <= 0 {
+		limit = DefaultMaxOutputBytes
+	}
+	return &limitedBuffer{limit: limit}
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	remaining := b.limit - b.buf.Len()
+	if remaining <= 0 {
+		if len(p) > 0 {
+			b.truncated = true
+		}
+		return len(p), nil
+	}
+	if len(p) > remaining {
+		b.buf.Write(p[:remaining])
+		b.truncated = true
+		return len(p), nil
+	}
+	b.buf.Write(p)
+	return len(p), nil
+}
+
+func (b *limitedBuffer) String() string {
+	return b.buf.String()
+}
+
+func (b *limitedBuffer) Truncated() bool {
+	return b.truncated
+}
@@ -1,13 +1,41 @@
 package runtime
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"shared/sessionagent"
+
+	"data-plane/internal/isolation"
 )
 
 type Adapter interface {
-	Run(code string) error
+	Run(ctx context.Context, req ExecRequest) (Result, error)
+}
+
+type ExecRequest struct {
+	ID              string
+	Code            string
+	Limits          isolation.Limits
+	EgressAllowList []string
+	Env             []string
+}
+
+// ExecIsolation configures how adapters confine the processes they start.
+// DenyEgress runs each process in its own network namespace whose only
+// route out is an allowlist proxy.
+type ExecIsolation struct {
+	Limiter    *isolation.CgroupLimiter
+	DenyEgress bool
 }
 
 type Registry struct {
@@ -19,10 +47,11 @@ func NewRegistry() Registry {
 }
 
 func DefaultRegistry() Registry {
-	registry := NewRegistry()
-	registry.Register("python", ExecAdapter{Command: "python3"})
-	registry.Register("node", ExecAdapter{Command: "node"})
-	return registry
+	return DefaultRegistryWithIsolation(ExecIsolation{})
+}
+
+func DefaultRegistryWithIsolation(iso ExecIsolation) Registry {
+	return NewRegistryFromLanguages(DefaultLanguages, iso)
 }
 
 func (r Registry) Register(language string, adapter Adapter) {
@@ -42,29 +71,187 @@ func (r Registry) Supports(language string) bool {
 	return ok
 }
 
+const processWaitDelay = 2 * time.Second
+
 type ExecAdapter struct {
-	Command string
-	Args    []string
+	Command        string
+	Args           []string
+	Compile        []string
+	Extension      string
+	MaxOutputBytes int
+	Limiter        *isolation.CgroupLimiter
+	DenyEgress     bool
 }
 
-func (a ExecAdapter) Run(code string) error {
+func (a ExecAdapter) Run(ctx context.Context, req ExecRequest) (Result, error) {
 	if a.Command == "" {
-		return errors.New("missing command")
+		return Result{}, errors.New("missing command")
+	}
+	dir, err := os.MkdirTemp("", "run-*")
+	if err != nil {
+		return Result{}, err
+	}
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "main"+a.Extension)
+	if err := os.WriteFile(src, []byte(req.Code), 0o600); err != nil {
+		return Result{}, err
+	}
+	expand := strings.NewReplacer("{src}", src, "{bin}", filepath.Join(dir, "main"), "{dir}", dir)
+	var group *isolation.Cgroup
+	if a.Limiter != nil && !req.Limits.IsZero() {
+		group, err = a.Limiter.Create(cgroupName("run", req.ID), req.Limits)
+		if err != nil {
+			return Result{}, fmt.Errorf("create cgroup: %w", err)
+		}
+		defer group.Close()
+	}
+	var sandbox *isolation.EgressSandbox
+	if a.DenyEgress {
+		sandbox, err = isolation.NewEgressSandbox(isolation.EgressPolicy{AllowList: req.EgressAllowList}, req.ID, "")
+		if err != nil {
+			return Result{}, fmt.Errorf("isolate network: %w", err)
+		}
+		defer sandbox.Close()
+	}
+	start := time.Now()
+	var usage sessionagent.ResourceUsage
+	if len(a.Compile) > 0 {
+		output := newLimitedBuffer(a.MaxOutputBytes)
+		state, err := a.exec(ctx, expandArgs(expand, a.Compile), req.Env, sandbox, group, output, output)
+		if err != nil {
+			return Result{}, fmt.Errorf("compile: %w", err)
+		}
+		usage = processUsage(state)
+		if state.ExitCode() != 0 {
+			result := Result{
+				ExitCode:      state.ExitCode(),
+				WallTime:      time.Since(start),
+				Truncated:     output.Truncated(),
+				FailureReason: FailureCompileError,
+				CompileOutput: output.String(),
+				Usage:         usage,
+			}
+			applyCgroupUsage(&result, group)
+			return result, nil
+		}
+	}
+	argv := append([]string{a.Command}, a.Args...)
+	if !hasPlaceholder(argv) {
+		argv = append(argv, src)
+	}
+	stdout := newLimitedBuffer(a.MaxOutputBytes)
+	stderr := newLimitedBuffer(a.MaxOutputBytes)
+	state, err := a.exec(ctx, expandArgs(expand, argv), req.Env, sandbox, group, stdout, stderr)
+	result := Result{
+		Stdout:    stdout.String(),
+		Stderr:    stderr.String(),
+		WallTime:  time.Since(start),
+		Truncated: stdout.Truncated() || stderr.Truncated(),
 	}
-	file, err := os.CreateTemp("", "run-*")
 	if err != nil {
-		return err
+		return result, err
+	}
+	result.ExitCode = state.ExitCode()
+	result.Usage = usage.Add(processUsage(state))
+	applyCgroupUsage(&result, group)
+	return result, nil
+}
+
+// exec runs argv to completion in its own process group, inside the cgroup
+// and network sandbox when given. A non-zero exit is reported through the
+// returned state, not as an error.
+func (a ExecAdapter) exec(ctx context.Context, argv []string, env []string, sandbox *isolation.EgressSandbox, group *isolation.Cgroup, stdout io.Writer, stderr io.Writer) (*os.ProcessState, error) {
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	// Run in a new process group so cancellation also kills anything the
+	// script spawned, not just the interpreter.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = processWaitDelay
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	start := cmd.Start
+	if sandbox != nil {
+		cmd.Env = append(append(os.Environ(), env...), sandbox.Env()...)
+		start = func() error { return sandbox.Start(cmd) }
 	}
-	defer os.Remove(file.Name())
-	if _, err := file.WriteString(code); err != nil {
-		_ = file.Close()
-		return err
+	if err := startInCgroup(group, cmd, start); err != nil {
+		return nil, err
 	}
-	if err := file.Close(); err != nil {
-		return err
+	if err := cmd.Wait(); err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return cmd.ProcessState, err
+		}
+	}
+	return cmd.ProcessState, nil
+}
+
+// startInCgroup starts cmd through start, inside group when there is one.
+func startInCgroup(group *isolation.Cgroup, cmd *exec.Cmd, start func() error) error {
+	if group == nil {
+		return start()
+	}
+	return group.Start(cmd, start)
+}
+
+func expandArgs(expand *strings.Replacer, args []string) []string {
+	out := make([]string, len(args))
+	for i, arg := range args {
+		out[i] = expand.Replace(arg)
+	}
+	return out
+}
+
+func hasPlaceholder(args []string) bool {
+	for _, arg := range args {
+		if strings.Contains(arg, "{src}") || strings.Contains(arg, "{bin}") {
+			return true
+		}
+	}
+	return false
+}
+
+func applyCgroupUsage(result *Result, group *isolation.Cgroup) {
+	if group == nil {
+		return
+	}
+	if oom, _ := group.OOMKilled(); oom {
+		result.FailureReason = FailureOOMKilled
+	}
+	// The cgroup also counts descendants the interpreter never reaped.
+	if stats, err := group.Stats(); err == nil {
+		result.Usage.CPUTimeMs = stats.CPUUsage.Milliseconds()
+		result.Usage.IOBytes = stats.IOBytes
+		if stats.MemoryPeak > 0 {
+			result.Usage.MaxRSSBytes = stats.MemoryPeak
+		}
+	}
+}
+
+func processUsage(state *os.ProcessState) sessionagent.ResourceUsage {
+	if state == nil {
+		return sessionagent.ResourceUsage{}
+	}
+	rusage, ok := state.SysUsage().(*syscall.Rusage)
+	if !ok {
+		return sessionagent.ResourceUsage{}
+	}
+	return sessionagent.ResourceUsage{
+		CPUTimeMs: (state.UserTime() + state.SystemTime()).Milliseconds(),
+		// ru_maxrss is in kilobytes and block counts are 512-byte units.
+		MaxRSSBytes: rusage.Maxrss * 1024,
+		IOBytes:     (rusage.Inblock + rusage.Oublock) * 512,
+	}
+}
+
+func cgroupName(kind string, id string) string {
+	if id == "" {
+		id = strconv.FormatInt(time.Now().UnixNano(), 10)
 	}
-	args := append([]string{}, a.Args...)
-	args = append(args, file.Name())
-	cmd := exec.Command(a.Command, args...)
-	return cmd.Run()
+	return kind + "-" + strings.NewReplacer("/", "_", `\`, "_").Replace(id)
 }
@@ -1,12 +1,17 @@
 package runtime
 
-import "testing"
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
 
 type noopAdapter struct{}
 
-func (noopAdapter) Run(code string) error {
-	_ = code
-	return nil
+func (noopAdapter) Run(ctx context.Context, req ExecRequest) (Result, error) {
+	_ = req
+	return Result{}, nil
 }
 
 func TestRegistryLookup(t *testing.T) {
@@ -19,3 +24,60 @@ func TestRegistryLookup(t *testing.T) {
 		t.Fatalf("expected adapter")
 	}
 }
+
+func TestExecAdapterCapturesOutput(t *testing.T) {
+	adapter := ExecAdapter{Command: "sh"}
+	result, err := adapter.Run(context.Background(), ExecRequest{Code: "echo out; echo err 1>&2; exit 3"})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if strings.TrimSpace(result.Stdout) != "out" || strings.TrimSpace(result.Stderr) != "err" {
+		t.Fatalf("unexpected output stdout=%q stderr=%q", result.Stdout, result.Stderr)
+	}
+	if result.ExitCode != 3 {
+		t.Fatalf("expected exit code 3, got %d", result.ExitCode)
+	}
+}
+
+func TestExecAdapterTruncatesOutput(t *testing.T) {
+	adapter := ExecAdapter{Command: "sh", MaxOutputBytes: 4}
+	result, err := adapter.Run(context.Background(), ExecRequest{Code: "echo 0123456789"})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if result.Stdout != "0123" || !result.Truncated {
+		t.Fatalf("expected truncated output, got %q truncated=%v", result.Stdout, result.Truncated)
+	}
+}
+
+func TestExecAdapterKillsProcessGroupOnTimeout(t *testing.T) {
+	adapter := ExecAdapter{Command: "sh"}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	result, err := adapter.Run(ctx, ExecRequest{Code: "sleep 30 & sleep 30; echo done"})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("expected run to stop on timeout, took %s", elapsed)
+	}
+	if result.ExitCode == 0 || strings.Contains(result.Stdout, "done") {
+		t.Fatalf("expected killed process, got exit=%d stdout=%q", result.ExitCode, result.Stdout)
+	}
+}
+
+func TestExecAdapterReportsResourceUsage(t *testing.T) {
+	adapter := ExecAdapter{Command: "python3"}
+	code := "import time\nend = time.process_time() + 0.2\nwhile time.process_time() < end:\n    pass\ndata = bytearray(32 * 1024 * 1024)"
+	result, err := adapter.Run(context.Background(), ExecRequest{Code: code})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if result.Usage.CPUTimeMs < 100 {
+		t.Fatalf("expected cpu time to be reported, got %+v", result.Usage)
+	}
+	if result.Usage.MaxRSSBytes < 32*1024*1024 {
+		t.Fatalf("expected peak rss to cover the allocation, got %+v", result.Usage)
+	}
+}
@@ -27,7 +27,13 @@ func RouterWithDependencies(deps Dependencies) http.Handler {
 	sessionHandler := deps.SessionHandler
 	r.Post("/sessions", sessionHandler.ServeHTTP)
 	r.Post("/sessions/{sessionId}/steps", sessionHandler.ServeHTTP)
+	r.Post("/sessions/{sessionId}/steps/stream", sessionHandler.ServeHTTP)
 	r.Post("/sessions/{sessionId}/terminate", sessionHandler.ServeHTTP)
+	r.Post("/sessions/{sessionId}/interrupt", sessionHandler.ServeHTTP)
+	r.Put("/sessions/{sessionId}/files", sessionHandler.ServeHTTP)
+	r.Get("/sessions/{sessionId}/files", sessionHandler.ServeHTTP)
+	r.Post("/sessions/{sessionId}/snapshot", sessionHandler.ServeHTTP)
+	r.Get("/snapshots/{snapshotId}", sessionHandler.ServeHTTP)
 
 	r.Get("/healthz", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -0,0 +1,236 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+var (
+	ErrRunQueueFull = errors.New("run queue full")
+	ErrRunNotActive = errors.New("run not active")
+)
+
+type RunQueue struct {
+	Runner   Runner
+	Store    RunStore
+	Workers  int
+	Notifier RunNotifier
+	pending  chan string
+	once     sync.Once
+	mu       sync.Mutex
+	active   map[string]context.CancelFunc
+}
+
+func NewRunQueue(runner Runner, store RunStore, workers int, capacity int) *RunQueue {
+	if workers <= 0 {
+		workers = 1
+	}
+	if capacity <= 0 {
+		capacity = 100
+	}
+	return &RunQueue{
+		Runner:  runner,
+		Store:   store,
+		Workers: workers,
+		pending: make(chan string, capacity),
+		active:  map[string]context.CancelFunc{},
+	}
+}
+
+func (q *RunQueue) Start(ctx context.Context) error {
+	if q.Runner == nil || q.Store == nil {
+		return errors.New("run queue requires runner and store")
+	}
+	runs, err := q.Store.List(ctx)
+	if err != nil {
+		return err
+	}
+	var recovered []string
+	for _, run := range runs {
+		switch run.Status {
+		case RunStatusQueued:
+			recovered = append(recovered, run.ID)
+		case RunStatusRunning:
+			run.Status = RunStatusFailed
+			run.Stderr = "run interrupted by data-plane restart"
+			if err := q.Store.Update(ctx, run); err != nil {
+				return err
+			}
+			q.notify(ctx, run)
+		}
+	}
+	q.once.Do(func() {
+		for i := 0; i < q.Workers; i++ {
+			go q.work(ctx)
+		}
+	})
+	go func() {
+		for _, id := range recovered {
+			select {
+			case q.pending <- id:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	log.Printf("runs: queue started workers=%d recovered=%d", q.Workers, len(recovered))
+	return nil
+}
+
+func (q *RunQueue) Enqueue(ctx context.Context, run Run) error {
+	run.Status = RunStatusQueued
+	if err := q.Store.Create(ctx, run); err != nil {
+		return err
+	}
+	select {
+	case q.pending <- run.ID:
+		return nil
+	default:
+		run.Status = RunStatusFailed
+		run.Stderr = ErrRunQueueFull.Error()
+		if err := q.Store.Update(ctx, run); err != nil {
+			log.Printf("runs: update error run_id=%s: %v", run.ID, err)
+		} else {
+			q.notify(ctx, run)
+		}
+		return ErrRunQueueFull
+	}
+}
+
+func (q *RunQueue) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case id := <-q.pending:
+			q.execute(ctx, id)
+		}
+	}
+}
+
+func (q *RunQueue) execute(ctx context.Context, id string) {
+	run, err := q.Store.Get(ctx, id)
+	if err != nil {
+		log.Printf("runs: dequeue error run_id=%s: %v", id, err)
+		return
+	}
+	// A run terminated while queued has already been reported by Terminate.
+	if err := q.Store.Transition(ctx, id, RunStatusQueued, RunStatusRunning); err != nil {
+		if !errors.Is(err, ErrRunStatusChanged) {
+			log.Printf("runs: update error run_id=%s: %v", id, err)
+		}
+		return
+	}
+	run.Status = RunStatusRunning
+	runCtx, cancel := context.WithCancel(ctx)
+	if run.TimeoutSeconds > 0 {
+		var stop context.CancelFunc
+		runCtx, stop = context.WithTimeout(runCtx, time.Duration(run.TimeoutSeconds)*time.Second)
+		defer stop()
+	}
+	q.track(id, cancel)
+	if current, err := q.Store.Get(ctx, id); err == nil && current.Status == RunStatusTerminated {
+		cancel()
+	}
+	result, err := q.Runner.Run(runCtx, run)
+	q.untrack(id)
+	cancel()
+	if err != nil {
+		run.Status = RunStatusFailed
+		run.Stderr = err.Error()
+		if errors.Is(err, ErrDependencyInstallFailed) {
+			run.FailureReason = FailureDependencyInstall
+		}
+	} else {
+		result.ID = run.ID
+		result.TenantID = run.TenantID
+		result.Language = run.Language
+		result.Code = run.Code
+		result.TimeoutSeconds = run.TimeoutSeconds
+		result.DepsAllowlist = run.DepsAllowlist
+		result.RuntimeClass = run.RuntimeClass
+		run = result
+	}
+	// Claiming the final status first keeps a concurrent Terminate from
+	// being overwritten by the result.
+	if err := q.Store.Transition(ctx, id, RunStatusRunning, run.Status); err != nil {
+		if !errors.Is(err, ErrRunStatusChanged) {
+			log.Printf("runs: update error run_id=%s: %v", id, err)
+			return
+		}
+		run.Status = RunStatusTerminated
+	}
+	if err := q.Store.Update(ctx, run); err != nil {
+		log.Printf("runs: update error run_id=%s: %v", id, err)
+		return
+	}
+	log.Printf("runs: completed job_id=%s run_id=%s status=%s exit_status=%d", run.JobID, run.ID, run.Status, run.ExitStatus)
+	q.notify(ctx, run)
+}
+
+// Terminate stops a queued or running run. Queued runs are skipped when a
+// worker picks them up; running runs have their process group killed.
+func (q *RunQueue) Terminate(ctx context.Context, id string) error {
+	run, err := q.Store.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if RunStatusTerminal(run.Status) {
+		return ErrRunNotActive
+	}
+	// Only the caller that moves a queued run to terminated reports it; a
+	// worker that claimed it first runs it and reports the result.
+	err = q.Store.Transition(ctx, id, RunStatusQueued, RunStatusTerminated)
+	if err == nil {
+		run.Status = RunStatusTerminated
+		q.notify(ctx, run)
+		return nil
+	}
+	if !errors.Is(err, ErrRunStatusChanged) {
+		return err
+	}
+	if err := q.Store.Transition(ctx, id, RunStatusRunning, RunStatusTerminated); err != nil {
+		if errors.Is(err, ErrRunStatusChanged) {
+			return ErrRunNotActive
+		}
+		return err
+	}
+	q.mu.Lock()
+	cancel, ok := q.active[id]
+	q.mu.Unlock()
+	if ok {
+		cancel()
+	}
+	return nil
+}
+
+func (q *RunQueue) track(id string, cancel context.CancelFunc) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.active == nil {
+		q.active = map[string]context.CancelFunc{}
+	}
+	q.active[id] = cancel
+}
+
+func (q *RunQueue) untrack(id string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.active, id)
+}
+
+func (q *RunQueue) notify(ctx context.Context, run Run) {
+	if q.Notifier == nil {
+		return
+	}
+	if err := q.Notifier.Notify(ctx, run); err != nil {
+		log.Printf("runs: callback error job_id=%s run_id=%s: %v", run.JobID, run.ID, err)
+	}
+}
+
+func RunIDForJob(jobID string) string {
+	return jobID + "-run"
+}
@@ -0,0 +1,244 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type stubRunner struct {
+	started chan string
+}
+
+func (s stubRunner) Run(ctx context.Context, run Run) (Run, error) {
+	_ = ctx
+	if s.started != nil {
+		s.started <- run.Code
+	}
+	return Run{ID: RunIDForJob(run.JobID), JobID: run.JobID, Status: RunStatusFinished, Stdout: run.Code}, nil
+}
+
+func waitForStatus(t *testing.T, store RunStore, id string, status string) Run {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		run, err := store.Get(context.Background(), id)
+		if err == nil && run.Status == status {
+			return run
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("run %s did not reach status %s", id, status)
+	return Run{}
+}
+
+func TestRunQueueExecutesEnqueuedRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := NewInMemoryRunStore()
+	queue := NewRunQueue(stubRunner{}, store, 2, 10)
+	if err := queue.Start(ctx); err != nil {
+		t.Fatalf("start queue: %v", err)
+	}
+	if err := queue.Enqueue(ctx, Run{ID: "job-1-run", JobID: "job-1", TenantID: "tenant-1", Language: "python", Code: "print(1)", DepsAllowlist: []string{"requests"}, RuntimeClass: "gvisor"}); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	run := waitForStatus(t, store, "job-1-run", RunStatusFinished)
+	if run.Stdout != "print(1)" || run.Language != "python" {
+		t.Fatalf("expected result on stored run, got %+v", run)
+	}
+	if run.TenantID != "tenant-1" || len(run.DepsAllowlist) != 1 || run.RuntimeClass != "gvisor" {
+		t.Fatalf("expected the request's tenant, allowlist and runtime class to be kept, got %+v", run)
+	}
+	if err := queue.Enqueue(ctx, Run{ID: "job-1-run", JobID: "job-1", Code: "print(2)"}); !errors.Is(err, ErrRunExists) {
+		t.Fatalf("expected duplicate run to be rejected, got %v", err)
+	}
+	if run, _ := store.Get(ctx, "job-1-run"); run.Status != RunStatusFinished || run.Stdout != "print(1)" {
+		t.Fatalf("expected duplicate to leave the stored run alone, got %+v", run)
+	}
+}
+
+func TestRunQueueRecoversQueuedRuns(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	path := filepath.Join(t.TempDir(), "runs.json")
+	store, err := NewFileRunStore(path)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	if err := store.Create(ctx, Run{ID: "job-1-run", JobID: "job-1", Status: RunStatusQueued, Code: "a"}); err != nil {
+		t.Fatalf("create queued run: %v", err)
+	}
+	if err := store.Create(ctx, Run{ID: "job-2-run", JobID: "job-2", Status: RunStatusRunning, Code: "b"}); err != nil {
+		t.Fatalf("create running run: %v", err)
+	}
+
+	reopened, err := NewFileRunStore(path)
+	if err != nil {
+		t.Fatalf("reopen store: %v", err)
+	}
+	queue := NewRunQueue(stubRunner{}, reopened, 1, 10)
+	if err := queue.Start(ctx); err != nil {
+		t.Fatalf("start queue: %v", err)
+	}
+	waitForStatus(t, reopened, "job-1-run", RunStatusFinished)
+	waitForStatus(t, reopened, "job-2-run", RunStatusFailed)
+}
+
+func TestRunQueueFull(t *testing.T) {
+	store := NewInMemoryRunStore()
+	queue := NewRunQueue(stubRunner{}, store, 1, 1)
+	ctx := context.Background()
+	if err := queue.Enqueue(ctx, Run{ID: "job-1-run", JobID: "job-1"}); err != nil {
+		t.Fatalf("enqueue first run: %v", err)
+	}
+	notifier := recordingNotifier{runs: make(chan Run, 1)}
+	queue.Notifier = notifier
+	if err := queue.Enqueue(ctx, Run{ID: "job-2-run", JobID: "job-2"}); err != ErrRunQueueFull {
+		t.Fatalf("expected queue full error, got %v", err)
+	}
+	select {
+	case run := <-notifier.runs:
+		if run.JobID != "job-2" || run.Status != RunStatusFailed {
+			t.Fatalf("unexpected notification %+v", run)
+		}
+	default:
+		t.Fatalf("expected the refused run to be reported")
+	}
+}
+
+type recordingNotifier struct {
+	runs chan Run
+}
+
+func (n recordingNotifier) Notify(ctx context.Context, run Run) error {
+	_ = ctx
+	n.runs <- run
+	return nil
+}
+
+func TestRunQueueNotifiesOnCompletion(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := NewInMemoryRunStore()
+	notifier := recordingNotifier{runs: make(chan Run, 1)}
+	queue := NewRunQueue(stubRunner{}, store, 1, 10)
+	queue.Notifier = notifier
+	if err := queue.Start(ctx); err != nil {
+		t.Fatalf("start queue: %v", err)
+	}
+	if err := queue.Enqueue(ctx, Run{ID: "job-1-run", JobID: "job-1", Code: "x"}); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	select {
+	case run := <-notifier.runs:
+		if run.JobID != "job-1" || run.Status != RunStatusFinished {
+			t.Fatalf("unexpected notification %+v", run)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("expected completion notification")
+	}
+}
+
+type blockingRunner struct {
+	started chan struct{}
+}
+
+func (b blockingRunner) Run(ctx context.Context, run Run) (Run, error) {
+	b.started <- struct{}{}
+	<-ctx.Done()
+	status := RunStatusTerminated
+	if ctx.Err() == context.DeadlineExceeded {
+		status = RunStatusTimedOut
+	}
+	return Run{ID: RunIDForJob(run.JobID), JobID: run.JobID, Status: status, ExitStatus: -1}, nil
+}
+
+func TestRunQueueTerminatesRunningRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := NewInMemoryRunStore()
+	runner := blockingRunner{started: make(chan struct{}, 1)}
+	queue := NewRunQueue(runner, store, 1, 10)
+	if err := queue.Start(ctx); err != nil {
+		t.Fatalf("start queue: %v", err)
+	}
+	if err := queue.Enqueue(ctx, Run{ID: "job-1-run", JobID: "job-1"}); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	<-runner.started
+	if err := queue.Terminate(ctx, "job-1-run"); err != nil {
+		t.Fatalf("terminate: %v", err)
+	}
+	waitForStatus(t, store, "job-1-run", RunStatusTerminated)
+	if err := queue.Terminate(ctx, "job-1-run"); err != ErrRunNotActive {
+		t.Fatalf("expected ErrRunNotActive for completed run, got %v", err)
+	}
+}
+
+func TestRunQueueTimesOutRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := NewInMemoryRunStore()
+	runner := blockingRunner{started: make(chan struct{}, 1)}
+	queue := NewRunQueue(runner, store, 1, 10)
+	if err := queue.Start(ctx); err != nil {
+		t.Fatalf("start queue: %v", err)
+	}
+	if err := queue.Enqueue(ctx, Run{ID: "job-1-run", JobID: "job-1", TimeoutSeconds: 1}); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	waitForStatus(t, store, "job-1-run", RunStatusTimedOut)
+}
+
+func TestRunQueueTerminatedQueuedRunNeverStarts(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := NewInMemoryRunStore()
+	runner := blockingRunner{started: make(chan struct{}, 2)}
+	notifier := recordingNotifier{runs: make(chan Run, 4)}
+	queue := NewRunQueue(runner, store, 1, 10)
+	queue.Notifier = notifier
+	if err := queue.Start(ctx); err != nil {
+		t.Fatalf("start queue: %v", err)
+	}
+	if err := queue.Enqueue(ctx, Run{ID: "job-1-run", JobID: "job-1"}); err != nil {
+		t.Fatalf("enqueue first run: %v", err)
+	}
+	<-runner.started
+	if err := queue.Enqueue(ctx, Run{ID: "job-2-run", JobID: "job-2"}); err != nil {
+		t.Fatalf("enqueue second run: %v", err)
+	}
+	if err := queue.Terminate(ctx, "job-2-run"); err != nil {
+		t.Fatalf("terminate queued run: %v", err)
+	}
+	if err := queue.Terminate(ctx, "job-1-run"); err != nil {
+		t.Fatalf("terminate running run: %v", err)
+	}
+	waitForStatus(t, store, "job-1-run", RunStatusTerminated)
+
+	reported := map[string]int{}
+	for len(reported) < 2 {
+		select {
+		case run := <-notifier.runs:
+			if run.Status != RunStatusTerminated {
+				t.Fatalf("unexpected notification %+v", run)
+			}
+			reported[run.JobID]++
+		case <-time.After(2 * time.Second):
+			t.Fatalf("expected both runs to be reported, got %v", reported)
+		}
+	}
+	select {
+	case <-runner.started:
+		t.Fatalf("expected the terminated queued run not to start")
+	case run := <-notifier.runs:
+		t.Fatalf("expected one report per run, got another for %+v", run)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if run, _ := store.Get(ctx, "job-2-run"); run.Status != RunStatusTerminated {
+		t.Fatalf("expected the queued run to stay terminated, got %+v", run)
+	}
+}
@@ -0,0 +1,232 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+var (
+	ErrRunNotFound = errors.New("run not found")
+	ErrRunExists   = errors.New("run already exists")
+	// ErrRunStatusChanged is returned by Transition when the run is no
+	// longer in the expected status.
+	ErrRunStatusChanged = errors.New("run status changed")
+)
+
+type InMemoryRunStore struct {
+	mu    sync.RWMutex
+	items map[string]Run
+}
+
+func NewInMemoryRunStore() *InMemoryRunStore {
+	return &InMemoryRunStore{items: map[string]Run{}}
+}
+
+func (s *InMemoryRunStore) Create(ctx context.Context, run Run) error {
+	_ = ctx
+	if run.ID == "" {
+		return errors.New("missing run id")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.items[run.ID]; ok {
+		return ErrRunExists
+	}
+	s.items[run.ID] = run
+	return nil
+}
+
+func (s *InMemoryRunStore) Get(ctx context.Context, id string) (Run, error) {
+	_ = ctx
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	run, ok := s.items[id]
+	if !ok {
+		return Run{}, ErrRunNotFound
+	}
+	return run, nil
+}
+
+func (s *InMemoryRunStore) Update(ctx context.Context, run Run) error {
+	_ = ctx
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.items[run.ID]; !ok {
+		return ErrRunNotFound
+	}
+	s.items[run.ID] = run
+	return nil
+}
+
+func (s *InMemoryRunStore) UpdateStatus(ctx context.Context, id string, status string) error {
+	_ = ctx
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	run, ok := s.items[id]
+	if !ok {
+		return ErrRunNotFound
+	}
+	run.Status = status
+	s.items[id] = run
+	return nil
+}
+
+func (s *InMemoryRunStore) Transition(ctx context.Context, id string, from string, to string) error {
+	_ = ctx
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	run, ok := s.items[id]
+	if !ok {
+		return ErrRunNotFound
+	}
+	if run.Status != from {
+		return ErrRunStatusChanged
+	}
+	run.Status = to
+	s.items[id] = run
+	return nil
+}
+
+func (s *InMemoryRunStore) List(ctx context.Context) ([]Run, error) {
+	_ = ctx
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return sortedRuns(s.items), nil
+}
+
+type FileRunStore struct {
+	mu    sync.RWMutex
+	path  string
+	items map[string]Run
+}
+
+func NewFileRunStore(path string) (*FileRunStore, error) {
+	if path == "" {
+		return nil, errors.New("missing run store path")
+	}
+	store := &FileRunStore{
+		path:  path,
+		items: map[string]Run{},
+	}
+	if err := store.load(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+func (s *FileRunStore) Create(ctx context.Context, run Run) error {
+	_ = ctx
+	if run.ID == "" {
+		return errors.New("missing run id")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.items[run.ID]; ok {
+		return ErrRunExists
+	}
+	s.items[run.ID] = run
+	return s.persistLocked()
+}
+
+func (s *FileRunStore) Get(ctx context.Context, id string) (Run, error) {
+	_ = ctx
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	run, ok := s.items[id]
+	if !ok {
+		return Run{}, ErrRunNotFound
+	}
+	return run, nil
+}
+
+func (s *FileRunStore) Update(ctx context.Context, run Run) error {
+	_ = ctx
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.items[run.ID]; !ok {
+		return ErrRunNotFound
+	}
+	s.items[run.ID] = run
+	return s.persistLocked()
+}
+
+func (s *FileRunStore) UpdateStatus(ctx context.Context, id string, status string) error {
+	_ = ctx
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	run, ok := s.items[id]
+	if !ok {
+		return ErrRunNotFound
+	}
+	run.Status = status
+	s.items[id] = run
+	return s.persistLocked()
+}
+
+func (s *FileRunStore) Transition(ctx context.Context, id string, from string, to string) error {
+	_ = ctx
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	run, ok := s.items[id]
+	if !ok {
+		return ErrRunNotFound
+	}
+	if run.Status != from {
+		return ErrRunStatusChanged
+	}
+	run.Status = to
+	s.items[id] = run
+	return s.persistLocked()
+}
+
+func (s *FileRunStore) List(ctx context.Context) ([]Run, error) {
+	_ = ctx
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return sortedRuns(s.items), nil
+}
+
+func (s *FileRunStore) load() error {
+	content, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if len(content) == 0 {
+		return nil
+	}
+	return json.Unmarshal(content, &s.items)
+}
+
+func (s *FileRunStore) persistLocked() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	temp := s.path + ".tmp"
+	payload, err := json.Marshal(s.items)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(temp, payload, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(temp, s.path)
+}
+
+func sortedRuns(items map[string]Run) []Run {
+	runs := make([]Run, 0, len(items))
+	for _, run := range items {
+		runs = append(runs, run)
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].ID < runs[j].ID
+	})
+	return runs
+}
@@ -3,5 +3,5 @@ package runtime
 import "context"
 
 type Runner interface {
-	Run(ctx context.Context, jobID string, language string, code string) (string, error)
+	Run(ctx context.Context, run Run) (Run, error)
 }
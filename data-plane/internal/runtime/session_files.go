@@ -0,0 +1,176 @@
+package runtime
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"shared/sessionagent"
+)
+
+// handleUpload stores the request body in the session workspace at the
+// "path" query parameter. Local workspaces are written directly; other
+// sessions are proxied to their agent.
+func (h SessionHandler) handleUpload(w http.ResponseWriter, r *http.Request) {
+	sessionID, name, maxBytes, route, ok := h.fileRequest(w, r)
+	if !ok {
+		return
+	}
+	if maxBytes > 0 && r.ContentLength > maxBytes {
+		writeFileError(w, sessionagent.ErrFileTooLarge)
+		return
+	}
+	var info sessionagent.FileInfo
+	dir, err := h.workspaceDir(route)
+	switch {
+	case err == nil:
+		info, err = writeLocalFile(dir, name, r.Body, maxBytes)
+	case errors.Is(err, errNoLocalWorkspace):
+		info, err = h.Agent.UploadFile(r.Context(), AgentRoute{
+			Endpoint: route.Endpoint,
+			Token:    route.Token,
+			AuthMode: route.AuthMode,
+		}, sessionID, name, r.Body, maxBytes)
+	}
+	if err != nil {
+		log.Printf("sessions: upload session_id=%s path=%q error: %v", sessionID, name, err)
+		writeFileError(w, err)
+		return
+	}
+	log.Printf("sessions: upload session_id=%s path=%q size=%d", sessionID, name, info.Size)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(info)
+}
+
+// handleDownload streams a workspace file with its SHA-256 checksum in the
+// X-Checksum-Sha256 header.
+func (h SessionHandler) handleDownload(w http.ResponseWriter, r *http.Request) {
+	sessionID, name, maxBytes, route, ok := h.fileRequest(w, r)
+	if !ok {
+		return
+	}
+	var body io.ReadCloser
+	var info sessionagent.FileInfo
+	dir, err := h.workspaceDir(route)
+	switch {
+	case err == nil:
+		body, info, err = openLocalFile(dir, name)
+	case errors.Is(err, errNoLocalWorkspace):
+		body, info, err = h.Agent.DownloadFile(r.Context(), AgentRoute{
+			Endpoint: route.Endpoint,
+			Token:    route.Token,
+			AuthMode: route.AuthMode,
+		}, sessionID, name, maxBytes)
+	}
+	if err != nil {
+		log.Printf("sessions: download session_id=%s path=%q error: %v", sessionID, name, err)
+		writeFileError(w, err)
+		return
+	}
+	defer body.Close()
+	if maxBytes > 0 && info.Size > maxBytes {
+		writeFileError(w, sessionagent.ErrFileTooLarge)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	if info.Size >= 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+	}
+	w.Header().Set(sessionagent.FileChecksumHeader, info.Checksum)
+	w.WriteHeader(http.StatusOK)
+	_, _ = io.Copy(w, body)
+}
+
+func (h SessionHandler) fileRequest(w http.ResponseWriter, r *http.Request) (string, string, int64, SessionRoute, bool) {
+	if h.Runtime == nil || h.Registry == nil {
+		w.WriteHeader(http.StatusNotImplemented)
+		return "", "", 0, SessionRoute{}, false
+	}
+	sessionID := chi.URLParam(r, "sessionId")
+	name := r.URL.Query().Get("path")
+	if sessionID == "" || name == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return "", "", 0, SessionRoute{}, false
+	}
+	var maxBytes int64
+	if value := r.URL.Query().Get(sessionagent.FileMaxBytesParam); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || parsed < 0 {
+			writeJSONError(w, http.StatusBadRequest, "invalid_max_bytes", "maxBytes must be a non-negative integer")
+			return "", "", 0, SessionRoute{}, false
+		}
+		maxBytes = parsed
+	}
+	route, ok := h.Registry.Get(sessionID)
+	if !ok {
+		writeJSONError(w, http.StatusGone, "session_expired", "session not found")
+		return "", "", 0, SessionRoute{}, false
+	}
+	return sessionID, name, maxBytes, route, true
+}
+
+var (
+	errNoLocalWorkspace = errors.New("session workspace is not local")
+	errFilesUnsupported = errors.New("session runtime does not support file transfer")
+)
+
+// workspaceDir returns the local workspace of a session, or
+// errNoLocalWorkspace when its files must go through the agent.
+func (h SessionHandler) workspaceDir(route SessionRoute) (string, error) {
+	if provider, ok := h.Runtime.(WorkspaceProvider); ok {
+		dir, err := provider.WorkspaceDir(route.RuntimeID)
+		if err == nil || h.Agent == nil || route.Endpoint == "" {
+			return dir, err
+		}
+	}
+	if h.Agent != nil && route.Endpoint != "" {
+		return "", errNoLocalWorkspace
+	}
+	return "", errFilesUnsupported
+}
+
+func writeLocalFile(dir string, name string, body io.Reader, maxBytes int64) (sessionagent.FileInfo, error) {
+	return sessionagent.WriteWorkspaceFile(dir, name, body, sessionagent.WriteOptions{MaxBytes: maxBytes})
+}
+
+func openLocalFile(dir string, name string) (io.ReadCloser, sessionagent.FileInfo, error) {
+	file, _, err := sessionagent.OpenWorkspaceFile(dir, name)
+	if err != nil {
+		return nil, sessionagent.FileInfo{}, err
+	}
+	size, checksum, err := sessionagent.ChecksumFile(file)
+	if err != nil {
+		_ = file.Close()
+		return nil, sessionagent.FileInfo{}, err
+	}
+	return file, sessionagent.FileInfo{Path: name, Size: size, Checksum: checksum}, nil
+}
+
+func writeFileError(w http.ResponseWriter, err error) {
+	var unreachable runtimeUnreachableError
+	switch {
+	case errors.Is(err, sessionagent.ErrInvalidFilePath):
+		writeJSONError(w, http.StatusBadRequest, "invalid_path", err.Error())
+	case errors.Is(err, sessionagent.ErrFileTooLarge):
+		writeJSONError(w, http.StatusRequestEntityTooLarge, "file_too_large", err.Error())
+	case errors.Is(err, fs.ErrNotExist):
+		writeJSONError(w, http.StatusNotFound, "file_not_found", "file not found")
+	case errors.Is(err, sessionagent.ErrWorkspaceUnavailable):
+		writeJSONError(w, http.StatusConflict, "workspace_unavailable", err.Error())
+	case errors.Is(err, errFilesUnsupported):
+		writeJSONError(w, http.StatusNotImplemented, "files_unsupported", err.Error())
+	case errors.As(err, &unreachable):
+		writeJSONError(w, http.StatusServiceUnavailable, "runtime_unreachable", "runtime agent is not reachable")
+	case errors.Is(err, ErrRuntimeNotFound):
+		writeJSONError(w, http.StatusServiceUnavailable, "runtime_crashed", "session runtime is not available")
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
@@ -4,14 +4,19 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
 	"path/filepath"
+	"strings"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"shared/sessionagent"
+
+	"data-plane/internal/isolation"
 )
 
 type KubernetesSessionRuntime struct {
@@ -22,29 +27,77 @@ type KubernetesSessionRuntime struct {
 	Image         string
 	PythonImage   string
 	NodeImage     string
+	Images        map[string]string
 	Env           string
 	AgentAddr     string
 	AgentAuthMode string
+	Pool          *WarmPool
+	Packing       *AgentPacking
+	// RuntimeClasses maps policy runtime classes to RuntimeClass names for
+	// sessions whose policy requires one.
+	RuntimeClasses map[string]string
+	// AgentAdminSecret derives the admin token each pod's agent is started
+	// with; see agentAdminToken.
+	AgentAdminSecret string
 }
 
-func (r KubernetesSessionRuntime) StartSession(ctx context.Context, sessionID string, policyID string, workspaceRef string, runtime string) (SessionRoute, error) {
-	_ = policyID
+const sessionWorkspaceRoot = "/workspace"
+
+func (r KubernetesSessionRuntime) StartSession(ctx context.Context, spec SessionSpec) (SessionRoute, error) {
 	if r.Client == nil {
 		return SessionRoute{}, errors.New("missing kubernetes client")
 	}
-	if sessionID == "" {
+	if spec.ID == "" {
 		return SessionRoute{}, errors.New("missing session id")
 	}
-	if r.Namespace == "" {
-		r.Namespace = "default"
+	if len(spec.Deps) > 0 {
+		// Packages are cached on the data-plane host, which pods cannot see.
+		return SessionRoute{}, ErrDependenciesUnsupported
+	}
+	if err := (Enforcement{Limits: true, Disk: true, RuntimeClasses: r.RuntimeClasses}).Check(spec.Policy); err != nil {
+		return SessionRoute{}, err
+	}
+	// Warm and shared pods run with the default runtime class and limits,
+	// so a session whose policy sets either gets a pod of its own.
+	dedicated := spec.Policy.RuntimeClass != "" || !spec.Policy.Limits.IsZero()
+	workspaceDir := filepath.Join(sessionWorkspaceRoot, spec.ID)
+	if spec.WorkspaceRef != "" {
+		workspaceDir = filepath.Join(sessionWorkspaceRoot, spec.WorkspaceRef)
+	}
+	if r.Packing != nil && !dedicated {
+		return r.startShared(ctx, spec, workspaceDir)
 	}
-	image := imageForRuntime(runtime, r.PythonImage, r.NodeImage, r.Image)
-	podName := fmt.Sprintf("session-%s", sessionID)
-	workspaceRoot := "/workspace"
-	workspaceDir := filepath.Join(workspaceRoot, sessionID)
-	if workspaceRef != "" {
-		workspaceDir = filepath.Join(workspaceRoot, workspaceRef)
+	if r.Pool != nil && !dedicated {
+		if warm, ok := r.Pool.Claim(ctx, spec.Runtime); ok {
+			route, err := r.claimWarm(ctx, warm, spec, workspaceDir)
+			if err == nil {
+				return route, nil
+			}
+			log.Printf("sessions: warm claim session_id=%s pod=%s error: %v", spec.ID, warm.ID, err)
+			r.deletePod(warm.ID)
+		}
+	}
+	podName := fmt.Sprintf("session-%s", spec.ID)
+	endpoint, err := r.startPod(ctx, podName, spec.Runtime, map[string]string{
+		"app":        "sandbox-session",
+		"session_id": spec.ID,
+	}, nil, spec.Policy)
+	if err != nil {
+		return SessionRoute{}, err
+	}
+	route, err := r.registerSession(ctx, podName, endpoint, spec, workspaceDir)
+	if err != nil {
+		r.deletePod(podName)
+		return SessionRoute{}, err
 	}
+	return route, nil
+}
+
+// startPod creates a session pod and waits until its agent is healthy,
+// returning the agent endpoint. The pod is deleted if it never gets there.
+// policy sets the pod's runtime class and resource limits.
+func (r KubernetesSessionRuntime) startPod(ctx context.Context, podName string, runtime string, labels map[string]string, extraEnv []corev1.EnvVar, policy WorkloadPolicy) (string, error) {
+	image := r.WarmImage(runtime)
 	envVars := []corev1.EnvVar{}
 	if r.Env != "" {
 		envVars = append(envVars, corev1.EnvVar{Name: "ENV", Value: r.Env})
@@ -52,24 +105,29 @@ func (r KubernetesSessionRuntime) StartSession(ctx context.Context, sessionID st
 	if r.AgentAddr != "" {
 		envVars = append(envVars, corev1.EnvVar{Name: "SESSION_AGENT_ADDR", Value: r.AgentAddr})
 	}
-	envVars = append(envVars, corev1.EnvVar{Name: "WORKSPACE_ROOT", Value: workspaceRoot})
+	envVars = append(envVars, corev1.EnvVar{Name: "WORKSPACE_ROOT", Value: sessionWorkspaceRoot})
 	if r.AgentAuthMode == "bypass" {
 		envVars = append(envVars, corev1.EnvVar{Name: "SESSION_AGENT_AUTH_BYPASS", Value: "true"})
 	} else {
-		envVars = append(envVars, corev1.EnvVar{Name: "SESSION_AGENT_AUTH_BYPASS", Value: "false"})
+		envVars = append(envVars,
+			corev1.EnvVar{Name: "SESSION_AGENT_AUTH_BYPASS", Value: "false"},
+			corev1.EnvVar{Name: "SESSION_AGENT_ADMIN_TOKEN", Value: agentAdminToken(r.AgentAdminSecret, podName)},
+		)
 	}
+	envVars = append(envVars, extraEnv...)
 	volumeName := "workspace"
+	runtimeClass := r.RuntimeClass
+	if policy.RuntimeClass != "" {
+		runtimeClass = r.RuntimeClasses[policy.RuntimeClass]
+	}
 	pod := &corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      podName,
-			Namespace: r.Namespace,
-			Labels: map[string]string{
-				"app":        "sandbox-session",
-				"session_id": sessionID,
-			},
+			Namespace: r.namespace(),
+			Labels:    labels,
 		},
 		Spec: corev1.PodSpec{
-			RuntimeClassName: runtimeClassName(r.RuntimeClass),
+			RuntimeClassName: runtimeClassName(runtimeClass),
 			Volumes: []corev1.Volume{
 				{
 					Name: volumeName,
@@ -80,13 +138,14 @@ func (r KubernetesSessionRuntime) StartSession(ctx context.Context, sessionID st
 			},
 			Containers: []corev1.Container{
 				{
-					Name:  "session",
-					Image: image,
-					Env:   envVars,
+					Name:      "session",
+					Image:     image,
+					Env:       envVars,
+					Resources: podResources(policy.Limits),
 					VolumeMounts: []corev1.VolumeMount{
 						{
 							Name:      volumeName,
-							MountPath: workspaceRoot,
+							MountPath: sessionWorkspaceRoot,
 						},
 					},
 				},
@@ -94,64 +153,81 @@ func (r KubernetesSessionRuntime) StartSession(ctx context.Context, sessionID st
 			RestartPolicy: corev1.RestartPolicyNever,
 		},
 	}
-	if _, err := r.Client.CoreV1().Pods(r.Namespace).Create(ctx, pod, metav1.CreateOptions{}); err != nil {
-		return SessionRoute{}, err
-	}
-	cleanupPod := func() {
-		namespace := r.Namespace
-		if namespace == "" {
-			namespace = "default"
-		}
-		grace := int64(0)
-		cleanupCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-		_ = r.Client.CoreV1().Pods(namespace).Delete(cleanupCtx, podName, metav1.DeleteOptions{
-			GracePeriodSeconds: &grace,
-		})
+	if _, err := r.Client.CoreV1().Pods(r.namespace()).Create(ctx, pod, metav1.CreateOptions{}); err != nil {
+		return "", err
 	}
 	timeout := durationFromEnv("SESSION_READY_TIMEOUT", 60*time.Second)
 	readyCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 	if err := r.WaitForPodReady(readyCtx, podName); err != nil {
-		cleanupPod()
-		return SessionRoute{}, err
+		r.deletePod(podName)
+		return "", err
 	}
 	endpoint := r.buildAgentEndpoint(ctx, podName)
-	client := NewAgentClient()
-	if err := client.WaitForHealth(readyCtx, endpoint, 500*time.Millisecond); err != nil {
-		cleanupPod()
-		return SessionRoute{}, err
+	if err := r.agentClient(podName).WaitForReady(readyCtx, endpoint, 500*time.Millisecond); err != nil {
+		r.deletePod(podName)
+		return "", err
 	}
+	return endpoint, nil
+}
+
+func (r KubernetesSessionRuntime) registerSession(ctx context.Context, podName string, endpoint string, spec SessionSpec, workspaceDir string) (SessionRoute, error) {
 	token := ""
 	if r.AgentAuthMode != "bypass" {
 		token = generateSessionToken()
 	}
-	if err := client.RegisterSession(ctx, AgentRoute{
+	if err := r.agentClient(podName).RegisterSession(ctx, AgentRoute{
 		Endpoint: endpoint,
 		Token:    token,
 		AuthMode: r.AgentAuthMode,
 	}, sessionagent.SessionRegisterRequest{
-		SessionID:    sessionID,
-		Runtime:      runtime,
+		SessionID:    spec.ID,
+		Runtime:      spec.Runtime,
 		Token:        token,
 		WorkspaceDir: workspaceDir,
 	}); err != nil {
-		cleanupPod()
 		return SessionRoute{}, err
 	}
 	return SessionRoute{
 		RuntimeID: podName,
-		Runtime:   runtime,
+		Runtime:   spec.Runtime,
 		Endpoint:  endpoint,
 		Token:     token,
 		AuthMode:  r.AgentAuthMode,
 	}, nil
 }
 
-func (r KubernetesSessionRuntime) RunStep(ctx context.Context, runtimeID string, command string) (StepOutput, error) {
+// agentClient returns a client for the agent in podName that carries the
+// pod's admin token.
+func (r KubernetesSessionRuntime) agentClient(podName string) *AgentClient {
+	client := NewAgentClient()
+	if r.AgentAuthMode != "bypass" {
+		client.AdminToken = agentAdminToken(r.AgentAdminSecret, podName)
+	}
+	return client
+}
+
+func (r KubernetesSessionRuntime) deletePod(podName string) {
+	grace := int64(0)
+	cleanupCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_ = r.Client.CoreV1().Pods(r.namespace()).Delete(cleanupCtx, podName, metav1.DeleteOptions{
+		GracePeriodSeconds: &grace,
+	})
+}
+
+func (r KubernetesSessionRuntime) namespace() string {
+	if r.Namespace == "" {
+		return "default"
+	}
+	return r.Namespace
+}
+
+func (r KubernetesSessionRuntime) RunStep(ctx context.Context, runtimeID string, command string, timeout time.Duration) (StepOutput, error) {
 	_ = ctx
 	_ = runtimeID
 	_ = command
+	_ = timeout
 	return StepOutput{}, ErrRuntimeUnavailable
 }
 
@@ -162,6 +238,9 @@ func (r KubernetesSessionRuntime) TerminateSession(ctx context.Context, runtimeI
 	if runtimeID == "" {
 		return errors.New("missing runtime id")
 	}
+	if podName, sessionID, ok := strings.Cut(runtimeID, "/"); ok && r.Packing != nil {
+		return r.terminateShared(ctx, podName, sessionID)
+	}
 	namespace := r.Namespace
 	if namespace == "" {
 		namespace = "default"
@@ -172,6 +251,25 @@ func (r KubernetesSessionRuntime) TerminateSession(ctx context.Context, runtimeI
 	})
 }
 
+// podResources turns limits into container limits. Kubernetes has no
+// per-container PID limit, so PIDs are left to the kubelet's pod limit.
+func podResources(limits isolation.Limits) corev1.ResourceRequirements {
+	if limits.IsZero() {
+		return corev1.ResourceRequirements{}
+	}
+	list := corev1.ResourceList{}
+	if limits.CPU > 0 {
+		list[corev1.ResourceCPU] = *resource.NewMilliQuantity(int64(limits.CPU), resource.DecimalSI)
+	}
+	if limits.Memory > 0 {
+		list[corev1.ResourceMemory] = *resource.NewQuantity(int64(limits.Memory), resource.BinarySI)
+	}
+	if limits.Disk > 0 {
+		list[corev1.ResourceEphemeralStorage] = *resource.NewQuantity(int64(limits.Disk), resource.BinarySI)
+	}
+	return corev1.ResourceRequirements{Limits: list}
+}
+
 func runtimeClassName(value string) *string {
 	if value == "" {
 		return nil
@@ -179,7 +277,7 @@ func runtimeClassName(value string) *string {
 	return &value
 }
 
-func imageForRuntime(runtime string, pythonImage string, nodeImage string, fallback string) string {
+func imageForRuntime(runtime string, pythonImage string, nodeImage string, fallback string, images map[string]string) string {
 	switch runtime {
 	case "python":
 		if pythonImage != "" {
@@ -190,6 +288,9 @@ func imageForRuntime(runtime string, pythonImage string, nodeImage string, fallb
 			return nodeImage
 		}
 	}
+	if image := images[runtime]; image != "" {
+		return image
+	}
 	if fallback != "" {
 		return fallback
 	}
@@ -210,13 +311,20 @@ func (r KubernetesSessionRuntime) buildAgentEndpoint(ctx context.Context, podNam
 	}
 	if r.Client != nil {
 		pod, err := r.Client.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
-		if err == nil && pod.Status.PodIP != "" {
-			return "http://" + pod.Status.PodIP + ":9000"
+		if err == nil {
+			return podAgentEndpoint(pod)
 		}
 	}
 	return fmt.Sprintf("http://%s.%s.pod:9000", podName, namespace)
 }
 
+func podAgentEndpoint(pod *corev1.Pod) string {
+	if pod.Status.PodIP != "" {
+		return "http://" + pod.Status.PodIP + ":9000"
+	}
+	return fmt.Sprintf("http://%s.%s.pod:9000", pod.Name, pod.Namespace)
+}
+
 func (r KubernetesSessionRuntime) WaitForPodReady(ctx context.Context, podName string) error {
 	namespace := r.Namespace
 	if namespace == "" {
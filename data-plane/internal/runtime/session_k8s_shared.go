@@ -0,0 +1,202 @@
+package runtime
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"sync"
+
+	"github.com/google/uuid"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// sharedAgentLabel marks agent pods that host several sessions.
+const sharedAgentLabel = "agent_mode"
+
+// sharedTenantLabel holds the tenant whose sessions a shared pod hosts.
+const sharedTenantLabel = "tenant"
+
+// sharedAgentUIDBase is the first UID shared agents give their sessions.
+const sharedAgentUIDBase = 20000
+
+// AgentPacking hosts up to Capacity sessions on each shared agent pod. A
+// pod's load is the sessions Registry routes to its endpoint plus those
+// placed on it since, so placement survives a data-plane restart with a
+// persistent registry. Sessions on shared pods get a runtime ID of
+// "<pod>/<session>", and a pod is deleted with its last session.
+type AgentPacking struct {
+	Capacity int
+	Registry SessionRegistry
+	// CgroupRoot, when set, gives each session its own cgroup inside the
+	// agent with the limits below.
+	CgroupRoot    string
+	CPUMillicores int
+	MemoryMB      int
+	PIDsMax       int
+
+	mu      sync.Mutex
+	placed  map[string]map[string]bool
+	closing map[string]bool
+}
+
+func NewAgentPacking(capacity int, registry SessionRegistry) *AgentPacking {
+	return &AgentPacking{
+		Capacity: capacity,
+		Registry: registry,
+		placed:   map[string]map[string]bool{},
+		closing:  map[string]bool{},
+	}
+}
+
+// loadLocked counts the sessions on endpoint other than except. Callers
+// hold p.mu.
+func (p *AgentPacking) loadLocked(endpoint string, except string) int {
+	sessions := map[string]bool{}
+	if p.Registry != nil {
+		for _, sessionID := range p.Registry.SessionsAt(endpoint) {
+			sessions[sessionID] = true
+		}
+	}
+	for sessionID := range p.placed[endpoint] {
+		sessions[sessionID] = true
+	}
+	delete(sessions, except)
+	return len(sessions)
+}
+
+func (p *AgentPacking) placeLocked(endpoint string, sessionID string) {
+	if p.placed[endpoint] == nil {
+		p.placed[endpoint] = map[string]bool{}
+	}
+	p.placed[endpoint][sessionID] = true
+}
+
+func (p *AgentPacking) agentEnv() []corev1.EnvVar {
+	env := []corev1.EnvVar{
+		{Name: "SESSION_AGENT_MAX_SESSIONS", Value: strconv.Itoa(p.Capacity)},
+		{Name: "SESSION_AGENT_UID_BASE", Value: strconv.Itoa(sharedAgentUIDBase)},
+	}
+	if p.CgroupRoot != "" {
+		env = append(env,
+			corev1.EnvVar{Name: "SESSION_AGENT_CGROUP_ROOT", Value: p.CgroupRoot},
+			corev1.EnvVar{Name: "SESSION_AGENT_CPU_MILLICORES", Value: strconv.Itoa(p.CPUMillicores)},
+			corev1.EnvVar{Name: "SESSION_AGENT_MEMORY_MB", Value: strconv.Itoa(p.MemoryMB)},
+			corev1.EnvVar{Name: "SESSION_AGENT_PIDS_MAX", Value: strconv.Itoa(p.PIDsMax)},
+		)
+	}
+	return env
+}
+
+// startShared registers the session with a shared agent pod that has
+// room, starting a new one when none does.
+func (r KubernetesSessionRuntime) startShared(ctx context.Context, spec SessionSpec, workspaceDir string) (SessionRoute, error) {
+	podName, endpoint, err := r.placeShared(ctx, spec)
+	if err != nil {
+		return SessionRoute{}, err
+	}
+	route, err := r.registerSession(ctx, podName, endpoint, spec, workspaceDir)
+	if err != nil {
+		_ = r.terminateShared(ctx, podName, spec.ID)
+		return SessionRoute{}, err
+	}
+	route.RuntimeID = podName + "/" + spec.ID
+	return route, nil
+}
+
+func (r KubernetesSessionRuntime) placeShared(ctx context.Context, spec SessionSpec) (string, string, error) {
+	p := r.Packing
+	// Sessions only share a pod with sessions of the same tenant.
+	tenant := tenantLabelValue(spec.TenantID)
+	selector := sharedAgentLabel + "=shared,runtime=" + spec.Runtime + "," + sharedTenantLabel + "=" + tenant
+	pods, err := r.Client.CoreV1().Pods(r.namespace()).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return "", "", err
+	}
+	var candidates []*corev1.Pod
+	p.mu.Lock()
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.DeletionTimestamp != nil || !podReady(pod) {
+			continue
+		}
+		endpoint := podAgentEndpoint(pod)
+		if !p.closing[endpoint] && p.loadLocked(endpoint, "") < p.Capacity {
+			candidates = append(candidates, pod)
+		}
+	}
+	p.mu.Unlock()
+	for _, pod := range candidates {
+		endpoint := podAgentEndpoint(pod)
+		// The agent's own report also covers sessions the registry lost
+		// and agents that stopped answering.
+		status, err := r.agentClient(pod.Name).Status(ctx, endpoint)
+		if err != nil && !errors.Is(err, errAgentStatusUnsupported) || err == nil && !status.HasCapacity() {
+			continue
+		}
+		p.mu.Lock()
+		if !p.closing[endpoint] && p.loadLocked(endpoint, "") < p.Capacity {
+			p.placeLocked(endpoint, spec.ID)
+			p.mu.Unlock()
+			return pod.Name, endpoint, nil
+		}
+		p.mu.Unlock()
+	}
+
+	podName := "session-agent-" + uuid.NewString()
+	endpoint, err := r.startPod(ctx, podName, spec.Runtime, map[string]string{
+		"app":             "sandbox-session",
+		"runtime":         spec.Runtime,
+		sharedAgentLabel:  "shared",
+		sharedTenantLabel: tenant,
+	}, p.agentEnv(), WorkloadPolicy{})
+	if err != nil {
+		return "", "", err
+	}
+	p.mu.Lock()
+	p.placeLocked(endpoint, spec.ID)
+	p.mu.Unlock()
+	return podName, endpoint, nil
+}
+
+// tenantLabelValue returns tenantID as a label value, hashing IDs that are
+// not valid label values.
+func tenantLabelValue(tenantID string) string {
+	if len(validation.IsValidLabelValue(tenantID)) == 0 {
+		return tenantID
+	}
+	sum := sha256.Sum256([]byte(tenantID))
+	return "t-" + hex.EncodeToString(sum[:])[:40]
+}
+
+// terminateShared removes a session from its shared pod and deletes the
+// pod once no session is left on it.
+func (r KubernetesSessionRuntime) terminateShared(ctx context.Context, podName string, sessionID string) error {
+	p := r.Packing
+	endpoint := r.buildAgentEndpoint(ctx, podName)
+	p.mu.Lock()
+	delete(p.placed[endpoint], sessionID)
+	if len(p.placed[endpoint]) == 0 {
+		delete(p.placed, endpoint)
+	}
+	if p.closing[endpoint] || p.loadLocked(endpoint, sessionID) > 0 {
+		p.mu.Unlock()
+		return nil
+	}
+	// No session can be placed on the pod while it is being deleted, so
+	// the API call can run without holding the lock.
+	p.closing[endpoint] = true
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		delete(p.closing, endpoint)
+		p.mu.Unlock()
+	}()
+	grace := int64(5)
+	return r.Client.CoreV1().Pods(r.namespace()).Delete(ctx, podName, metav1.DeleteOptions{
+		GracePeriodSeconds: &grace,
+	})
+}
@@ -0,0 +1,87 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// warmPoolLabel marks pods started for the warm pool until a session
+// claims them.
+const warmPoolLabel = "warm_pool"
+
+func (r KubernetesSessionRuntime) ProvisionWarm(ctx context.Context, runtime string) (WarmRuntime, error) {
+	if r.Client == nil {
+		return WarmRuntime{}, errors.New("missing kubernetes client")
+	}
+	podName := "session-warm-" + uuid.NewString()
+	endpoint, err := r.startPod(ctx, podName, runtime, map[string]string{
+		"app":         "sandbox-session",
+		"runtime":     runtime,
+		warmPoolLabel: "idle",
+	}, nil, WorkloadPolicy{})
+	if err != nil {
+		return WarmRuntime{}, err
+	}
+	return WarmRuntime{
+		ID:       podName,
+		Runtime:  runtime,
+		Image:    r.WarmImage(runtime),
+		Endpoint: endpoint,
+	}, nil
+}
+
+func (r KubernetesSessionRuntime) DiscardWarm(ctx context.Context, warm WarmRuntime) error {
+	return r.TerminateSession(ctx, warm.ID)
+}
+
+func (r KubernetesSessionRuntime) WarmImage(runtime string) string {
+	return imageForRuntime(runtime, r.PythonImage, r.NodeImage, r.Image, r.Images)
+}
+
+// PruneWarm deletes idle warm pods left behind by an earlier data plane,
+// whose endpoints this one does not know.
+func (r KubernetesSessionRuntime) PruneWarm(ctx context.Context) error {
+	if r.Client == nil {
+		return errors.New("missing kubernetes client")
+	}
+	grace := int64(0)
+	return r.Client.CoreV1().Pods(r.namespace()).DeleteCollection(ctx, metav1.DeleteOptions{
+		GracePeriodSeconds: &grace,
+	}, metav1.ListOptions{
+		LabelSelector: warmPoolLabel + "=idle",
+	})
+}
+
+// claimWarm relabels an idle pod as the session's pod and registers the
+// session with its agent.
+func (r KubernetesSessionRuntime) claimWarm(ctx context.Context, warm WarmRuntime, spec SessionSpec, workspaceDir string) (SessionRoute, error) {
+	// An idle pod may have lost its agent while it waited.
+	status, err := r.agentClient(warm.ID).Status(ctx, warm.Endpoint)
+	if err != nil && !errors.Is(err, errAgentStatusUnsupported) {
+		return SessionRoute{}, err
+	}
+	if len(status.Sessions) > 0 {
+		return SessionRoute{}, fmt.Errorf("warm pod %s already hosts sessions", warm.ID)
+	}
+	patch, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{
+			"labels": map[string]any{
+				"session_id":  spec.ID,
+				warmPoolLabel: nil,
+			},
+		},
+	})
+	if err != nil {
+		return SessionRoute{}, err
+	}
+	if _, err := r.Client.CoreV1().Pods(r.namespace()).Patch(ctx, warm.ID, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return SessionRoute{}, err
+	}
+	return r.registerSession(ctx, warm.ID, warm.Endpoint, spec, workspaceDir)
+}
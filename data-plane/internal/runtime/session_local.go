@@ -8,17 +8,34 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"shared/sessionagent"
+
+	"data-plane/internal/isolation"
+	"data-plane/internal/workspace"
 )
 
 type LocalSessionRuntime struct {
+	// RestartCrashed restarts session interpreters that exit on their own;
+	// the next step then reports that session state was lost.
+	RestartCrashed  bool
+	Installer       *DependencyInstaller
+	Limiter         *isolation.CgroupLimiter
+	Limits          isolation.Limits
+	DenyEgress      bool
+	EgressAllowList []string
+	// Secrets provides the secrets a session's policy grants it.
+	Secrets   *workspace.SecretStore
 	mu        sync.RWMutex
 	processes map[string]*sessionProcess
 }
@@ -27,21 +44,124 @@ type sessionProcess struct {
 	cmd           *exec.Cmd
 	stdin         io.WriteCloser
 	stdout        *bufio.Reader
+	exit          *processExit
 	runtime       string
 	repl          bool
+	dir           string
+	env           []string
 	agentCmd      *exec.Cmd
 	agentEndpoint string
 	agentAuthMode string
+	cgroup        *isolation.Cgroup
+	egress        *isolation.EgressSandbox
+	closed        atomic.Bool
+	stateLost     bool
+	exitReason    string
+	backoff       sessionagent.RestartBackoff
 	mu            sync.Mutex
 }
 
+// stepInterruptGrace is how long a REPL gets to unwind after a timed-out
+// step's SIGINT before the interpreter is restarted.
+var stepInterruptGrace = 2 * time.Second
+
+const stepTimedOutMessage = "step timed out; interpreter restarted and session state was lost"
+
+// processExit is closed over by the supervisor of one interpreter; err is
+// set before done is closed.
+type processExit struct {
+	done chan struct{}
+	err  error
+}
+
 const pythonReplScript = `import contextlib
-import io
 import json
+import signal
 import sys
 import traceback
 
+real_stdout = sys.stdout
 globals_ns = {"__name__": "__main__"}
+running = False
+
+
+def on_interrupt(signum, frame):
+    # Only interrupt user code; a late SIGINT between steps is ignored.
+    if running:
+        raise KeyboardInterrupt
+
+
+signal.signal(signal.SIGINT, on_interrupt)
+
+
+def emit(frame):
+    real_stdout.write(json.dumps(frame) + "\n")
+    real_stdout.flush()
+
+
+class FrameWriter:
+    encoding = "utf-8"
+
+    def __init__(self, stream):
+        self.stream = stream
+
+    def write(self, data):
+        if data:
+            emit({"type": self.stream, "data": data})
+        return len(data)
+
+    def flush(self):
+        pass
+
+    def isatty(self):
+        return False
+
+
+def state_pickler():
+    try:
+        import dill
+        return dill
+    except ImportError:
+        import pickle
+        return pickle
+
+
+def save_state(path):
+    # Each global is pickled on its own so one unpicklable value (an open
+    # file, a module without dill) does not lose the rest.
+    pickler = state_pickler()
+    state = {}
+    for name, value in globals_ns.items():
+        if name.startswith("__"):
+            continue
+        try:
+            state[name] = pickler.dumps(value)
+        except Exception:
+            continue
+    with open(path, "wb") as f:
+        pickler.dump(state, f)
+
+
+def load_state(path):
+    pickler = state_pickler()
+    with open(path, "rb") as f:
+        state = pickler.load(f)
+    for name, blob in state.items():
+        try:
+            globals_ns[name] = pickler.loads(blob)
+        except Exception:
+            continue
+
+
+def run_op(req):
+    op = req.get("op")
+    if op == "save_state":
+        save_state(req["path"])
+    elif op == "load_state":
+        load_state(req["path"])
+    else:
+        raise ValueError("unknown op: " + str(op))
+
 
 for line in sys.stdin:
     line = line.rstrip("\n")
@@ -51,18 +171,25 @@ for line in sys.stdin:
         req = json.loads(line)
     except Exception:
         continue
+    if req.get("op"):
+        try:
+            run_op(req)
+            emit({"type": "done", "error": ""})
+        except Exception:
+            emit({"type": "done", "error": traceback.format_exc()})
+        continue
     code = req.get("code", "")
-    out = io.StringIO()
-    err = io.StringIO()
     failure = ""
     try:
-        with contextlib.redirect_stdout(out), contextlib.redirect_stderr(err):
-            exec(code, globals_ns)
-    except Exception:
+        with contextlib.redirect_stdout(FrameWriter("stdout")), contextlib.redirect_stderr(FrameWriter("stderr")):
+            running = True
+            try:
+                exec(code, globals_ns)
+            finally:
+                running = False
+    except (Exception, KeyboardInterrupt):
         failure = traceback.format_exc()
-    resp = {"stdout": out.getvalue(), "stderr": err.getvalue(), "error": failure}
-    sys.stdout.write(json.dumps(resp) + "\n")
-    sys.stdout.flush()
+    emit({"type": "done", "error": failure})
 `
 
 const nodeReplScript = `const readline = require("readline");
@@ -79,6 +206,13 @@ const context = vm.createContext({
   clearInterval,
 });
 
+const writeFrame = process.stdout.write.bind(process.stdout);
+const emit = (frame) => writeFrame(JSON.stringify(frame) + "\n");
+
+// SIGINT only interrupts running code (breakOnSigint); between steps it is
+// ignored rather than killing the REPL.
+process.on("SIGINT", () => {});
+
 const rl = readline.createInterface({
   input: process.stdin,
   crlfDelay: Infinity,
@@ -94,38 +228,33 @@ rl.on("line", (line) => {
   } catch (err) {
     return;
   }
-  let stdout = "";
-  let stderr = "";
   let error = "";
 
-  const originalStdoutWrite = process.stdout.write.bind(process.stdout);
-  const originalStderrWrite = process.stderr.write.bind(process.stderr);
+  const originalStdoutWrite = process.stdout.write;
+  const originalStderrWrite = process.stderr.write;
   const originalConsoleLog = console.log;
   const originalConsoleError = console.error;
 
-  process.stdout.write = (chunk, encoding, cb) => {
-    stdout += chunk instanceof Buffer ? chunk.toString() : chunk;
-    if (typeof cb === "function") {
-      cb();
-    }
-    return true;
-  };
-  process.stderr.write = (chunk, encoding, cb) => {
-    stderr += chunk instanceof Buffer ? chunk.toString() : chunk;
-    if (typeof cb === "function") {
+  const chunkWriter = (type) => (chunk, encoding, cb) => {
+    emit({ type, data: chunk instanceof Buffer ? chunk.toString() : String(chunk) });
+    if (typeof encoding === "function") {
+      encoding();
+    } else if (typeof cb === "function") {
       cb();
     }
     return true;
   };
+  process.stdout.write = chunkWriter("stdout");
+  process.stderr.write = chunkWriter("stderr");
   console.log = (...args) => {
-    stdout += args.join(" ") + "\n";
+    emit({ type: "stdout", data: args.join(" ") + "\n" });
   };
   console.error = (...args) => {
-    stderr += args.join(" ") + "\n";
+    emit({ type: "stderr", data: args.join(" ") + "\n" });
   };
 
   try {
-    vm.runInContext(req.code || "", context);
+    vm.runInContext(req.code || "", context, { breakOnSigint: true });
   } catch (err) {
     error = err && err.stack ? err.stack : String(err);
   }
@@ -135,60 +264,107 @@ rl.on("line", (line) => {
   console.log = originalConsoleLog;
   console.error = originalConsoleError;
 
-  const resp = JSON.stringify({ stdout, stderr, error });
-  originalStdoutWrite(resp + "\n");
+  emit({ type: "done", error });
 });
 `
 
 func NewLocalSessionRuntime() *LocalSessionRuntime {
-	return &LocalSessionRuntime{processes: map[string]*sessionProcess{}}
+	return &LocalSessionRuntime{RestartCrashed: true, processes: map[string]*sessionProcess{}}
 }
 
-func (r *LocalSessionRuntime) StartSession(ctx context.Context, sessionID string, policyID string, workspaceRef string, runtime string) (SessionRoute, error) {
-	_ = ctx
-	_ = policyID
-	if sessionID == "" {
-		return SessionRoute{}, errors.New("missing session id")
-	}
-	workspaceDir, err := resolveWorkspaceDir(workspaceRef, sessionID)
-	if err != nil {
-		return SessionRoute{}, err
-	}
+// sessionCommand builds the interpreter for a session runtime. Runtimes
+// without a REPL run their steps through sh. The interpreter leads its own
+// process group so a timed-out step's children are stopped with it.
+func sessionCommand(runtime string) (*exec.Cmd, bool) {
 	normalized := strings.ToLower(strings.TrimSpace(runtime))
-	cmd := exec.Command("sh")
-	repl := false
+	cmd, repl := exec.Command("sh"), false
 	if normalized == "python" || normalized == "python3" {
-		cmd = exec.Command("python3", "-u", "-c", pythonReplScript)
-		repl = true
+		cmd, repl = exec.Command("python3", "-u", "-c", pythonReplScript), true
 	} else if normalized == "node" || normalized == "javascript" {
-		cmd = exec.Command("node", "-e", nodeReplScript)
-		repl = true
-	}
-	if workspaceDir != "" {
-		cmd.Dir = workspaceDir
+		cmd, repl = exec.Command("node", "-e", nodeReplScript), true
 	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	return cmd, repl
+}
+
+func pipeSessionCommand(cmd *exec.Cmd, repl bool) (io.WriteCloser, *bufio.Reader, error) {
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
-		return SessionRoute{}, err
+		return nil, nil, err
 	}
 	stdoutPipe, err := cmd.StdoutPipe()
 	if err != nil {
 		_ = stdin.Close()
-		return SessionRoute{}, err
+		return nil, nil, err
 	}
 	if repl {
 		cmd.Stderr = os.Stderr
 	} else {
 		cmd.Stderr = cmd.Stdout
 	}
-	if err := cmd.Start(); err != nil {
+	return stdin, bufio.NewReader(stdoutPipe), nil
+}
+
+func (r *LocalSessionRuntime) StartSession(ctx context.Context, spec SessionSpec) (SessionRoute, error) {
+	_ = ctx
+	if spec.ID == "" {
+		return SessionRoute{}, errors.New("missing session id")
+	}
+	policy := spec.Policy
+	if err := (Enforcement{Limits: r.Limiter != nil, Egress: r.DenyEgress, Secrets: r.Secrets}).Check(policy); err != nil {
+		return SessionRoute{}, err
+	}
+	workspaceDir, err := resolveWorkspaceDir(spec.WorkspaceRef, spec.ID)
+	if err != nil {
+		return SessionRoute{}, err
+	}
+	cmd, repl := sessionCommand(spec.Runtime)
+	if workspaceDir != "" {
+		cmd.Dir = workspaceDir
+	}
+	env, err := r.installDeps(ctx, spec)
+	if err != nil {
+		return SessionRoute{}, err
+	}
+	if len(policy.Secrets) > 0 {
+		secrets, err := r.Secrets.Env(spec.TenantID, policy.Secrets)
+		if err != nil {
+			return SessionRoute{}, err
+		}
+		env = append(env, secrets...)
+	}
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	stdin, stdout, err := pipeSessionCommand(cmd, repl)
+	if err != nil {
+		return SessionRoute{}, err
+	}
+	egress := policy.EgressAllowList
+	if egress == nil {
+		egress = r.EgressAllowList
+	}
+	group, err := r.limit(spec.ID, policy.Limits.WithDefaults(r.Limits))
+	if err != nil {
 		_ = stdin.Close()
 		return SessionRoute{}, err
 	}
-	agentEndpoint, agentMode, agentCmd, err := launchSessionAgent(sessionID)
+	sandbox, err := r.startSessionProcess(spec.ID, cmd, egress, group)
+	if err != nil {
+		_ = stdin.Close()
+		if group != nil {
+			_ = group.Close()
+		}
+		return SessionRoute{}, err
+	}
+	agentEndpoint, agentMode, agentToken, agentCmd, err := launchSessionAgent(spec.ID, group)
 	if err != nil {
 		_ = stdin.Close()
 		_ = cmd.Process.Kill()
+		if group != nil {
+			_ = group.Close()
+		}
+		closeEgress(sandbox)
 		return SessionRoute{}, err
 	}
 	token := ""
@@ -197,15 +373,17 @@ func (r *LocalSessionRuntime) StartSession(ctx context.Context, sessionID string
 	}
 	if agentEndpoint != "" {
 		client := NewAgentClient()
+		client.AdminToken = agentToken
 		err = client.RegisterSession(ctx, AgentRoute{
 			Endpoint: agentEndpoint,
 			AuthMode: agentMode,
 			Token:    token,
 		}, sessionagent.SessionRegisterRequest{
-			SessionID:    sessionID,
-			Runtime:      runtime,
+			SessionID:    spec.ID,
+			Runtime:      spec.Runtime,
 			Token:        token,
 			WorkspaceDir: workspaceDir,
+			Env:          env,
 		})
 		if err != nil {
 			_ = stdin.Close()
@@ -213,31 +391,50 @@ func (r *LocalSessionRuntime) StartSession(ctx context.Context, sessionID string
 			if agentCmd != nil && agentCmd.Process != nil {
 				_ = agentCmd.Process.Kill()
 			}
+			if group != nil {
+				_ = group.Close()
+			}
+			closeEgress(sandbox)
 			return SessionRoute{}, err
 		}
 	}
-	r.mu.Lock()
-	r.processes[sessionID] = &sessionProcess{
+	process := &sessionProcess{
 		cmd:           cmd,
 		stdin:         stdin,
-		stdout:        bufio.NewReader(stdoutPipe),
-		runtime:       runtime,
+		stdout:        stdout,
+		exit:          &processExit{done: make(chan struct{})},
+		runtime:       spec.Runtime,
 		repl:          repl,
+		dir:           cmd.Dir,
+		env:           cmd.Env,
 		agentCmd:      agentCmd,
 		agentEndpoint: agentEndpoint,
 		agentAuthMode: agentMode,
+		cgroup:        group,
+		egress:        sandbox,
 	}
+	go r.supervise(spec.ID, process, cmd, process.exit)
+	r.mu.Lock()
+	r.processes[spec.ID] = process
 	r.mu.Unlock()
 	return SessionRoute{
-		RuntimeID: sessionID,
-		Runtime:   runtime,
+		RuntimeID: spec.ID,
+		Runtime:   spec.Runtime,
 		Endpoint:  agentEndpoint,
 		AuthMode:  agentMode,
 		Token:     token,
 	}, nil
 }
 
-func (r *LocalSessionRuntime) RunStep(ctx context.Context, runtimeID string, command string) (StepOutput, error) {
+func (r *LocalSessionRuntime) RunStep(ctx context.Context, runtimeID string, command string, timeout time.Duration) (StepOutput, error) {
+	return r.StreamStep(ctx, runtimeID, command, timeout, nil)
+}
+
+// StreamStep runs a step and passes REPL output to emit as it is written.
+// Shell sessions have no framing, so their output is emitted once the step
+// has finished. A step that outlives timeout (when positive) gets SIGINT,
+// and the interpreter is restarted if that does not stop it.
+func (r *LocalSessionRuntime) StreamStep(ctx context.Context, runtimeID string, command string, timeout time.Duration, emit func(stream string, data string)) (StepOutput, error) {
 	_ = ctx
 	if runtimeID == "" {
 		return StepOutput{}, errors.New("missing runtime id")
@@ -253,8 +450,205 @@ func (r *LocalSessionRuntime) RunStep(ctx context.Context, runtimeID string, com
 	}
 	process.mu.Lock()
 	defer process.mu.Unlock()
+	if process.cmd == nil {
+		return StepOutput{}, fmt.Errorf("%w: %s", ErrRuntimeExited, process.exitReason)
+	}
+	cmd, exit := process.cmd, process.exit
+	pid := cmd.Process.Pid
+	_ = sessionagent.ResetPeakRSS(pid)
+	before, _ := sessionagent.ReadProcessUsage(pid)
+	output, err := r.runStepWithin(runtimeID, process, command, timeout, emit)
+	if after, usageErr := sessionagent.ReadProcessUsage(pid); usageErr == nil {
+		output.Usage = after.Sub(before)
+	}
+	if err != nil {
+		// A dead interpreter surfaces as a read error once its stdout is
+		// closed; make sure it is replaced before reporting.
+		exited := false
+		select {
+		case <-exit.done:
+			exited = true
+		case <-time.After(time.Second):
+		}
+		if process.cgroup != nil {
+			if oom, _ := process.cgroup.OOMKilled(); oom {
+				err = fmt.Errorf("%w: %v", ErrRuntimeOOMKilled, err)
+			}
+		}
+		if exited {
+			r.handleExit(runtimeID, process, cmd)
+			if !errors.Is(err, ErrRuntimeOOMKilled) {
+				err = fmt.Errorf("%w (%s); session state was lost", ErrRuntimeExited, exitReason(exit.err))
+			}
+		}
+		return StepOutput{}, err
+	}
+	output.StateLost = output.StateLost || process.stateLost
+	process.stateLost = false
+	return output, nil
+}
+
+// runStepWithin runs a step, stopping it once timeout has passed when
+// timeout is positive: the interpreter's process group gets SIGINT and, if
+// the step has not finished within stepInterruptGrace, the interpreter is
+// replaced. Callers hold process.mu.
+func (r *LocalSessionRuntime) runStepWithin(sessionID string, process *sessionProcess, command string, timeout time.Duration, emit func(stream string, data string)) (StepOutput, error) {
+	if timeout <= 0 {
+		return runStep(process, command, emit)
+	}
+	// The step's reader may outlive the timeout, so output is only
+	// forwarded while the step is attached.
+	var emitMu sync.Mutex
+	attached := true
+	done := make(chan stepResult, 1)
+	go func() {
+		output, err := runStep(process, command, func(stream string, data string) {
+			emitMu.Lock()
+			defer emitMu.Unlock()
+			if attached && emit != nil {
+				emit(stream, data)
+			}
+		})
+		done <- stepResult{output: output, err: err}
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case result := <-done:
+		return result.output, result.err
+	case <-timer.C:
+	}
+
+	cmd, exit := process.cmd, process.exit
+	finished := false
+	if err := signalGroup(cmd, syscall.SIGINT); err == nil {
+		select {
+		case result := <-done:
+			if result.err == nil {
+				result.output.TimedOut = true
+				return result.output, nil
+			}
+			// The interrupt killed the interpreter.
+			finished = true
+		case <-time.After(stepInterruptGrace):
+		}
+	}
+	emitMu.Lock()
+	attached = false
+	emitMu.Unlock()
+	_ = process.stdin.Close()
+	_ = signalGroup(cmd, syscall.SIGKILL)
+	<-exit.done
+	if !finished {
+		<-done
+	}
+	// The supervisor ignores the exit once process.cmd no longer matches.
+	process.cmd = nil
+	process.exitReason = exitReason(exit.err)
+	log.Printf("sessions: step timed out, restarting interpreter session_id=%s", sessionID)
+	if err := r.restart(sessionID, process); err != nil {
+		process.exitReason = "restart failed: " + err.Error()
+		return StepOutput{}, fmt.Errorf("%w: step timed out and the interpreter could not be restarted: %v", ErrRuntimeExited, err)
+	}
+	if emit != nil {
+		emit(sessionagent.StepEventStderr, stepTimedOutMessage)
+	}
+	return StepOutput{Stderr: stepTimedOutMessage, StateLost: true, TimedOut: true}, nil
+}
+
+type stepResult struct {
+	output StepOutput
+	err    error
+}
+
+// signalGroup delivers sig to the interpreter's process group.
+func signalGroup(cmd *exec.Cmd, sig syscall.Signal) error {
+	return syscall.Kill(-cmd.Process.Pid, sig)
+}
+
+func (r *LocalSessionRuntime) supervise(sessionID string, process *sessionProcess, cmd *exec.Cmd, exit *processExit) {
+	exit.err = cmd.Wait()
+	close(exit.done)
+	process.mu.Lock()
+	defer process.mu.Unlock()
+	r.handleExit(sessionID, process, cmd)
+}
+
+// handleExit records that cmd, the session's interpreter, has exited and
+// restarts it when configured to. Callers hold process.mu.
+func (r *LocalSessionRuntime) handleExit(sessionID string, process *sessionProcess, cmd *exec.Cmd) {
+	if process.cmd != cmd || process.closed.Load() {
+		return
+	}
+	process.cmd = nil
+	process.stateLost = true
+	process.exitReason = exitReason(process.exit.err)
+	log.Printf("sessions: interpreter exited session_id=%s reason=%q", sessionID, process.exitReason)
+	if !r.RestartCrashed {
+		return
+	}
+	if delay := process.backoff.Next(time.Now()); delay > 0 {
+		// Steps fail with the exit reason until the delayed restart.
+		log.Printf("sessions: interpreter restart delayed session_id=%s delay=%s", sessionID, delay)
+		process.exitReason = fmt.Sprintf("%s; crashing repeatedly, restarting in %s", process.exitReason, delay)
+		time.AfterFunc(delay, func() {
+			process.mu.Lock()
+			defer process.mu.Unlock()
+			if process.cmd != nil || process.closed.Load() {
+				return
+			}
+			r.restartCrashed(sessionID, process)
+		})
+		return
+	}
+	r.restartCrashed(sessionID, process)
+}
+
+// restartCrashed replaces the session's interpreter. Callers hold
+// process.mu.
+func (r *LocalSessionRuntime) restartCrashed(sessionID string, process *sessionProcess) {
+	if err := r.restart(sessionID, process); err != nil {
+		log.Printf("sessions: interpreter restart failed session_id=%s: %v", sessionID, err)
+		process.exitReason = "restart failed: " + err.Error()
+	}
+}
+
+// restart starts a fresh interpreter in the session's existing workspace,
+// egress sandbox and cgroup.
+func (r *LocalSessionRuntime) restart(sessionID string, process *sessionProcess) error {
+	cmd, _ := sessionCommand(process.runtime)
+	cmd.Dir = process.dir
+	cmd.Env = process.env
+	stdin, stdout, err := pipeSessionCommand(cmd, process.repl)
+	if err != nil {
+		return err
+	}
+	start := cmd.Start
+	if process.egress != nil {
+		start = func() error { return process.egress.Start(cmd) }
+	}
+	if err := startInCgroup(process.cgroup, cmd, start); err != nil {
+		_ = stdin.Close()
+		return err
+	}
+	process.cmd = cmd
+	process.stdin = stdin
+	process.stdout = stdout
+	process.exit = &processExit{done: make(chan struct{})}
+	go r.supervise(sessionID, process, cmd, process.exit)
+	return nil
+}
+
+func exitReason(err error) string {
+	if err == nil {
+		return "exit status 0"
+	}
+	return err.Error()
+}
+
+func runStep(process *sessionProcess, command string, emit func(stream string, data string)) (StepOutput, error) {
 	if process.repl {
-		return runStepRepl(process, command)
+		return runStepRepl(process, command, emit)
 	}
 	token := fmt.Sprintf("step-%d", time.Now().UTC().UnixNano())
 	wrapped := command
@@ -273,9 +667,81 @@ func (r *LocalSessionRuntime) RunStep(ctx context.Context, runtimeID string, com
 	if err != nil {
 		return StepOutput{}, err
 	}
+	if emit != nil {
+		if stdout != "" {
+			emit(sessionagent.StepEventStdout, stdout)
+		}
+		if stderr != "" {
+			emit(sessionagent.StepEventStderr, stderr)
+		}
+	}
 	return StepOutput{Stdout: stdout, Stderr: stderr}, nil
 }
 
+func (r *LocalSessionRuntime) WorkspaceDir(runtimeID string) (string, error) {
+	r.mu.RLock()
+	process, ok := r.processes[runtimeID]
+	r.mu.RUnlock()
+	if !ok {
+		return "", ErrRuntimeNotFound
+	}
+	return process.dir, nil
+}
+
+// SnapshotSession archives the session workspace. With includeState a
+// Python REPL first pickles its globals into the archive.
+func (r *LocalSessionRuntime) SnapshotSession(ctx context.Context, runtimeID string, includeState bool, w io.Writer) error {
+	_ = ctx
+	r.mu.RLock()
+	process, ok := r.processes[runtimeID]
+	r.mu.RUnlock()
+	if !ok {
+		return ErrRuntimeNotFound
+	}
+	process.mu.Lock()
+	defer process.mu.Unlock()
+	if process.dir == "" {
+		return sessionagent.ErrWorkspaceUnavailable
+	}
+	if includeState && process.repl && sessionagent.SupportsReplState(process.runtime) {
+		statePath := filepath.Join(process.dir, sessionagent.SnapshotStateFile)
+		defer os.Remove(statePath)
+		if err := runReplOp(process, sessionagent.ReplOpSaveState, statePath); err != nil {
+			return err
+		}
+	}
+	return sessionagent.ArchiveWorkspace(process.dir, w)
+}
+
+// RestoreSession unpacks a snapshot into the session workspace and loads
+// any saved interpreter state into the REPL.
+func (r *LocalSessionRuntime) RestoreSession(ctx context.Context, runtimeID string, rd io.Reader) error {
+	_ = ctx
+	r.mu.RLock()
+	process, ok := r.processes[runtimeID]
+	r.mu.RUnlock()
+	if !ok {
+		return ErrRuntimeNotFound
+	}
+	process.mu.Lock()
+	defer process.mu.Unlock()
+	if process.dir == "" {
+		return sessionagent.ErrWorkspaceUnavailable
+	}
+	if err := sessionagent.ExtractWorkspace(rd, process.dir); err != nil {
+		return err
+	}
+	statePath := filepath.Join(process.dir, sessionagent.SnapshotStateFile)
+	if _, err := os.Stat(statePath); err != nil {
+		return nil
+	}
+	defer os.Remove(statePath)
+	if !process.repl || !sessionagent.SupportsReplState(process.runtime) {
+		return nil
+	}
+	return runReplOp(process, sessionagent.ReplOpLoadState, statePath)
+}
+
 func (r *LocalSessionRuntime) TerminateSession(ctx context.Context, runtimeID string) error {
 	_ = ctx
 	r.mu.Lock()
@@ -287,18 +753,83 @@ func (r *LocalSessionRuntime) TerminateSession(ctx context.Context, runtimeID st
 	if !ok {
 		return ErrRuntimeNotFound
 	}
+	process.closed.Store(true)
 	if process.stdin != nil {
 		_ = process.stdin.Close()
 	}
 	if process.cmd != nil && process.cmd.Process != nil {
-		_ = process.cmd.Process.Kill()
+		_ = signalGroup(process.cmd, syscall.SIGKILL)
 	}
 	if process.agentCmd != nil && process.agentCmd.Process != nil {
 		_ = process.agentCmd.Process.Kill()
 	}
+	if process.cgroup != nil {
+		if err := process.cgroup.Close(); err != nil {
+			log.Printf("sessions: cgroup cleanup error session_id=%s: %v", runtimeID, err)
+		}
+	}
+	closeEgress(process.egress)
 	return nil
 }
 
+// startSessionProcess starts cmd in group, inside an egress sandbox limited
+// to allowList when egress is denied by default. A launched session agent
+// executes code itself outside the sandbox, so that combination is refused
+// rather than silently leaking.
+func (r *LocalSessionRuntime) startSessionProcess(sessionID string, cmd *exec.Cmd, allowList []string, group *isolation.Cgroup) (*isolation.EgressSandbox, error) {
+	if !r.DenyEgress {
+		return nil, startInCgroup(group, cmd, cmd.Start)
+	}
+	if os.Getenv("SESSION_AGENT_LAUNCH") == "true" {
+		return nil, ErrEgressAgentUnsupported
+	}
+	sandbox, err := isolation.NewEgressSandbox(isolation.EgressPolicy{AllowList: allowList}, "", sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("isolate network: %w", err)
+	}
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env, sandbox.Env()...)
+	if err := startInCgroup(group, cmd, func() error { return sandbox.Start(cmd) }); err != nil {
+		closeEgress(sandbox)
+		return nil, err
+	}
+	return sandbox, nil
+}
+
+func (r *LocalSessionRuntime) installDeps(ctx context.Context, spec SessionSpec) ([]string, error) {
+	if len(spec.Deps) == 0 {
+		return nil, nil
+	}
+	if r.Installer == nil {
+		return nil, ErrDependenciesNotConfigured
+	}
+	return r.Installer.Install(ctx, spec.Runtime, spec.Deps)
+}
+
+func closeEgress(sandbox *isolation.EgressSandbox) {
+	if sandbox == nil {
+		return
+	}
+	if err := sandbox.Close(); err != nil {
+		log.Printf("sessions: egress cleanup error: %v", err)
+	}
+}
+
+// limit creates the cgroup the session's processes start in when a
+// limiter is configured.
+func (r *LocalSessionRuntime) limit(sessionID string, limits isolation.Limits) (*isolation.Cgroup, error) {
+	if r.Limiter == nil || limits.IsZero() {
+		return nil, nil
+	}
+	group, err := r.Limiter.Create(cgroupName("session", sessionID), limits)
+	if err != nil {
+		return nil, fmt.Errorf("create cgroup: %w", err)
+	}
+	return group, nil
+}
+
 func readStepOutput(reader *bufio.Reader, token string) (string, string, error) {
 	stdoutMarker := "__STDOUT__" + token
 	stderrMarker := "__STDERR__" + token
@@ -339,13 +870,7 @@ func readUntilMarker(reader *bufio.Reader, marker string) (string, error) {
 	}
 }
 
-type replResponse struct {
-	Stdout string `json:"stdout"`
-	Stderr string `json:"stderr"`
-	Error  string `json:"error"`
-}
-
-func runStepRepl(process *sessionProcess, command string) (StepOutput, error) {
+func runStepRepl(process *sessionProcess, command string, emit func(stream string, data string)) (StepOutput, error) {
 	payload, err := json.Marshal(map[string]string{"code": command})
 	if err != nil {
 		return StepOutput{}, err
@@ -353,52 +878,81 @@ func runStepRepl(process *sessionProcess, command string) (StepOutput, error) {
 	if _, err := process.stdin.Write(append(payload, '\n')); err != nil {
 		return StepOutput{}, err
 	}
-	line, err := process.stdout.ReadString('\n')
+	var stdout, stderr strings.Builder
+	failure, err := sessionagent.ReadReplStep(process.stdout, func(stream string, data string) {
+		if stream == sessionagent.StepEventStderr {
+			stderr.WriteString(data)
+		} else {
+			stdout.WriteString(data)
+		}
+		if emit != nil {
+			emit(stream, data)
+		}
+	})
 	if err != nil {
 		return StepOutput{}, err
 	}
-	var resp replResponse
-	if err := json.Unmarshal([]byte(strings.TrimSpace(line)), &resp); err != nil {
-		return StepOutput{}, err
+	if failure != "" && emit != nil {
+		emit(sessionagent.StepEventStderr, failure)
 	}
-	stderr := resp.Stderr
-	if resp.Error != "" {
-		if stderr != "" {
-			stderr = stderr + "\n" + resp.Error
-		} else {
-			stderr = resp.Error
-		}
+	return StepOutput{Stdout: stdout.String(), Stderr: sessionagent.JoinStepError(stderr.String(), failure)}, nil
+}
+
+// runReplOp sends a control request to the REPL. Callers hold process.mu.
+func runReplOp(process *sessionProcess, op string, path string) error {
+	if process.cmd == nil {
+		return fmt.Errorf("%w: %s", ErrRuntimeExited, process.exitReason)
+	}
+	payload, err := json.Marshal(map[string]string{"op": op, "path": path})
+	if err != nil {
+		return err
 	}
-	return StepOutput{Stdout: resp.Stdout, Stderr: stderr}, nil
+	if _, err := process.stdin.Write(append(payload, '\n')); err != nil {
+		return err
+	}
+	failure, err := sessionagent.ReadReplStep(process.stdout, nil)
+	if err != nil {
+		return err
+	}
+	if failure != "" {
+		return fmt.Errorf("%w: %s", sessionagent.ErrReplStateFailed, strings.TrimSpace(failure))
+	}
+	return nil
 }
 
-func launchSessionAgent(sessionID string) (string, string, *exec.Cmd, error) {
+// launchSessionAgent returns the endpoint, auth mode and admin token of the
+// session's agent, starting one with a fresh admin token when
+// SESSION_AGENT_LAUNCH is set.
+func launchSessionAgent(sessionID string, group *isolation.Cgroup) (string, string, string, *exec.Cmd, error) {
 	if os.Getenv("SESSION_AGENT_LAUNCH") != "true" {
-		return os.Getenv("SESSION_AGENT_ENDPOINT"), getenv("SESSION_AGENT_AUTH_MODE", "bypass"), nil, nil
+		return os.Getenv("SESSION_AGENT_ENDPOINT"), getenv("SESSION_AGENT_AUTH_MODE", "bypass"), os.Getenv("SESSION_AGENT_ADMIN_TOKEN"), nil, nil
 	}
 	addr := os.Getenv("SESSION_AGENT_ADDR")
 	if addr == "" {
 		listener, err := net.Listen("tcp", "127.0.0.1:0")
 		if err != nil {
-			return "", "", nil, err
+			return "", "", "", nil, err
 		}
 		addr = listener.Addr().String()
 		_ = listener.Close()
 	}
 	authMode := getenv("SESSION_AGENT_AUTH_MODE", "bypass")
+	adminToken := generateSessionToken()
 	bin := getenv("SESSION_AGENT_BIN", "session-agent")
 	cmd := exec.Command(bin)
 	cmd.Env = append(os.Environ(),
 		"ENV="+getenv("ENV", "dev"),
 		"SESSION_AGENT_ADDR="+addr,
 		"SESSION_AGENT_AUTH_BYPASS="+boolToString(authMode == "bypass"),
+		"SESSION_AGENT_ADMIN_TOKEN="+adminToken,
+		"WORKSPACE_ROOT="+getenv("WORKSPACE_ROOT", "/tmp/sessions"),
 		"SESSION_ID="+sessionID,
 	)
-	if err := cmd.Start(); err != nil {
-		return "", "", nil, err
+	if err := startInCgroup(group, cmd, cmd.Start); err != nil {
+		return "", "", "", nil, err
 	}
 	endpoint := "http://" + addr
-	return endpoint, authMode, cmd, nil
+	return endpoint, authMode, adminToken, cmd, nil
 }
 
 func boolToString(value bool) string {
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 )
 
@@ -20,6 +21,9 @@ type SessionRegistry interface {
 	Put(sessionID string, route SessionRoute) error
 	Get(sessionID string) (SessionRoute, bool)
 	Delete(sessionID string)
+	// SessionsAt returns the sessions routed to an agent endpoint, which
+	// is how shared agents are filled up to their capacity.
+	SessionsAt(endpoint string) []string
 }
 
 type InMemorySessionRegistry struct {
@@ -54,6 +58,12 @@ func (r *InMemorySessionRegistry) Delete(sessionID string) {
 	delete(r.items, sessionID)
 }
 
+func (r *InMemorySessionRegistry) SessionsAt(endpoint string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return sessionsAt(r.items, endpoint)
+}
+
 type FileSessionRegistry struct {
 	mu    sync.RWMutex
 	path  string
@@ -98,6 +108,23 @@ func (r *FileSessionRegistry) Delete(sessionID string) {
 	_ = r.persistLocked()
 }
 
+func (r *FileSessionRegistry) SessionsAt(endpoint string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return sessionsAt(r.items, endpoint)
+}
+
+func sessionsAt(items map[string]SessionRoute, endpoint string) []string {
+	var sessions []string
+	for sessionID, route := range items {
+		if endpoint != "" && route.Endpoint == endpoint {
+			sessions = append(sessions, sessionID)
+		}
+	}
+	sort.Strings(sessions)
+	return sessions
+}
+
 func (r *FileSessionRegistry) load() error {
 	content, err := os.ReadFile(r.path)
 	if err != nil {
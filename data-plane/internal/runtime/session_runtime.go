@@ -1,14 +1,62 @@
 package runtime
 
-import "context"
+import (
+	"context"
+	"io"
+	"time"
+
+	"shared/sessionagent"
+)
 
 type SessionRuntime interface {
-	StartSession(ctx context.Context, sessionID string, policyID string, workspaceRef string, runtime string) (SessionRoute, error)
-	RunStep(ctx context.Context, runtimeID string, command string) (StepOutput, error)
+	StartSession(ctx context.Context, spec SessionSpec) (SessionRoute, error)
+	// RunStep runs command in the session, stopping it once timeout has
+	// passed when timeout is positive.
+	RunStep(ctx context.Context, runtimeID string, command string, timeout time.Duration) (StepOutput, error)
 	TerminateSession(ctx context.Context, runtimeID string) error
 }
 
+// StepStreamer is implemented by runtimes that can relay step output while
+// the step is still running.
+type StepStreamer interface {
+	StreamStep(ctx context.Context, runtimeID string, command string, timeout time.Duration, emit func(stream string, data string)) (StepOutput, error)
+}
+
+// WorkspaceProvider is implemented by runtimes whose session workspaces are
+// on the data plane's own filesystem, so files can be moved without the agent.
+type WorkspaceProvider interface {
+	WorkspaceDir(runtimeID string) (string, error)
+}
+
+// SessionSnapshotter is implemented by runtimes whose interpreters and
+// workspaces run on the data plane, so they can be snapshotted without the
+// agent. Snapshots are gzip-compressed tar archives of the workspace.
+type SessionSnapshotter interface {
+	SnapshotSession(ctx context.Context, runtimeID string, includeState bool, w io.Writer) error
+	RestoreSession(ctx context.Context, runtimeID string, r io.Reader) error
+}
+
+type SessionSpec struct {
+	ID string
+	// TenantID selects the directory the secrets named by Policy are read
+	// from.
+	TenantID     string
+	PolicyID     string
+	WorkspaceRef string
+	Runtime      string
+	Deps         []string
+	// Policy is enforced by the runtime, which refuses the session when it
+	// cannot be.
+	Policy WorkloadPolicy
+}
+
 type StepOutput struct {
 	Stdout string
 	Stderr string
+	// StateLost reports that the interpreter was restarted since the
+	// previous step, discarding session state.
+	StateLost bool
+	// TimedOut reports that the step was stopped at its timeout.
+	TimedOut bool
+	Usage    sessionagent.ResourceUsage
 }
@@ -0,0 +1,185 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"data-plane/internal/workspace"
+	"shared/sessionagent"
+)
+
+type sessionSnapshotRequest struct {
+	IncludeState bool `json:"includeState"`
+}
+
+var (
+	errSnapshotsUnsupported    = errors.New("session snapshots are not configured")
+	errSnapshotRuntimeMismatch = errors.New("snapshot was taken from a different runtime")
+)
+
+// handleSnapshot archives the session workspace, optionally with the
+// interpreter's pickled globals, and stores it encrypted as an artifact.
+func (h SessionHandler) handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	if h.Runtime == nil || h.Registry == nil {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+	sessionID := chi.URLParam(r, "sessionId")
+	if sessionID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var req sessionSnapshotRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if h.Snapshots == nil {
+		writeSnapshotError(w, errSnapshotsUnsupported)
+		return
+	}
+	route, ok := h.Registry.Get(sessionID)
+	if !ok {
+		writeJSONError(w, http.StatusGone, "session_expired", "session not found")
+		return
+	}
+	var archive []byte
+	snapshotter, local := h.snapshotter(route)
+	switch {
+	case local:
+		var buf bytes.Buffer
+		err := snapshotter.SnapshotSession(r.Context(), route.RuntimeID, req.IncludeState, &buf)
+		if err != nil {
+			log.Printf("sessions: snapshot session_id=%s error: %v", sessionID, err)
+			writeSnapshotError(w, err)
+			return
+		}
+		archive = buf.Bytes()
+	case h.Agent != nil && route.Endpoint != "":
+		var err error
+		archive, err = h.Agent.SnapshotSession(r.Context(), agentRoute(route), sessionID, req.IncludeState)
+		if err != nil {
+			log.Printf("sessions: snapshot session_id=%s error: %v", sessionID, err)
+			writeSnapshotError(w, err)
+			return
+		}
+	default:
+		writeSnapshotError(w, errSnapshotsUnsupported)
+		return
+	}
+	snapshot, err := h.Snapshots.Save(workspace.Snapshot{
+		SessionID: sessionID,
+		Runtime:   route.Runtime,
+		HasState:  req.IncludeState && sessionagent.SupportsReplState(route.Runtime),
+	}, archive)
+	if err != nil {
+		log.Printf("sessions: snapshot session_id=%s store error: %v", sessionID, err)
+		writeSnapshotError(w, err)
+		return
+	}
+	log.Printf("sessions: snapshot session_id=%s snapshot_id=%s size=%d", sessionID, snapshot.ID, snapshot.Size)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(snapshot)
+}
+
+func (h SessionHandler) handleGetSnapshot(w http.ResponseWriter, r *http.Request) {
+	if h.Snapshots == nil {
+		writeSnapshotError(w, errSnapshotsUnsupported)
+		return
+	}
+	snapshot, err := h.Snapshots.Get(chi.URLParam(r, "snapshotId"))
+	if err != nil {
+		writeSnapshotError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(snapshot)
+}
+
+// loadSnapshot decrypts the snapshot a new session is restored from,
+// writing an error response when it cannot be used for runtime.
+func (h SessionHandler) loadSnapshot(w http.ResponseWriter, snapshotID string, runtime string) ([]byte, bool) {
+	if h.Snapshots == nil {
+		writeSnapshotError(w, errSnapshotsUnsupported)
+		return nil, false
+	}
+	snapshot, archive, err := h.Snapshots.Load(snapshotID)
+	if err != nil {
+		log.Printf("sessions: load snapshot_id=%s error: %v", snapshotID, err)
+		writeSnapshotError(w, err)
+		return nil, false
+	}
+	if !strings.EqualFold(snapshot.Runtime, runtime) {
+		writeSnapshotError(w, errSnapshotRuntimeMismatch)
+		return nil, false
+	}
+	return archive, true
+}
+
+func (h SessionHandler) restoreSnapshot(ctx context.Context, sessionID string, route SessionRoute, archive []byte) error {
+	if snapshotter, local := h.snapshotter(route); local {
+		return snapshotter.RestoreSession(ctx, route.RuntimeID, bytes.NewReader(archive))
+	}
+	if h.Agent != nil && route.Endpoint != "" {
+		return h.Agent.RestoreSession(ctx, agentRoute(route), sessionID, bytes.NewReader(archive))
+	}
+	return errSnapshotsUnsupported
+}
+
+// snapshotter returns the runtime when it holds the session's interpreter
+// itself. Sessions whose steps go to an agent are snapshotted there, since
+// that is where the interpreter state lives.
+func (h SessionHandler) snapshotter(route SessionRoute) (SessionSnapshotter, bool) {
+	snapshotter, ok := h.Runtime.(SessionSnapshotter)
+	if !ok {
+		return nil, false
+	}
+	if h.Agent != nil && route.Endpoint != "" && h.AgentPrefer {
+		return nil, false
+	}
+	return snapshotter, true
+}
+
+func agentRoute(route SessionRoute) AgentRoute {
+	return AgentRoute{
+		Endpoint: route.Endpoint,
+		Token:    route.Token,
+		AuthMode: route.AuthMode,
+	}
+}
+
+func writeSnapshotError(w http.ResponseWriter, err error) {
+	var unreachable runtimeUnreachableError
+	switch {
+	case errors.Is(err, workspace.ErrSnapshotNotFound):
+		writeJSONError(w, http.StatusNotFound, "snapshot_not_found", err.Error())
+	case errors.Is(err, errSnapshotRuntimeMismatch):
+		writeJSONError(w, http.StatusBadRequest, "snapshot_runtime_mismatch", err.Error())
+	case errors.Is(err, sessionagent.ErrInvalidSnapshot), errors.Is(err, sessionagent.ErrInvalidFilePath):
+		writeJSONError(w, http.StatusUnprocessableEntity, "invalid_snapshot", err.Error())
+	case errors.Is(err, sessionagent.ErrReplStateFailed):
+		writeJSONError(w, http.StatusUnprocessableEntity, "state_unavailable", err.Error())
+	case errors.Is(err, sessionagent.ErrWorkspaceUnavailable):
+		writeJSONError(w, http.StatusConflict, "workspace_unavailable", err.Error())
+	case errors.Is(err, errSnapshotsUnsupported):
+		writeJSONError(w, http.StatusNotImplemented, "snapshots_unsupported", err.Error())
+	case errors.As(err, &unreachable):
+		writeJSONError(w, http.StatusServiceUnavailable, "runtime_unreachable", "runtime agent is not reachable")
+	case errors.Is(err, ErrRuntimeNotFound):
+		writeJSONError(w, http.StatusServiceUnavailable, "runtime_crashed", "session runtime is not available")
+	case errors.Is(err, ErrRuntimeExited):
+		writeJSONError(w, http.StatusServiceUnavailable, "runtime_exited", err.Error())
+	default:
+		log.Printf("sessions: snapshot error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
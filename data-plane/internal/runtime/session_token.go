@@ -1,7 +1,22 @@
 package runtime
 
-import "github.com/google/uuid"
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/google/uuid"
+)
 
 func generateSessionToken() string {
 	return uuid.NewString()
 }
+
+// agentAdminToken derives the admin token of the agent in podName from
+// secret, so a session that reads its own agent's token cannot use it
+// against another pod.
+func agentAdminToken(secret string, podName string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(podName))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,42 @@
+package runtime
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"shared/sessionagent"
+)
+
+var (
+	usageMetricsOnce     sync.Once
+	usageCPUHistogram    metric.Int64Histogram
+	usageMaxRSSHistogram metric.Int64Histogram
+	usageIOHistogram     metric.Int64Histogram
+)
+
+// RecordResourceUsage exports what one run or step consumed. kind is "run"
+// or "step".
+func RecordResourceUsage(ctx context.Context, kind string, usage sessionagent.ResourceUsage) {
+	usageMetricsOnce.Do(initUsageMetrics)
+	attrs := metric.WithAttributes(attribute.String("kind", kind))
+	if usageCPUHistogram != nil {
+		usageCPUHistogram.Record(ctx, usage.CPUTimeMs, attrs)
+	}
+	if usageMaxRSSHistogram != nil {
+		usageMaxRSSHistogram.Record(ctx, usage.MaxRSSBytes, attrs)
+	}
+	if usageIOHistogram != nil {
+		usageIOHistogram.Record(ctx, usage.IOBytes, attrs)
+	}
+}
+
+func initUsageMetrics() {
+	meter := otel.Meter("data-plane.runtime")
+	usageCPUHistogram, _ = meter.Int64Histogram("dataplane.usage.cpu_time_ms", metric.WithUnit("ms"))
+	usageMaxRSSHistogram, _ = meter.Int64Histogram("dataplane.usage.max_rss_bytes", metric.WithUnit("By"))
+	usageIOHistogram, _ = meter.Int64Histogram("dataplane.usage.io_bytes", metric.WithUnit("By"))
+}
@@ -0,0 +1,237 @@
+package runtime
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// WarmRuntime is an idle runtime whose agent is already healthy, waiting
+// to be claimed by a session.
+type WarmRuntime struct {
+	ID       string
+	Runtime  string
+	Image    string
+	Endpoint string
+}
+
+// WarmProvisioner creates and removes the runtimes a WarmPool holds.
+type WarmProvisioner interface {
+	ProvisionWarm(ctx context.Context, runtime string) (WarmRuntime, error)
+	DiscardWarm(ctx context.Context, warm WarmRuntime) error
+	WarmImage(runtime string) string
+}
+
+// WarmPool keeps Sizes[runtime] idle runtimes per runtime so sessions can
+// claim one instead of waiting for a new runtime to become ready. Claimed
+// runtimes are replaced in the background.
+type WarmPool struct {
+	Provisioner    WarmProvisioner
+	Sizes          map[string]int
+	RefillInterval time.Duration
+
+	mu      sync.Mutex
+	idle    map[string][]WarmRuntime
+	pending map[string]int
+	refill  chan struct{}
+}
+
+func NewWarmPool(provisioner WarmProvisioner, sizes map[string]int) *WarmPool {
+	normalized := make(map[string]int, len(sizes))
+	for runtime, size := range sizes {
+		if size > 0 {
+			normalized[warmPoolKey(runtime)] = size
+		}
+	}
+	return &WarmPool{
+		Provisioner:    provisioner,
+		Sizes:          normalized,
+		RefillInterval: 10 * time.Second,
+		idle:           map[string][]WarmRuntime{},
+		pending:        map[string]int{},
+		refill:         make(chan struct{}, 1),
+	}
+}
+
+// Start fills the pool and keeps it filled until ctx is done, when the
+// idle runtimes are discarded.
+func (p *WarmPool) Start(ctx context.Context) {
+	registration := p.registerMetrics()
+	go func() {
+		if registration != nil {
+			defer func() { _ = registration.Unregister() }()
+		}
+		ticker := time.NewTicker(p.RefillInterval)
+		defer ticker.Stop()
+		for {
+			p.fill(ctx)
+			select {
+			case <-ctx.Done():
+				p.drain()
+				return
+			case <-p.refill:
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Claim takes an idle runtime for runtime, if one is ready. The caller owns
+// the returned runtime and must discard it if it cannot be used.
+func (p *WarmPool) Claim(ctx context.Context, runtime string) (WarmRuntime, bool) {
+	key := warmPoolKey(runtime)
+	p.mu.Lock()
+	idle := p.idle[key]
+	var warm WarmRuntime
+	ok := len(idle) > 0
+	if ok {
+		warm = idle[0]
+		p.idle[key] = idle[1:]
+	}
+	p.mu.Unlock()
+	if _, pooled := p.Sizes[key]; pooled {
+		recordWarmPoolClaim(ctx, key, p.Provisioner.WarmImage(key), ok)
+	}
+	if ok {
+		select {
+		case p.refill <- struct{}{}:
+		default:
+		}
+	}
+	return warm, ok
+}
+
+// Idle returns how many runtimes are waiting to be claimed for runtime.
+func (p *WarmPool) Idle(runtime string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.idle[warmPoolKey(runtime)])
+}
+
+func (p *WarmPool) fill(ctx context.Context) {
+	for runtime, size := range p.Sizes {
+		p.mu.Lock()
+		missing := size - len(p.idle[runtime]) - p.pending[runtime]
+		if missing > 0 {
+			p.pending[runtime] += missing
+		}
+		p.mu.Unlock()
+		for i := 0; i < missing; i++ {
+			go p.provision(ctx, runtime)
+		}
+	}
+}
+
+func (p *WarmPool) provision(ctx context.Context, runtime string) {
+	warm, err := p.Provisioner.ProvisionWarm(ctx, runtime)
+	p.mu.Lock()
+	p.pending[runtime]--
+	if err == nil && ctx.Err() == nil {
+		p.idle[runtime] = append(p.idle[runtime], warm)
+		p.mu.Unlock()
+		return
+	}
+	p.mu.Unlock()
+	if err != nil {
+		// The next tick retries, so a failing image does not spin.
+		log.Printf("warm pool: provision runtime=%s error: %v", runtime, err)
+		recordWarmPoolFailure(ctx, runtime, p.Provisioner.WarmImage(runtime))
+		return
+	}
+	p.discard(warm)
+}
+
+func (p *WarmPool) drain() {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = map[string][]WarmRuntime{}
+	p.mu.Unlock()
+	for _, runtimes := range idle {
+		for _, warm := range runtimes {
+			p.discard(warm)
+		}
+	}
+}
+
+func (p *WarmPool) discard(warm WarmRuntime) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := p.Provisioner.DiscardWarm(ctx, warm); err != nil {
+		log.Printf("warm pool: discard runtime_id=%s error: %v", warm.ID, err)
+	}
+}
+
+func warmPoolKey(runtime string) string {
+	return strings.ToLower(strings.TrimSpace(runtime))
+}
+
+var (
+	warmPoolMetricsOnce  sync.Once
+	warmPoolMeter        metric.Meter
+	warmPoolIdleGauge    metric.Int64ObservableGauge
+	warmPoolTargetGauge  metric.Int64ObservableGauge
+	warmPoolClaimCounter metric.Int64Counter
+	warmPoolFailures     metric.Int64Counter
+)
+
+func initWarmPoolMetrics() {
+	warmPoolMeter = otel.Meter("data-plane.runtime")
+	warmPoolIdleGauge, _ = warmPoolMeter.Int64ObservableGauge("dataplane.warm_pool.idle")
+	warmPoolTargetGauge, _ = warmPoolMeter.Int64ObservableGauge("dataplane.warm_pool.target")
+	warmPoolClaimCounter, _ = warmPoolMeter.Int64Counter("dataplane.warm_pool.claims")
+	warmPoolFailures, _ = warmPoolMeter.Int64Counter("dataplane.warm_pool.provision_failures")
+}
+
+func (p *WarmPool) registerMetrics() metric.Registration {
+	warmPoolMetricsOnce.Do(initWarmPoolMetrics)
+	if warmPoolIdleGauge == nil || warmPoolTargetGauge == nil {
+		return nil
+	}
+	registration, err := warmPoolMeter.RegisterCallback(func(_ context.Context, observer metric.Observer) error {
+		for runtime, size := range p.Sizes {
+			attrs := metric.WithAttributes(warmPoolAttributes(runtime, p.Provisioner.WarmImage(runtime))...)
+			observer.ObserveInt64(warmPoolIdleGauge, int64(p.Idle(runtime)), attrs)
+			observer.ObserveInt64(warmPoolTargetGauge, int64(size), attrs)
+		}
+		return nil
+	}, warmPoolIdleGauge, warmPoolTargetGauge)
+	if err != nil {
+		log.Printf("warm pool: metrics error: %v", err)
+		return nil
+	}
+	return registration
+}
+
+func recordWarmPoolClaim(ctx context.Context, runtime string, image string, hit bool) {
+	warmPoolMetricsOnce.Do(initWarmPoolMetrics)
+	if warmPoolClaimCounter == nil {
+		return
+	}
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	attrs := append(warmPoolAttributes(runtime, image), attribute.String("result", result))
+	warmPoolClaimCounter.Add(ctx, 1, metric.WithAttributes(attrs...))
+}
+
+func recordWarmPoolFailure(ctx context.Context, runtime string, image string) {
+	warmPoolMetricsOnce.Do(initWarmPoolMetrics)
+	if warmPoolFailures == nil {
+		return
+	}
+	warmPoolFailures.Add(ctx, 1, metric.WithAttributes(warmPoolAttributes(runtime, image)...))
+}
+
+func warmPoolAttributes(runtime string, image string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("runtime", runtime),
+		attribute.String("image", image),
+	}
+}
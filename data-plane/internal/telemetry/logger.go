@@ -2,16 +2,18 @@ package telemetry
 
 import (
 	"context"
+	"log"
 	"time"
 )
 
 type Event struct {
-	TenantID string
-	RunID    string
-	Action   string
-	Outcome  string
-	Detail   string
-	Time     time.Time
+	TenantID  string
+	RunID     string
+	SessionID string
+	Action    string
+	Outcome   string
+	Detail    string
+	Time      time.Time
 }
 
 type Logger interface {
@@ -22,7 +24,7 @@ type StdoutLogger struct{}
 
 func (StdoutLogger) Log(ctx context.Context, event Event) error {
 	_ = ctx
-	_ = event
+	log.Printf("telemetry event action=%s outcome=%s tenant=%s run=%s session=%s detail=%s", event.Action, event.Outcome, event.TenantID, event.RunID, event.SessionID, event.Detail)
 	return nil
 }
 
@@ -1,6 +1,13 @@
 package workspace
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
 
 type SecretPolicy struct {
 	AllowPersist bool
@@ -16,3 +23,42 @@ func MaterializeSecrets(policy SecretPolicy) error {
 	}
 	return nil
 }
+
+var ErrSecretNotFound = errors.New("secret not found")
+
+var secretNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// SecretStore reads secrets from one file per secret in a directory per
+// tenant, Dir/<tenant>/<name>. A secret is handed to a workload as an
+// environment variable of the same name.
+type SecretStore struct {
+	Dir string
+}
+
+// Env returns NAME=value for each secret the tenant has under the given
+// names. Trailing newlines in the files are dropped.
+func (s SecretStore) Env(tenantID string, names []string) ([]string, error) {
+	if !validTenantDir(tenantID) {
+		return nil, fmt.Errorf("invalid secret tenant %q", tenantID)
+	}
+	env := make([]string, 0, len(names))
+	for _, name := range names {
+		if !secretNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid secret name %q", name)
+		}
+		value, err := os.ReadFile(filepath.Join(s.Dir, tenantID, name))
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s", ErrSecretNotFound, name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		env = append(env, name+"="+strings.TrimRight(string(value), "\r\n"))
+	}
+	return env, nil
+}
+
+// validTenantDir reports whether tenantID names a single directory in Dir.
+func validTenantDir(tenantID string) bool {
+	return tenantID != "" && tenantID != "." && tenantID != ".." && !strings.ContainsAny(tenantID, `/\`)
+}
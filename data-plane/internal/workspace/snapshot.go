@@ -0,0 +1,145 @@
+package workspace
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrSnapshotNotFound = errors.New("snapshot not found")
+
+// Snapshot describes a stored session snapshot. Size and Checksum are of
+// the encrypted artifact.
+type Snapshot struct {
+	ID        string    `json:"id"`
+	SessionID string    `json:"sessionId"`
+	Runtime   string    `json:"runtime"`
+	HasState  bool      `json:"hasState"`
+	Size      int64     `json:"size"`
+	Checksum  string    `json:"checksum"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// SnapshotStore keeps encrypted session snapshots as artifacts under Root,
+// each next to a JSON file with its metadata.
+type SnapshotStore struct {
+	Root string
+}
+
+func NewSnapshotID() string {
+	return "snap-" + uuid.NewString()
+}
+
+// Save encrypts the workspace archive with the workspace key and stores it
+// under a new snapshot ID.
+func (s SnapshotStore) Save(snapshot Snapshot, archive []byte) (Snapshot, error) {
+	if s.Root == "" {
+		return Snapshot{}, errors.New("missing snapshot root")
+	}
+	encrypted, err := Encrypt(archive)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	if err := os.MkdirAll(s.Root, 0o750); err != nil {
+		return Snapshot{}, err
+	}
+	snapshot.ID = NewSnapshotID()
+	if snapshot.CreatedAt.IsZero() {
+		snapshot.CreatedAt = time.Now().UTC()
+	}
+	path := s.artifactPath(snapshot.ID)
+	if err := writeFileAtomic(path, encrypted); err != nil {
+		return Snapshot{}, err
+	}
+	artifact, err := CaptureArtifact(path)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	snapshot.Size = artifact.Size
+	snapshot.Checksum = artifact.Checksum
+	meta, err := json.Marshal(snapshot)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	if err := writeFileAtomic(s.metadataPath(snapshot.ID), meta); err != nil {
+		_ = os.Remove(path)
+		return Snapshot{}, err
+	}
+	return snapshot, nil
+}
+
+func (s SnapshotStore) Get(id string) (Snapshot, error) {
+	if !validSnapshotID(id) {
+		return Snapshot{}, ErrSnapshotNotFound
+	}
+	data, err := os.ReadFile(s.metadataPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return Snapshot{}, ErrSnapshotNotFound
+	}
+	if err != nil {
+		return Snapshot{}, err
+	}
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return Snapshot{}, err
+	}
+	return snapshot, nil
+}
+
+// Load returns a snapshot with its decrypted workspace archive.
+func (s SnapshotStore) Load(id string) (Snapshot, []byte, error) {
+	snapshot, err := s.Get(id)
+	if err != nil {
+		return Snapshot{}, nil, err
+	}
+	encrypted, err := os.ReadFile(s.artifactPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return Snapshot{}, nil, ErrSnapshotNotFound
+	}
+	if err != nil {
+		return Snapshot{}, nil, err
+	}
+	archive, err := Decrypt(encrypted)
+	if err != nil {
+		return Snapshot{}, nil, err
+	}
+	return snapshot, archive, nil
+}
+
+func (s SnapshotStore) artifactPath(id string) string {
+	return filepath.Join(s.Root, id+".tar.gz.enc")
+}
+
+func (s SnapshotStore) metadataPath(id string) string {
+	return filepath.Join(s.Root, id+".json")
+}
+
+func validSnapshotID(id string) bool {
+	rest, ok := strings.CutPrefix(id, "snap-")
+	if !ok {
+		return false
+	}
+	_, err := uuid.Parse(rest)
+	return err == nil
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
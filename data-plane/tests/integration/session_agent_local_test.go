@@ -8,15 +8,15 @@ import (
 
 func TestSessionAgentLocalRuntimePersistence(t *testing.T) {
 	runtimeRunner := runtime.NewLocalSessionRuntime()
-	route, err := runtimeRunner.StartSession(nil, "session-local", "", "", "python")
+	route, err := runtimeRunner.StartSession(nil, runtime.SessionSpec{ID: "session-local", Runtime: "python"})
 	if err != nil {
 		t.Fatalf("start session: %v", err)
 	}
-	_, err = runtimeRunner.RunStep(nil, route.RuntimeID, "x = 10")
+	_, err = runtimeRunner.RunStep(nil, route.RuntimeID, "x = 10", 0)
 	if err != nil {
 		t.Fatalf("run step 1: %v", err)
 	}
-	output, err := runtimeRunner.RunStep(nil, route.RuntimeID, "print(x + 5)")
+	output, err := runtimeRunner.RunStep(nil, route.RuntimeID, "print(x + 5)", 0)
 	if err != nil {
 		t.Fatalf("run step 2: %v", err)
 	}
@@ -12,7 +12,7 @@ import (
 
 func TestSessionConcurrency(t *testing.T) {
 	runtimeRunner := runtime.NewLocalSessionRuntime()
-	route, err := runtimeRunner.StartSession(context.Background(), "session-concurrency", "", "", "python")
+	route, err := runtimeRunner.StartSession(context.Background(), runtime.SessionSpec{ID: "session-concurrency", Runtime: "python"})
 	if err != nil {
 		t.Fatalf("start session: %v", err)
 	}
@@ -32,7 +32,7 @@ func TestSessionConcurrency(t *testing.T) {
 		go func() {
 			defer wg.Done()
 			code := fmt.Sprintf("print(%d)", stepID)
-			output, err := runtimeRunner.RunStep(context.Background(), route.RuntimeID, code)
+			output, err := runtimeRunner.RunStep(context.Background(), route.RuntimeID, code, 0)
 			if err != nil {
 				errs <- err
 				return
@@ -0,0 +1,157 @@
+package integration
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"data-plane/internal/runtime"
+	"shared/sessionagent"
+)
+
+func putFile(t *testing.T, url string, content string) (*http.Response, map[string]any) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPut, url, strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	defer resp.Body.Close()
+	var decoded map[string]any
+	_ = json.NewDecoder(resp.Body).Decode(&decoded)
+	return resp, decoded
+}
+
+func TestSessionFilesLocalWorkspace(t *testing.T) {
+	t.Setenv("AUTHZ_BYPASS", "true")
+	t.Setenv("WORKSPACE_ROOT", t.TempDir())
+	sessionRuntime := runtime.NewLocalSessionRuntime()
+	server := httptest.NewServer(runtime.RouterWithDependencies(runtime.Dependencies{
+		SessionHandler: runtime.SessionHandler{Runtime: sessionRuntime, Registry: runtime.NewInMemorySessionRegistry()},
+	}))
+	defer server.Close()
+
+	body, _ := json.Marshal(map[string]any{"sessionId": "session-files", "runtime": "python"})
+	resp, err := http.Post(server.URL+"/sessions", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", resp.StatusCode)
+	}
+	defer http.Post(server.URL+"/sessions/session-files/terminate", "application/json", nil)
+	filesURL := server.URL + "/sessions/session-files/files?path="
+
+	resp, info := putFile(t, filesURL+"data/input.txt", "42")
+	sum := sha256.Sum256([]byte("42"))
+	if resp.StatusCode != http.StatusCreated || info["checksum"] != hex.EncodeToString(sum[:]) || info["size"] != float64(2) {
+		t.Fatalf("unexpected upload %d %v", resp.StatusCode, info)
+	}
+
+	body, _ = json.Marshal(map[string]any{"command": "print(int(open('data/input.txt').read()) + 1)"})
+	resp, err = http.Post(server.URL+"/sessions/session-files/steps", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("run step: %v", err)
+	}
+	var step map[string]any
+	_ = json.NewDecoder(resp.Body).Decode(&step)
+	resp.Body.Close()
+	if strings.TrimSpace(step["stdout"].(string)) != "43" {
+		t.Fatalf("expected the step to read the uploaded file, got %v", step)
+	}
+
+	body, _ = json.Marshal(map[string]any{"command": "open('out.txt', 'w').write('result')"})
+	resp, err = http.Post(server.URL+"/sessions/session-files/steps", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("run step: %v", err)
+	}
+	resp.Body.Close()
+	resp, err = http.Get(filesURL + "out.txt")
+	if err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	content, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	sum = sha256.Sum256([]byte("result"))
+	if resp.StatusCode != http.StatusOK || string(content) != "result" || resp.Header.Get(sessionagent.FileChecksumHeader) != hex.EncodeToString(sum[:]) {
+		t.Fatalf("unexpected download %d %q", resp.StatusCode, content)
+	}
+
+	resp, decoded := putFile(t, filesURL+"../escape.txt", "x")
+	if resp.StatusCode != http.StatusBadRequest || decoded["error"] != "invalid_path" {
+		t.Fatalf("expected traversal to be rejected, got %d %v", resp.StatusCode, decoded)
+	}
+	resp, decoded = putFile(t, filesURL+"big.bin&maxBytes=4", "too large")
+	if resp.StatusCode != http.StatusRequestEntityTooLarge || decoded["error"] != "file_too_large" {
+		t.Fatalf("expected size limit to apply, got %d %v", resp.StatusCode, decoded)
+	}
+	resp, err = http.Get(filesURL + "missing.txt")
+	if err != nil {
+		t.Fatalf("download missing: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", resp.StatusCode)
+	}
+}
+
+func TestSessionFilesProxyAgent(t *testing.T) {
+	t.Setenv("AUTHZ_BYPASS", "true")
+	var uploaded, maxBytes string
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/sessions/session-agent/files" || r.URL.Query().Get("path") != "report.csv" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		maxBytes = r.URL.Query().Get("maxBytes")
+		switch r.Method {
+		case http.MethodPut:
+			data, _ := io.ReadAll(r.Body)
+			uploaded = string(data)
+			w.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(w).Encode(sessionagent.FileInfo{Path: "report.csv", Size: int64(len(data)), Checksum: "abc"})
+		case http.MethodGet:
+			w.Header().Set(sessionagent.FileChecksumHeader, "abc")
+			_, _ = w.Write([]byte("a,b"))
+		}
+	}))
+	defer agent.Close()
+
+	registry := runtime.NewInMemorySessionRegistry()
+	if err := registry.Put("session-agent", runtime.SessionRoute{RuntimeID: "session-agent", Runtime: "python", Endpoint: agent.URL, AuthMode: "bypass"}); err != nil {
+		t.Fatalf("register route: %v", err)
+	}
+	server := httptest.NewServer(runtime.RouterWithDependencies(runtime.Dependencies{
+		SessionHandler: runtime.SessionHandler{
+			Runtime:  runtime.NewLocalSessionRuntime(),
+			Registry: registry,
+			Agent:    runtime.NewAgentClient(),
+		},
+	}))
+	defer server.Close()
+	filesURL := server.URL + "/sessions/session-agent/files?path=report.csv&maxBytes=1024"
+
+	resp, info := putFile(t, filesURL, "a,b")
+	if resp.StatusCode != http.StatusCreated || uploaded != "a,b" || maxBytes != "1024" || info["checksum"] != "abc" {
+		t.Fatalf("expected upload to be proxied, got %d %v uploaded=%q maxBytes=%q", resp.StatusCode, info, uploaded, maxBytes)
+	}
+	resp, err := http.Get(filesURL)
+	if err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	content, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(content) != "a,b" || resp.Header.Get(sessionagent.FileChecksumHeader) != "abc" {
+		t.Fatalf("expected download to be proxied, got %d %q", resp.StatusCode, content)
+	}
+}
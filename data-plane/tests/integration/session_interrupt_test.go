@@ -0,0 +1,131 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"data-plane/internal/runtime"
+	"shared/sessionagent"
+)
+
+func TestSessionStepTimeoutAndInterruptProxyAgent(t *testing.T) {
+	t.Setenv("AUTHZ_BYPASS", "true")
+
+	var stepTimeout int64
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/steps":
+			var req sessionagent.StepRequest
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			stepTimeout = req.TimeoutMs
+			_ = json.NewEncoder(w).Encode(sessionagent.StepResult{StepID: req.StepID, Status: sessionagent.StepStatusTimedOut})
+		case "/v1/sessions/session-agent/interrupt":
+			_ = json.NewEncoder(w).Encode(sessionagent.SessionInterruptResponse{SessionID: "session-agent", Interrupted: true})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer agent.Close()
+
+	registry := runtime.NewInMemorySessionRegistry()
+	if err := registry.Put("session-agent", runtime.SessionRoute{RuntimeID: "session-agent", Runtime: "python", Endpoint: agent.URL, AuthMode: "bypass"}); err != nil {
+		t.Fatalf("register route: %v", err)
+	}
+	if err := registry.Put("session-local", runtime.SessionRoute{RuntimeID: "session-local", Runtime: "python"}); err != nil {
+		t.Fatalf("register route: %v", err)
+	}
+	server := httptest.NewServer(runtime.RouterWithDependencies(runtime.Dependencies{
+		SessionHandler: runtime.SessionHandler{
+			Runtime:     runtime.NewLocalSessionRuntime(),
+			Registry:    registry,
+			Agent:       runtime.NewAgentClient(),
+			AgentPrefer: true,
+		},
+	}))
+	defer server.Close()
+
+	body, _ := json.Marshal(map[string]any{"command": "while True: pass", "timeoutMs": 1500})
+	resp, err := http.Post(server.URL+"/sessions/session-agent/steps", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("run step: %v", err)
+	}
+	var step map[string]any
+	_ = json.NewDecoder(resp.Body).Decode(&step)
+	resp.Body.Close()
+	if stepTimeout != 1500 || step["status"] != sessionagent.StepStatusTimedOut {
+		t.Fatalf("expected timeout to be forwarded, got timeout=%d step=%v", stepTimeout, step)
+	}
+
+	resp, err = http.Post(server.URL+"/sessions/session-agent/interrupt", "application/json", nil)
+	if err != nil {
+		t.Fatalf("interrupt: %v", err)
+	}
+	var interrupt map[string]any
+	_ = json.NewDecoder(resp.Body).Decode(&interrupt)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted || interrupt["interrupted"] != true {
+		t.Fatalf("unexpected interrupt response %d %v", resp.StatusCode, interrupt)
+	}
+
+	resp, err = http.Post(server.URL+"/sessions/session-local/interrupt", "application/json", nil)
+	if err != nil {
+		t.Fatalf("interrupt local: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotImplemented {
+		t.Fatalf("expected local sessions to reject interrupts, got %d", resp.StatusCode)
+	}
+}
+
+func TestLocalSessionStepTimeout(t *testing.T) {
+	t.Setenv("AUTHZ_BYPASS", "true")
+	ctx := context.Background()
+	sessionRuntime := runtime.NewLocalSessionRuntime()
+	if _, err := sessionRuntime.StartSession(ctx, runtime.SessionSpec{ID: "session-timeout", Runtime: "python"}); err != nil {
+		t.Fatalf("start session: %v", err)
+	}
+	defer func() { _ = sessionRuntime.TerminateSession(ctx, "session-timeout") }()
+	if _, err := sessionRuntime.RunStep(ctx, "session-timeout", "x = 1", 0); err != nil {
+		t.Fatalf("setup step: %v", err)
+	}
+
+	registry := runtime.NewInMemorySessionRegistry()
+	if err := registry.Put("session-timeout", runtime.SessionRoute{RuntimeID: "session-timeout", Runtime: "python"}); err != nil {
+		t.Fatalf("register route: %v", err)
+	}
+	server := httptest.NewServer(runtime.RouterWithDependencies(runtime.Dependencies{
+		SessionHandler: runtime.SessionHandler{Runtime: sessionRuntime, Registry: registry},
+	}))
+	defer server.Close()
+	body, _ := json.Marshal(map[string]any{"command": "while True: pass", "timeoutMs": 300})
+	resp, err := http.Post(server.URL+"/sessions/session-timeout/steps", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("run step: %v", err)
+	}
+	var step map[string]any
+	_ = json.NewDecoder(resp.Body).Decode(&step)
+	resp.Body.Close()
+	if step["status"] != sessionagent.StepStatusTimedOut || !strings.Contains(step["stderr"].(string), "KeyboardInterrupt") {
+		t.Fatalf("expected an interrupted step to time out, got %v", step)
+	}
+	output, err := sessionRuntime.RunStep(ctx, "session-timeout", "print(x)", 0)
+	if err != nil || output.Stdout != "1\n" || output.StateLost {
+		t.Fatalf("expected state kept after an interrupted step, got %+v (%v)", output, err)
+	}
+
+	ignoring := "import signal\nsignal.signal(signal.SIGINT, signal.SIG_IGN)\nwhile True: pass"
+	output, err = sessionRuntime.RunStep(ctx, "session-timeout", ignoring, 300*time.Millisecond)
+	if err != nil || !output.TimedOut || !output.StateLost {
+		t.Fatalf("expected a step ignoring the interrupt to restart the interpreter, got %+v (%v)", output, err)
+	}
+	output, err = sessionRuntime.RunStep(ctx, "session-timeout", "print('x' in globals())", 0)
+	if err != nil || output.Stdout != "False\n" || output.StateLost {
+		t.Fatalf("expected a fresh interpreter reporting state loss once, got %+v (%v)", output, err)
+	}
+}
@@ -10,11 +10,11 @@ import (
 
 func TestSessionIsolation(t *testing.T) {
 	runtimeRunner := runtime.NewLocalSessionRuntime()
-	routeA, err := runtimeRunner.StartSession(context.Background(), "session-a", "", "", "python")
+	routeA, err := runtimeRunner.StartSession(context.Background(), runtime.SessionSpec{ID: "session-a", Runtime: "python"})
 	if err != nil {
 		t.Fatalf("start session-a: %v", err)
 	}
-	routeB, err := runtimeRunner.StartSession(context.Background(), "session-b", "", "", "python")
+	routeB, err := runtimeRunner.StartSession(context.Background(), runtime.SessionSpec{ID: "session-b", Runtime: "python"})
 	if err != nil {
 		_ = runtimeRunner.TerminateSession(context.Background(), routeA.RuntimeID)
 		t.Fatalf("start session-b: %v", err)
@@ -28,18 +28,18 @@ func TestSessionIsolation(t *testing.T) {
 		}
 	}()
 
-	if _, err := runtimeRunner.RunStep(context.Background(), routeA.RuntimeID, "x = 1"); err != nil {
+	if _, err := runtimeRunner.RunStep(context.Background(), routeA.RuntimeID, "x = 1", 0); err != nil {
 		t.Fatalf("step session-a: %v", err)
 	}
-	if _, err := runtimeRunner.RunStep(context.Background(), routeB.RuntimeID, "x = 10"); err != nil {
+	if _, err := runtimeRunner.RunStep(context.Background(), routeB.RuntimeID, "x = 10", 0); err != nil {
 		t.Fatalf("step session-b: %v", err)
 	}
 
-	outA, err := runtimeRunner.RunStep(context.Background(), routeA.RuntimeID, "print(x)")
+	outA, err := runtimeRunner.RunStep(context.Background(), routeA.RuntimeID, "print(x)", 0)
 	if err != nil {
 		t.Fatalf("read session-a: %v", err)
 	}
-	outB, err := runtimeRunner.RunStep(context.Background(), routeB.RuntimeID, "print(x)")
+	outB, err := runtimeRunner.RunStep(context.Background(), routeB.RuntimeID, "print(x)", 0)
 	if err != nil {
 		t.Fatalf("read session-b: %v", err)
 	}
@@ -0,0 +1,53 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"data-plane/internal/runtime"
+)
+
+func TestLocalSessionRestartsCrashedInterpreter(t *testing.T) {
+	ctx := context.Background()
+	sessionRuntime := runtime.NewLocalSessionRuntime()
+	if _, err := sessionRuntime.StartSession(ctx, runtime.SessionSpec{ID: "session-crash", Runtime: "python"}); err != nil {
+		t.Fatalf("start session: %v", err)
+	}
+	defer func() { _ = sessionRuntime.TerminateSession(ctx, "session-crash") }()
+
+	if _, err := sessionRuntime.RunStep(ctx, "session-crash", "x = 1", 0); err != nil {
+		t.Fatalf("setup step: %v", err)
+	}
+	if _, err := sessionRuntime.RunStep(ctx, "session-crash", "import os\nos._exit(7)", 0); !errors.Is(err, runtime.ErrRuntimeExited) {
+		t.Fatalf("expected interpreter exit error, got %v", err)
+	}
+	output, err := sessionRuntime.RunStep(ctx, "session-crash", "print('x' in globals())", 0)
+	if err != nil {
+		t.Fatalf("step after crash: %v", err)
+	}
+	if !output.StateLost || output.Stdout != "False\n" {
+		t.Fatalf("expected restarted interpreter to report lost state, got %+v", output)
+	}
+	output, err = sessionRuntime.RunStep(ctx, "session-crash", "print(1)", 0)
+	if err != nil || output.StateLost {
+		t.Fatalf("expected state loss to be reported once, got %+v %v", output, err)
+	}
+}
+
+func TestLocalSessionWithoutRestartReportsExit(t *testing.T) {
+	ctx := context.Background()
+	sessionRuntime := runtime.NewLocalSessionRuntime()
+	sessionRuntime.RestartCrashed = false
+	if _, err := sessionRuntime.StartSession(ctx, runtime.SessionSpec{ID: "session-exit", Runtime: "python"}); err != nil {
+		t.Fatalf("start session: %v", err)
+	}
+	defer func() { _ = sessionRuntime.TerminateSession(ctx, "session-exit") }()
+
+	if _, err := sessionRuntime.RunStep(ctx, "session-exit", "import os\nos._exit(1)", 0); !errors.Is(err, runtime.ErrRuntimeExited) {
+		t.Fatalf("expected interpreter exit error, got %v", err)
+	}
+	if _, err := sessionRuntime.RunStep(ctx, "session-exit", "print(1)", 0); !errors.Is(err, runtime.ErrRuntimeExited) {
+		t.Fatalf("expected later steps to keep failing, got %v", err)
+	}
+}
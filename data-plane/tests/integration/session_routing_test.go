@@ -21,7 +21,6 @@ func TestSessionRoutingIsolation(t *testing.T) {
 	runHandler := runtime.RunHandler{
 		Runner: execution.Runner{
 			Registry: runtime.DefaultRegistry(),
-			Deps:     runtime.DependencyPolicy{},
 		},
 	}
 
@@ -72,7 +71,7 @@ func TestSessionRoutingIsolation(t *testing.T) {
 	if err != nil {
 		t.Fatalf("read session-1: %v", err)
 	}
-	var output map[string]string
+	var output map[string]any
 	if err := json.NewDecoder(resp.Body).Decode(&output); err != nil {
 		t.Fatalf("decode session-1: %v", err)
 	}
@@ -85,7 +84,7 @@ func TestSessionRoutingIsolation(t *testing.T) {
 	if err != nil {
 		t.Fatalf("read session-2: %v", err)
 	}
-	output = map[string]string{}
+	output = map[string]any{}
 	if err := json.NewDecoder(resp.Body).Decode(&output); err != nil {
 		t.Fatalf("decode session-2: %v", err)
 	}
@@ -22,7 +22,6 @@ func TestSessionRunnerLocal(t *testing.T) {
 	runHandler := runtime.RunHandler{
 		Runner: execution.Runner{
 			Registry: runtime.DefaultRegistry(),
-			Deps:     runtime.DependencyPolicy{},
 		},
 	}
 
@@ -64,7 +63,7 @@ func TestSessionRunnerLocal(t *testing.T) {
 	if resp.StatusCode != http.StatusAccepted {
 		t.Fatalf("expected %d, got %d", http.StatusAccepted, resp.StatusCode)
 	}
-	var stepResp map[string]string
+	var stepResp map[string]any
 	if err := json.NewDecoder(resp.Body).Decode(&stepResp); err != nil {
 		t.Fatalf("decode step response: %v", err)
 	}
@@ -74,8 +73,11 @@ func TestSessionRunnerLocal(t *testing.T) {
 	if stepResp["stderr"] == "" {
 		t.Fatalf("expected stderr")
 	}
-	if stepResp["status"] != "accepted" {
-		t.Fatalf("expected accepted status")
+	if stepResp["status"] != "completed" {
+		t.Fatalf("expected completed status")
+	}
+	if _, ok := stepResp["resourceUsage"].(map[string]any); !ok {
+		t.Fatalf("expected resource usage, got %v", stepResp["resourceUsage"])
 	}
 	_ = resp.Body.Close()
 
@@ -103,11 +105,11 @@ func TestSessionRunnerLocal(t *testing.T) {
 	if resp.StatusCode != http.StatusAccepted {
 		t.Fatalf("expected %d, got %d", http.StatusAccepted, resp.StatusCode)
 	}
-	stepResp = map[string]string{}
+	stepResp = map[string]any{}
 	if err := json.NewDecoder(resp.Body).Decode(&stepResp); err != nil {
 		t.Fatalf("decode step response: %v", err)
 	}
-	if !strings.Contains(stepResp["stdout"], "42") {
+	if stdout, _ := stepResp["stdout"].(string); !strings.Contains(stdout, "42") {
 		t.Fatalf("expected stdout to contain 42, got %q", stepResp["stdout"])
 	}
 	resp.Body.Close()
@@ -0,0 +1,105 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"data-plane/internal/runtime"
+	"data-plane/internal/workspace"
+)
+
+func postJSON(t *testing.T, url string, payload any) (*http.Response, map[string]any) {
+	t.Helper()
+	body, _ := json.Marshal(payload)
+	resp, err := http.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("post %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	var decoded map[string]any
+	_ = json.NewDecoder(resp.Body).Decode(&decoded)
+	return resp, decoded
+}
+
+func TestSessionSnapshotRestoreLocal(t *testing.T) {
+	t.Setenv("AUTHZ_BYPASS", "true")
+	t.Setenv("WORKSPACE_ROOT", t.TempDir())
+	t.Setenv("WORKSPACE_KEY_B64", base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32)))
+	snapshotRoot := t.TempDir()
+	server := httptest.NewServer(runtime.RouterWithDependencies(runtime.Dependencies{
+		SessionHandler: runtime.SessionHandler{
+			Runtime:   runtime.NewLocalSessionRuntime(),
+			Registry:  runtime.NewInMemorySessionRegistry(),
+			Snapshots: &workspace.SnapshotStore{Root: snapshotRoot},
+		},
+	}))
+	defer server.Close()
+
+	if resp, _ := postJSON(t, server.URL+"/sessions", map[string]any{"sessionId": "session-source", "runtime": "python"}); resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", resp.StatusCode)
+	}
+	defer http.Post(server.URL+"/sessions/session-source/terminate", "application/json", nil)
+	postJSON(t, server.URL+"/sessions/session-source/steps", map[string]any{"command": "x = 41\nopen('notes.txt', 'w').write('kept')"})
+
+	resp, snapshot := postJSON(t, server.URL+"/sessions/session-source/snapshot", map[string]any{"includeState": true})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %v", resp.StatusCode, snapshot)
+	}
+	snapshotID, _ := snapshot["id"].(string)
+	if snapshotID == "" || snapshot["sessionId"] != "session-source" || snapshot["hasState"] != true || snapshot["checksum"] == "" {
+		t.Fatalf("unexpected snapshot %v", snapshot)
+	}
+	stored, err := os.ReadFile(filepath.Join(snapshotRoot, snapshotID+".tar.gz.enc"))
+	if err != nil || bytes.HasPrefix(stored, []byte{0x1f, 0x8b}) {
+		t.Fatalf("expected an encrypted snapshot artifact (%v)", err)
+	}
+	get, err := http.Get(server.URL + "/snapshots/" + snapshotID)
+	if err != nil {
+		t.Fatalf("get snapshot: %v", err)
+	}
+	get.Body.Close()
+	if get.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", get.StatusCode)
+	}
+
+	resp, _ = postJSON(t, server.URL+"/sessions", map[string]any{"sessionId": "session-restored", "runtime": "python", "snapshotId": snapshotID})
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("expected 202 restoring, got %d", resp.StatusCode)
+	}
+	defer http.Post(server.URL+"/sessions/session-restored/terminate", "application/json", nil)
+	_, step := postJSON(t, server.URL+"/sessions/session-restored/steps", map[string]any{"command": "print(x + 1, open('notes.txt').read())"})
+	if stdout, _ := step["stdout"].(string); strings.TrimSpace(stdout) != "42 kept" {
+		t.Fatalf("expected restored state and workspace, got %v", step)
+	}
+	files, err := http.Get(server.URL + "/sessions/session-restored/files?path=.session-state.pkl")
+	if err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	io.Copy(io.Discard, files.Body)
+	files.Body.Close()
+	if files.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected the state file to be cleaned up, got %d", files.StatusCode)
+	}
+
+	cases := []struct {
+		payload map[string]any
+		status  int
+	}{
+		{map[string]any{"sessionId": "session-node", "runtime": "node", "snapshotId": snapshotID}, http.StatusBadRequest},
+		{map[string]any{"sessionId": "session-missing", "runtime": "python", "snapshotId": "snap-00000000-0000-0000-0000-000000000000"}, http.StatusNotFound},
+		{map[string]any{"sessionId": "session-bad", "runtime": "python", "snapshotId": "../etc/passwd"}, http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		if resp, body := postJSON(t, server.URL+"/sessions", tc.payload); resp.StatusCode != tc.status {
+			t.Fatalf("expected %d for %v, got %d: %v", tc.status, tc.payload, resp.StatusCode, body)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"data-plane/internal/runtime"
+	"shared/sessionagent"
+)
+
+func TestSessionStepStreamLocal(t *testing.T) {
+	t.Setenv("AUTHZ_BYPASS", "true")
+
+	sessionRuntime := runtime.NewLocalSessionRuntime()
+	handler := runtime.RouterWithDependencies(runtime.Dependencies{
+		SessionHandler: runtime.SessionHandler{Runtime: sessionRuntime, Registry: runtime.NewInMemorySessionRegistry()},
+	})
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	body, _ := json.Marshal(map[string]string{"sessionId": "session-stream", "runtime": "python"})
+	resp, err := http.Post(server.URL+"/sessions", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	resp.Body.Close()
+	defer func() { _ = sessionRuntime.TerminateSession(context.Background(), "session-stream") }()
+
+	body, _ = json.Marshal(map[string]string{"command": "import time\nprint('tick', flush=True)\ntime.sleep(0.5)\nprint('tock')"})
+	start := time.Now()
+	resp, err = http.Post(server.URL+"/sessions/session-stream/steps/stream", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("stream step: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("unexpected response %d %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	var stdout strings.Builder
+	var firstAt time.Duration
+	result, err := sessionagent.ReadStepEvents(resp.Body, func(event sessionagent.StepEvent) error {
+		if event.Type == sessionagent.StepEventStdout {
+			if stdout.Len() == 0 {
+				firstAt = time.Since(start)
+			}
+			stdout.WriteString(event.Data)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("read events: %v", err)
+	}
+	if firstAt >= 500*time.Millisecond {
+		t.Fatalf("expected output before the step finished, first chunk after %s", firstAt)
+	}
+	if stdout.String() != "tick\ntock\n" || result.Status != sessionagent.StepStatusCompleted {
+		t.Fatalf("unexpected stream stdout=%q result=%+v", stdout.String(), result)
+	}
+}
+
+func TestSessionStepStreamProxiesAgent(t *testing.T) {
+	t.Setenv("AUTHZ_BYPASS", "true")
+
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/v1/steps/") || !strings.HasSuffix(r.URL.Path, "/stream") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var req sessionagent.StepRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set("Content-Type", "text/event-stream")
+		_ = sessionagent.WriteStepEvent(w, sessionagent.StepEvent{Type: sessionagent.StepEventStdout, Data: "from agent\n"})
+		_ = sessionagent.WriteStepEvent(w, sessionagent.StepEvent{Type: sessionagent.StepEventStatus, Result: &sessionagent.StepResult{StepID: req.StepID, Status: sessionagent.StepStatusCompleted}})
+	}))
+	defer agent.Close()
+
+	registry := runtime.NewInMemorySessionRegistry()
+	if err := registry.Put("session-agent", runtime.SessionRoute{RuntimeID: "session-agent", Runtime: "python", Endpoint: agent.URL, AuthMode: "bypass"}); err != nil {
+		t.Fatalf("register route: %v", err)
+	}
+	handler := runtime.RouterWithDependencies(runtime.Dependencies{
+		SessionHandler: runtime.SessionHandler{
+			Runtime:     runtime.NewLocalSessionRuntime(),
+			Registry:    registry,
+			Agent:       runtime.NewAgentClient(),
+			AgentPrefer: true,
+		},
+	})
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	body, _ := json.Marshal(map[string]string{"command": "print('x')"})
+	resp, err := http.Post(server.URL+"/sessions/session-agent/steps/stream", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("stream step: %v", err)
+	}
+	defer resp.Body.Close()
+	var chunks []string
+	result, err := sessionagent.ReadStepEvents(resp.Body, func(event sessionagent.StepEvent) error {
+		chunks = append(chunks, event.Type+":"+event.Data)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("read events: %v", err)
+	}
+	if strings.Join(chunks, ",") != "stdout:from agent\n,status:" || !strings.HasPrefix(result.StepID, "step-") {
+		t.Fatalf("unexpected proxied stream %q result=%+v", chunks, result)
+	}
+}
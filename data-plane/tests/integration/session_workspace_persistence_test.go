@@ -13,7 +13,7 @@ import (
 func TestSessionWorkspacePersistence(t *testing.T) {
 	workspaceRoot := t.TempDir()
 	runtimeRunner := runtime.NewLocalSessionRuntime()
-	route, err := runtimeRunner.StartSession(context.Background(), "session-workspace", "", workspaceRoot, "python")
+	route, err := runtimeRunner.StartSession(context.Background(), runtime.SessionSpec{ID: "session-workspace", WorkspaceRef: workspaceRoot, Runtime: "python"})
 	if err != nil {
 		t.Fatalf("start session: %v", err)
 	}
@@ -25,11 +25,11 @@ func TestSessionWorkspacePersistence(t *testing.T) {
 
 	filePath := filepath.Join(workspaceRoot, "note.txt")
 	writeCode := fmt.Sprintf("with open(%q, 'w') as f:\n    f.write('hello')", filePath)
-	if _, err := runtimeRunner.RunStep(context.Background(), route.RuntimeID, writeCode); err != nil {
+	if _, err := runtimeRunner.RunStep(context.Background(), route.RuntimeID, writeCode, 0); err != nil {
 		t.Fatalf("write step: %v", err)
 	}
 	readCode := fmt.Sprintf("print(open(%q).read())", filePath)
-	output, err := runtimeRunner.RunStep(context.Background(), route.RuntimeID, readCode)
+	output, err := runtimeRunner.RunStep(context.Background(), route.RuntimeID, readCode, 0)
 	if err != nil {
 		t.Fatalf("read step: %v", err)
 	}
@@ -0,0 +1,67 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"data-plane/internal/runtime"
+	"shared/sessionagent"
+)
+
+func TestAgentClientWaitForReadyUsesStatus(t *testing.T) {
+	var polls atomic.Int32
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/status" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("X-Agent-Token") != "admin-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		status := sessionagent.AgentStatus{MaxSessions: 1, Sessions: []sessionagent.SessionStatus{{SessionID: "busy"}}}
+		// The agent is full until its third status report.
+		if polls.Add(1) >= 3 {
+			status.Sessions = nil
+		}
+		_ = json.NewEncoder(w).Encode(status)
+	}))
+	defer agent.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	client := runtime.NewAgentClient()
+	client.AdminToken = "admin-token"
+	if err := client.WaitForReady(ctx, agent.URL, 10*time.Millisecond); err != nil {
+		t.Fatalf("wait for ready: %v", err)
+	}
+	if polls.Load() < 3 {
+		t.Fatalf("expected to wait for a free slot, polled %d times", polls.Load())
+	}
+	status, err := client.Status(ctx, agent.URL)
+	if err != nil || len(status.Sessions) != 0 || !status.HasCapacity() {
+		t.Fatalf("unexpected status %+v (%v)", status, err)
+	}
+}
+
+func TestAgentClientWaitForReadyFallsBackToHealth(t *testing.T) {
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/health" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer agent.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := runtime.NewAgentClient().WaitForReady(ctx, agent.URL, 10*time.Millisecond); err != nil {
+		t.Fatalf("expected agents without /v1/status to pass on health: %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+
+	"data-plane/internal/runtime"
+	"shared/sessionagent"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func sharedAgentPod(name string, ip string, tenant string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			Labels:    map[string]string{"app": "sandbox-session", "runtime": "python", "agent_mode": "shared", "tenant": tenant},
+		},
+		Status: corev1.PodStatus{
+			Phase:      corev1.PodRunning,
+			PodIP:      ip,
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+		},
+	}
+}
+
+func TestKubernetesSessionRuntimePacksSharedAgents(t *testing.T) {
+	// Agent endpoints are always on port 9000; both pods resolve to this
+	// listener through different loopback addresses.
+	listener, err := net.Listen("tcp", ":9000")
+	if err != nil {
+		t.Skipf("port 9000 unavailable: %v", err)
+	}
+	var mu sync.Mutex
+	hosts := map[string]int{}
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/status" {
+			_ = json.NewEncoder(w).Encode(sessionagent.AgentStatus{MaxSessions: 2})
+			return
+		}
+		mu.Lock()
+		hosts[r.Host]++
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	})}
+	go func() { _ = server.Serve(listener) }()
+	defer server.Close()
+
+	// agent-0 has room but belongs to another tenant.
+	client := fake.NewSimpleClientset(
+		sharedAgentPod("agent-0", "127.0.0.3", "tenant-b"),
+		sharedAgentPod("agent-a", "127.0.0.1", "tenant-a"),
+		sharedAgentPod("agent-b", "127.0.0.2", "tenant-a"),
+	)
+	registry := runtime.NewInMemorySessionRegistry()
+	for _, id := range []string{"s1", "s2"} {
+		if err := registry.Put(id, runtime.SessionRoute{RuntimeID: "agent-a/" + id, Runtime: "python", Endpoint: "http://127.0.0.1:9000"}); err != nil {
+			t.Fatalf("put route: %v", err)
+		}
+	}
+	r := runtime.KubernetesSessionRuntime{
+		Client:        client,
+		Namespace:     "default",
+		AgentAuthMode: "bypass",
+		Packing:       runtime.NewAgentPacking(2, registry),
+	}
+	ctx := context.Background()
+
+	route, err := r.StartSession(ctx, runtime.SessionSpec{ID: "s3", Runtime: "python", TenantID: "tenant-a"})
+	if err != nil {
+		t.Fatalf("start session: %v", err)
+	}
+	if route.RuntimeID != "agent-b/s3" || route.Endpoint != "http://127.0.0.2:9000" {
+		t.Fatalf("expected the session on the agent with room, got %+v", route)
+	}
+	mu.Lock()
+	registered := hosts["127.0.0.2:9000"]
+	mu.Unlock()
+	if registered != 1 {
+		t.Fatalf("expected one registration with agent-b, got %v", hosts)
+	}
+
+	if err := r.TerminateSession(ctx, "agent-a/s1"); err != nil {
+		t.Fatalf("terminate s1: %v", err)
+	}
+	if _, err := client.CoreV1().Pods("default").Get(ctx, "agent-a", metav1.GetOptions{}); err != nil {
+		t.Fatalf("expected agent-a to stay while s2 runs on it: %v", err)
+	}
+	if err := r.TerminateSession(ctx, "agent-b/s3"); err != nil {
+		t.Fatalf("terminate s3: %v", err)
+	}
+	if _, err := client.CoreV1().Pods("default").Get(ctx, "agent-b", metav1.GetOptions{}); err == nil {
+		t.Fatalf("expected agent-b to be deleted with its last session")
+	}
+}
@@ -0,0 +1,148 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"data-plane/internal/runtime"
+	"shared/sessionagent"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// podProvisioner creates warm pods in a fake cluster whose agent is served
+// by endpoint.
+type podProvisioner struct {
+	client   *fake.Clientset
+	endpoint string
+
+	mu        sync.Mutex
+	created   int
+	discarded []string
+}
+
+func (p *podProvisioner) ProvisionWarm(ctx context.Context, lang string) (runtime.WarmRuntime, error) {
+	p.mu.Lock()
+	p.created++
+	name := fmt.Sprintf("session-warm-%d", p.created)
+	p.mu.Unlock()
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:      name,
+		Namespace: "default",
+		Labels:    map[string]string{"app": "sandbox-session", "warm_pool": "idle"},
+	}}
+	if _, err := p.client.CoreV1().Pods("default").Create(ctx, pod, metav1.CreateOptions{}); err != nil {
+		return runtime.WarmRuntime{}, err
+	}
+	return runtime.WarmRuntime{ID: name, Runtime: lang, Endpoint: p.endpoint}, nil
+}
+
+func (p *podProvisioner) DiscardWarm(ctx context.Context, warm runtime.WarmRuntime) error {
+	p.mu.Lock()
+	p.discarded = append(p.discarded, warm.ID)
+	p.mu.Unlock()
+	return nil
+}
+
+func (p *podProvisioner) WarmImage(lang string) string {
+	return "image-" + lang
+}
+
+func waitForIdle(t *testing.T, pool *runtime.WarmPool, lang string, want int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for pool.Idle(lang) != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d idle %s runtimes, got %d", want, lang, pool.Idle(lang))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWarmPoolFillsAndRefills(t *testing.T) {
+	provisioner := &podProvisioner{client: fake.NewSimpleClientset()}
+	pool := runtime.NewWarmPool(provisioner, map[string]int{"python": 2})
+	ctx, cancel := context.WithCancel(context.Background())
+	pool.Start(ctx)
+	waitForIdle(t, pool, "python", 2)
+
+	warm, ok := pool.Claim(ctx, "python")
+	if !ok || warm.ID == "" {
+		t.Fatalf("expected a warm runtime, got %+v", warm)
+	}
+	waitForIdle(t, pool, "python", 2)
+	if _, ok := pool.Claim(ctx, "node"); ok {
+		t.Fatalf("expected no warm runtime for an unpooled runtime")
+	}
+
+	cancel()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		provisioner.mu.Lock()
+		discarded := len(provisioner.discarded)
+		provisioner.mu.Unlock()
+		if discarded == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected idle runtimes to be discarded on shutdown, got %d", discarded)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestKubernetesSessionRuntimeClaimsWarmPod(t *testing.T) {
+	var registered sessionagent.SessionRegisterRequest
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/sessions" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewDecoder(r.Body).Decode(&registered)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer agent.Close()
+
+	client := fake.NewSimpleClientset()
+	provisioner := &podProvisioner{client: client, endpoint: agent.URL}
+	pool := runtime.NewWarmPool(provisioner, map[string]int{"python": 1})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pool.Start(ctx)
+	waitForIdle(t, pool, "python", 1)
+
+	r := runtime.KubernetesSessionRuntime{
+		Client:        client,
+		Namespace:     "default",
+		AgentAuthMode: "bypass",
+		Pool:          pool,
+	}
+	route, err := r.StartSession(ctx, runtime.SessionSpec{ID: "sess-1", Runtime: "python"})
+	if err != nil {
+		t.Fatalf("start session: %v", err)
+	}
+	if route.RuntimeID != "session-warm-1" || route.Endpoint != agent.URL {
+		t.Fatalf("expected the warm pod route, got %+v", route)
+	}
+	if registered.SessionID != "sess-1" || registered.WorkspaceDir != "/workspace/sess-1" {
+		t.Fatalf("unexpected registration: %+v", registered)
+	}
+	pod, err := client.CoreV1().Pods("default").Get(ctx, route.RuntimeID, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get pod: %v", err)
+	}
+	if pod.Labels["session_id"] != "sess-1" {
+		t.Fatalf("expected claimed pod to be labelled with the session, got %v", pod.Labels)
+	}
+	if _, ok := pod.Labels["warm_pool"]; ok {
+		t.Fatalf("expected claimed pod to leave the warm pool, got %v", pod.Labels)
+	}
+}
@@ -0,0 +1,97 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"data-plane/internal/isolation"
+	"data-plane/internal/runtime"
+
+	corev1 "k8s.io/api/core/v1"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestKubernetesSessionRuntimeAppliesPolicy(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	var mu sync.Mutex
+	var created *corev1.Pod
+	client.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, k8sruntime.Object, error) {
+		mu.Lock()
+		created = action.(k8stesting.CreateAction).GetObject().(*corev1.Pod).DeepCopy()
+		mu.Unlock()
+		return false, nil, nil
+	})
+	r := runtime.KubernetesSessionRuntime{
+		Client:         client,
+		Namespace:      "default",
+		RuntimeClass:   "runc",
+		RuntimeClasses: map[string]string{"gvisor": "gvisor-rc"},
+		AgentAuthMode:  "bypass",
+	}
+	// The pod never becomes ready; only its spec matters here.
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	_, _ = r.StartSession(ctx, runtime.SessionSpec{ID: "s1", Runtime: "python", Policy: runtime.WorkloadPolicy{
+		RuntimeClass: "gvisor",
+		Limits:       isolation.Limits{CPU: 500, Memory: 256 << 20},
+	}})
+	mu.Lock()
+	pod := created
+	mu.Unlock()
+	if pod == nil {
+		t.Fatalf("expected a session pod to be created")
+	}
+	if pod.Spec.RuntimeClassName == nil || *pod.Spec.RuntimeClassName != "gvisor-rc" {
+		t.Fatalf("expected the mapped runtime class, got %v", pod.Spec.RuntimeClassName)
+	}
+	limits := pod.Spec.Containers[0].Resources.Limits
+	if limits.Cpu().MilliValue() != 500 || limits.Memory().Value() != 256<<20 {
+		t.Fatalf("expected policy limits on the container, got %v", limits)
+	}
+
+	for _, tc := range []struct {
+		policy runtime.WorkloadPolicy
+		want   error
+	}{
+		{runtime.WorkloadPolicy{RuntimeClass: "firecracker"}, runtime.ErrRuntimeClassUnsupported},
+		{runtime.WorkloadPolicy{EgressAllowList: []string{}}, runtime.ErrEgressUnenforced},
+		{runtime.WorkloadPolicy{Secrets: []string{"API_TOKEN"}}, runtime.ErrSecretsUnsupported},
+	} {
+		if _, err := r.StartSession(context.Background(), runtime.SessionSpec{ID: "s2", Runtime: "python", Policy: tc.policy}); !errors.Is(err, tc.want) {
+			t.Fatalf("expected %v for %+v, got %v", tc.want, tc.policy, err)
+		}
+	}
+}
+
+func TestRunHandlerRefusesUnenforceablePolicy(t *testing.T) {
+	handler := runtime.RunHandler{Enforcement: runtime.Enforcement{Egress: true}}
+	body := `{"jobId":"job-1","language":"python","code":"print(1)","limits":{"memoryBytes":1048576}}`
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/runs", strings.NewReader(body)))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 without a cgroup limiter, got %d", rec.Code)
+	}
+	var resp struct {
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil || resp.Error != "policy_unenforceable" {
+		t.Fatalf("expected policy_unenforceable, got %q (%v)", resp.Error, err)
+	}
+
+	handler.Enforcement.Limits = true
+	body = `{"jobId":"job-1","language":"python","code":"print(1)","limits":{"diskBytes":1048576}}`
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/runs", strings.NewReader(body)))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for a disk limit cgroups cannot enforce, got %d", rec.Code)
+	}
+}
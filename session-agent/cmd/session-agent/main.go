@@ -18,26 +18,56 @@ func main() {
 		log.Fatalf("config error: %v", err)
 	}
 	logger := telemetry.NewLogger("session-agent")
+	metrics := telemetry.NewMetrics()
 	runner := runtime.NewRunner()
+	runner.Logger = logger
+	runner.Metrics = metrics
+	metrics.SessionsGauge(runner.SessionCount)
+	runner.RestartCrashed = cfg.RestartREPL
+	runner.MaxSessions = cfg.MaxSessions
+	runner.WorkspaceRoot = cfg.WorkspaceRoot
+	runner.Isolation = runtime.SessionIsolation{
+		UIDBase:    cfg.UIDBase,
+		CgroupRoot: cfg.CgroupRoot,
+	}
+	if cfg.CgroupRoot != "" {
+		runner.Isolation.CPUMillicores = cfg.CPUMillicores
+		runner.Isolation.MemoryBytes = int64(cfg.MemoryMB) << 20
+		runner.Isolation.PIDs = cfg.PIDsMax
+	}
+	if err := runner.Isolation.Prepare(); err != nil {
+		log.Fatalf("session isolation error: %v", err)
+	}
 	requireToken := !cfg.AuthBypass
-	stepHandler := handlers.StepHandler{Runner: runner, RequireToken: requireToken}
+	stepHandler := handlers.StepHandler{Runner: runner, RequireToken: requireToken, Logger: logger}
 	sessionHandler := handlers.SessionHandler{Runner: runner, RequireToken: requireToken}
 
-	var authMiddleware func(http.Handler) http.Handler
+	var authMiddleware, adminMiddleware func(http.Handler) http.Handler
 	if cfg.AuthBypass {
 		authMiddleware = middleware.AuthBypassMiddleware
+		adminMiddleware = middleware.AuthBypassMiddleware
 	} else {
 		authMiddleware = middleware.RequireTokenMiddleware
+		adminMiddleware = middleware.RequireAgentTokenMiddleware(cfg.AdminToken)
 	}
 
 	router := api.NewRouter(api.RouterDeps{
 		StepsHandler:            stepHandler,
+		StepStreamHandler:       http.HandlerFunc(stepHandler.Stream),
 		SessionsHandler:         http.HandlerFunc(sessionHandler.Register),
 		SessionTerminateHandler: http.HandlerFunc(sessionHandler.Terminate),
+		SessionInterruptHandler: http.HandlerFunc(sessionHandler.Interrupt),
+		FileUploadHandler:       http.HandlerFunc(sessionHandler.Upload),
+		FileDownloadHandler:     http.HandlerFunc(sessionHandler.Download),
+		SnapshotHandler:         http.HandlerFunc(sessionHandler.Snapshot),
+		RestoreHandler:          http.HandlerFunc(sessionHandler.Restore),
+		StatusHandler:           handlers.StatusHandler{Runner: runner},
+		MetricsHandler:          metrics.Handler(),
 		AuthMiddleware:          authMiddleware,
+		AdminMiddleware:         adminMiddleware,
 	})
 
-	logger.Info("starting server")
+	logger.Info("starting server", "addr", cfg.ListenAddr, "max_sessions", cfg.MaxSessions)
 	if err := http.ListenAndServe(cfg.ListenAddr, router); err != nil {
 		log.Fatalf("server error: %v", err)
 	}
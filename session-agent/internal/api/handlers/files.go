@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"session-agent/internal/api/middleware"
+	"session-agent/internal/runtime"
+	"shared/sessionagent"
+)
+
+// Upload streams the request body into the session workspace at the path
+// given by the "path" query parameter.
+func (h SessionHandler) Upload(w http.ResponseWriter, r *http.Request) {
+	sessionID, ok := h.fileSession(w, r)
+	if !ok {
+		return
+	}
+	maxBytes, err := maxBytesParam(r)
+	if err != nil {
+		http.Error(w, "invalid maxBytes", http.StatusBadRequest)
+		return
+	}
+	if maxBytes > 0 && r.ContentLength > maxBytes {
+		http.Error(w, sessionagent.ErrFileTooLarge.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	info, err := h.Runner.WriteFile(sessionID, r.URL.Query().Get("path"), r.Body, maxBytes)
+	if err != nil {
+		writeFileError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(info)
+}
+
+// Download streams a workspace file back with its SHA-256 checksum in the
+// X-Checksum-Sha256 header.
+func (h SessionHandler) Download(w http.ResponseWriter, r *http.Request) {
+	sessionID, ok := h.fileSession(w, r)
+	if !ok {
+		return
+	}
+	maxBytes, err := maxBytesParam(r)
+	if err != nil {
+		http.Error(w, "invalid maxBytes", http.StatusBadRequest)
+		return
+	}
+	file, info, err := h.Runner.OpenFile(sessionID, r.URL.Query().Get("path"))
+	if err != nil {
+		writeFileError(w, err)
+		return
+	}
+	defer file.Close()
+	if maxBytes > 0 && info.Size > maxBytes {
+		writeFileError(w, sessionagent.ErrFileTooLarge)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+	w.Header().Set(sessionagent.FileChecksumHeader, info.Checksum)
+	w.WriteHeader(http.StatusOK)
+	_, _ = io.Copy(w, file)
+}
+
+func (h SessionHandler) fileSession(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if h.Runner != nil && r.URL.Query().Get("path") == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return "", false
+	}
+	return h.authorizedSession(w, r)
+}
+
+// authorizedSession returns the addressed session ID once the request's
+// session token, when required, has been checked.
+func (h SessionHandler) authorizedSession(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if h.Runner == nil {
+		w.WriteHeader(http.StatusNotImplemented)
+		return "", false
+	}
+	sessionID := chi.URLParam(r, "sessionId")
+	if sessionID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return "", false
+	}
+	if h.RequireToken {
+		token := middleware.TokenFromRequest(r)
+		if err := h.Runner.Authorize(sessionID, token); err != nil {
+			http.Error(w, "invalid session token", http.StatusUnauthorized)
+			return "", false
+		}
+	}
+	return sessionID, true
+}
+
+func maxBytesParam(r *http.Request) (int64, error) {
+	value := r.URL.Query().Get(sessionagent.FileMaxBytesParam)
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+func writeFileError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, runtime.ErrSessionNotRegistered):
+		http.Error(w, "session not registered", http.StatusNotFound)
+	case errors.Is(err, sessionagent.ErrInvalidFilePath):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, sessionagent.ErrFileTooLarge):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case errors.Is(err, sessionagent.ErrWorkspaceUnavailable):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, os.ErrNotExist):
+		http.Error(w, "file not found", http.StatusNotFound)
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
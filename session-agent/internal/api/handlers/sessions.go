@@ -51,6 +51,18 @@ func (h SessionHandler) Register(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "session runtime mismatch", http.StatusConflict)
 			return
 		}
+		if errors.Is(err, runtime.ErrSessionExists) {
+			http.Error(w, "session already registered", http.StatusConflict)
+			return
+		}
+		if errors.Is(err, runtime.ErrWorkspaceOutsideRoot) {
+			http.Error(w, "workspace must be inside the workspace root", http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, runtime.ErrAgentAtCapacity) {
+			http.Error(w, "session agent is at capacity", http.StatusServiceUnavailable)
+			return
+		}
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -62,6 +74,42 @@ func (h SessionHandler) Register(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// Interrupt sends SIGINT to the step running in a session; an unresponsive
+// REPL is restarted by the step itself once the grace period passes.
+func (h SessionHandler) Interrupt(w http.ResponseWriter, r *http.Request) {
+	if h.Runner == nil {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+	sessionID := chi.URLParam(r, "sessionId")
+	if sessionID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if h.RequireToken {
+		token := middleware.TokenFromRequest(r)
+		if err := h.Runner.Authorize(sessionID, token); err != nil {
+			http.Error(w, "invalid session token", http.StatusUnauthorized)
+			return
+		}
+	}
+	interrupted, err := h.Runner.Interrupt(sessionID)
+	if err != nil {
+		if errors.Is(err, runtime.ErrSessionNotRegistered) {
+			http.Error(w, "session not registered", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(sessionagent.SessionInterruptResponse{
+		SessionID:   sessionID,
+		Interrupted: interrupted,
+	})
+}
+
 func (h SessionHandler) Terminate(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		w.WriteHeader(http.StatusMethodNotAllowed)
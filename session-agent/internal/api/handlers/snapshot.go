@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"session-agent/internal/runtime"
+	"shared/sessionagent"
+)
+
+// Snapshot streams the session workspace as a gzip-compressed tar archive.
+// "state=true" includes the pickled interpreter globals.
+func (h SessionHandler) Snapshot(w http.ResponseWriter, r *http.Request) {
+	sessionID, ok := h.authorizedSession(w, r)
+	if !ok {
+		return
+	}
+	includeState, _ := strconv.ParseBool(r.URL.Query().Get(sessionagent.SnapshotStateParam))
+	// Buffer the archive so a failure can still be reported as an error status.
+	var archive bytes.Buffer
+	if err := h.Runner.Snapshot(sessionID, includeState, &archive); err != nil {
+		writeSnapshotError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Length", strconv.Itoa(archive.Len()))
+	w.WriteHeader(http.StatusOK)
+	_, _ = archive.WriteTo(w)
+}
+
+// Restore unpacks a snapshot archive from the request body into the
+// session workspace.
+func (h SessionHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	sessionID, ok := h.authorizedSession(w, r)
+	if !ok {
+		return
+	}
+	if err := h.Runner.Restore(sessionID, r.Body); err != nil {
+		writeSnapshotError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeSnapshotError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, sessionagent.ErrInvalidSnapshot), errors.Is(err, sessionagent.ErrInvalidFilePath):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, sessionagent.ErrReplStateFailed):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	case errors.Is(err, runtime.ErrInterpreterExited):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	default:
+		writeFileError(w, err)
+	}
+}
@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"session-agent/internal/runtime"
+)
+
+// StatusHandler reports the agent's sessions, their interpreter PIDs and
+// memory use, and the agent's uptime.
+type StatusHandler struct {
+	Runner *runtime.Runner
+}
+
+func (h StatusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.Runner == nil {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(h.Runner.Status())
+}
@@ -2,16 +2,21 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
+	"github.com/go-chi/chi/v5"
+
 	"session-agent/internal/api/middleware"
 	"session-agent/internal/runtime"
+	"session-agent/internal/telemetry"
 	"shared/sessionagent"
 )
 
 type StepHandler struct {
 	Runner       *runtime.Runner
 	RequireToken bool
+	Logger       telemetry.Logger
 }
 
 func (h StepHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -37,10 +42,61 @@ func (h StepHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 	result, err := h.Runner.RunStep(r.Context(), req)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		writeStepError(w, err)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	_ = json.NewEncoder(w).Encode(result)
 }
+
+// Stream runs a step and relays its output as server-sent events: stdout and
+// stderr chunks as they are written, then one status event with the result.
+func (h StepHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	if h.Runner == nil {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+	var req sessionagent.StepRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if stepID := chi.URLParam(r, "stepId"); stepID != "" {
+		req.StepID = stepID
+	}
+	if h.RequireToken {
+		token := middleware.TokenFromRequest(r)
+		if err := h.Runner.Authorize(req.SessionID, token); err != nil {
+			http.Error(w, "invalid session token", http.StatusUnauthorized)
+			return
+		}
+	}
+	// The stream only starts with the first event, so a request the runner
+	// rejects still gets a plain error status.
+	events := sessionagent.NewEventStream(w)
+	result, err := h.Runner.StreamStep(r.Context(), req, func(stream string, data string) {
+		_ = events.Write(sessionagent.StepEvent{Type: stream, Data: data})
+	})
+	if err != nil {
+		h.Logger.Error("step stream failed", "session_id", req.SessionID, "step_id", req.StepID, "error", err.Error())
+		if !events.Started() {
+			writeStepError(w, err)
+			return
+		}
+		result = sessionagent.StepResult{StepID: req.StepID, Status: sessionagent.StepStatusFailed}
+		_ = events.Write(sessionagent.StepEvent{Type: sessionagent.StepEventStderr, Data: err.Error()})
+	}
+	_ = events.Write(sessionagent.StepEvent{Type: sessionagent.StepEventStatus, Result: &result})
+}
+
+func writeStepError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, runtime.ErrInvalidStep):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, runtime.ErrSessionNotRegistered):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
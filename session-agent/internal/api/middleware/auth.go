@@ -1,10 +1,14 @@
 package middleware
 
 import (
+	"crypto/subtle"
 	"net/http"
 )
 
-const sessionTokenHeader = "X-Session-Token"
+const (
+	sessionTokenHeader = "X-Session-Token"
+	agentTokenHeader   = "X-Agent-Token"
+)
 
 func AuthBypassMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -22,6 +26,22 @@ func RequireTokenMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// RequireAgentTokenMiddleware guards agent-wide routes with the admin token
+// the data plane configured the agent with. An empty token rejects every
+// request.
+func RequireAgentTokenMiddleware(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			presented := r.Header.Get(agentTokenHeader)
+			if token == "" || subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+				http.Error(w, "invalid agent token", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 func TokenFromRequest(r *http.Request) string {
 	return r.Header.Get(sessionTokenHeader)
 }
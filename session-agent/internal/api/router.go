@@ -8,10 +8,19 @@ import (
 
 type RouterDeps struct {
 	HealthHandler           http.Handler
+	StatusHandler           http.Handler
+	MetricsHandler          http.Handler
 	StepsHandler            http.Handler
+	StepStreamHandler       http.Handler
 	AuthMiddleware          func(http.Handler) http.Handler
+	AdminMiddleware         func(http.Handler) http.Handler
 	SessionsHandler         http.Handler
 	SessionTerminateHandler http.Handler
+	SessionInterruptHandler http.Handler
+	FileUploadHandler       http.Handler
+	FileDownloadHandler     http.Handler
+	SnapshotHandler         http.Handler
+	RestoreHandler          http.Handler
 }
 
 func NewRouter(deps RouterDeps) http.Handler {
@@ -28,12 +37,23 @@ func NewRouter(deps RouterDeps) http.Handler {
 			http.Error(w, "steps handler not configured", http.StatusNotImplemented)
 		})
 	}
+	streamHandler := deps.StepStreamHandler
+	if streamHandler == nil {
+		streamHandler = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			http.Error(w, "step stream handler not configured", http.StatusNotImplemented)
+		})
+	}
 	sessionsHandler := deps.SessionsHandler
 	if sessionsHandler == nil {
 		sessionsHandler = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
 			http.Error(w, "sessions handler not configured", http.StatusNotImplemented)
 		})
 	}
+	// Registration is for the data plane only, so it needs the agent's
+	// admin token on top of the session token.
+	if deps.AdminMiddleware != nil {
+		sessionsHandler = deps.AdminMiddleware(sessionsHandler)
+	}
 	terminateHandler := deps.SessionTerminateHandler
 	if terminateHandler == nil {
 		terminateHandler = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
@@ -41,18 +61,75 @@ func NewRouter(deps RouterDeps) http.Handler {
 		})
 	}
 
+	interruptHandler := deps.SessionInterruptHandler
+	if interruptHandler == nil {
+		interruptHandler = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			http.Error(w, "interrupt handler not configured", http.StatusNotImplemented)
+		})
+	}
+
+	uploadHandler := deps.FileUploadHandler
+	if uploadHandler == nil {
+		uploadHandler = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			http.Error(w, "file upload handler not configured", http.StatusNotImplemented)
+		})
+	}
+	downloadHandler := deps.FileDownloadHandler
+	if downloadHandler == nil {
+		downloadHandler = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			http.Error(w, "file download handler not configured", http.StatusNotImplemented)
+		})
+	}
+
+	snapshotHandler := deps.SnapshotHandler
+	if snapshotHandler == nil {
+		snapshotHandler = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			http.Error(w, "snapshot handler not configured", http.StatusNotImplemented)
+		})
+	}
+	restoreHandler := deps.RestoreHandler
+	if restoreHandler == nil {
+		restoreHandler = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			http.Error(w, "restore handler not configured", http.StatusNotImplemented)
+		})
+	}
+
 	router := chi.NewRouter()
 	router.Get("/v1/health", healthHandler.ServeHTTP)
+	// Status and metrics list every session on the agent, so only the
+	// holder of the admin token may read them.
+	admin := chi.Router(router)
+	if deps.AdminMiddleware != nil {
+		admin = router.With(deps.AdminMiddleware)
+	}
+	if deps.StatusHandler != nil {
+		admin.Get("/v1/status", deps.StatusHandler.ServeHTTP)
+	}
+	if deps.MetricsHandler != nil {
+		admin.Get("/metrics", deps.MetricsHandler.ServeHTTP)
+	}
 	router.Route("/v1", func(r chi.Router) {
 		if deps.AuthMiddleware != nil {
 			r.With(deps.AuthMiddleware).Post("/steps", stepsHandler.ServeHTTP)
+			r.With(deps.AuthMiddleware).Post("/steps/{stepId}/stream", streamHandler.ServeHTTP)
 			r.With(deps.AuthMiddleware).Post("/sessions", sessionsHandler.ServeHTTP)
 			r.With(deps.AuthMiddleware).Post("/sessions/{sessionId}/terminate", terminateHandler.ServeHTTP)
+			r.With(deps.AuthMiddleware).Post("/sessions/{sessionId}/interrupt", interruptHandler.ServeHTTP)
+			r.With(deps.AuthMiddleware).Put("/sessions/{sessionId}/files", uploadHandler.ServeHTTP)
+			r.With(deps.AuthMiddleware).Get("/sessions/{sessionId}/files", downloadHandler.ServeHTTP)
+			r.With(deps.AuthMiddleware).Post("/sessions/{sessionId}/snapshot", snapshotHandler.ServeHTTP)
+			r.With(deps.AuthMiddleware).Post("/sessions/{sessionId}/restore", restoreHandler.ServeHTTP)
 			return
 		}
 		r.Post("/steps", stepsHandler.ServeHTTP)
+		r.Post("/steps/{stepId}/stream", streamHandler.ServeHTTP)
 		r.Post("/sessions", sessionsHandler.ServeHTTP)
 		r.Post("/sessions/{sessionId}/terminate", terminateHandler.ServeHTTP)
+		r.Post("/sessions/{sessionId}/interrupt", interruptHandler.ServeHTTP)
+		r.Put("/sessions/{sessionId}/files", uploadHandler.ServeHTTP)
+		r.Get("/sessions/{sessionId}/files", downloadHandler.ServeHTTP)
+		r.Post("/sessions/{sessionId}/snapshot", snapshotHandler.ServeHTTP)
+		r.Post("/sessions/{sessionId}/restore", restoreHandler.ServeHTTP)
 	})
 
 	return router
@@ -3,19 +3,39 @@ package config
 import (
 	"errors"
 	"os"
+	"path/filepath"
+	"strconv"
 )
 
 type Config struct {
-	Env        string
-	ListenAddr string
-	AuthBypass bool
+	Env           string
+	ListenAddr    string
+	AuthBypass    bool
+	AdminToken    string
+	WorkspaceRoot string
+	RestartREPL   bool
+	MaxSessions   int
+	UIDBase       int
+	CgroupRoot    string
+	CPUMillicores int
+	MemoryMB      int
+	PIDsMax       int
 }
 
 func Load() (Config, error) {
 	cfg := Config{
-		Env:        os.Getenv("ENV"),
-		ListenAddr: getenv("SESSION_AGENT_ADDR", ":9000"),
-		AuthBypass: os.Getenv("SESSION_AGENT_AUTH_BYPASS") == "true",
+		Env:           os.Getenv("ENV"),
+		ListenAddr:    getenv("SESSION_AGENT_ADDR", ":9000"),
+		AuthBypass:    os.Getenv("SESSION_AGENT_AUTH_BYPASS") == "true",
+		AdminToken:    os.Getenv("SESSION_AGENT_ADMIN_TOKEN"),
+		WorkspaceRoot: getenv("WORKSPACE_ROOT", "/workspace"),
+		RestartREPL:   os.Getenv("SESSION_AGENT_RESTART_REPL") != "false",
+		MaxSessions:   getenvInt("SESSION_AGENT_MAX_SESSIONS", 0),
+		UIDBase:       getenvInt("SESSION_AGENT_UID_BASE", 0),
+		CgroupRoot:    os.Getenv("SESSION_AGENT_CGROUP_ROOT"),
+		CPUMillicores: getenvInt("SESSION_AGENT_CPU_MILLICORES", 1000),
+		MemoryMB:      getenvInt("SESSION_AGENT_MEMORY_MB", 512),
+		PIDsMax:       getenvInt("SESSION_AGENT_PIDS_MAX", 256),
 	}
 	return cfg, cfg.Validate()
 }
@@ -30,6 +50,24 @@ func (c Config) Validate() error {
 	if c.Env == "production" && c.AuthBypass {
 		return errors.New("SESSION_AGENT_AUTH_BYPASS is not allowed in production")
 	}
+	if !c.AuthBypass && c.AdminToken == "" {
+		return errors.New("SESSION_AGENT_ADMIN_TOKEN is required unless SESSION_AGENT_AUTH_BYPASS is set")
+	}
+	if !filepath.IsAbs(c.WorkspaceRoot) {
+		return errors.New("WORKSPACE_ROOT must be an absolute path")
+	}
+	if c.MaxSessions < 0 {
+		return errors.New("SESSION_AGENT_MAX_SESSIONS must not be negative")
+	}
+	if c.UIDBase < 0 {
+		return errors.New("SESSION_AGENT_UID_BASE must not be negative")
+	}
+	if c.UIDBase > 0 && c.MaxSessions == 0 {
+		return errors.New("SESSION_AGENT_MAX_SESSIONS is required when SESSION_AGENT_UID_BASE is set")
+	}
+	if c.CgroupRoot != "" && (c.CPUMillicores <= 0 || c.MemoryMB <= 0 || c.PIDsMax <= 0) {
+		return errors.New("SESSION_AGENT_CPU_MILLICORES, SESSION_AGENT_MEMORY_MB and SESSION_AGENT_PIDS_MAX must be positive integers when SESSION_AGENT_CGROUP_ROOT is set")
+	}
 	return nil
 }
 
@@ -39,3 +77,17 @@ func getenv(key string, fallback string) string {
 	}
 	return fallback
 }
+
+// getenvInt returns -1 for values that are not integers so Validate
+// rejects them.
+func getenvInt(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return -1
+	}
+	return parsed
+}
@@ -0,0 +1,29 @@
+//go:build linux
+
+package runtime
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// start starts cmd inside the group, so neither the interpreter nor any
+// child it forks ever runs outside the session's limits.
+func (g *sessionCgroup) start(cmd *exec.Cmd) error {
+	if g == nil {
+		return cmd.Start()
+	}
+	dir, err := os.Open(g.path)
+	if err != nil {
+		return fmt.Errorf("open cgroup: %w", err)
+	}
+	defer dir.Close()
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(dir.Fd())
+	return cmd.Start()
+}
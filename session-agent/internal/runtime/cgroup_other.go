@@ -0,0 +1,15 @@
+//go:build !linux
+
+package runtime
+
+import (
+	"errors"
+	"os/exec"
+)
+
+func (g *sessionCgroup) start(cmd *exec.Cmd) error {
+	if g == nil {
+		return cmd.Start()
+	}
+	return errors.New("cgroups require linux")
+}
@@ -2,4 +2,12 @@ package runtime
 
 import "errors"
 
-var ErrSessionRuntimeMismatch = errors.New("session runtime mismatch")
+var (
+	ErrSessionRuntimeMismatch = errors.New("session runtime mismatch")
+	ErrSessionNotRegistered   = errors.New("session not registered")
+	ErrSessionExists          = errors.New("session already registered")
+	ErrWorkspaceOutsideRoot   = errors.New("workspace outside workspace root")
+	ErrInterpreterExited      = errors.New("session interpreter exited")
+	ErrAgentAtCapacity        = errors.New("session agent is at capacity")
+	ErrInvalidStep            = errors.New("invalid step")
+)
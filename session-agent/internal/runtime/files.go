@@ -0,0 +1,38 @@
+package runtime
+
+import (
+	"io"
+	"os"
+
+	"shared/sessionagent"
+)
+
+// WriteFile stores an uploaded file in the session's workspace.
+func (r *Runner) WriteFile(sessionID string, name string, body io.Reader, maxBytes int64) (sessionagent.FileInfo, error) {
+	session, ok := r.GetSession(sessionID)
+	if !ok {
+		return sessionagent.FileInfo{}, ErrSessionNotRegistered
+	}
+	// Files and directories written on the session's behalf belong to the
+	// session user.
+	return sessionagent.WriteWorkspaceFile(session.WorkspaceDir, name, body, sessionagent.WriteOptions{MaxBytes: maxBytes, UID: session.sandbox.uid})
+}
+
+// OpenFile opens a file in the session's workspace for download, along with
+// its checksum. The caller closes the file.
+func (r *Runner) OpenFile(sessionID string, name string) (*os.File, sessionagent.FileInfo, error) {
+	session, ok := r.GetSession(sessionID)
+	if !ok {
+		return nil, sessionagent.FileInfo{}, ErrSessionNotRegistered
+	}
+	file, _, err := sessionagent.OpenWorkspaceFile(session.WorkspaceDir, name)
+	if err != nil {
+		return nil, sessionagent.FileInfo{}, err
+	}
+	size, checksum, err := sessionagent.ChecksumFile(file)
+	if err != nil {
+		_ = file.Close()
+		return nil, sessionagent.FileInfo{}, err
+	}
+	return file, sessionagent.FileInfo{Path: name, Size: size, Checksum: checksum}, nil
+}
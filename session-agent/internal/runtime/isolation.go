@@ -0,0 +1,224 @@
+package runtime
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const cpuPeriodMicros = 100000
+
+// SessionIsolation separates sessions sharing one agent. With UIDBase set,
+// the session in slot n runs as UID and GID UIDBase+n and owns its
+// workspace, which no other session can read. Slots are never reused, so
+// no session inherits a UID. With CgroupRoot set, each session's
+// interpreter gets its own cgroup v2 group with the limits below; zero
+// limits are unlimited.
+type SessionIsolation struct {
+	UIDBase       int
+	CgroupRoot    string
+	CPUMillicores int
+	MemoryBytes   int64
+	PIDs          int
+}
+
+// Prepare checks that the agent can apply the isolation and enables the
+// cgroup controllers session groups need.
+func (i SessionIsolation) Prepare() error {
+	if i.UIDBase > 0 && os.Geteuid() != 0 {
+		return errors.New("per-session users require the agent to run as root")
+	}
+	if i.CgroupRoot == "" {
+		return nil
+	}
+	if err := os.MkdirAll(i.CgroupRoot, 0o755); err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(i.CgroupRoot, "cgroup.controllers")); err != nil {
+		return fmt.Errorf("cgroup v2 not available at %s: %w", i.CgroupRoot, err)
+	}
+	_ = os.WriteFile(filepath.Join(i.CgroupRoot, "cgroup.subtree_control"), []byte("+cpu +memory +pids"), 0o644)
+	return nil
+}
+
+// sessionSandbox is the isolation applied to one session's interpreter.
+type sessionSandbox struct {
+	uid    int
+	cgroup *sessionCgroup
+}
+
+func (s sessionSandbox) apply(cmd *exec.Cmd, workspaceDir string) error {
+	if s.uid <= 0 {
+		return nil
+	}
+	if workspaceDir != "" {
+		if err := os.Chown(workspaceDir, s.uid, s.uid); err != nil {
+			return err
+		}
+		if err := os.Chmod(workspaceDir, 0o700); err != nil {
+			return err
+		}
+		if cmd.Env == nil {
+			cmd.Env = os.Environ()
+		}
+		cmd.Env = append(cmd.Env, "HOME="+workspaceDir)
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Credential: &syscall.Credential{Uid: uint32(s.uid), Gid: uint32(s.uid)},
+	}
+	return nil
+}
+
+// own hands path, and everything under it, to the session user so files
+// the agent writes on the session's behalf stay usable by the session.
+func (s sessionSandbox) own(path string) error {
+	if s.uid <= 0 {
+		return nil
+	}
+	return filepath.WalkDir(path, func(name string, _ fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Lchown(name, s.uid, s.uid)
+	})
+}
+
+// release kills whatever still runs as the session user and deletes the
+// workspace it owned, so nothing the session started or wrote outlives it.
+func (s sessionSandbox) release(workspaceDir string) error {
+	if s.uid <= 0 {
+		return nil
+	}
+	if err := killUser(s.uid); err != nil {
+		return err
+	}
+	if workspaceDir == "" {
+		return nil
+	}
+	return os.RemoveAll(workspaceDir)
+}
+
+// killUser sends SIGKILL to every process running as uid, going over
+// /proc again until none is left so children forked meanwhile die too.
+func killUser(uid int) error {
+	for attempt := 0; attempt < 10; attempt++ {
+		pids, err := userProcesses(uid)
+		if err != nil || len(pids) == 0 {
+			return err
+		}
+		for _, pid := range pids {
+			_ = syscall.Kill(pid, syscall.SIGKILL)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return fmt.Errorf("processes of uid %d survived SIGKILL", uid)
+}
+
+// userProcesses lists the live processes with uid as their real,
+// effective or saved user ID. Zombies are left to their parents.
+func userProcesses(uid int) ([]int, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+	want := strconv.Itoa(uid)
+	var pids []int
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		status, err := os.ReadFile(filepath.Join("/proc", entry.Name(), "status"))
+		if err != nil {
+			continue
+		}
+		var uids []string
+		zombie := false
+		for _, line := range strings.Split(string(status), "\n") {
+			if state, ok := strings.CutPrefix(line, "State:"); ok {
+				zombie = strings.HasPrefix(strings.TrimSpace(state), "Z")
+			}
+			if ids, ok := strings.CutPrefix(line, "Uid:"); ok {
+				uids = strings.Fields(ids)
+			}
+		}
+		if !zombie && len(uids) >= 3 && (uids[0] == want || uids[1] == want || uids[2] == want) {
+			pids = append(pids, pid)
+		}
+	}
+	return pids, nil
+}
+
+type sessionCgroup struct {
+	path string
+}
+
+func newSessionCgroup(isolation SessionIsolation, sessionID string) (*sessionCgroup, error) {
+	if isolation.CgroupRoot == "" {
+		return nil, nil
+	}
+	if sessionID == "" || sessionID == "." || sessionID == ".." || filepath.Base(sessionID) != sessionID {
+		return nil, errors.New("invalid session id for cgroup")
+	}
+	group := &sessionCgroup{path: filepath.Join(isolation.CgroupRoot, "session-"+sessionID)}
+	if err := os.Mkdir(group.path, 0o755); err != nil {
+		return nil, err
+	}
+	if err := group.apply(isolation); err != nil {
+		_ = group.close()
+		return nil, err
+	}
+	return group, nil
+}
+
+func (g *sessionCgroup) apply(isolation SessionIsolation) error {
+	if isolation.CPUMillicores > 0 {
+		quota := isolation.CPUMillicores * cpuPeriodMicros / 1000
+		if err := g.write("cpu.max", fmt.Sprintf("%d %d", quota, cpuPeriodMicros)); err != nil {
+			return err
+		}
+	}
+	if isolation.MemoryBytes > 0 {
+		if err := g.write("memory.max", strconv.FormatInt(isolation.MemoryBytes, 10)); err != nil {
+			return err
+		}
+		_ = g.write("memory.swap.max", "0")
+	}
+	if isolation.PIDs > 0 {
+		if err := g.write("pids.max", strconv.Itoa(isolation.PIDs)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// close kills anything left in the group and removes it.
+func (g *sessionCgroup) close() error {
+	if g == nil {
+		return nil
+	}
+	_ = g.write("cgroup.kill", "1")
+	var err error
+	for i := 0; i < 20; i++ {
+		err = os.Remove(g.path)
+		if err == nil || errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return err
+}
+
+func (g *sessionCgroup) write(file string, value string) error {
+	if err := os.WriteFile(filepath.Join(g.path, file), []byte(value), 0o644); err != nil {
+		return fmt.Errorf("write %s: %w", file, err)
+	}
+	return nil
+}
@@ -4,103 +4,450 @@ import (
 	"bufio"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"session-agent/internal/telemetry"
+	"shared/sessionagent"
 )
 
+// interruptGrace is how long a REPL gets to unwind after SIGINT before the
+// interpreter is restarted.
+var interruptGrace = 2 * time.Second
+
+const stateLostMessage = "step did not respond to interrupt; interpreter restarted and session state was lost"
+
+// sessionProcess owns a session's REPL. A supervisor goroutine waits on
+// each interpreter it starts; when one dies outside of Close the exit is
+// recorded, the interpreter is optionally restarted, and the next step
+// result reports that session state was lost.
 type sessionProcess struct {
-	cmd     *exec.Cmd
-	stdin   io.WriteCloser
-	stdout  *bufio.Reader
-	runtime string
-	mu      sync.Mutex
+	runtime      string
+	workspaceDir string
+	env          []string
+	restart      bool
+	sandbox      sessionSandbox
+	logger       telemetry.Logger
+	metrics      *telemetry.Metrics
+	mu           sync.Mutex
+	proc         *replProcess
+	closed       bool
+	stateLost    bool
+	exitReason   string
+	backoff      sessionagent.RestartBackoff
+
+	busy       atomic.Bool
+	interrupts chan struct{}
+	// pid is the running interpreter's, readable while a step holds mu.
+	pid atomic.Int64
+}
+
+type replProcess struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	// output is the read end of the interpreter's stdout, closed by the
+	// supervisor once no step is reading it.
+	output *os.File
+	exited chan struct{}
+	err    error
+}
+
+// signal delivers sig to the interpreter's process group.
+func (r *replProcess) signal(sig syscall.Signal) error {
+	return syscall.Kill(-r.cmd.Process.Pid, sig)
+}
+
+// stepOutcome describes how a step ended. Failure holds the uncaught error
+// text reported by the REPL or the reason the step was cut short.
+type stepOutcome struct {
+	Status    string
+	Failure   string
+	StateLost bool
+	Usage     sessionagent.ResourceUsage
+}
+
+type replResult struct {
+	failure string
+	err     error
 }
 
-type replResponse struct {
-	Stdout string `json:"stdout"`
-	Stderr string `json:"stderr"`
-	Error  string `json:"error"`
+// processOptions are what a Runner hands each session process.
+type processOptions struct {
+	restart bool
+	sandbox sessionSandbox
+	logger  telemetry.Logger
+	metrics *telemetry.Metrics
 }
 
-func startSessionProcess(runtime string, workspaceDir string) (*sessionProcess, error) {
-	normalized := strings.ToLower(strings.TrimSpace(runtime))
+func startSessionProcess(runtime string, workspaceDir string, env []string, options processOptions) (*sessionProcess, error) {
+	p := &sessionProcess{
+		runtime:      runtime,
+		workspaceDir: workspaceDir,
+		env:          env,
+		restart:      options.restart,
+		sandbox:      options.sandbox,
+		logger:       options.logger,
+		metrics:      options.metrics,
+		interrupts:   make(chan struct{}, 1),
+	}
+	if err := p.start(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *sessionProcess) start() error {
+	normalized := strings.ToLower(strings.TrimSpace(p.runtime))
 	var cmd *exec.Cmd
 	if normalized == "python" || normalized == "python3" {
 		cmd = exec.Command("python3", "-u", "-c", pythonReplScript)
 	} else if normalized == "node" || normalized == "javascript" {
 		cmd = exec.Command("node", "-e", nodeReplScript)
 	} else {
-		return nil, errors.New("unsupported runtime")
+		return errors.New("unsupported runtime")
 	}
-	if workspaceDir != "" {
-		if err := os.MkdirAll(workspaceDir, 0o750); err != nil {
-			return nil, err
+	if p.workspaceDir != "" {
+		if err := os.MkdirAll(p.workspaceDir, 0o750); err != nil {
+			return err
 		}
-		cmd.Dir = workspaceDir
+		cmd.Dir = p.workspaceDir
+	}
+	if len(p.env) > 0 {
+		cmd.Env = append(os.Environ(), p.env...)
 	}
+	if err := p.sandbox.apply(cmd, p.workspaceDir); err != nil {
+		return err
+	}
+	// The interpreter leads its own process group so interrupts and kills
+	// also reach processes the step started.
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
-		return nil, err
+		return err
 	}
-	stdoutPipe, err := cmd.StdoutPipe()
+	// stdout is an os.Pipe rather than cmd.StdoutPipe, which Wait closes:
+	// the supervisor waits on the interpreter while a step may still be
+	// reading the last of its output.
+	output, outputWriter, err := os.Pipe()
 	if err != nil {
 		_ = stdin.Close()
-		return nil, err
+		return err
 	}
+	cmd.Stdout = outputWriter
 	cmd.Stderr = os.Stderr
-	if err := cmd.Start(); err != nil {
+	startErr := p.sandbox.cgroup.start(cmd)
+	// Only the interpreter holds the write end now, so reads end at EOF
+	// once it and anything it started have exited.
+	_ = outputWriter.Close()
+	if startErr != nil {
 		_ = stdin.Close()
-		return nil, err
+		_ = output.Close()
+		return startErr
 	}
-	return &sessionProcess{
-		cmd:     cmd,
-		stdin:   stdin,
-		stdout:  bufio.NewReader(stdoutPipe),
-		runtime: runtime,
-	}, nil
+	stdout := bufio.NewReader(output)
+	// Wait until the REPL has installed its SIGINT handler, so an early
+	// interrupt cannot kill it.
+	if err := awaitReady(stdout); err != nil {
+		_ = stdin.Close()
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		_ = output.Close()
+		return fmt.Errorf("start %s repl: %w", p.runtime, err)
+	}
+	proc := &replProcess{cmd: cmd, stdin: stdin, stdout: stdout, output: output, exited: make(chan struct{})}
+	p.proc = proc
+	p.pid.Store(int64(cmd.Process.Pid))
+	go p.supervise(proc)
+	return nil
+}
+
+func awaitReady(reader *bufio.Reader) error {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	var frame sessionagent.ReplFrame
+	if err := json.Unmarshal([]byte(line), &frame); err != nil || frame.Type != sessionagent.ReplFrameReady {
+		return errors.New("repl did not report ready")
+	}
+	return nil
+}
+
+// supervise waits for proc to exit. Steps read its output while holding
+// p.mu and finish reading before releasing it, so the output can be closed
+// once the lock is held.
+func (p *sessionProcess) supervise(proc *replProcess) {
+	proc.err = proc.cmd.Wait()
+	close(proc.exited)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_ = proc.output.Close()
+	p.handleExit(proc, p.restart)
+}
+
+// handleExit records that proc has died and starts a replacement when
+// restart is set. Callers hold p.mu; exits of replaced interpreters and
+// of closed sessions are ignored.
+func (p *sessionProcess) handleExit(proc *replProcess, restart bool) {
+	if p.proc != proc || p.closed {
+		return
+	}
+	p.proc = nil
+	p.pid.Store(0)
+	p.stateLost = true
+	p.exitReason = exitReason(proc.err)
+	p.logger.Info("repl exited", "runtime", p.runtime, "reason", p.exitReason)
+	if !restart {
+		return
+	}
+	if delay := p.backoff.Next(time.Now()); delay > 0 {
+		// Steps fail with the exit reason until the delayed restart.
+		p.logger.Info("repl restart delayed", "runtime", p.runtime, "delay", delay.String())
+		p.exitReason = fmt.Sprintf("%s; crashing repeatedly, restarting in %s", p.exitReason, delay)
+		time.AfterFunc(delay, p.delayedRestart)
+		return
+	}
+	p.restartRepl()
+}
+
+func (p *sessionProcess) delayedRestart() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.proc != nil || p.closed {
+		return
+	}
+	p.restartRepl()
+}
+
+// restartRepl starts a replacement interpreter. Callers hold p.mu.
+func (p *sessionProcess) restartRepl() {
+	if err := p.start(); err != nil {
+		p.logger.Error("repl restart failed", "runtime", p.runtime, "error", err.Error())
+		p.exitReason = err.Error()
+		return
+	}
+	p.metrics.ReplRestarted(p.runtime)
+}
+
+func exitReason(err error) string {
+	if err == nil {
+		return "exit status 0"
+	}
+	return err.Error()
 }
 
 func (p *sessionProcess) Close() error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	if p.stdin != nil {
-		_ = p.stdin.Close()
+	p.closed = true
+	p.pid.Store(0)
+	var err error
+	if p.proc != nil {
+		_ = p.proc.stdin.Close()
+		err = p.proc.signal(syscall.SIGKILL)
+	}
+	if cgroupErr := p.sandbox.cgroup.close(); err == nil {
+		err = cgroupErr
 	}
-	if p.cmd != nil && p.cmd.Process != nil {
-		return p.cmd.Process.Kill()
+	return err
+}
+
+// Interrupt asks the running step to stop. It reports false when no step
+// is running.
+func (p *sessionProcess) Interrupt() bool {
+	if !p.busy.Load() {
+		return false
+	}
+	select {
+	case p.interrupts <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// StateOp asks the REPL to save or load its globals at path.
+func (p *sessionProcess) StateOp(op string, path string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return errors.New("session process closed")
+	}
+	proc := p.proc
+	if proc == nil {
+		return fmt.Errorf("%w: %s", ErrInterpreterExited, p.exitReason)
+	}
+	payload, err := json.Marshal(map[string]string{"op": op, "path": path})
+	if err != nil {
+		return err
+	}
+	if _, err := proc.stdin.Write(append(payload, '\n')); err != nil {
+		return err
+	}
+	failure, err := sessionagent.ReadReplStep(proc.stdout, nil)
+	if err != nil {
+		return err
+	}
+	if failure != "" {
+		return fmt.Errorf("%w: %s", sessionagent.ErrReplStateFailed, strings.TrimSpace(failure))
 	}
 	return nil
 }
 
-func (p *sessionProcess) RunStep(code string) (string, string, error) {
+func (p *sessionProcess) RunStep(code string, timeout time.Duration) (string, string, stepOutcome, error) {
+	var stdout, stderr strings.Builder
+	outcome, err := p.StreamStep(code, timeout, func(stream string, data string) {
+		if stream == sessionagent.StepEventStderr {
+			stderr.WriteString(data)
+			return
+		}
+		stdout.WriteString(data)
+	})
+	if err != nil {
+		return "", "", outcome, err
+	}
+	return stdout.String(), sessionagent.JoinStepError(stderr.String(), outcome.Failure), outcome, nil
+}
+
+// StreamStep runs code and hands each output chunk to emit as the REPL
+// produces it. A step that outlives timeout (when positive) or is
+// interrupted gets SIGINT, and the interpreter is restarted if that does
+// not stop it.
+func (p *sessionProcess) StreamStep(code string, timeout time.Duration, emit func(stream string, data string)) (stepOutcome, error) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	outcome := stepOutcome{Status: sessionagent.StepStatusCompleted}
+	if p.closed {
+		return outcome, errors.New("session process closed")
+	}
+	// Report a crash that happened between steps exactly once.
+	outcome.StateLost = p.stateLost
+	p.stateLost = false
+	proc := p.proc
+	if proc == nil {
+		return outcome, fmt.Errorf("%w: %s", ErrInterpreterExited, p.exitReason)
+	}
 	payload, err := json.Marshal(map[string]string{"code": code})
 	if err != nil {
-		return "", "", err
+		return outcome, err
 	}
-	if _, err := p.stdin.Write(append(payload, '\n')); err != nil {
-		return "", "", err
+	select {
+	case <-p.interrupts:
+	default:
 	}
-	line, err := p.stdout.ReadString('\n')
-	if err != nil {
-		return "", "", err
-	}
-	var resp replResponse
-	if err := json.Unmarshal([]byte(strings.TrimSpace(line)), &resp); err != nil {
-		return "", "", err
-	}
-	stderr := resp.Stderr
-	if resp.Error != "" {
-		if stderr != "" {
-			stderr = stderr + "\n" + resp.Error
-		} else {
-			stderr = resp.Error
+	pid := proc.cmd.Process.Pid
+	_ = sessionagent.ResetPeakRSS(pid)
+	before, _ := sessionagent.ReadProcessUsage(pid)
+	p.busy.Store(true)
+	defer p.busy.Store(false)
+
+	// The reader may outlive this call if the interpreter has to be
+	// restarted, so output is only forwarded while the step is attached.
+	var emitMu sync.Mutex
+	attached := true
+	detach := func() {
+		emitMu.Lock()
+		attached = false
+		emitMu.Unlock()
+	}
+	done := make(chan replResult, 1)
+	if _, err := proc.stdin.Write(append(payload, '\n')); err != nil {
+		done <- replResult{err: err}
+	} else {
+		go func() {
+			failure, err := sessionagent.ReadReplStep(proc.stdout, func(stream string, data string) {
+				emitMu.Lock()
+				defer emitMu.Unlock()
+				if attached && emit != nil {
+					emit(stream, data)
+				}
+			})
+			done <- replResult{failure: failure, err: err}
+		}()
+	}
+
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	var result replResult
+	select {
+	case result = <-done:
+	case <-deadline:
+		outcome.Status = sessionagent.StepStatusTimedOut
+		result = p.stopStep(proc, done, detach)
+	case <-p.interrupts:
+		outcome.Status = sessionagent.StepStatusInterrupted
+		result = p.stopStep(proc, done, detach)
+	}
+	detach()
+	if result.err != nil {
+		// The interpreter died under the step; the reader only fails once
+		// its stdout is gone.
+		_ = proc.signal(syscall.SIGKILL)
+		<-proc.exited
+		p.handleExit(proc, p.restart)
+		if outcome.Status == sessionagent.StepStatusCompleted {
+			outcome.Status = sessionagent.StepStatusFailed
 		}
+		result = replResult{failure: fmt.Sprintf("interpreter exited (%s); session state was lost", exitReason(proc.err))}
+	}
+	if p.stateLost {
+		outcome.StateLost = true
+		p.stateLost = false
+	}
+	if after, usageErr := sessionagent.ReadProcessUsage(pid); usageErr == nil {
+		outcome.Usage = after.Sub(before)
+	}
+	outcome.Failure = result.failure
+	return outcome, nil
+}
+
+// stopStep sends SIGINT to the REPL's process group and waits for it to
+// finish the step. If it does not, the interpreter is replaced regardless
+// of p.restart.
+func (p *sessionProcess) stopStep(proc *replProcess, done <-chan replResult, detach func()) replResult {
+	if err := proc.signal(syscall.SIGINT); err == nil {
+		select {
+		case result := <-done:
+			return result
+		case <-time.After(interruptGrace):
+		}
+	}
+	detach()
+	_ = proc.stdin.Close()
+	_ = proc.signal(syscall.SIGKILL)
+	<-proc.exited
+	// The step is abandoned; closing its output ends the read even if a
+	// process that left the group still holds the pipe.
+	_ = proc.output.Close()
+	<-done
+	p.handleExit(proc, true)
+	if p.proc == nil {
+		return replResult{failure: fmt.Sprintf("interpreter not restarted: %s", p.exitReason)}
+	}
+	return replResult{failure: stateLostMessage}
+}
+
+// status samples the interpreter for the agent's status report.
+func (p *sessionProcess) status() (pid int, memoryBytes int64) {
+	pid = int(p.pid.Load())
+	if pid == 0 {
+		return 0, 0
 	}
-	return resp.Stdout, stderr, nil
+	memoryBytes, _ = sessionagent.ReadProcessRSS(pid)
+	return pid, memoryBytes
 }
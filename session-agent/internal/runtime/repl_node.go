@@ -14,6 +14,14 @@ const context = vm.createContext({
   clearInterval,
 });
 
+const writeFrame = process.stdout.write.bind(process.stdout);
+const emit = (frame) => writeFrame(JSON.stringify(frame) + "\n");
+
+// SIGINT only interrupts running code (breakOnSigint); between steps it is
+// ignored rather than killing the REPL.
+process.on("SIGINT", () => {});
+emit({ type: "ready" });
+
 const rl = readline.createInterface({
   input: process.stdin,
   crlfDelay: Infinity,
@@ -29,38 +37,33 @@ rl.on("line", (line) => {
   } catch (err) {
     return;
   }
-  let stdout = "";
-  let stderr = "";
   let error = "";
 
-  const originalStdoutWrite = process.stdout.write.bind(process.stdout);
-  const originalStderrWrite = process.stderr.write.bind(process.stderr);
+  const originalStdoutWrite = process.stdout.write;
+  const originalStderrWrite = process.stderr.write;
   const originalConsoleLog = console.log;
   const originalConsoleError = console.error;
 
-  process.stdout.write = (chunk, encoding, cb) => {
-    stdout += chunk instanceof Buffer ? chunk.toString() : chunk;
-    if (typeof cb === "function") {
-      cb();
-    }
-    return true;
-  };
-  process.stderr.write = (chunk, encoding, cb) => {
-    stderr += chunk instanceof Buffer ? chunk.toString() : chunk;
-    if (typeof cb === "function") {
+  const chunkWriter = (type) => (chunk, encoding, cb) => {
+    emit({ type, data: chunk instanceof Buffer ? chunk.toString() : String(chunk) });
+    if (typeof encoding === "function") {
+      encoding();
+    } else if (typeof cb === "function") {
       cb();
     }
     return true;
   };
+  process.stdout.write = chunkWriter("stdout");
+  process.stderr.write = chunkWriter("stderr");
   console.log = (...args) => {
-    stdout += args.join(" ") + "\n";
+    emit({ type: "stdout", data: args.join(" ") + "\n" });
   };
   console.error = (...args) => {
-    stderr += args.join(" ") + "\n";
+    emit({ type: "stderr", data: args.join(" ") + "\n" });
   };
 
   try {
-    vm.runInContext(req.code || "", context);
+    vm.runInContext(req.code || "", context, { breakOnSigint: true });
   } catch (err) {
     error = err && err.stack ? err.stack : String(err);
   }
@@ -70,7 +73,6 @@ rl.on("line", (line) => {
   console.log = originalConsoleLog;
   console.error = originalConsoleError;
 
-  const resp = JSON.stringify({ stdout, stderr, error });
-  originalStdoutWrite(resp + "\n");
+  emit({ type: "done", error });
 });
 `
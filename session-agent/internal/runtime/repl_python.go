@@ -1,13 +1,95 @@
 package runtime
 
 const pythonReplScript = `import contextlib
-import io
 import json
+import signal
 import sys
 import traceback
 
+real_stdout = sys.stdout
 globals_ns = {"__name__": "__main__"}
+running = False
 
+
+def on_interrupt(signum, frame):
+    # Only interrupt user code; a late SIGINT between steps is ignored.
+    if running:
+        raise KeyboardInterrupt
+
+
+signal.signal(signal.SIGINT, on_interrupt)
+
+
+def emit(frame):
+    real_stdout.write(json.dumps(frame) + "\n")
+    real_stdout.flush()
+
+
+class FrameWriter:
+    encoding = "utf-8"
+
+    def __init__(self, stream):
+        self.stream = stream
+
+    def write(self, data):
+        if data:
+            emit({"type": self.stream, "data": data})
+        return len(data)
+
+    def flush(self):
+        pass
+
+    def isatty(self):
+        return False
+
+
+def state_pickler():
+    try:
+        import dill
+        return dill
+    except ImportError:
+        import pickle
+        return pickle
+
+
+def save_state(path):
+    # Each global is pickled on its own so one unpicklable value (an open
+    # file, a module without dill) does not lose the rest.
+    pickler = state_pickler()
+    state = {}
+    for name, value in globals_ns.items():
+        if name.startswith("__"):
+            continue
+        try:
+            state[name] = pickler.dumps(value)
+        except Exception:
+            continue
+    with open(path, "wb") as f:
+        pickler.dump(state, f)
+
+
+def load_state(path):
+    pickler = state_pickler()
+    with open(path, "rb") as f:
+        state = pickler.load(f)
+    for name, blob in state.items():
+        try:
+            globals_ns[name] = pickler.loads(blob)
+        except Exception:
+            continue
+
+
+def run_op(req):
+    op = req.get("op")
+    if op == "save_state":
+        save_state(req["path"])
+    elif op == "load_state":
+        load_state(req["path"])
+    else:
+        raise ValueError("unknown op: " + str(op))
+
+
+emit({"type": "ready"})
 for line in sys.stdin:
     line = line.rstrip("\n")
     if not line:
@@ -16,16 +98,23 @@ for line in sys.stdin:
         req = json.loads(line)
     except Exception:
         continue
+    if req.get("op"):
+        try:
+            run_op(req)
+            emit({"type": "done", "error": ""})
+        except Exception:
+            emit({"type": "done", "error": traceback.format_exc()})
+        continue
     code = req.get("code", "")
-    out = io.StringIO()
-    err = io.StringIO()
     failure = ""
     try:
-        with contextlib.redirect_stdout(out), contextlib.redirect_stderr(err):
-            exec(code, globals_ns)
-    except Exception:
+        with contextlib.redirect_stdout(FrameWriter("stdout")), contextlib.redirect_stderr(FrameWriter("stderr")):
+            running = True
+            try:
+                exec(code, globals_ns)
+            finally:
+                running = False
+    except (Exception, KeyboardInterrupt):
         failure = traceback.format_exc()
-    resp = {"stdout": out.getvalue(), "stderr": err.getvalue(), "error": failure}
-    sys.stdout.write(json.dumps(resp) + "\n")
-    sys.stdout.flush()
+    emit({"type": "done", "error": failure})
 `
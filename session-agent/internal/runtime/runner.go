@@ -2,10 +2,15 @@ package runtime
 
 import (
 	"context"
+	"crypto/subtle"
 	"errors"
+	"fmt"
+	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
+	"session-agent/internal/telemetry"
 	"shared/sessionagent"
 )
 
@@ -15,15 +20,37 @@ type Session struct {
 	Token        string
 	WorkspaceDir string
 	Process      *sessionProcess
+
+	slot    int
+	sandbox sessionSandbox
 }
 
 type Runner struct {
+	// RestartCrashed restarts session interpreters that exit on their own.
+	RestartCrashed bool
+	// MaxSessions caps how many sessions the agent hosts; zero is no cap.
+	MaxSessions int
+	// WorkspaceRoot, when set, is the directory every session workspace
+	// must live under.
+	WorkspaceRoot string
+	Isolation     SessionIsolation
+	Logger        telemetry.Logger
+	Metrics       *telemetry.Metrics
+
+	started  time.Time
 	mu       sync.RWMutex
 	sessions map[string]*Session
+	slots    map[int]bool
+	nextSlot int
 }
 
 func NewRunner() *Runner {
-	return &Runner{sessions: make(map[string]*Session)}
+	return &Runner{
+		RestartCrashed: true,
+		started:        time.Now(),
+		sessions:       make(map[string]*Session),
+		slots:          make(map[int]bool),
+	}
 }
 
 func (r *Runner) RegisterSession(req sessionagent.SessionRegisterRequest) (*Session, error) {
@@ -34,25 +61,47 @@ func (r *Runner) RegisterSession(req sessionagent.SessionRegisterRequest) (*Sess
 		return nil, errors.New("missing runtime")
 	}
 
+	if err := r.checkWorkspace(req.WorkspaceDir); err != nil {
+		return nil, err
+	}
+
 	r.mu.Lock()
 	if session, ok := r.sessions[req.SessionID]; ok {
+		r.mu.Unlock()
 		if !strings.EqualFold(session.Runtime, req.Runtime) {
-			r.mu.Unlock()
 			return nil, ErrSessionRuntimeMismatch
 		}
-		if req.Token != "" {
-			session.Token = req.Token
-		}
-		if req.WorkspaceDir != "" {
-			session.WorkspaceDir = req.WorkspaceDir
+		// A repeated registration is only accepted as a retry of the
+		// original one; it never changes who owns the session or where
+		// its files live.
+		if subtle.ConstantTimeCompare([]byte(session.Token), []byte(req.Token)) != 1 ||
+			session.WorkspaceDir != req.WorkspaceDir {
+			return nil, ErrSessionExists
 		}
-		r.mu.Unlock()
 		return session, nil
 	}
+	slot, err := r.reserveSlot()
 	r.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
 
-	process, err := startSessionProcess(req.Runtime, req.WorkspaceDir)
+	sandbox, err := r.sandbox(req.SessionID, slot)
+	if err != nil {
+		r.releaseSlot(slot)
+		return nil, err
+	}
+	logger := r.Logger.With("session_id", req.SessionID)
+	process, err := startSessionProcess(req.Runtime, req.WorkspaceDir, req.Env, processOptions{
+		restart: r.RestartCrashed,
+		sandbox: sandbox,
+		logger:  logger,
+		metrics: r.Metrics,
+	})
 	if err != nil {
+		logger.Error("session start failed", "runtime", req.Runtime, "error", err.Error())
+		_ = sandbox.cgroup.close()
+		r.releaseSlot(slot)
 		return nil, err
 	}
 	session := &Session{
@@ -61,13 +110,32 @@ func (r *Runner) RegisterSession(req sessionagent.SessionRegisterRequest) (*Sess
 		Token:        req.Token,
 		WorkspaceDir: req.WorkspaceDir,
 		Process:      process,
+		slot:         slot,
+		sandbox:      sandbox,
 	}
 	r.mu.Lock()
 	r.sessions[req.SessionID] = session
 	r.mu.Unlock()
+	logger.Info("session registered", "runtime", req.Runtime, "slot", slot, "uid", sandbox.uid)
 	return session, nil
 }
 
+// checkWorkspace rejects workspaces that are not strictly inside
+// WorkspaceRoot.
+func (r *Runner) checkWorkspace(dir string) error {
+	if r.WorkspaceRoot == "" {
+		return nil
+	}
+	if dir == "" || !filepath.IsAbs(dir) {
+		return ErrWorkspaceOutsideRoot
+	}
+	rel, err := filepath.Rel(filepath.Clean(r.WorkspaceRoot), filepath.Clean(dir))
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return ErrWorkspaceOutsideRoot
+	}
+	return nil
+}
+
 func (r *Runner) GetSession(sessionID string) (*Session, bool) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -84,7 +152,7 @@ func (r *Runner) Authorize(sessionID string, token string) error {
 	session, ok := r.sessions[sessionID]
 	r.mu.RUnlock()
 	if !ok {
-		return errors.New("session not registered")
+		return ErrSessionNotRegistered
 	}
 	if session.Token == "" || session.Token != token {
 		return errors.New("invalid session token")
@@ -93,36 +161,101 @@ func (r *Runner) Authorize(sessionID string, token string) error {
 }
 
 func (r *Runner) RunStep(ctx context.Context, req sessionagent.StepRequest) (sessionagent.StepResult, error) {
+	session, err := r.stepSession(req)
+	if err != nil {
+		return sessionagent.StepResult{}, err
+	}
+	started := time.Now()
+	stdout, stderr, outcome, err := session.Process.RunStep(req.Code, stepTimeout(req))
+	if err != nil {
+		outcome.Status = sessionagent.StepStatusFailed
+		stderr = strings.TrimSpace(strings.Join([]string{stderr, err.Error()}, "\n"))
+	}
+	r.finishStep(session, req.StepID, outcome, time.Since(started))
+
+	return sessionagent.StepResult{
+		StepID:        req.StepID,
+		Status:        outcome.Status,
+		Stdout:        stdout,
+		Stderr:        stderr,
+		StateLost:     outcome.StateLost,
+		ResourceUsage: outcome.Usage,
+	}, nil
+}
+
+// StreamStep runs a step like RunStep but passes output to emit as it is
+// produced. The returned result carries status and usage only; uncaught
+// errors are emitted as a final stderr chunk.
+func (r *Runner) StreamStep(ctx context.Context, req sessionagent.StepRequest, emit func(stream string, data string)) (sessionagent.StepResult, error) {
+	session, err := r.stepSession(req)
+	if err != nil {
+		return sessionagent.StepResult{}, err
+	}
+	started := time.Now()
+	outcome, err := session.Process.StreamStep(req.Code, stepTimeout(req), emit)
+	failure := outcome.Failure
+	if err != nil {
+		outcome.Status = sessionagent.StepStatusFailed
+		failure = sessionagent.JoinStepError(failure, err.Error())
+	}
+	r.finishStep(session, req.StepID, outcome, time.Since(started))
+	if failure != "" {
+		emit(sessionagent.StepEventStderr, failure)
+	}
+	return sessionagent.StepResult{
+		StepID:        req.StepID,
+		Status:        outcome.Status,
+		StateLost:     outcome.StateLost,
+		ResourceUsage: outcome.Usage,
+	}, nil
+}
+
+// Interrupt stops the step currently running in a session. It reports
+// whether a step was running.
+func (r *Runner) Interrupt(sessionID string) (bool, error) {
+	session, ok := r.GetSession(sessionID)
+	if !ok {
+		return false, ErrSessionNotRegistered
+	}
+	if session.Process == nil {
+		return false, nil
+	}
+	return session.Process.Interrupt(), nil
+}
+
+func (r *Runner) finishStep(session *Session, stepID string, outcome stepOutcome, duration time.Duration) {
+	r.Metrics.ObserveStep(session.Runtime, outcome.Status, outcome.Failure != "", duration)
+	r.Logger.Info("step finished",
+		"session_id", session.ID,
+		"step_id", stepID,
+		"status", outcome.Status,
+		"state_lost", outcome.StateLost,
+		"duration_ms", duration.Milliseconds(),
+	)
+}
+
+func stepTimeout(req sessionagent.StepRequest) time.Duration {
+	return time.Duration(req.TimeoutMs) * time.Millisecond
+}
+
+func (r *Runner) stepSession(req sessionagent.StepRequest) (*Session, error) {
 	if req.SessionID == "" {
-		return sessionagent.StepResult{}, errors.New("missing session id")
+		return nil, fmt.Errorf("%w: missing session id", ErrInvalidStep)
 	}
 	if req.StepID == "" {
-		return sessionagent.StepResult{}, errors.New("missing step id")
+		return nil, fmt.Errorf("%w: missing step id", ErrInvalidStep)
 	}
 	if strings.TrimSpace(req.Code) == "" {
-		return sessionagent.StepResult{}, errors.New("missing step code")
+		return nil, fmt.Errorf("%w: missing step code", ErrInvalidStep)
 	}
-
 	session, ok := r.GetSession(req.SessionID)
 	if !ok {
-		return sessionagent.StepResult{}, errors.New("session not registered")
+		return nil, ErrSessionNotRegistered
 	}
 	if session.Process == nil {
-		return sessionagent.StepResult{}, errors.New("session process not available")
+		return nil, errors.New("session process not available")
 	}
-	stdout, stderr, err := session.Process.RunStep(req.Code)
-	status := sessionagent.StepStatusCompleted
-	if err != nil {
-		status = sessionagent.StepStatusFailed
-		stderr = strings.TrimSpace(strings.Join([]string{stderr, err.Error()}, "\n"))
-	}
-
-	return sessionagent.StepResult{
-		StepID: req.StepID,
-		Status: status,
-		Stdout: stdout,
-		Stderr: stderr,
-	}, nil
+	return session, nil
 }
 
 func (r *Runner) RemoveSession(sessionID string) {
@@ -130,9 +263,52 @@ func (r *Runner) RemoveSession(sessionID string) {
 	session, ok := r.sessions[sessionID]
 	if ok {
 		delete(r.sessions, sessionID)
+		delete(r.slots, session.slot)
 	}
 	r.mu.Unlock()
-	if ok && session.Process != nil {
+	if !ok {
+		return
+	}
+	if session.Process != nil {
 		_ = session.Process.Close()
 	}
+	if err := session.sandbox.release(session.WorkspaceDir); err != nil {
+		r.Logger.Error("session release failed", "session_id", sessionID, "error", err.Error())
+	}
+	r.Logger.Info("session removed", "session_id", sessionID)
+}
+
+// reserveSlot takes the next slot, which fixes the session's UID. Slots
+// are never handed out twice, so a session cannot reach files or processes
+// a removed one left behind under the same UID. Callers hold r.mu.
+func (r *Runner) reserveSlot() (int, error) {
+	if r.slots == nil {
+		r.slots = make(map[int]bool)
+	}
+	if r.MaxSessions > 0 && len(r.slots) >= r.MaxSessions {
+		return 0, ErrAgentAtCapacity
+	}
+	slot := r.nextSlot
+	r.nextSlot++
+	r.slots[slot] = true
+	return slot, nil
+}
+
+func (r *Runner) releaseSlot(slot int) {
+	r.mu.Lock()
+	delete(r.slots, slot)
+	r.mu.Unlock()
+}
+
+func (r *Runner) sandbox(sessionID string, slot int) (sessionSandbox, error) {
+	sandbox := sessionSandbox{}
+	if r.Isolation.UIDBase > 0 {
+		sandbox.uid = r.Isolation.UIDBase + slot
+	}
+	group, err := newSessionCgroup(r.Isolation, sessionID)
+	if err != nil {
+		return sessionSandbox{}, err
+	}
+	sandbox.cgroup = group
+	return sandbox, nil
 }
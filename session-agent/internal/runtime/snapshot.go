@@ -0,0 +1,56 @@
+package runtime
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+
+	"shared/sessionagent"
+)
+
+// Snapshot writes the session workspace as a gzip-compressed tar archive.
+// With includeState a Python REPL first pickles its globals into it.
+func (r *Runner) Snapshot(sessionID string, includeState bool, w io.Writer) error {
+	session, ok := r.GetSession(sessionID)
+	if !ok {
+		return ErrSessionNotRegistered
+	}
+	if session.WorkspaceDir == "" {
+		return sessionagent.ErrWorkspaceUnavailable
+	}
+	if includeState && sessionagent.SupportsReplState(session.Runtime) {
+		statePath := filepath.Join(session.WorkspaceDir, sessionagent.SnapshotStateFile)
+		defer os.Remove(statePath)
+		if err := session.Process.StateOp(sessionagent.ReplOpSaveState, statePath); err != nil {
+			return err
+		}
+	}
+	return sessionagent.ArchiveWorkspace(session.WorkspaceDir, w)
+}
+
+// Restore unpacks a snapshot archive into the session workspace and loads
+// any interpreter state it carries.
+func (r *Runner) Restore(sessionID string, body io.Reader) error {
+	session, ok := r.GetSession(sessionID)
+	if !ok {
+		return ErrSessionNotRegistered
+	}
+	if session.WorkspaceDir == "" {
+		return sessionagent.ErrWorkspaceUnavailable
+	}
+	if err := sessionagent.ExtractWorkspace(body, session.WorkspaceDir); err != nil {
+		return err
+	}
+	if err := session.sandbox.own(session.WorkspaceDir); err != nil {
+		return err
+	}
+	statePath := filepath.Join(session.WorkspaceDir, sessionagent.SnapshotStateFile)
+	if _, err := os.Stat(statePath); err != nil {
+		return nil
+	}
+	defer os.Remove(statePath)
+	if !sessionagent.SupportsReplState(session.Runtime) {
+		return nil
+	}
+	return session.Process.StateOp(sessionagent.ReplOpLoadState, statePath)
+}
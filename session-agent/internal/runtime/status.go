@@ -0,0 +1,42 @@
+package runtime
+
+import (
+	"sort"
+	"time"
+
+	"shared/sessionagent"
+)
+
+// Status reports the agent's sessions with their interpreter PIDs and
+// resident memory.
+func (r *Runner) Status() sessionagent.AgentStatus {
+	r.mu.RLock()
+	sessions := make([]*Session, 0, len(r.sessions))
+	for _, session := range r.sessions {
+		sessions = append(sessions, session)
+	}
+	r.mu.RUnlock()
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].ID < sessions[j].ID })
+
+	status := sessionagent.AgentStatus{
+		UptimeSeconds: int64(time.Since(r.started).Seconds()),
+		MaxSessions:   r.MaxSessions,
+		Sessions:      make([]sessionagent.SessionStatus, 0, len(sessions)),
+	}
+	for _, session := range sessions {
+		entry := sessionagent.SessionStatus{SessionID: session.ID, Runtime: session.Runtime}
+		if session.Process != nil {
+			entry.PID, entry.MemoryBytes = session.Process.status()
+			entry.Busy = session.Process.busy.Load()
+		}
+		status.Sessions = append(status.Sessions, entry)
+	}
+	return status
+}
+
+// SessionCount returns how many sessions the agent hosts.
+func (r *Runner) SessionCount() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.sessions)
+}
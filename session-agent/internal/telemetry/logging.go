@@ -1,19 +1,37 @@
 package telemetry
 
-import "log"
+import (
+	"log/slog"
+	"os"
+)
 
+// Logger writes JSON log lines. Attributes are key/value pairs; sessions
+// and steps are identified with "session_id" and "step_id". The zero
+// Logger writes through slog's default logger.
 type Logger struct {
-	component string
+	logger *slog.Logger
 }
 
 func NewLogger(component string) Logger {
-	return Logger{component: component}
+	return Logger{logger: slog.New(slog.NewJSONHandler(os.Stderr, nil)).With("component", component)}
 }
 
-func (l Logger) Info(msg string) {
-	log.Printf("component=%s level=info msg=%q", l.component, msg)
+func (l Logger) Info(msg string, attrs ...any) {
+	l.slog().Info(msg, attrs...)
 }
 
-func (l Logger) Error(msg string) {
-	log.Printf("component=%s level=error msg=%q", l.component, msg)
+func (l Logger) Error(msg string, attrs ...any) {
+	l.slog().Error(msg, attrs...)
+}
+
+// With returns a logger that adds attrs to every line.
+func (l Logger) With(attrs ...any) Logger {
+	return Logger{logger: l.slog().With(attrs...)}
+}
+
+func (l Logger) slog() *slog.Logger {
+	if l.logger == nil {
+		return slog.Default()
+	}
+	return l.logger
 }
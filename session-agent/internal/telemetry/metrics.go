@@ -0,0 +1,85 @@
+package telemetry
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics are the agent's Prometheus metrics. A nil *Metrics records
+// nothing, so runners built without one still work.
+type Metrics struct {
+	registry     *prometheus.Registry
+	steps        *prometheus.CounterVec
+	stepDuration *prometheus.HistogramVec
+	stepFailures *prometheus.CounterVec
+	replRestarts *prometheus.CounterVec
+}
+
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		steps: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "session_agent_steps_total",
+			Help: "Steps run, by runtime and final status.",
+		}, []string{"runtime", "status"}),
+		stepDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "session_agent_step_duration_seconds",
+			Help:    "Wall-clock time of steps, by runtime.",
+			Buckets: prometheus.ExponentialBuckets(0.01, 4, 8),
+		}, []string{"runtime"}),
+		stepFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "session_agent_step_failures_total",
+			Help: "Steps that raised an uncaught error (reason \"error\") or did not complete (reason is the status).",
+		}, []string{"runtime", "reason"}),
+		replRestarts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "session_agent_repl_restarts_total",
+			Help: "Session interpreters restarted after exiting or ignoring an interrupt.",
+		}, []string{"runtime"}),
+	}
+	m.registry.MustRegister(m.steps, m.stepDuration, m.stepFailures, m.replRestarts)
+	m.registry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	return m
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// SessionsGauge exports count as the number of registered sessions.
+func (m *Metrics) SessionsGauge(count func() int) {
+	if m == nil {
+		return
+	}
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "session_agent_sessions",
+		Help: "Sessions registered with the agent.",
+	}, func() float64 { return float64(count()) }))
+}
+
+// ObserveStep records a finished step; raised reports an uncaught error in
+// a step that otherwise completed.
+func (m *Metrics) ObserveStep(runtime string, status string, raised bool, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.steps.WithLabelValues(runtime, status).Inc()
+	m.stepDuration.WithLabelValues(runtime).Observe(duration.Seconds())
+	switch {
+	case status != "completed":
+		m.stepFailures.WithLabelValues(runtime, status).Inc()
+	case raised:
+		m.stepFailures.WithLabelValues(runtime, "error").Inc()
+	}
+}
+
+func (m *Metrics) ReplRestarted(runtime string) {
+	if m == nil {
+		return
+	}
+	m.replRestarts.WithLabelValues(runtime).Inc()
+}
@@ -0,0 +1,208 @@
+package contract
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+
+	"session-agent/internal/api"
+	"session-agent/internal/api/handlers"
+	"session-agent/internal/api/middleware"
+	"session-agent/internal/runtime"
+	"shared/sessionagent"
+)
+
+func TestAgentRejectsSessionsBeyondCapacity(t *testing.T) {
+	runner := runtime.NewRunner()
+	runner.MaxSessions = 1
+	sessionHandler := handlers.SessionHandler{Runner: runner}
+	server := httptest.NewServer(api.NewRouter(api.RouterDeps{
+		SessionsHandler:         http.HandlerFunc(sessionHandler.Register),
+		SessionTerminateHandler: http.HandlerFunc(sessionHandler.Terminate),
+	}))
+	defer server.Close()
+
+	register := func(sessionID string) int {
+		body, _ := json.Marshal(sessionagent.SessionRegisterRequest{SessionID: sessionID, Runtime: "python", WorkspaceDir: t.TempDir()})
+		resp, err := http.Post(server.URL+"/v1/sessions", "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("register %s: %v", sessionID, err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if status := register("session-a"); status != http.StatusOK {
+		t.Fatalf("expected 200, got %d", status)
+	}
+	defer runner.RemoveSession("session-a")
+	if status := register("session-b"); status != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 at capacity, got %d", status)
+	}
+	resp, err := http.Post(server.URL+"/v1/sessions/session-a/terminate", "application/json", nil)
+	if err != nil {
+		t.Fatalf("terminate: %v", err)
+	}
+	resp.Body.Close()
+	if status := register("session-b"); status != http.StatusOK {
+		t.Fatalf("expected 200 once a slot is free, got %d", status)
+	}
+	runner.RemoveSession("session-b")
+}
+
+func TestDenseSessionsRunAsSeparateUsers(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("per-session users need root")
+	}
+	probe := exec.Command("python3", "-c", "pass")
+	probe.SysProcAttr = &syscall.SysProcAttr{Credential: &syscall.Credential{Uid: 20000, Gid: 20000}}
+	if err := probe.Run(); err != nil {
+		t.Skipf("python3 is not usable by unprivileged users here: %v", err)
+	}
+	root := t.TempDir()
+	// Session users must be able to reach their workspaces.
+	for dir := root; dir != os.TempDir() && dir != "/"; dir = filepath.Dir(dir) {
+		if err := os.Chmod(dir, 0o755); err != nil {
+			t.Fatalf("chmod %s: %v", dir, err)
+		}
+	}
+	runner := runtime.NewRunner()
+	runner.MaxSessions = 2
+	runner.Isolation = runtime.SessionIsolation{UIDBase: 20000}
+	for _, id := range []string{"dense-a", "dense-b"} {
+		if _, err := runner.RegisterSession(sessionagent.SessionRegisterRequest{SessionID: id, Runtime: "python", WorkspaceDir: filepath.Join(root, id)}); err != nil {
+			t.Fatalf("register %s: %v", id, err)
+		}
+		defer runner.RemoveSession(id)
+	}
+
+	info, err := os.Stat(filepath.Join(root, "dense-b"))
+	if err != nil {
+		t.Fatalf("stat workspace: %v", err)
+	}
+	if info.Mode().Perm() != 0o700 {
+		t.Fatalf("expected a private workspace, got %v", info.Mode().Perm())
+	}
+	result, err := runner.RunStep(context.Background(), sessionagent.StepRequest{
+		SessionID: "dense-b",
+		StepID:    "step-1",
+		Code:      "import os\nprint(os.getuid())",
+	})
+	if err != nil || strings.TrimSpace(result.Stdout) != "20001" {
+		t.Fatalf("expected the second session to run as uid 20001, got %+v (%v)", result, err)
+	}
+	result, err = runner.RunStep(context.Background(), sessionagent.StepRequest{
+		SessionID: "dense-a",
+		StepID:    "step-2",
+		Code:      "import os\nos.listdir(" + `"` + filepath.Join(root, "dense-b") + `"` + ")",
+	})
+	if err != nil || !strings.Contains(result.Stderr, "PermissionError") {
+		t.Fatalf("expected another session's workspace to be unreadable, got %+v (%v)", result, err)
+	}
+
+	// A removed session leaves no process or workspace behind, and its UID
+	// is not handed to the next session.
+	result, err = runner.RunStep(context.Background(), sessionagent.StepRequest{
+		SessionID: "dense-a",
+		StepID:    "step-3",
+		Code:      "import subprocess\nprint(subprocess.Popen(['sleep', '60'], start_new_session=True).pid)",
+	})
+	if err != nil {
+		t.Fatalf("start background process: %v", err)
+	}
+	leftover, err := strconv.Atoi(strings.TrimSpace(result.Stdout))
+	if err != nil {
+		t.Fatalf("expected a pid, got %+v", result)
+	}
+	runner.RemoveSession("dense-a")
+	// A killed process may linger as a zombie until its new parent reaps it.
+	if status, err := os.ReadFile("/proc/" + strconv.Itoa(leftover) + "/status"); err == nil && !strings.Contains(string(status), "State:\tZ") {
+		t.Fatalf("expected the session's background process to be killed, got %s", status)
+	}
+	if _, err := os.Stat(filepath.Join(root, "dense-a")); !os.IsNotExist(err) {
+		t.Fatalf("expected the removed session's workspace to be deleted, got %v", err)
+	}
+	if _, err := runner.RegisterSession(sessionagent.SessionRegisterRequest{SessionID: "dense-c", Runtime: "python", WorkspaceDir: filepath.Join(root, "dense-c")}); err != nil {
+		t.Fatalf("register dense-c: %v", err)
+	}
+	defer runner.RemoveSession("dense-c")
+	result, err = runner.RunStep(context.Background(), sessionagent.StepRequest{
+		SessionID: "dense-c",
+		StepID:    "step-4",
+		Code:      "import os\nprint(os.getuid())",
+	})
+	if err != nil || strings.TrimSpace(result.Stdout) != "20002" {
+		t.Fatalf("expected a fresh uid for the new session, got %+v (%v)", result, err)
+	}
+}
+
+func TestAgentRegistrationCannotTakeOverSessions(t *testing.T) {
+	root := t.TempDir()
+	runner := runtime.NewRunner()
+	runner.WorkspaceRoot = root
+	sessionHandler := handlers.SessionHandler{Runner: runner, RequireToken: true}
+	server := httptest.NewServer(api.NewRouter(api.RouterDeps{
+		SessionsHandler: http.HandlerFunc(sessionHandler.Register),
+		AuthMiddleware:  middleware.RequireTokenMiddleware,
+		AdminMiddleware: middleware.RequireAgentTokenMiddleware("admin-token"),
+	}))
+	defer server.Close()
+
+	register := func(adminToken string, req sessionagent.SessionRegisterRequest) int {
+		body, _ := json.Marshal(req)
+		httpReq, _ := http.NewRequest(http.MethodPost, server.URL+"/v1/sessions", bytes.NewReader(body))
+		httpReq.Header.Set("Content-Type", "application/json")
+		httpReq.Header.Set("X-Session-Token", req.Token)
+		if adminToken != "" {
+			httpReq.Header.Set("X-Agent-Token", adminToken)
+		}
+		resp, err := http.DefaultClient.Do(httpReq)
+		if err != nil {
+			t.Fatalf("register %s: %v", req.SessionID, err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	owner := sessionagent.SessionRegisterRequest{SessionID: "victim", Runtime: "python", Token: "owner", WorkspaceDir: filepath.Join(root, "victim")}
+	if status := register("wrong", owner); status != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without the admin token, got %d", status)
+	}
+	if status := register("admin-token", owner); status != http.StatusOK {
+		t.Fatalf("expected 200, got %d", status)
+	}
+	defer runner.RemoveSession("victim")
+	if status := register("admin-token", owner); status != http.StatusOK {
+		t.Fatalf("expected a repeated registration to succeed, got %d", status)
+	}
+
+	hijack := owner
+	hijack.Token = "attacker"
+	if status := register("admin-token", hijack); status != http.StatusConflict {
+		t.Fatalf("expected 409 for a new token, got %d", status)
+	}
+	hijack = owner
+	hijack.WorkspaceDir = filepath.Join(root, "elsewhere")
+	if status := register("admin-token", hijack); status != http.StatusConflict {
+		t.Fatalf("expected 409 for a new workspace, got %d", status)
+	}
+	if err := runner.Authorize("victim", "owner"); err != nil {
+		t.Fatalf("expected the original token to stay valid: %v", err)
+	}
+
+	outside := sessionagent.SessionRegisterRequest{SessionID: "outside", Runtime: "python", Token: "t", WorkspaceDir: "/etc"}
+	if status := register("admin-token", outside); status != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a workspace outside the root, got %d", status)
+	}
+	outside.WorkspaceDir = filepath.Join(root, "..", "escape")
+	if status := register("admin-token", outside); status != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a workspace escaping the root, got %d", status)
+	}
+}
@@ -0,0 +1,128 @@
+package contract
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"session-agent/internal/api"
+	"session-agent/internal/api/handlers"
+	"session-agent/internal/runtime"
+	"shared/sessionagent"
+)
+
+func TestSessionFilesRoundTripWithinWorkspace(t *testing.T) {
+	workspace := t.TempDir()
+	outside := t.TempDir()
+	runner := runtime.NewRunner()
+	if _, err := runner.RegisterSession(sessionagent.SessionRegisterRequest{SessionID: "session-files", Runtime: "python", WorkspaceDir: workspace}); err != nil {
+		t.Fatalf("register session: %v", err)
+	}
+	defer runner.RemoveSession("session-files")
+	if err := os.Symlink(outside, filepath.Join(workspace, "escape")); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+	sessionHandler := handlers.SessionHandler{Runner: runner}
+	server := httptest.NewServer(api.NewRouter(api.RouterDeps{
+		FileUploadHandler:   http.HandlerFunc(sessionHandler.Upload),
+		FileDownloadHandler: http.HandlerFunc(sessionHandler.Download),
+	}))
+	defer server.Close()
+	filesURL := server.URL + "/v1/sessions/session-files/files?path="
+
+	upload := func(path string, content string, query string) *http.Response {
+		req, err := http.NewRequest(http.MethodPut, filesURL+path+query, strings.NewReader(content))
+		if err != nil {
+			t.Fatalf("new request: %v", err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("upload: %v", err)
+		}
+		return resp
+	}
+
+	resp := upload("data/input.csv", "a,b\n1,2\n", "")
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", resp.StatusCode)
+	}
+	var info sessionagent.FileInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		t.Fatalf("decode upload: %v", err)
+	}
+	sum := sha256.Sum256([]byte("a,b\n1,2\n"))
+	if info.Size != 8 || info.Checksum != hex.EncodeToString(sum[:]) {
+		t.Fatalf("unexpected file info %+v", info)
+	}
+	if data, err := os.ReadFile(filepath.Join(workspace, "data", "input.csv")); err != nil || string(data) != "a,b\n1,2\n" {
+		t.Fatalf("expected file in workspace, got %q (%v)", data, err)
+	}
+
+	download, err := http.Get(filesURL + "data/input.csv")
+	if err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	body, _ := io.ReadAll(download.Body)
+	download.Body.Close()
+	if download.StatusCode != http.StatusOK || string(body) != "a,b\n1,2\n" || download.Header.Get(sessionagent.FileChecksumHeader) != info.Checksum {
+		t.Fatalf("unexpected download %d %q", download.StatusCode, body)
+	}
+
+	for _, path := range []string{"../secret", "/etc/passwd", "escape/secret"} {
+		resp := upload(path, "x", "")
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %q, got %d", path, resp.StatusCode)
+		}
+	}
+	if entries, _ := os.ReadDir(outside); len(entries) != 0 {
+		t.Fatalf("expected nothing written outside the workspace, got %v", entries)
+	}
+
+	// Symlinks are never followed, and only regular files are served.
+	if err := os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0o600); err != nil {
+		t.Fatalf("write secret: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret.txt"), filepath.Join(workspace, "leak.txt")); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+	if err := syscall.Mkfifo(filepath.Join(workspace, "pipe"), 0o600); err != nil {
+		t.Fatalf("mkfifo: %v", err)
+	}
+	for _, path := range []string{"leak.txt", "escape/secret.txt", "pipe"} {
+		resp, err := http.Get(filesURL + path)
+		if err != nil {
+			t.Fatalf("download %s: %v", path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400 downloading %q, got %d", path, resp.StatusCode)
+		}
+	}
+
+	resp = upload("big.bin", strings.Repeat("x", 64), "&maxBytes=16")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413, got %d", resp.StatusCode)
+	}
+	if _, err := os.Stat(filepath.Join(workspace, "big.bin")); !os.IsNotExist(err) {
+		t.Fatalf("expected oversized upload to be discarded")
+	}
+	missing, err := http.Get(filesURL + "missing.txt")
+	if err != nil {
+		t.Fatalf("download missing: %v", err)
+	}
+	missing.Body.Close()
+	if missing.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", missing.StatusCode)
+	}
+}
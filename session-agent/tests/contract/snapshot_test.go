@@ -0,0 +1,82 @@
+package contract
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"session-agent/internal/api"
+	"session-agent/internal/api/handlers"
+	"session-agent/internal/runtime"
+	"shared/sessionagent"
+)
+
+func TestSessionSnapshotRestoresWorkspaceAndState(t *testing.T) {
+	runner := runtime.NewRunner()
+	source := t.TempDir()
+	target := t.TempDir()
+	for id, dir := range map[string]string{"session-source": source, "session-target": target} {
+		if _, err := runner.RegisterSession(sessionagent.SessionRegisterRequest{SessionID: id, Runtime: "python", WorkspaceDir: dir}); err != nil {
+			t.Fatalf("register %s: %v", id, err)
+		}
+		defer runner.RemoveSession(id)
+	}
+	ctx := context.Background()
+	if _, err := runner.RunStep(ctx, sessionagent.StepRequest{SessionID: "session-source", StepID: "step-1", Code: "import os\nos.makedirs('notes', exist_ok=True)\nopen('notes/a.txt', 'w').write('hi')\nx = 41"}); err != nil {
+		t.Fatalf("run step: %v", err)
+	}
+
+	sessionHandler := handlers.SessionHandler{Runner: runner}
+	server := httptest.NewServer(api.NewRouter(api.RouterDeps{
+		SnapshotHandler: http.HandlerFunc(sessionHandler.Snapshot),
+		RestoreHandler:  http.HandlerFunc(sessionHandler.Restore),
+	}))
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/v1/sessions/session-source/snapshot?state=true", "", nil)
+	if err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	archive, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", resp.StatusCode, archive)
+	}
+	if _, err := os.Stat(filepath.Join(source, sessionagent.SnapshotStateFile)); !os.IsNotExist(err) {
+		t.Fatalf("expected state file to be removed after snapshot, got %v", err)
+	}
+
+	resp, err = http.Post(server.URL+"/v1/sessions/session-target/restore", "application/gzip", bytes.NewReader(archive))
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", resp.StatusCode)
+	}
+	if data, err := os.ReadFile(filepath.Join(target, "notes", "a.txt")); err != nil || string(data) != "hi" {
+		t.Fatalf("expected restored file, got %q (%v)", data, err)
+	}
+	result, err := runner.RunStep(ctx, sessionagent.StepRequest{SessionID: "session-target", StepID: "step-2", Code: "print(x + 1)"})
+	if err != nil {
+		t.Fatalf("run restored step: %v", err)
+	}
+	if strings.TrimSpace(result.Stdout) != "42" {
+		t.Fatalf("expected restored globals, got stdout %q stderr %q", result.Stdout, result.Stderr)
+	}
+
+	resp, err = http.Post(server.URL+"/v1/sessions/session-target/restore", "application/gzip", strings.NewReader("not a snapshot"))
+	if err != nil {
+		t.Fatalf("restore garbage: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid archive, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,94 @@
+package contract
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"session-agent/internal/api"
+	"session-agent/internal/api/handlers"
+	"session-agent/internal/api/middleware"
+	"session-agent/internal/runtime"
+	"session-agent/internal/telemetry"
+	"shared/sessionagent"
+)
+
+func TestAgentStatusAndMetrics(t *testing.T) {
+	metrics := telemetry.NewMetrics()
+	runner := runtime.NewRunner()
+	runner.MaxSessions = 4
+	runner.Metrics = metrics
+	metrics.SessionsGauge(runner.SessionCount)
+	if _, err := runner.RegisterSession(sessionagent.SessionRegisterRequest{SessionID: "session-status", Runtime: "python", WorkspaceDir: t.TempDir()}); err != nil {
+		t.Fatalf("register session: %v", err)
+	}
+	defer runner.RemoveSession("session-status")
+	for _, code := range []string{"x = 1", "raise ValueError('boom')"} {
+		if _, err := runner.RunStep(context.Background(), sessionagent.StepRequest{SessionID: "session-status", StepID: code, Code: code}); err != nil {
+			t.Fatalf("run step: %v", err)
+		}
+	}
+	server := httptest.NewServer(api.NewRouter(api.RouterDeps{
+		StatusHandler:   handlers.StatusHandler{Runner: runner},
+		MetricsHandler:  metrics.Handler(),
+		AuthMiddleware:  middleware.RequireTokenMiddleware,
+		AdminMiddleware: middleware.RequireAgentTokenMiddleware("admin-token"),
+	}))
+	defer server.Close()
+	get := func(path string, token string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, server.URL+path, nil)
+		if err != nil {
+			t.Fatalf("new request: %v", err)
+		}
+		if token != "" {
+			req.Header.Set("X-Agent-Token", token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("get %s: %v", path, err)
+		}
+		return resp
+	}
+	for _, path := range []string{"/v1/status", "/metrics"} {
+		for _, token := range []string{"", "token-1"} {
+			resp := get(path, token)
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusUnauthorized {
+				t.Fatalf("expected %d for %s with token %q, got %d", http.StatusUnauthorized, path, token, resp.StatusCode)
+			}
+		}
+	}
+
+	resp := get("/v1/status", "admin-token")
+	defer resp.Body.Close()
+	var status sessionagent.AgentStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatalf("decode status: %v", err)
+	}
+	if status.MaxSessions != 4 || len(status.Sessions) != 1 || !status.HasCapacity() {
+		t.Fatalf("unexpected status %+v", status)
+	}
+	session := status.Sessions[0]
+	if session.SessionID != "session-status" || session.PID == 0 || session.MemoryBytes == 0 {
+		t.Fatalf("expected the session with its interpreter pid and memory, got %+v", session)
+	}
+
+	resp = get("/metrics", "admin-token")
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	for _, want := range []string{
+		`session_agent_steps_total{runtime="python",status="completed"} 2`,
+		`session_agent_step_failures_total{reason="error",runtime="python"} 1`,
+		`session_agent_step_duration_seconds_count{runtime="python"} 2`,
+		`session_agent_sessions 1`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Fatalf("expected %q in metrics:\n%s", want, body)
+		}
+	}
+}
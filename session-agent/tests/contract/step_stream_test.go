@@ -0,0 +1,92 @@
+package contract
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"session-agent/internal/api"
+	"session-agent/internal/api/handlers"
+	"session-agent/internal/runtime"
+	"shared/sessionagent"
+)
+
+func TestStepStreamDeliversChunksBeforeCompletion(t *testing.T) {
+	runner := runtime.NewRunner()
+	if _, err := runner.RegisterSession(sessionagent.SessionRegisterRequest{SessionID: "session-stream", Runtime: "python"}); err != nil {
+		t.Fatalf("register session: %v", err)
+	}
+	defer runner.RemoveSession("session-stream")
+	stepHandler := handlers.StepHandler{Runner: runner}
+	server := httptest.NewServer(api.NewRouter(api.RouterDeps{StepStreamHandler: http.HandlerFunc(stepHandler.Stream)}))
+	defer server.Close()
+
+	code := "import sys, time\nprint('first')\ntime.sleep(0.5)\nsys.stderr.write('warn\\n')\nraise ValueError('boom')"
+	body, err := json.Marshal(sessionagent.StepRequest{SessionID: "session-stream", Code: code})
+	if err != nil {
+		t.Fatalf("marshal payload: %v", err)
+	}
+	start := time.Now()
+	resp, err := http.Post(server.URL+"/v1/steps/step-1/stream", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("post: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+
+	var events []sessionagent.StepEvent
+	var firstAt time.Duration
+	result, err := sessionagent.ReadStepEvents(resp.Body, func(event sessionagent.StepEvent) error {
+		if len(events) == 0 {
+			firstAt = time.Since(start)
+		}
+		events = append(events, event)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("read events: %v", err)
+	}
+	if firstAt >= 500*time.Millisecond {
+		t.Fatalf("expected first chunk before the step finished, got it after %s", firstAt)
+	}
+	var kinds []string
+	for _, event := range events {
+		kinds = append(kinds, event.Type)
+	}
+	if got := strings.Join(kinds, ","); !strings.HasPrefix(got, "stdout,stdout,stderr,stderr,status") {
+		t.Fatalf("unexpected event order %q", got)
+	}
+	if !strings.Contains(events[3].Data, "ValueError: boom") {
+		t.Fatalf("expected traceback chunk, got %q", events[3].Data)
+	}
+	if result.StepID != "step-1" || result.Status != sessionagent.StepStatusCompleted {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	for _, tc := range []struct {
+		req  sessionagent.StepRequest
+		want int
+	}{
+		{sessionagent.StepRequest{SessionID: "session-stream"}, http.StatusBadRequest},
+		{sessionagent.StepRequest{SessionID: "session-unknown", Code: "print(1)"}, http.StatusNotFound},
+	} {
+		body, err := json.Marshal(tc.req)
+		if err != nil {
+			t.Fatalf("marshal payload: %v", err)
+		}
+		resp, err := http.Post(server.URL+"/v1/steps/step-2/stream", "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("post: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.want || resp.Header.Get("Content-Type") == "text/event-stream" {
+			t.Fatalf("expected a plain %d for %+v, got %d %q", tc.want, tc.req, resp.StatusCode, resp.Header.Get("Content-Type"))
+		}
+	}
+}
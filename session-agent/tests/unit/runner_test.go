@@ -1,8 +1,12 @@
 package unit
 
 import (
+	"context"
 	"errors"
+	"os/exec"
+	"strings"
 	"testing"
+	"time"
 
 	"session-agent/internal/runtime"
 	"shared/sessionagent"
@@ -55,3 +59,261 @@ func TestRunnerRemoveSession(t *testing.T) {
 		t.Fatalf("expected session to be removed")
 	}
 }
+
+func TestRunnerStepReportsResourceUsage(t *testing.T) {
+	runner := runtime.NewRunner()
+	if _, err := runner.RegisterSession(sessionagent.SessionRegisterRequest{
+		SessionID: "session-usage",
+		Runtime:   "python",
+		Token:     "token-usage",
+	}); err != nil {
+		t.Fatalf("register session: %v", err)
+	}
+	defer runner.RemoveSession("session-usage")
+	result, err := runner.RunStep(context.Background(), sessionagent.StepRequest{
+		SessionID: "session-usage",
+		StepID:    "step-1",
+		Code:      "import time\nend = time.process_time() + 0.2\nwhile time.process_time() < end:\n    pass\ndata = bytearray(32 * 1024 * 1024)",
+	})
+	if err != nil {
+		t.Fatalf("run step: %v", err)
+	}
+	if result.Status != sessionagent.StepStatusCompleted {
+		t.Fatalf("unexpected status %q: %s", result.Status, result.Stderr)
+	}
+	if result.ResourceUsage.CPUTimeMs < 100 {
+		t.Fatalf("expected cpu time to be reported, got %+v", result.ResourceUsage)
+	}
+	if result.ResourceUsage.MaxRSSBytes < 32*1024*1024 {
+		t.Fatalf("expected peak rss to cover the allocation, got %+v", result.ResourceUsage)
+	}
+}
+
+func TestRunnerStepTimeoutInterruptsPython(t *testing.T) {
+	runner := runtime.NewRunner()
+	if _, err := runner.RegisterSession(sessionagent.SessionRegisterRequest{
+		SessionID: "session-timeout",
+		Runtime:   "python",
+	}); err != nil {
+		t.Fatalf("register session: %v", err)
+	}
+	defer runner.RemoveSession("session-timeout")
+	run := func(stepID string, code string, timeoutMs int64) sessionagent.StepResult {
+		t.Helper()
+		result, err := runner.RunStep(context.Background(), sessionagent.StepRequest{
+			SessionID: "session-timeout",
+			StepID:    stepID,
+			Code:      code,
+			TimeoutMs: timeoutMs,
+		})
+		if err != nil {
+			t.Fatalf("run step %s: %v", stepID, err)
+		}
+		return result
+	}
+	run("step-1", "x = 41", 0)
+	result := run("step-2", "while True:\n    pass", 200)
+	if result.Status != sessionagent.StepStatusTimedOut {
+		t.Fatalf("expected timed_out, got %q", result.Status)
+	}
+	if !strings.Contains(result.Stderr, "KeyboardInterrupt") {
+		t.Fatalf("expected KeyboardInterrupt in stderr, got %q", result.Stderr)
+	}
+	result = run("step-3", "print(x + 1)", 0)
+	if result.Status != sessionagent.StepStatusCompleted || result.Stdout != "42\n" {
+		t.Fatalf("expected session state to survive the interrupt, got %+v", result)
+	}
+}
+
+func TestRunnerPassesRawStdoutThrough(t *testing.T) {
+	runner := runtime.NewRunner()
+	if _, err := runner.RegisterSession(sessionagent.SessionRegisterRequest{
+		SessionID: "session-raw",
+		Runtime:   "python",
+	}); err != nil {
+		t.Fatalf("register session: %v", err)
+	}
+	defer runner.RemoveSession("session-raw")
+	result, err := runner.RunStep(context.Background(), sessionagent.StepRequest{
+		SessionID: "session-raw",
+		StepID:    "step-1",
+		Code:      "import os, subprocess\nx = 1\nsubprocess.run(['echo', 'from child'])\nos.write(1, b'no newline')",
+	})
+	if err != nil {
+		t.Fatalf("run step: %v", err)
+	}
+	if result.Status != sessionagent.StepStatusCompleted || result.Stdout != "from child\nno newline" {
+		t.Fatalf("expected raw output as stdout, got %+v", result)
+	}
+	result, err = runner.RunStep(context.Background(), sessionagent.StepRequest{
+		SessionID: "session-raw",
+		StepID:    "step-2",
+		Code:      "print(x)",
+	})
+	if err != nil {
+		t.Fatalf("run step: %v", err)
+	}
+	if result.Status != sessionagent.StepStatusCompleted || result.Stdout != "1\n" || result.StateLost {
+		t.Fatalf("expected the interpreter to keep its state, got %+v", result)
+	}
+}
+
+func TestRunnerInterruptRestartsUnresponsiveRepl(t *testing.T) {
+	runner := runtime.NewRunner()
+	if _, err := runner.RegisterSession(sessionagent.SessionRegisterRequest{
+		SessionID: "session-interrupt",
+		Runtime:   "python",
+	}); err != nil {
+		t.Fatalf("register session: %v", err)
+	}
+	defer runner.RemoveSession("session-interrupt")
+	if interrupted, err := runner.Interrupt("session-interrupt"); err != nil || interrupted {
+		t.Fatalf("expected idle session not to be interrupted, got %v %v", interrupted, err)
+	}
+	go func() {
+		for i := 0; i < 50; i++ {
+			time.Sleep(100 * time.Millisecond)
+			if interrupted, _ := runner.Interrupt("session-interrupt"); interrupted {
+				return
+			}
+		}
+	}()
+	result, err := runner.RunStep(context.Background(), sessionagent.StepRequest{
+		SessionID: "session-interrupt",
+		StepID:    "step-1",
+		Code:      "import signal\nsignal.signal(signal.SIGINT, signal.SIG_IGN)\nx = 1\nwhile True:\n    pass",
+	})
+	if err != nil {
+		t.Fatalf("run step: %v", err)
+	}
+	if result.Status != sessionagent.StepStatusInterrupted {
+		t.Fatalf("expected interrupted, got %q", result.Status)
+	}
+	if !strings.Contains(result.Stderr, "restarted") || !result.StateLost {
+		t.Fatalf("expected restart notice and state loss, got %+v", result)
+	}
+	result, err = runner.RunStep(context.Background(), sessionagent.StepRequest{
+		SessionID: "session-interrupt",
+		StepID:    "step-2",
+		Code:      "print('x' in globals())",
+	})
+	if err != nil {
+		t.Fatalf("run step after restart: %v", err)
+	}
+	if result.Status != sessionagent.StepStatusCompleted || result.Stdout != "False\n" {
+		t.Fatalf("expected a fresh interpreter, got %+v", result)
+	}
+}
+
+func TestRunnerStepTimeoutInterruptsNode(t *testing.T) {
+	if _, err := exec.LookPath("node"); err != nil {
+		t.Skip("node not installed")
+	}
+	runner := runtime.NewRunner()
+	if _, err := runner.RegisterSession(sessionagent.SessionRegisterRequest{
+		SessionID: "session-node-timeout",
+		Runtime:   "node",
+	}); err != nil {
+		t.Fatalf("register session: %v", err)
+	}
+	defer runner.RemoveSession("session-node-timeout")
+	result, err := runner.RunStep(context.Background(), sessionagent.StepRequest{
+		SessionID: "session-node-timeout",
+		StepID:    "step-1",
+		Code:      "globalThis.kept = 7; while (true) {}",
+		TimeoutMs: 200,
+	})
+	if err != nil {
+		t.Fatalf("run step: %v", err)
+	}
+	if result.Status != sessionagent.StepStatusTimedOut {
+		t.Fatalf("expected timed_out, got %q: %s", result.Status, result.Stderr)
+	}
+	result, err = runner.RunStep(context.Background(), sessionagent.StepRequest{
+		SessionID: "session-node-timeout",
+		StepID:    "step-2",
+		Code:      "console.log(kept)",
+	})
+	if err != nil || result.Stdout != "7\n" {
+		t.Fatalf("expected session state to survive the interrupt, got %+v %v", result, err)
+	}
+}
+
+func TestRunnerRestartsCrashedRepl(t *testing.T) {
+	runner := runtime.NewRunner()
+	if _, err := runner.RegisterSession(sessionagent.SessionRegisterRequest{
+		SessionID: "session-crash",
+		Runtime:   "python",
+	}); err != nil {
+		t.Fatalf("register session: %v", err)
+	}
+	defer runner.RemoveSession("session-crash")
+	run := func(stepID string, code string) sessionagent.StepResult {
+		t.Helper()
+		result, err := runner.RunStep(context.Background(), sessionagent.StepRequest{
+			SessionID: "session-crash",
+			StepID:    stepID,
+			Code:      code,
+		})
+		if err != nil {
+			t.Fatalf("run step %s: %v", stepID, err)
+		}
+		return result
+	}
+
+	result := run("step-1", "import os\nx = 1\nprint('before exit')\nos._exit(3)")
+	if result.Status != sessionagent.StepStatusFailed || !result.StateLost || !strings.Contains(result.Stderr, "exit status 3") {
+		t.Fatalf("expected crash to be reported with state loss, got %+v", result)
+	}
+	if result.Stdout != "before exit\n" {
+		t.Fatalf("expected output written before the crash to be kept, got %q", result.Stdout)
+	}
+	result = run("step-2", "print('x' in globals())")
+	if result.Status != sessionagent.StepStatusCompleted || result.StateLost || result.Stdout != "False\n" {
+		t.Fatalf("expected a fresh interpreter without a repeated state-loss flag, got %+v", result)
+	}
+
+	// A crash between steps is reported by the next step. As the second
+	// crash in a row, its restart waits half a second.
+	run("step-3", "import os, threading\nthreading.Timer(0.1, lambda: os._exit(1)).start()")
+	time.Sleep(1500 * time.Millisecond)
+	result = run("step-4", "print('ok')")
+	if result.Status != sessionagent.StepStatusCompleted || !result.StateLost || result.Stdout != "ok\n" {
+		t.Fatalf("expected idle crash to be reported by the next step, got %+v", result)
+	}
+
+	// The third crash in a row waits a second; steps in between fail.
+	run("step-5", "import os\nos._exit(1)")
+	if result := run("step-6", "print('ok')"); result.Status != sessionagent.StepStatusFailed || !strings.Contains(result.Stderr, "restarting in 1s") {
+		t.Fatalf("expected the restart to be delayed, got %+v", result)
+	}
+	time.Sleep(1500 * time.Millisecond)
+	if result := run("step-7", "print('ok')"); result.Status != sessionagent.StepStatusCompleted || result.Stdout != "ok\n" {
+		t.Fatalf("expected the interpreter back after the delay, got %+v", result)
+	}
+}
+
+func TestRunnerWithoutRestartFailsAfterCrash(t *testing.T) {
+	runner := runtime.NewRunner()
+	runner.RestartCrashed = false
+	if _, err := runner.RegisterSession(sessionagent.SessionRegisterRequest{
+		SessionID: "session-no-restart",
+		Runtime:   "python",
+	}); err != nil {
+		t.Fatalf("register session: %v", err)
+	}
+	defer runner.RemoveSession("session-no-restart")
+	for _, stepID := range []string{"step-1", "step-2"} {
+		result, err := runner.RunStep(context.Background(), sessionagent.StepRequest{
+			SessionID: "session-no-restart",
+			StepID:    stepID,
+			Code:      "import os\nos._exit(1)",
+		})
+		if err != nil {
+			t.Fatalf("run step: %v", err)
+		}
+		if result.Status != sessionagent.StepStatusFailed {
+			t.Fatalf("expected %s to fail, got %+v", stepID, result)
+		}
+	}
+}
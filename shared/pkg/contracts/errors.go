@@ -13,8 +13,8 @@ const (
 )
 
 type Error struct {
-	Code    ErrorCode
-	Message string
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message,omitempty"`
 }
 
 func (e Error) Error() string {
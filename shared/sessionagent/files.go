@@ -0,0 +1,72 @@
+package sessionagent
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+var (
+	ErrInvalidFilePath      = errors.New("file path must be relative to the session workspace")
+	ErrFileTooLarge         = errors.New("file exceeds the size limit")
+	ErrWorkspaceUnavailable = errors.New("session has no workspace")
+)
+
+// FileInfo describes a file uploaded to a session workspace. Checksum is the
+// hex SHA-256 of the content.
+type FileInfo struct {
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	Checksum string `json:"checksum"`
+}
+
+// Headers carrying a downloaded file's checksum and the upload or download
+// size limit on the raw file endpoints.
+const (
+	FileChecksumHeader = "X-Checksum-Sha256"
+	FileMaxBytesParam  = "maxBytes"
+)
+
+// WriteOptions control how WriteWorkspaceFile stores a file. A MaxBytes of
+// zero means no limit and a zero Mode means 0600. With a positive UID the
+// file, and any directories created for it, are owned by UID:UID.
+type WriteOptions struct {
+	MaxBytes int64
+	Mode     fs.FileMode
+	UID      int
+}
+
+// workspaceName validates a client-supplied path relative to the workspace
+// root and returns it cleaned. Absolute paths, paths climbing out with ".."
+// and the root itself are rejected.
+func workspaceName(root string, name string) (string, error) {
+	if root == "" {
+		return "", ErrWorkspaceUnavailable
+	}
+	if !filepath.IsLocal(name) {
+		return "", ErrInvalidFilePath
+	}
+	name = filepath.Clean(name)
+	if name == "." {
+		return "", ErrInvalidFilePath
+	}
+	return name, nil
+}
+
+// ChecksumFile returns the size and hex SHA-256 of file, leaving its
+// offset at the start for the caller to read it again.
+func ChecksumFile(file *os.File) (int64, string, error) {
+	hasher := sha256.New()
+	size, err := io.Copy(hasher, file)
+	if err != nil {
+		return 0, "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return 0, "", err
+	}
+	return size, hex.EncodeToString(hasher.Sum(nil)), nil
+}
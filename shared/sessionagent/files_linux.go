@@ -0,0 +1,203 @@
+//go:build linux
+
+package sessionagent
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// WriteWorkspaceFile streams r into name under the workspace root, creating
+// parent directories. The file is written next to its destination and
+// renamed into place, so readers never see a partial upload.
+func WriteWorkspaceFile(root string, name string, r io.Reader, opts WriteOptions) (FileInfo, error) {
+	clean, err := workspaceName(root, name)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	dirFD, err := openWorkspaceDir(root, filepath.Dir(clean), true, opts.UID)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	defer syscall.Close(dirFD)
+	base := filepath.Base(clean)
+	tmpName, tmp, err := createTempAt(dirFD, filepath.Join(root, filepath.Dir(clean)), base)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	defer syscall.Unlinkat(dirFD, tmpName)
+	src := r
+	if opts.MaxBytes > 0 {
+		src = io.LimitReader(r, opts.MaxBytes+1)
+	}
+	hasher := sha256.New()
+	written, err := io.Copy(io.MultiWriter(tmp, hasher), src)
+	if err == nil {
+		err = finishTemp(tmp, opts)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return FileInfo{}, err
+	}
+	if opts.MaxBytes > 0 && written > opts.MaxBytes {
+		return FileInfo{}, ErrFileTooLarge
+	}
+	// renameat replaces a symlink at base rather than following it.
+	if err := syscall.Renameat(dirFD, tmpName, dirFD, base); err != nil {
+		return FileInfo{}, &os.PathError{Op: "rename", Path: filepath.Join(root, clean), Err: err}
+	}
+	return FileInfo{Path: name, Size: written, Checksum: hex.EncodeToString(hasher.Sum(nil))}, nil
+}
+
+func finishTemp(tmp *os.File, opts WriteOptions) error {
+	mode := opts.Mode.Perm()
+	if mode == 0 {
+		mode = 0o600
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		return err
+	}
+	if opts.UID > 0 {
+		return tmp.Chown(opts.UID, opts.UID)
+	}
+	return nil
+}
+
+// createTempAt creates a uniquely named file for base in the directory
+// dirFD, which is dir.
+func createTempAt(dirFD int, dir string, base string) (string, *os.File, error) {
+	for {
+		name := "." + base + ".upload-" + strconv.FormatUint(uint64(rand.Uint32()), 10)
+		fd, err := syscall.Openat(dirFD, name, syscall.O_WRONLY|syscall.O_CREAT|syscall.O_EXCL|syscall.O_NOFOLLOW|syscall.O_CLOEXEC, 0o600)
+		if errors.Is(err, syscall.EEXIST) {
+			continue
+		}
+		if err != nil {
+			return "", nil, &os.PathError{Op: "create", Path: filepath.Join(dir, name), Err: err}
+		}
+		return name, os.NewFile(uintptr(fd), filepath.Join(dir, name)), nil
+	}
+}
+
+// OpenWorkspaceFile opens name under the workspace root for download. Only
+// regular files can be opened.
+func OpenWorkspaceFile(root string, name string) (*os.File, os.FileInfo, error) {
+	clean, err := workspaceName(root, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	dirFD, err := openWorkspaceDir(root, filepath.Dir(clean), false, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer syscall.Close(dirFD)
+	path := filepath.Join(root, clean)
+	// O_NONBLOCK keeps a FIFO planted in the workspace from blocking the
+	// open; it is refused below.
+	fd, err := syscall.Openat(dirFD, filepath.Base(clean), syscall.O_RDONLY|syscall.O_NOFOLLOW|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, workspacePathError(path, err)
+	}
+	if err := syscall.SetNonblock(fd, false); err != nil {
+		_ = syscall.Close(fd)
+		return nil, nil, err
+	}
+	file := os.NewFile(uintptr(fd), path)
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, nil, err
+	}
+	if !info.Mode().IsRegular() {
+		_ = file.Close()
+		return nil, nil, ErrInvalidFilePath
+	}
+	return file, info, nil
+}
+
+// MakeWorkspaceDir creates the directory name, and any missing parents,
+// under the workspace root.
+func MakeWorkspaceDir(root string, name string) error {
+	if name == "." {
+		return nil
+	}
+	clean, err := workspaceName(root, name)
+	if err != nil {
+		return err
+	}
+	fd, err := openWorkspaceDir(root, clean, true, 0)
+	if err != nil {
+		return err
+	}
+	return syscall.Close(fd)
+}
+
+// openWorkspaceDir opens dir, a cleaned local path, under root one
+// component at a time. No component may be a symlink, so a link the
+// session swaps in cannot send the caller outside the workspace. With
+// create, missing directories are made, owned by uid when it is positive.
+func openWorkspaceDir(root string, dir string, create bool, uid int) (int, error) {
+	fd, err := syscall.Open(root, syscall.O_RDONLY|syscall.O_DIRECTORY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return -1, &os.PathError{Op: "open", Path: root, Err: err}
+	}
+	if dir == "." {
+		return fd, nil
+	}
+	for _, part := range strings.Split(dir, string(filepath.Separator)) {
+		next, err := openDirAt(fd, part)
+		if errors.Is(err, syscall.ENOENT) && create {
+			next, err = makeDirAt(fd, part, uid)
+		}
+		_ = syscall.Close(fd)
+		if err != nil {
+			return -1, workspacePathError(filepath.Join(root, dir), err)
+		}
+		fd = next
+	}
+	return fd, nil
+}
+
+func openDirAt(dirFD int, name string) (int, error) {
+	return syscall.Openat(dirFD, name, syscall.O_RDONLY|syscall.O_DIRECTORY|syscall.O_NOFOLLOW|syscall.O_CLOEXEC, 0)
+}
+
+// makeDirAt creates and opens the directory name in dirFD, tolerating one
+// created concurrently, which keeps its owner.
+func makeDirAt(dirFD int, name string, uid int) (int, error) {
+	err := syscall.Mkdirat(dirFD, name, 0o750)
+	if err != nil && !errors.Is(err, syscall.EEXIST) {
+		return -1, err
+	}
+	created := err == nil
+	fd, err := openDirAt(dirFD, name)
+	if err != nil {
+		return -1, err
+	}
+	if created && uid > 0 {
+		if err := syscall.Fchown(fd, uid, uid); err != nil {
+			_ = syscall.Close(fd)
+			return -1, err
+		}
+	}
+	return fd, nil
+}
+
+// workspacePathError reports a symlink, or a file where a directory was
+// expected, as ErrInvalidFilePath.
+func workspacePathError(path string, err error) error {
+	if errors.Is(err, syscall.ELOOP) || errors.Is(err, syscall.ENOTDIR) {
+		return ErrInvalidFilePath
+	}
+	return &os.PathError{Op: "open", Path: path, Err: err}
+}
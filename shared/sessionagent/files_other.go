@@ -0,0 +1,23 @@
+//go:build !linux
+
+package sessionagent
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+var errWorkspaceFilesUnsupported = errors.New("workspace files require linux")
+
+func WriteWorkspaceFile(root string, name string, r io.Reader, opts WriteOptions) (FileInfo, error) {
+	return FileInfo{}, errWorkspaceFilesUnsupported
+}
+
+func OpenWorkspaceFile(root string, name string) (*os.File, os.FileInfo, error) {
+	return nil, nil, errWorkspaceFilesUnsupported
+}
+
+func MakeWorkspaceDir(root string, name string) error {
+	return errWorkspaceFilesUnsupported
+}
@@ -0,0 +1,87 @@
+package sessionagent
+
+import (
+	"bufio"
+	"encoding/json"
+	"strings"
+)
+
+// ReplFrame is one line the session REPLs write to stdout: output chunks
+// while a step runs, then a "done" frame with any uncaught error.
+type ReplFrame struct {
+	Type  string `json:"type"`
+	Data  string `json:"data,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+const (
+	ReplFrameReady = "ready"
+	ReplFrameDone  = "done"
+)
+
+// ReadReplStep consumes the frames of one step, handing each output chunk
+// to emit as it arrives, and returns the uncaught error text, if any.
+// Output that bypasses the REPL's writers, such as a child process writing
+// to the inherited stdout, is passed on as stdout.
+func ReadReplStep(reader *bufio.Reader, emit func(stream string, data string)) (string, error) {
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		raw, frame, ok := splitReplFrame(line)
+		if raw != "" && emit != nil {
+			emit(StepEventStdout, raw)
+		}
+		if !ok {
+			continue
+		}
+		switch frame.Type {
+		case ReplFrameDone:
+			return frame.Error, nil
+		case StepEventStdout, StepEventStderr:
+			if emit != nil && frame.Data != "" {
+				emit(frame.Type, frame.Data)
+			}
+		}
+	}
+}
+
+// splitReplFrame finds the frame a stdout line ends with. Raw output
+// written without a trailing newline shares its line with the next frame,
+// so whatever precedes the frame is returned as raw output; a line without
+// a frame is raw output as a whole.
+func splitReplFrame(line string) (string, ReplFrame, bool) {
+	for start := 0; start < len(line); {
+		offset := strings.Index(line[start:], `{"type"`)
+		if offset < 0 {
+			break
+		}
+		start += offset
+		var frame ReplFrame
+		if err := json.Unmarshal([]byte(strings.TrimSpace(line[start:])), &frame); err == nil && isReplFrameType(frame.Type) {
+			return line[:start], frame, true
+		}
+		start++
+	}
+	return line, ReplFrame{}, false
+}
+
+func isReplFrameType(value string) bool {
+	switch value {
+	case ReplFrameReady, ReplFrameDone, StepEventStdout, StepEventStderr:
+		return true
+	}
+	return false
+}
+
+// JoinStepError appends an uncaught error to a step's stderr.
+func JoinStepError(stderr string, failure string) string {
+	if failure == "" {
+		return stderr
+	}
+	if stderr == "" {
+		return failure
+	}
+	return stderr + "\n" + failure
+}
@@ -0,0 +1,38 @@
+package sessionagent
+
+import "time"
+
+const (
+	minRestartDelay = 500 * time.Millisecond
+	maxRestartDelay = 30 * time.Second
+	// restartWindow is how long a restarted interpreter has to stay up for
+	// the next crash to be restarted immediately again.
+	restartWindow = time.Minute
+)
+
+// RestartBackoff spaces out the restarts of an interpreter that keeps
+// crashing. The first restart is immediate; each further one within
+// restartWindow of the previous waits twice as long as the last, up to
+// maxRestartDelay. The zero value is ready to use.
+type RestartBackoff struct {
+	restarts int
+	last     time.Time
+}
+
+// Next records a restart wanted at now and returns how long to wait before
+// performing it.
+func (b *RestartBackoff) Next(now time.Time) time.Duration {
+	if b.last.IsZero() || now.Sub(b.last) > restartWindow {
+		b.restarts = 0
+	}
+	b.restarts++
+	var delay time.Duration
+	if b.restarts > 1 {
+		delay = maxRestartDelay
+		if shift := b.restarts - 2; shift < 6 {
+			delay = min(minRestartDelay<<shift, maxRestartDelay)
+		}
+	}
+	b.last = now.Add(delay)
+	return delay
+}
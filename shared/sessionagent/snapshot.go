@@ -0,0 +1,130 @@
+package sessionagent
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SnapshotStateFile holds the pickled interpreter globals inside a
+// snapshot archive. It only exists in the workspace while a snapshot is
+// being taken or restored.
+const SnapshotStateFile = ".session-state.pkl"
+
+// REPL control requests: instead of {"code": ...} the REPL is sent
+// {"op": ..., "path": ...} and answers with a single "done" frame.
+const (
+	ReplOpSaveState = "save_state"
+	ReplOpLoadState = "load_state"
+)
+
+var (
+	ErrInvalidSnapshot = errors.New("invalid snapshot archive")
+	ErrReplStateFailed = errors.New("interpreter state could not be saved or loaded")
+)
+
+// SnapshotStateParam is the query parameter asking an agent snapshot to
+// include interpreter state.
+const SnapshotStateParam = "state"
+
+// SupportsReplState reports whether the runtime's REPL can save and load
+// its globals. Only the Python REPL can, through dill when it is installed.
+func SupportsReplState(runtime string) bool {
+	normalized := strings.ToLower(strings.TrimSpace(runtime))
+	return normalized == "python" || normalized == "python3"
+}
+
+// ArchiveWorkspace writes the workspace as a gzip-compressed tar stream.
+// Only directories and regular files are archived; symlinks are skipped
+// so a snapshot cannot carry links out of the workspace it is restored to.
+func ArchiveWorkspace(root string, w io.Writer) error {
+	if root == "" {
+		return ErrWorkspaceUnavailable
+	}
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == root || !(entry.IsDir() || entry.Type().IsRegular()) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if entry.IsDir() {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(tw, file)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// ExtractWorkspace unpacks an archive written by ArchiveWorkspace into the
+// workspace root. Entries must stay inside the workspace and be
+// directories or regular files.
+func ExtractWorkspace(r io.Reader, root string) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+		}
+		name := filepath.FromSlash(strings.TrimSuffix(header.Name, "/"))
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := MakeWorkspaceDir(root, name); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if _, err := WriteWorkspaceFile(root, name, tr, WriteOptions{Mode: fs.FileMode(header.Mode)}); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%w: unsupported entry %q", ErrInvalidSnapshot, header.Name)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package sessionagent
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Step stream event types. Output chunks arrive in the order the code
+// produced them and the status event is always last.
+const (
+	StepEventStdout = "stdout"
+	StepEventStderr = "stderr"
+	StepEventStatus = "status"
+)
+
+const maxStepEventBytes = 4 << 20
+
+var ErrStepStreamIncomplete = errors.New("step stream ended without a status event")
+
+type StepEvent struct {
+	Type   string      `json:"type"`
+	Data   string      `json:"data,omitempty"`
+	Result *StepResult `json:"result,omitempty"`
+}
+
+// WriteStepEvent writes event as a server-sent event and flushes it, so
+// chunks reach the client while the step is still running.
+func WriteStepEvent(w io.Writer, event StepEvent) error {
+	return writeEvent(w, event.Type, event)
+}
+
+func writeEvent(w io.Writer, name string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, data); err != nil {
+		return err
+	}
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
+
+// EventStream writes server-sent events to a response. It sends the stream
+// headers with the first event, so a request that fails before producing
+// output can still be answered with a plain error status.
+type EventStream struct {
+	w       http.ResponseWriter
+	started bool
+}
+
+func NewEventStream(w http.ResponseWriter) *EventStream {
+	return &EventStream{w: w}
+}
+
+// Started reports whether the headers have been sent.
+func (s *EventStream) Started() bool {
+	return s.started
+}
+
+// Send writes payload as JSON under the event name.
+func (s *EventStream) Send(name string, payload any) error {
+	if !s.started {
+		s.w.Header().Set("Content-Type", "text/event-stream")
+		s.w.Header().Set("Cache-Control", "no-cache")
+		s.w.WriteHeader(http.StatusOK)
+		s.started = true
+	}
+	return writeEvent(s.w, name, payload)
+}
+
+// Write sends a step event in the format ReadStepEvents parses.
+func (s *EventStream) Write(event StepEvent) error {
+	return s.Send(event.Type, event)
+}
+
+// ReadStepEvents parses a stream written by WriteStepEvent, passing every
+// event to fn, and returns the result carried by the status event.
+func ReadStepEvents(r io.Reader, fn func(StepEvent) error) (StepResult, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxStepEventBytes)
+	var data bytes.Buffer
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line != "" {
+			if payload, ok := strings.CutPrefix(line, "data:"); ok {
+				data.WriteString(strings.TrimPrefix(payload, " "))
+			}
+			continue
+		}
+		if data.Len() == 0 {
+			continue
+		}
+		var event StepEvent
+		if err := json.Unmarshal(data.Bytes(), &event); err != nil {
+			return StepResult{}, fmt.Errorf("decode step event: %w", err)
+		}
+		data.Reset()
+		if fn != nil {
+			if err := fn(event); err != nil {
+				return StepResult{}, err
+			}
+		}
+		if event.Type == StepEventStatus && event.Result != nil {
+			return *event.Result, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return StepResult{}, err
+	}
+	return StepResult{}, ErrStepStreamIncomplete
+}
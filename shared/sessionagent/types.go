@@ -5,13 +5,15 @@ type StepRequest struct {
 	StepID    string `json:"stepId"`
 	Code      string `json:"code"`
 	Runtime   string `json:"runtime,omitempty"`
+	TimeoutMs int64  `json:"timeoutMs,omitempty"`
 }
 
 type SessionRegisterRequest struct {
-	SessionID    string `json:"sessionId"`
-	Runtime      string `json:"runtime"`
-	Token        string `json:"token,omitempty"`
-	WorkspaceDir string `json:"workspaceDir,omitempty"`
+	SessionID    string   `json:"sessionId"`
+	Runtime      string   `json:"runtime"`
+	Token        string   `json:"token,omitempty"`
+	WorkspaceDir string   `json:"workspaceDir,omitempty"`
+	Env          []string `json:"env,omitempty"`
 }
 
 type SessionRegisterResponse struct {
@@ -19,20 +21,52 @@ type SessionRegisterResponse struct {
 	Status    string `json:"status"`
 }
 
+type SessionInterruptResponse struct {
+	SessionID   string `json:"sessionId"`
+	Interrupted bool   `json:"interrupted"`
+}
+
 type SessionTerminateResponse struct {
 	SessionID string `json:"sessionId"`
 	Status    string `json:"status"`
 }
 
 type StepResult struct {
-	StepID   string `json:"stepId"`
-	Status   string `json:"status"`
-	ExitCode int    `json:"exitCode,omitempty"`
-	Stdout   string `json:"stdout"`
-	Stderr   string `json:"stderr"`
+	StepID        string        `json:"stepId"`
+	Status        string        `json:"status"`
+	ExitCode      int           `json:"exitCode,omitempty"`
+	Stdout        string        `json:"stdout"`
+	Stderr        string        `json:"stderr"`
+	StateLost     bool          `json:"stateLost,omitempty"`
+	ResourceUsage ResourceUsage `json:"resourceUsage"`
 }
 
 const (
-	StepStatusCompleted = "completed"
-	StepStatusFailed    = "failed"
+	StepStatusCompleted   = "completed"
+	StepStatusFailed      = "failed"
+	StepStatusTimedOut    = "timed_out"
+	StepStatusInterrupted = "interrupted"
 )
+
+// AgentStatus is what an agent reports on GET /v1/status. MaxSessions is
+// zero when the agent takes any number of sessions.
+type AgentStatus struct {
+	UptimeSeconds int64           `json:"uptimeSeconds"`
+	MaxSessions   int             `json:"maxSessions,omitempty"`
+	Sessions      []SessionStatus `json:"sessions"`
+}
+
+// SessionStatus describes one registered session. PID is zero while its
+// interpreter is down.
+type SessionStatus struct {
+	SessionID   string `json:"sessionId"`
+	Runtime     string `json:"runtime"`
+	PID         int    `json:"pid,omitempty"`
+	MemoryBytes int64  `json:"memoryBytes"`
+	Busy        bool   `json:"busy"`
+}
+
+// HasCapacity reports whether the agent can take another session.
+func (s AgentStatus) HasCapacity() bool {
+	return s.MaxSessions == 0 || len(s.Sessions) < s.MaxSessions
+}
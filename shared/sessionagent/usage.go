@@ -0,0 +1,114 @@
+package sessionagent
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// clockTicksPerSecond is USER_HZ, which Linux fixes at 100 on every
+// architecture we run on.
+const clockTicksPerSecond = 100
+
+// ResourceUsage is what one run or step consumed. MaxRSSBytes is a peak, so
+// unlike the other fields it is not additive across steps.
+type ResourceUsage struct {
+	CPUTimeMs   int64 `json:"cpuTimeMs"`
+	MaxRSSBytes int64 `json:"maxRssBytes"`
+	IOBytes     int64 `json:"ioBytes"`
+}
+
+// Sub returns the usage accumulated since before was sampled from the same
+// process.
+func (u ResourceUsage) Sub(before ResourceUsage) ResourceUsage {
+	return ResourceUsage{
+		CPUTimeMs:   max(u.CPUTimeMs-before.CPUTimeMs, 0),
+		MaxRSSBytes: u.MaxRSSBytes,
+		IOBytes:     max(u.IOBytes-before.IOBytes, 0),
+	}
+}
+
+// Add combines usage of processes that ran one after another.
+func (u ResourceUsage) Add(other ResourceUsage) ResourceUsage {
+	return ResourceUsage{
+		CPUTimeMs:   u.CPUTimeMs + other.CPUTimeMs,
+		MaxRSSBytes: max(u.MaxRSSBytes, other.MaxRSSBytes),
+		IOBytes:     u.IOBytes + other.IOBytes,
+	}
+}
+
+// ReadProcessUsage samples /proc for pid. CPU time and I/O include children
+// the process has already reaped; the RSS peak covers the process itself.
+// I/O counters may be unreadable without ptrace access and are then zero.
+func ReadProcessUsage(pid int) (ResourceUsage, error) {
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return ResourceUsage{}, err
+	}
+	// The command name can contain spaces, so fields are counted from the
+	// closing parenthesis.
+	end := strings.LastIndexByte(string(stat), ')')
+	if end < 0 {
+		return ResourceUsage{}, fmt.Errorf("malformed stat for pid %d", pid)
+	}
+	fields := strings.Fields(string(stat[end+1:]))
+	// utime, stime, cutime and cstime are fields 14-17 of the full line.
+	if len(fields) < 15 {
+		return ResourceUsage{}, fmt.Errorf("malformed stat for pid %d", pid)
+	}
+	var ticks int64
+	for _, field := range fields[11:15] {
+		value, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return ResourceUsage{}, err
+		}
+		ticks += value
+	}
+	usage := ResourceUsage{CPUTimeMs: ticks * 1000 / clockTicksPerSecond}
+	if kb, err := readProcField(pid, "status", "VmHWM:"); err == nil {
+		usage.MaxRSSBytes = kb * 1024
+	}
+	if read, err := readProcField(pid, "io", "read_bytes:"); err == nil {
+		usage.IOBytes += read
+	}
+	if written, err := readProcField(pid, "io", "write_bytes:"); err == nil {
+		usage.IOBytes += written
+	}
+	return usage, nil
+}
+
+// ResetPeakRSS restarts the VmHWM high-water mark so the next sample reports
+// the peak of the work that follows.
+func ResetPeakRSS(pid int) error {
+	return os.WriteFile(fmt.Sprintf("/proc/%d/clear_refs", pid), []byte("5"), 0o200)
+}
+
+// ReadProcessRSS returns the resident memory of pid right now.
+func ReadProcessRSS(pid int) (int64, error) {
+	kb, err := readProcField(pid, "status", "VmRSS:")
+	if err != nil {
+		return 0, err
+	}
+	return kb * 1024, nil
+}
+
+func readProcField(pid int, file string, key string) (int64, error) {
+	handle, err := os.Open(fmt.Sprintf("/proc/%d/%s", pid, file))
+	if err != nil {
+		return 0, err
+	}
+	defer handle.Close()
+	scanner := bufio.NewScanner(handle)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == key {
+			return strconv.ParseInt(fields[1], 10, 64)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("%s not found in /proc/%d/%s", key, pid, file)
+}